@@ -1,6 +1,8 @@
 # app/agent/chain.py
 
+import asyncio
 import json
+from typing import List, Optional
 from openai import OpenAI
 from app.agent.prompt import JSON_SCHEMA_SYSTEM_MESSAGE
 from app.schema import AgentDecision
@@ -13,7 +15,7 @@ FALLBACK_DECISION = {
     "priority": "LOW",
     "summary": "Unable to classify this email.",
     "should_create_task": False,
-    "task": None,
+    "tasks": [],
     "should_notify": False,
     "notification_channel": None,
     "notification_message": None
@@ -43,18 +45,24 @@ class DecisionChain:
         user_id = payload.get("user_id", "")
         subject = payload.get("subject", "")
         body = payload.get("body", "")
+        corrections = format_corrections(payload.get("corrections") or [])
+        sent_at = payload.get("sent_at") or "unknown"
+        timezone = payload.get("timezone") or "UTC"
+        sender = format_sender(payload.get("sender"))
         
         # 使用 f-string 格式化用户消息（直接变量注入）
         user_message = f"""
 You are an AI assistant that analyzes a single email and makes decisions.
 
 Return ONLY valid JSON.
-
+{corrections}
 --------------------
 INPUT:
 Email ID: {email_id}
 User ID: {user_id}
-
+Sent at: {sent_at}
+User timezone: {timezone}
+{sender}
 Subject: {subject}
 Body: {body}
 --------------------
@@ -76,7 +84,9 @@ Now output the JSON.
 
         try:
             logger.debug("正在调用 LLM...")
-            response = self.client.chat.completions.create(
+            # 同步客户端放到线程中执行，避免阻塞事件循环（/decide-batch 并发调用）
+            response = await asyncio.to_thread(
+                self.client.chat.completions.create,
                 model="gpt-4o-mini",
                 temperature=0.2,
                 messages=openai_messages
@@ -99,6 +109,66 @@ Now output the JSON.
             return AgentDecision(**FALLBACK_DECISION)
 
 
+    async def abatch(self, payloads: List[dict], concurrency: int) -> List[AgentDecision]:
+        """并发处理多封邮件，结果与 payloads 顺序一致；单封失败时对应位置为异常对象"""
+        semaphore = asyncio.Semaphore(max(concurrency, 1))
+
+        async def run(payload: dict) -> AgentDecision:
+            async with semaphore:
+                return await self.ainvoke(payload)
+
+        return await asyncio.gather(*(run(p) for p in payloads), return_exceptions=True)
+
+
+def format_corrections(corrections: list) -> str:
+    """将用户最近的修正格式化为 few-shot 示例，没有修正时返回空字符串"""
+    if not corrections:
+        return ""
+
+    lines = [
+        "",
+        "This user has corrected some of your previous decisions.",
+        "Follow the same preferences for similar emails:",
+    ]
+    for c in corrections:
+        original = f"categories={c.get('original_categories') or []}, priority={c.get('original_priority') or ''}"
+        kind = c.get("feedback_type")
+        if kind == "classification":
+            fixed = f"categories={c.get('corrected_categories') or []}, priority={c.get('corrected_priority') or ''}"
+            line = f'- Email "{c.get("subject", "")}": you decided {original}; the user changed it to {fixed}.'
+        elif kind == "task":
+            line = f'- Email "{c.get("subject", "")}": you created the task "{c.get("detail") or ""}", the user marked it as wrong (no task was needed).'
+        elif kind == "notification":
+            line = f'- Email "{c.get("subject", "")}": you sent the notification "{c.get("detail") or ""}", the user marked it as wrong (no notification was needed).'
+        else:
+            continue
+        if c.get("comment"):
+            line += f' User comment: {c["comment"]}'
+        lines.append(line)
+    return "\n".join(lines) + "\n"
+
+
+def format_sender(sender: Optional[dict]) -> str:
+    """格式化发件人重要性信号，未知发件人时返回空字符串"""
+    if not sender:
+        return ""
+
+    name = sender.get("display_name") or sender.get("address", "")
+    lines = [f"Sender: {name} <{sender.get('address', '')}>"]
+    importance = sender.get("importance") or "NORMAL"
+    if sender.get("is_vip"):
+        lines.append("Sender importance: VIP (marked by the user; treat as important)")
+    else:
+        lines.append(f"Sender importance: {importance}")
+
+    history = f"Sender history: {sender.get('message_count', 0)} previous emails"
+    if sender.get("avg_priority") is not None:
+        history += f", average priority {sender['avg_priority']:.1f} (LOW=1, MEDIUM=2, HIGH=3)"
+    history += f", {round((sender.get('task_rate') or 0) * 100)}% produced tasks"
+    lines.append(history)
+    return "\n".join(lines) + "\n"
+
+
 def build_decision_chain() -> DecisionChain:
     """构建并返回决策链实例"""
     return DecisionChain()
@@ -14,10 +14,14 @@ Here is the REQUIRED JSON schema:
   "summary": "short English summary, 1-3 sentences",
 
   "should_create_task": true or false,
-  "task": {
-    "title": "short task title",
-    "due_in_days": integer (>=0)
-  } or null,
+  "tasks": [
+    {
+      "title": "short task title",
+      "due_in_days": integer (>=0),
+      "priority": "LOW" | "MEDIUM" | "HIGH",
+      "due_date": "YYYY-MM-DD" or "YYYY-MM-DDTHH:MM" or null
+    }
+  ],
 
   "should_notify": true or false,
   "notification_channel": "EMAIL" or null,
@@ -27,8 +31,15 @@ Here is the REQUIRED JSON schema:
 Your job:
 - Classify the email
 - Determine urgency
-- Decide if a follow-up task is needed
-- Decide if notification is needed
+- Decide if follow-up tasks are needed; create one task per distinct action
+  (e.g. "review the doc by Monday and book the room for Thursday" -> 2 tasks)
+- Set "due_date" only when the email states a deadline; otherwise null.
+  Resolve relative dates ("by Friday", "tomorrow 3pm") against the email's
+  "Sent at" time, in the user's timezone. Use "YYYY-MM-DD" for a day and
+  "YYYY-MM-DDTHH:MM" only when a time of day is stated
+- Use an empty "tasks" list when should_create_task is false
+- Decide if notification is needed. When the input gives the sender's importance,
+  lean towards notifying for VIP / HIGH senders and against it for LOW senders
 - Generate a concise summary
 
 Think carefully, then output ONLY the JSON."""
@@ -6,6 +6,8 @@ class Settings(BaseSettings):
     openai_api_key: str = ""
     model_name: str = "gpt-3.5-turbo"
     port: int = 8000
+    max_batch_size: int = 50  # /decide-batch 每次最多的邮件数
+    batch_concurrency: int = 8  # /decide-batch 同时进行的 LLM 调用数
 
     class Config:
         env_file = ".env"
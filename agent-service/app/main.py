@@ -2,7 +2,10 @@ from fastapi import FastAPI, HTTPException
 from app.agent.chain import build_decision_chain
 from app.agent.text_to_tasks_chain import build_text_to_tasks_chain
 from app.agent.project_planner_chain import build_project_planner_chain
-from app.schema import EmailInput, AgentDecision, TaskListResponse, TaskTextInput, ProjectPlanResponse, ProjectTextInput
+from app.schema import (
+    EmailInput, AgentDecision, TaskListResponse, TaskTextInput, ProjectPlanResponse, ProjectTextInput,
+    BatchDecisionInput, BatchDecisionResponse, BatchDecisionResult,
+)
 from app.config import settings
 import logging
 import json
@@ -72,6 +75,37 @@ async def decide(payload: EmailInput) -> AgentDecision:
         )
 
 
+@app.post("/decide-batch", response_model=BatchDecisionResponse)
+async def decide_batch(payload: BatchDecisionInput) -> BatchDecisionResponse:
+    if len(payload.emails) > settings.max_batch_size:
+        raise HTTPException(
+            status_code=400,
+            detail=f"最多 {settings.max_batch_size} 封邮件"
+        )
+    logger.info(f"收到批量决策请求 - 邮件数量: {len(payload.emails)}")
+
+    payload_dicts = [
+        email.model_dump() if hasattr(email, 'model_dump') else email.dict()
+        for email in payload.emails
+    ]
+    decisions = await decision_chain.abatch(payload_dicts, settings.batch_concurrency)
+
+    # 单封邮件失败不影响其他邮件，调用方对失败的邮件使用 fallback
+    results = []
+    for email, decision in zip(payload.emails, decisions):
+        if isinstance(decision, Exception):
+            logger.error(f"批量决策中单封邮件失败 - email_id: {email.email_id}, trace_id: {email.trace_id}, 错误: {str(decision)}")
+            results.append(BatchDecisionResult(email_id=email.email_id, error=str(decision)))
+        else:
+            results.append(BatchDecisionResult(email_id=email.email_id, decision=decision))
+
+    logger.info(
+        "批量决策完成 - 邮件数量: %d, 失败: %d",
+        len(results), sum(1 for r in results if r.error)
+    )
+    return BatchDecisionResponse(results=results)
+
+
 @app.post("/text-to-tasks", response_model=TaskListResponse)
 async def text_to_tasks(payload: TaskTextInput) -> TaskListResponse:
     logger.info(f"收到文本转任务请求 - user_id: {payload.user_id}, text长度: {len(payload.text)}")
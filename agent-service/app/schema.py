@@ -3,16 +3,46 @@ from pydantic import BaseModel
 from typing import List, Optional
 
 
+class FeedbackExample(BaseModel):
+    """用户对历史决策的修正（few-shot 示例）"""
+    subject: str
+    feedback_type: str  # classification / task / notification
+    original_categories: List[str] = []
+    original_priority: str = ""
+    corrected_categories: Optional[List[str]] = None
+    corrected_priority: Optional[str] = None
+    detail: Optional[str] = None  # 被标记为错误的任务标题 / 通知内容
+    comment: Optional[str] = None
+
+
+class SenderProfile(BaseModel):
+    """发件人历史统计（email-processor-service 的 contacts）"""
+    address: str
+    display_name: Optional[str] = None
+    message_count: int = 0
+    avg_priority: Optional[float] = None  # LOW=1, MEDIUM=2, HIGH=3
+    task_rate: float = 0.0  # 产生任务的邮件比例
+    is_vip: bool = False
+    importance: str = "NORMAL"  # VIP / HIGH / NORMAL / LOW
+
+
 class EmailInput(BaseModel):
     email_id: int
     user_id: int
     subject: str
     body: str
+    corrections: List[FeedbackExample] = []  # 该用户最近的修正
+    sent_at: Optional[str] = None  # 邮件发送时间（用户时区，RFC3339），用于解析"周五前"等相对日期
+    timezone: Optional[str] = None  # 用户时区（IANA），如 Asia/Shanghai
+    sender: Optional[SenderProfile] = None  # 未知发件人为空
+    trace_id: Optional[str] = None  # /decide-batch 中每封邮件的 trace_id（单条请求通过请求头传播）
 
 
 class TaskDecision(BaseModel):
     title: str
     due_in_days: int
+    priority: Optional[str] = None  # LOW / MEDIUM / HIGH，为空时使用邮件优先级
+    due_date: Optional[str] = None  # YYYY-MM-DD 或 YYYY-MM-DDTHH:MM（用户时区），邮件中有明确截止时间时填写
 
 
 class AgentDecision(BaseModel):
@@ -21,13 +51,28 @@ class AgentDecision(BaseModel):
     summary: str
 
     should_create_task: bool
-    task: Optional[TaskDecision]
+    tasks: List[TaskDecision] = []  # 一封邮件可能包含多个任务
+    task: Optional[TaskDecision] = None  # 兼容旧版，新版使用 tasks
 
     should_notify: bool
     notification_channel: Optional[str]
     notification_message: Optional[str]
 
 
+class BatchDecisionInput(BaseModel):
+    emails: List[EmailInput]
+
+
+class BatchDecisionResult(BaseModel):
+    email_id: int
+    decision: Optional[AgentDecision] = None  # 失败时为空，调用方对这封邮件使用 fallback
+    error: Optional[str] = None
+
+
+class BatchDecisionResponse(BaseModel):
+    results: List[BatchDecisionResult]  # 与请求中的 emails 顺序一致
+
+
 class TaskItem(BaseModel):
     title: str
     due_in_days: int
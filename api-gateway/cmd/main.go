@@ -52,6 +52,9 @@ func main() {
 	// Init Repositories
 	userRepo := repository.NewUserRepository(dbConn)
 	emailRepo := repository.NewEmailRepository(dbConn)
+	classificationRuleRepo := repository.NewClassificationRuleRepository(dbConn)
+	feedbackRepo := repository.NewFeedbackRepository(dbConn)
+	contactRepo := repository.NewContactRepository(dbConn)
 
 	// Init MQ Publisher
 	taskPublisher, err := mq.NewPublisher(cfg.MQ.URL)
@@ -70,9 +73,15 @@ func main() {
 	// Init Handlers
 	authHandler := handler.NewAuthHandler(authService)
 	mailProxyHandler := handler.NewMailProxyHandler(cfg.MailIngestionServiceURL)
-	emailQueryHandler := handler.NewEmailQueryHandler(emailRepo)
-	taskController := handler.NewTaskController(dbConn, cfg.AgentServiceURL, cfg.TaskServiceURL, taskPublisher, logger)
+	emailQueryHandler := handler.NewEmailQueryHandler(emailRepo, logger)
+	taskController := handler.NewTaskController(dbConn, cfg.AgentServiceURL, cfg.TaskServiceURL, taskPublisher, userRepo, logger)
 	adminHandler := handler.NewAdminHandler(replayService, logger)
+	classificationRuleHandler := handler.NewClassificationRuleHandler(classificationRuleRepo, logger)
+	reclassifyHandler := handler.NewReclassifyHandler(dbConn, emailRepo, logger)
+	feedbackHandler := handler.NewFeedbackHandler(dbConn, emailRepo, feedbackRepo, logger)
+	userSettingsHandler := handler.NewUserSettingsHandler(userRepo, logger)
+	emailActionHandler := handler.NewEmailActionHandler(dbConn, emailRepo, logger)
+	contactHandler := handler.NewContactHandler(contactRepo, logger)
 
 	// Init Outbox Dispatcher
 	dispatcher := outbox.NewDispatcher(outboxRepo, taskPublisher, logger)
@@ -85,6 +94,12 @@ func main() {
 		emailQueryHandler,
 		taskController,
 		adminHandler,
+		classificationRuleHandler,
+		reclassifyHandler,
+		feedbackHandler,
+		userSettingsHandler,
+		emailActionHandler,
+		contactHandler,
 		cfg.JWT.Secret,
 		dbConn,
 	)
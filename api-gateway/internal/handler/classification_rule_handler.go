@@ -0,0 +1,279 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"api-gateway/internal/repository"
+	"mygoproject/contracts/db"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	maxRuleKeywords = 50
+	maxRuleHeaders  = 10
+)
+
+type ClassificationRuleHandler struct {
+	ruleRepo *repository.ClassificationRuleRepository
+	logger   *zap.Logger
+}
+
+func NewClassificationRuleHandler(ruleRepo *repository.ClassificationRuleRepository, logger *zap.Logger) *ClassificationRuleHandler {
+	return &ClassificationRuleHandler{
+		ruleRepo: ruleRepo,
+		logger:   logger,
+	}
+}
+
+// classificationRuleRequest 创建 / 更新规则的请求体
+type classificationRuleRequest struct {
+	Name       string            `json:"name"`
+	Position   int               `json:"position"`
+	Enabled    *bool             `json:"enabled"` // 默认启用
+	Decisive   bool              `json:"decisive"`
+	Conditions db.RuleConditions `json:"conditions"`
+	Actions    db.RuleActions    `json:"actions"`
+}
+
+// CreateRule handles POST /classification-rules
+func (h *ClassificationRuleHandler) CreateRule(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var req classificationRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	rule, err := buildClassificationRule(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule.UserID = userID.(int)
+
+	id, err := h.ruleRepo.Create(c.Request.Context(), rule)
+	if err != nil {
+		h.logger.Error("CreateRule: failed to create classification rule", zap.Int("user_id", rule.UserID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create classification rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rule_id": id})
+}
+
+// ListRules handles GET /classification-rules（按评估顺序返回）
+func (h *ClassificationRuleHandler) ListRules(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	rules, err := h.ruleRepo.ListByUser(c.Request.Context(), userID.(int))
+	if err != nil {
+		h.logger.Error("ListRules: failed to fetch classification rules", zap.Int("user_id", userID.(int)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch classification rules"})
+		return
+	}
+	if rules == nil {
+		rules = []db.ClassificationRule{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// GetRule handles GET /classification-rules/:id
+func (h *ClassificationRuleHandler) GetRule(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule id"})
+		return
+	}
+
+	rule, err := h.ruleRepo.GetByID(c.Request.Context(), id, userID.(int))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "classification rule not found"})
+			return
+		}
+		h.logger.Error("GetRule: failed to fetch classification rule", zap.Int("rule_id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch classification rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// UpdateRule handles PUT /classification-rules/:id
+func (h *ClassificationRuleHandler) UpdateRule(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule id"})
+		return
+	}
+
+	var req classificationRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	rule, err := buildClassificationRule(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule.ID = id
+	rule.UserID = userID.(int)
+
+	updated, err := h.ruleRepo.Update(c.Request.Context(), rule)
+	if err != nil {
+		h.logger.Error("UpdateRule: failed to update classification rule", zap.Int("rule_id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update classification rule"})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusNotFound, gin.H{"error": "classification rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// DeleteRule handles DELETE /classification-rules/:id
+func (h *ClassificationRuleHandler) DeleteRule(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule id"})
+		return
+	}
+
+	deleted, err := h.ruleRepo.Delete(c.Request.Context(), id, userID.(int))
+	if err != nil {
+		h.logger.Error("DeleteRule: failed to delete classification rule", zap.Int("rule_id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete classification rule"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "classification rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// buildClassificationRule 校验并规范化请求：至少一个条件、至少一个动作，正则必须可编译
+func buildClassificationRule(req classificationRuleRequest) (*db.ClassificationRule, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 100 {
+		return nil, errors.New("name is required (max 100 characters)")
+	}
+
+	cond := req.Conditions
+	cond.Sender = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(cond.Sender), "@"))
+	cond.SubjectRegex = strings.TrimSpace(cond.SubjectRegex)
+	if cond.SubjectRegex != "" {
+		if _, err := regexp.Compile("(?i)" + cond.SubjectRegex); err != nil {
+			return nil, errors.New("invalid subject_regex")
+		}
+	}
+
+	keywords := make([]string, 0, len(cond.BodyKeywords))
+	for _, keyword := range cond.BodyKeywords {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	if len(keywords) > maxRuleKeywords {
+		return nil, errors.New("too many body_keywords")
+	}
+	cond.BodyKeywords = keywords
+
+	if len(cond.Headers) > maxRuleHeaders {
+		return nil, errors.New("too many header conditions")
+	}
+	for i := range cond.Headers {
+		cond.Headers[i].Name = strings.TrimSpace(cond.Headers[i].Name)
+		if cond.Headers[i].Name == "" || strings.ContainsAny(cond.Headers[i].Name, ": ") {
+			return nil, errors.New("invalid header name")
+		}
+		if _, err := regexp.Compile("(?i)" + cond.Headers[i].Pattern); err != nil {
+			return nil, errors.New("invalid header pattern")
+		}
+	}
+
+	if cond.Sender == "" && cond.SubjectRegex == "" && len(cond.BodyKeywords) == 0 && len(cond.Headers) == 0 {
+		return nil, errors.New("at least one condition is required")
+	}
+
+	actions := req.Actions
+	actions.Priority = strings.ToUpper(strings.TrimSpace(actions.Priority))
+	switch actions.Priority {
+	case "", "HIGH", "MEDIUM", "LOW":
+	default:
+		return nil, errors.New("priority must be HIGH, MEDIUM or LOW")
+	}
+	if task := actions.CreateTask; task != nil {
+		task.Title = strings.TrimSpace(task.Title)
+		if task.Title == "" || task.DueInDays < 0 {
+			return nil, errors.New("create_task requires a title and a non-negative due_in_days")
+		}
+	}
+	if notify := actions.Notify; notify != nil {
+		notify.Channel = strings.ToUpper(strings.TrimSpace(notify.Channel))
+		switch notify.Channel {
+		case "EMAIL", "PUSH", "SMS", "WEBHOOK":
+		default:
+			return nil, errors.New("notify channel must be EMAIL, PUSH, SMS or WEBHOOK")
+		}
+		if strings.TrimSpace(notify.Message) == "" {
+			return nil, errors.New("notify requires a message")
+		}
+	}
+	if len(actions.Categories) == 0 && actions.Priority == "" && actions.CreateTask == nil && actions.Notify == nil {
+		return nil, errors.New("at least one action is required")
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	return &db.ClassificationRule{
+		Name:       name,
+		Position:   req.Position,
+		Enabled:    enabled,
+		Decisive:   req.Decisive,
+		Conditions: cond,
+		Actions:    actions,
+	}, nil
+}
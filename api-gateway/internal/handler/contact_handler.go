@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"api-gateway/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultContactLimit = 50
+	maxContactLimit     = 200
+)
+
+// ContactHandler 发件人联系人（统计由 email-processor-service 维护，用户可标记 VIP）
+type ContactHandler struct {
+	contactRepo *repository.ContactRepository
+	logger      *zap.Logger
+}
+
+func NewContactHandler(contactRepo *repository.ContactRepository, logger *zap.Logger) *ContactHandler {
+	return &ContactHandler{
+		contactRepo: contactRepo,
+		logger:      logger,
+	}
+}
+
+type updateContactRequest struct {
+	IsVIP *bool `json:"is_vip" binding:"required"`
+}
+
+// ListContacts handles GET /contacts?sort=recent|messages|tasks&vip=true&limit=50&offset=0
+func (h *ContactHandler) ListContacts(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	sort := c.DefaultQuery("sort", "recent")
+	switch sort {
+	case "recent", "messages", "tasks":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be recent, messages or tasks"})
+		return
+	}
+
+	vipOnly := false
+	if v := c.Query("vip"); v != "" {
+		var err error
+		if vipOnly, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "vip must be true or false"})
+			return
+		}
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultContactLimit)))
+	if err != nil || limit <= 0 || limit > maxContactLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+
+	contacts, err := h.contactRepo.List(c.Request.Context(), userID.(int), vipOnly, sort, limit, offset)
+	if err != nil {
+		h.logger.Error("ListContacts: failed to list contacts", zap.Int("user_id", userID.(int)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch contacts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"contacts": contacts})
+}
+
+// UpdateContact handles PATCH /contacts/:id
+func (h *ContactHandler) UpdateContact(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid contact id"})
+		return
+	}
+
+	var req updateContactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "is_vip is required"})
+		return
+	}
+
+	contact, err := h.contactRepo.SetVIP(c.Request.Context(), id, userID.(int), *req.IsVIP)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "contact not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("UpdateContact: failed to update contact", zap.Int("contact_id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update contact"})
+		return
+	}
+
+	c.JSON(http.StatusOK, contact)
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"api-gateway/internal/repository"
+	mqcontracts "mygoproject/contracts/mq"
+	"mygoproject/pkg/outbox"
+	"mygoproject/pkg/trace"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// EmailActionHandler 归档 / 删除邮件；删除后通过 outbox 发布 email.deleted 事件
+type EmailActionHandler struct {
+	db         *pgxpool.Pool
+	emailRepo  *repository.EmailRepository
+	outboxRepo *outbox.Repository
+	logger     *zap.Logger
+}
+
+func NewEmailActionHandler(db *pgxpool.Pool, emailRepo *repository.EmailRepository, logger *zap.Logger) *EmailActionHandler {
+	return &EmailActionHandler{
+		db:         db,
+		emailRepo:  emailRepo,
+		outboxRepo: outbox.NewRepository(db),
+		logger:     logger,
+	}
+}
+
+// ArchiveEmail handles POST /emails/:id/archive
+func (h *EmailActionHandler) ArchiveEmail(c *gin.Context) {
+	h.setArchived(c, true)
+}
+
+// UnarchiveEmail handles POST /emails/:id/unarchive
+func (h *EmailActionHandler) UnarchiveEmail(c *gin.Context) {
+	h.setArchived(c, false)
+}
+
+func (h *EmailActionHandler) setArchived(c *gin.Context, archived bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	emailID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email id"})
+		return
+	}
+
+	archivedAt, err := h.emailRepo.SetArchived(c.Request.Context(), emailID, userID.(int), archived)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "email not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to update email archive state",
+			zap.Int("email_id", emailID),
+			zap.Bool("archived", archived),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"email_id": emailID, "archived": archived, "archived_at": archivedAt})
+}
+
+// DeleteEmail handles DELETE /emails/:id?tasks=keep|delete
+// tasks=keep（默认）保留由该邮件创建的任务并解除关联；tasks=delete 删除其中未完成的任务。
+// 应用内通知随邮件删除，通知日志保留
+func (h *EmailActionHandler) DeleteEmail(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	emailID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email id"})
+		return
+	}
+
+	policy := c.DefaultQuery("tasks", mqcontracts.EmailDeleteKeepTasks)
+	if policy != mqcontracts.EmailDeleteKeepTasks && policy != mqcontracts.EmailDeleteDeleteTasks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tasks must be keep or delete"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		h.logger.Error("DeleteEmail: failed to begin transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete email"})
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	deletion, err := h.emailRepo.DeleteEmailTx(ctx, tx, emailID, userID.(int), policy == mqcontracts.EmailDeleteDeleteTasks)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "email not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("DeleteEmail: failed to delete email", zap.Int("email_id", emailID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete email"})
+		return
+	}
+
+	payload := mqcontracts.EmailDeletedPayload{
+		EmailID:              emailID,
+		UserID:               userID.(int),
+		DeletedAt:            time.Now().UTC(),
+		TraceID:              trace.FromContext(ctx),
+		TaskPolicy:           policy,
+		DeletedTaskIDs:       deletion.DeletedTaskIDs,
+		DetachedTaskIDs:      deletion.DetachedTaskIDs,
+		DeletedNotifications: deletion.DeletedNotifications,
+		AttachmentKeys:       deletion.AttachmentKeys,
+	}
+	if deletion.ThreadID != nil {
+		payload.ThreadID = *deletion.ThreadID
+	}
+	emailID64 := int64(emailID)
+	if err := outbox.InsertEventInTx(ctx, tx, h.outboxRepo, "email", &emailID64, "email.deleted", payload); err != nil {
+		h.logger.Error("DeleteEmail: failed to write outbox event", zap.Int("email_id", emailID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete email"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.logger.Error("DeleteEmail: failed to commit", zap.Int("email_id", emailID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete email"})
+		return
+	}
+
+	h.logger.Info("Email deleted",
+		zap.Int("email_id", emailID),
+		zap.Int("user_id", userID.(int)),
+		zap.String("task_policy", policy),
+		zap.Ints("deleted_task_ids", deletion.DeletedTaskIDs),
+		zap.Ints("detached_task_ids", deletion.DetachedTaskIDs),
+		zap.Int("cancelled_events", deletion.CancelledEvents),
+	)
+
+	deletedTasks, detachedTasks := deletion.DeletedTaskIDs, deletion.DetachedTaskIDs
+	if deletedTasks == nil {
+		deletedTasks = []int{}
+	}
+	if detachedTasks == nil {
+		detachedTasks = []int{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"email_id":              emailID,
+		"status":                "deleted",
+		"task_policy":           policy,
+		"deleted_task_ids":      deletedTasks,
+		"detached_task_ids":     detachedTasks,
+		"deleted_notifications": deletion.DeletedNotifications,
+	})
+}
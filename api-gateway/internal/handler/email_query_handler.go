@@ -1,19 +1,30 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
 	"api-gateway/internal/repository"
 )
 
+const (
+	defaultEmailListLimit = 50
+	maxEmailListLimit     = 200
+)
+
 type EmailQueryHandler struct {
 	emailRepo *repository.EmailRepository
+	logger    *zap.Logger
 }
 
-func NewEmailQueryHandler(emailRepo *repository.EmailRepository) *EmailQueryHandler {
+func NewEmailQueryHandler(emailRepo *repository.EmailRepository, logger *zap.Logger) *EmailQueryHandler {
 	return &EmailQueryHandler{
 		emailRepo: emailRepo,
+		logger:    logger,
 	}
 }
 
@@ -25,17 +36,193 @@ func (h *EmailQueryHandler) GetEmails(c *gin.Context) {
 		return
 	}
 
-	emails, err := h.emailRepo.ListEmailsWithMetadata(c.Request.Context(), userID.(int))
+	opts := repository.EmailListOptions{IncludeBody: true}
+	var err error
+	if opts.Categories, opts.Priorities, opts.Statuses, err = parseEmailAttrFilters(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts.Limit, err = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultEmailListLimit)))
+	if err != nil || opts.Limit <= 0 || opts.Limit > maxEmailListLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+		return
+	}
+
+	switch c.DefaultQuery("order", "desc") {
+	case "desc":
+	case "asc":
+		opts.Ascending = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return
+	}
+
+	// include_body=false 时只返回摘要，不返回正文
+	if v := c.Query("include_body"); v != "" {
+		if opts.IncludeBody, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "include_body must be true or false"})
+			return
+		}
+	}
+
+	// archived=true 时只返回已归档的邮件
+	if v := c.Query("archived"); v != "" {
+		if opts.Archived, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "archived must be true or false"})
+			return
+		}
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		if opts.Cursor, err = decodeEmailCursor(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+	}
+
+	// 多取一条判断是否还有下一页
+	limit := opts.Limit
+	opts.Limit++
+	emails, err := h.emailRepo.ListEmailsWithMetadata(c.Request.Context(), userID.(int), opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to fetch emails",
-			"details": err.Error(),
-		})
+		h.logger.Error("GetEmails: failed to fetch emails", zap.Int("user_id", userID.(int)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch emails"})
 		return
 	}
+	var nextCursor string
+	if len(emails) > limit {
+		emails = emails[:limit]
+		last := emails[len(emails)-1]
+		nextCursor = encodeEmailCursor(last.CreatedAt, last.ID)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"emails": emails,
+		"emails":      emails,
+		"next_cursor": nextCursor,
 	})
 }
 
+// GetEmail handles GET /emails/:id
+func (h *EmailQueryHandler) GetEmail(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	emailID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email id"})
+		return
+	}
+
+	email, err := h.emailRepo.GetEmail(c.Request.Context(), emailID, userID.(int))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "email not found"})
+			return
+		}
+		h.logger.Error("GetEmail: failed to fetch email", zap.Int("user_id", userID.(int)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, email)
+}
+
+// GetEmailAttachments handles GET /emails/:id/attachments
+func (h *EmailQueryHandler) GetEmailAttachments(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	emailID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email id"})
+		return
+	}
+
+	found, err := h.emailRepo.EmailExists(c.Request.Context(), emailID, userID.(int))
+	if err != nil {
+		h.logger.Error("GetEmailAttachments: failed to fetch email", zap.Int("user_id", userID.(int)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch email"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "email not found"})
+		return
+	}
+
+	attachments, err := h.emailRepo.ListAttachments(c.Request.Context(), emailID, userID.(int))
+	if err != nil {
+		h.logger.Error("GetEmailAttachments: failed to fetch attachments", zap.Int("user_id", userID.(int)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch attachments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"email_id":    emailID,
+		"attachments": attachments,
+	})
+}
+
+// GetThreads handles GET /threads
+func (h *EmailQueryHandler) GetThreads(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	threads, err := h.emailRepo.ListThreads(c.Request.Context(), userID.(int))
+	if err != nil {
+		h.logger.Error("GetThreads: failed to fetch threads", zap.Int("user_id", userID.(int)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch threads"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"threads": threads,
+	})
+}
+
+// GetThread handles GET /threads/:id
+func (h *EmailQueryHandler) GetThread(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	threadID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid thread id"})
+		return
+	}
+
+	thread, err := h.emailRepo.GetThread(c.Request.Context(), threadID, userID.(int))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "thread not found"})
+			return
+		}
+		h.logger.Error("GetThread: failed to fetch thread", zap.Int("user_id", userID.(int)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch thread"})
+		return
+	}
+
+	emails, err := h.emailRepo.ListThreadEmails(c.Request.Context(), threadID, userID.(int))
+	if err != nil {
+		h.logger.Error("GetThread: failed to fetch thread emails", zap.Int("user_id", userID.(int)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch thread emails"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"thread": thread,
+		"emails": emails,
+	})
+}
@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-gateway/internal/repository"
+	"mygoproject/contracts/db"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// SearchEmails handles GET /emails/search?q=budget&category=WORK&priority=HIGH&status=classified
+// &since=2026-01-01&until=2026-02-01&sender=example.com&limit=20&cursor=...
+// category / priority / status 可重复或用逗号分隔；sender 为完整地址或域名；
+// since / until 为 RFC 3339 或 YYYY-MM-DD（until 为日期时包含当天）
+func (h *EmailQueryHandler) SearchEmails(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	filter, err := parseSearchFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSearchLimit)))
+	if err != nil || limit <= 0 || limit > maxSearchLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+
+	var cursor *repository.EmailCursor
+	if raw := c.Query("cursor"); raw != "" {
+		if cursor, err = decodeEmailCursor(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	// 多取一条判断是否还有下一页
+	hits, err := h.emailRepo.SearchEmails(ctx, userID.(int), filter, cursor, limit+1)
+	if err != nil {
+		h.logger.Error("SearchEmails: failed to search emails", zap.Int("user_id", userID.(int)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search emails"})
+		return
+	}
+	var nextCursor string
+	if len(hits) > limit {
+		hits = hits[:limit]
+		last := hits[len(hits)-1]
+		nextCursor = encodeEmailCursor(last.CreatedAt, last.ID)
+	}
+	if hits == nil {
+		hits = []db.EmailSearchHit{}
+	}
+
+	facets, err := h.emailRepo.SearchFacets(ctx, userID.(int), filter)
+	if err != nil {
+		h.logger.Error("SearchEmails: failed to count search facets", zap.Int("user_id", userID.(int)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count search facets"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":     hits,
+		"facets":      facets,
+		"next_cursor": nextCursor,
+	})
+}
+
+func parseSearchFilter(c *gin.Context) (repository.EmailSearchFilter, error) {
+	f := repository.EmailSearchFilter{Query: strings.TrimSpace(c.Query("q"))}
+	var err error
+	if f.Categories, f.Priorities, f.Statuses, err = parseEmailAttrFilters(c); err != nil {
+		return f, err
+	}
+
+	if s := c.Query("since"); s != "" {
+		since, err := parseSince(s)
+		if err != nil {
+			return f, fmt.Errorf("since must be RFC 3339 or YYYY-MM-DD")
+		}
+		since = since.UTC()
+		f.Since = &since
+	}
+	if s := c.Query("until"); s != "" {
+		until, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			// 只有日期时包含当天
+			day, err := time.Parse("2006-01-02", s)
+			if err != nil {
+				return f, fmt.Errorf("until must be RFC 3339 or YYYY-MM-DD")
+			}
+			until = day.AddDate(0, 0, 1)
+		}
+		until = until.UTC()
+		f.Until = &until
+	}
+
+	// 含 @ 按完整地址匹配，否则按域名匹配
+	sender := strings.ToLower(strings.TrimSpace(c.Query("sender")))
+	if strings.Contains(sender, "@") {
+		f.SenderAddr = sender
+	} else {
+		f.SenderDomain = strings.TrimPrefix(sender, "@")
+	}
+	return f, nil
+}
+
+// parseEmailAttrFilters 解析 category / priority / status 过滤条件（/emails 和 /emails/search 共用）
+func parseEmailAttrFilters(c *gin.Context) (categories, priorities, statuses []string, err error) {
+	categories = queryList(c, "category")
+	priorities = queryList(c, "priority")
+	statuses = queryList(c, "status")
+
+	for i, p := range priorities {
+		priorities[i] = strings.ToUpper(p)
+		switch priorities[i] {
+		case "LOW", "MEDIUM", "HIGH":
+		default:
+			return nil, nil, nil, fmt.Errorf("priority must be LOW, MEDIUM or HIGH")
+		}
+	}
+	for i, cat := range categories {
+		categories[i] = strings.ToUpper(cat)
+	}
+	for i, s := range statuses {
+		statuses[i] = strings.ToLower(s)
+		switch statuses[i] {
+		case "received", "classified", "filtered":
+		default:
+			return nil, nil, nil, fmt.Errorf("status must be received, classified or filtered")
+		}
+	}
+	return categories, priorities, statuses, nil
+}
+
+// queryList 支持 ?k=a&k=b 和 ?k=a,b 两种写法
+func queryList(c *gin.Context, key string) []string {
+	var values []string
+	for _, v := range c.QueryArray(key) {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+	}
+	return values
+}
+
+// 游标为上一页最后一条结果的 created_at（微秒）和 id
+func encodeEmailCursor(createdAt time.Time, id int) string {
+	raw := strconv.FormatInt(createdAt.UnixMicro(), 10) + ":" + strconv.Itoa(id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeEmailCursor(s string) (*repository.EmailCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	micros, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	us, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	emailID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.EmailCursor{CreatedAt: time.UnixMicro(us).UTC(), ID: emailID}, nil
+}
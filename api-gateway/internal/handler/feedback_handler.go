@@ -0,0 +1,333 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"api-gateway/internal/repository"
+	"mygoproject/contracts/db"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const (
+	maxFeedbackCategories = 10
+	maxFeedbackComment    = 1000
+)
+
+// FeedbackHandler 用户对 AI 决策的修正：修改分类 / 优先级，标记错误的任务或通知
+// 最近的修正由 email-processor-service 作为 few-shot 示例发送给 agent-service
+type FeedbackHandler struct {
+	db           *pgxpool.Pool
+	emailRepo    *repository.EmailRepository
+	feedbackRepo *repository.FeedbackRepository
+	logger       *zap.Logger
+}
+
+func NewFeedbackHandler(
+	db *pgxpool.Pool,
+	emailRepo *repository.EmailRepository,
+	feedbackRepo *repository.FeedbackRepository,
+	logger *zap.Logger,
+) *FeedbackHandler {
+	return &FeedbackHandler{
+		db:           db,
+		emailRepo:    emailRepo,
+		feedbackRepo: feedbackRepo,
+		logger:       logger,
+	}
+}
+
+type updateMetadataRequest struct {
+	Categories []string `json:"categories"`
+	Priority   string   `json:"priority"`
+	Comment    string   `json:"comment"`
+}
+
+type createFeedbackRequest struct {
+	Type    string `json:"type" binding:"required"` // task / notification
+	Comment string `json:"comment"`
+}
+
+// UpdateMetadata handles PATCH /emails/:id/metadata
+// 未提供的字段保持不变，修正前的决策归档到 emails_metadata_history
+func (h *FeedbackHandler) UpdateMetadata(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	emailID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email id"})
+		return
+	}
+
+	var req updateMetadataRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+	categories, priority, err := normalizeCorrection(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	found, err := h.emailRepo.EmailExists(ctx, emailID, userID.(int))
+	if err != nil {
+		h.logger.Error("UpdateMetadata: failed to fetch email", zap.Int("email_id", emailID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch email"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "email not found"})
+		return
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		h.logger.Error("UpdateMetadata: failed to begin transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update metadata"})
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	origCategories, origPriority, origSource, err := h.feedbackRepo.GetMetadataForUpdateTx(ctx, tx, emailID, userID.(int))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusConflict, gin.H{"error": "email is not classified yet"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("UpdateMetadata: failed to fetch metadata", zap.Int("email_id", emailID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update metadata"})
+		return
+	}
+
+	if categories == nil {
+		categories = origCategories
+	}
+	if priority == "" {
+		priority = origPriority
+	}
+
+	if err := h.feedbackRepo.CorrectMetadataTx(ctx, tx, emailID, categories, priority); err != nil {
+		h.logger.Error("UpdateMetadata: failed to update metadata", zap.Int("email_id", emailID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update metadata"})
+		return
+	}
+
+	feedbackID, err := h.feedbackRepo.InsertTx(ctx, tx, &db.EmailFeedback{
+		UserID:              userID.(int),
+		EmailID:             emailID,
+		FeedbackType:        db.FeedbackClassification,
+		OriginalCategories:  origCategories,
+		OriginalPriority:    origPriority,
+		OriginalSource:      origSource,
+		CorrectedCategories: categories,
+		CorrectedPriority:   priority,
+		Comment:             strings.TrimSpace(req.Comment),
+	})
+	if err != nil {
+		h.logger.Error("UpdateMetadata: failed to record feedback", zap.Int("email_id", emailID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update metadata"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.logger.Error("UpdateMetadata: failed to commit", zap.Int("email_id", emailID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update metadata"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"email_id":        emailID,
+		"categories":      categories,
+		"priority":        priority,
+		"decision_source": "user",
+		"feedback_id":     feedbackID,
+	})
+}
+
+// CreateFeedback handles POST /emails/:id/feedback
+// 标记根据该邮件自动创建的任务或发送的通知是错误的
+func (h *FeedbackHandler) CreateFeedback(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	emailID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email id"})
+		return
+	}
+
+	var req createFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+	req.Type = strings.ToLower(strings.TrimSpace(req.Type))
+	if req.Type != db.FeedbackTask && req.Type != db.FeedbackNotification {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be task or notification"})
+		return
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+	if len(req.Comment) > maxFeedbackComment {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "comment too long"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		h.logger.Error("CreateFeedback: failed to begin transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record feedback"})
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	origCategories, origPriority, origSource, err := h.feedbackRepo.GetMetadataForUpdateTx(ctx, tx, emailID, userID.(int))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "email not found or not classified"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("CreateFeedback: failed to fetch metadata", zap.Int("email_id", emailID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record feedback"})
+		return
+	}
+
+	detail, err := h.feedbackRepo.FindActionTx(ctx, tx, req.Type, emailID, userID.(int))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no " + req.Type + " was created for this email"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("CreateFeedback: failed to fetch "+req.Type, zap.Int("email_id", emailID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record feedback"})
+		return
+	}
+
+	feedbackID, err := h.feedbackRepo.InsertTx(ctx, tx, &db.EmailFeedback{
+		UserID:             userID.(int),
+		EmailID:            emailID,
+		FeedbackType:       req.Type,
+		OriginalCategories: origCategories,
+		OriginalPriority:   origPriority,
+		OriginalSource:     origSource,
+		Detail:             detail,
+		Comment:            req.Comment,
+	})
+	if err != nil {
+		h.logger.Error("CreateFeedback: failed to record feedback", zap.Int("email_id", emailID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record feedback"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.logger.Error("CreateFeedback: failed to commit", zap.Int("email_id", emailID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record feedback"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": feedbackID})
+}
+
+// ListFeedback handles GET /emails/:id/feedback
+func (h *FeedbackHandler) ListFeedback(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	emailID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email id"})
+		return
+	}
+
+	feedback, err := h.feedbackRepo.ListByEmail(c.Request.Context(), emailID, userID.(int))
+	if err != nil {
+		h.logger.Error("ListFeedback: failed to fetch feedback", zap.Int("email_id", emailID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch feedback"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"feedback": feedback})
+}
+
+// GetFeedbackStats handles GET /feedback/stats
+// 每个分类的准确率，以及自动创建的任务 / 通知未被标记为错误的比例
+func (h *FeedbackHandler) GetFeedbackStats(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var stats db.FeedbackStats
+	var err error
+
+	if stats.Categories, err = h.feedbackRepo.CategoryPrecision(ctx, userID.(int)); err == nil {
+		if stats.Tasks, err = h.feedbackRepo.ActionPrecision(ctx, userID.(int), db.FeedbackTask); err == nil {
+			stats.Notifications, err = h.feedbackRepo.ActionPrecision(ctx, userID.(int), db.FeedbackNotification)
+		}
+	}
+	if err != nil {
+		h.logger.Error("GetFeedbackStats: failed to compute stats", zap.Int("user_id", userID.(int)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch feedback stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// normalizeCorrection 校验修正内容；返回 nil categories / 空 priority 表示保持不变
+func normalizeCorrection(req updateMetadataRequest) ([]string, string, error) {
+	var categories []string
+	if req.Categories != nil {
+		seen := make(map[string]bool)
+		categories = make([]string, 0, len(req.Categories))
+		for _, category := range req.Categories {
+			category = strings.ToUpper(strings.TrimSpace(category))
+			if category == "" || seen[category] {
+				continue
+			}
+			seen[category] = true
+			categories = append(categories, category)
+		}
+		if len(categories) == 0 {
+			return nil, "", errors.New("categories must not be empty")
+		}
+		if len(categories) > maxFeedbackCategories {
+			return nil, "", errors.New("too many categories")
+		}
+	}
+
+	priority := strings.ToUpper(strings.TrimSpace(req.Priority))
+	switch priority {
+	case "", "HIGH", "MEDIUM", "LOW":
+	default:
+		return nil, "", errors.New("priority must be HIGH, MEDIUM or LOW")
+	}
+
+	if categories == nil && priority == "" {
+		return nil, "", errors.New("categories or priority is required")
+	}
+	if len(strings.TrimSpace(req.Comment)) > maxFeedbackComment {
+		return nil, "", errors.New("comment too long")
+	}
+	return categories, priority, nil
+}
@@ -1,14 +1,21 @@
 package handler
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 
 	"github.com/gin-gonic/gin"
 )
 
+// webhookForwardHeaders 服务商 webhook 校验所需的请求头
+var webhookForwardHeaders = []string{
+	"Authorization", // Postmark Basic 认证
+	"X-Twilio-Email-Event-Webhook-Signature",
+	"X-Twilio-Email-Event-Webhook-Timestamp",
+}
+
 type MailProxyHandler struct {
 	ingestionServiceURL string
 	httpClient          *http.Client
@@ -23,30 +30,141 @@ func NewMailProxyHandler(ingestionServiceURL string) *MailProxyHandler {
 
 // SimulateNewEmail proxies POST /email/simulate to mail-ingestion-service
 func (h *MailProxyHandler) SimulateNewEmail(c *gin.Context) {
+	h.forward(c, http.MethodPost, "/email/simulate", "application/json")
+}
+
+// IngestRawEmail proxies POST /email/raw (message/rfc822) to mail-ingestion-service
+func (h *MailProxyHandler) IngestRawEmail(c *gin.Context) {
+	h.forward(c, http.MethodPost, "/email/raw", "message/rfc822")
+}
+
+// CreateImport proxies POST /email/import (multipart mbox / zip upload)
+func (h *MailProxyHandler) CreateImport(c *gin.Context) {
+	h.forward(c, http.MethodPost, "/email/import", "")
+}
+
+// GetImport proxies GET /email/import/:id
+func (h *MailProxyHandler) GetImport(c *gin.Context) {
+	h.forward(c, http.MethodGet, "/email/import/"+url.PathEscape(c.Param("id")), "")
+}
+
+// InboundWebhook proxies POST /webhooks/inbound/:provider（邮件服务商回调，无需登录）
+// 签名校验在 mail-ingestion-service 中完成，这里原样转发认证 / 签名相关的请求头
+func (h *MailProxyHandler) InboundWebhook(c *gin.Context) {
+	h.proxy(c, http.MethodPost, "/webhooks/inbound/"+url.PathEscape(c.Param("provider")), func(req *http.Request) {
+		req.Header.Set("Content-Type", c.GetHeader("Content-Type"))
+		for _, name := range webhookForwardHeaders {
+			if v := c.GetHeader(name); v != "" {
+				req.Header.Set(name, v)
+			}
+		}
+	})
+}
+
+// CreateMailbox proxies POST /mailboxes to mail-ingestion-service
+func (h *MailProxyHandler) CreateMailbox(c *gin.Context) {
+	h.forward(c, http.MethodPost, "/mailboxes", "application/json")
+}
+
+// ListMailboxes proxies GET /mailboxes (including per-mailbox sync status)
+func (h *MailProxyHandler) ListMailboxes(c *gin.Context) {
+	h.forward(c, http.MethodGet, "/mailboxes", "")
+}
+
+// GetMailbox proxies GET /mailboxes/:id
+func (h *MailProxyHandler) GetMailbox(c *gin.Context) {
+	h.forward(c, http.MethodGet, "/mailboxes/"+url.PathEscape(c.Param("id")), "")
+}
+
+// DeleteMailbox proxies DELETE /mailboxes/:id
+func (h *MailProxyHandler) DeleteMailbox(c *gin.Context) {
+	h.forward(c, http.MethodDelete, "/mailboxes/"+url.PathEscape(c.Param("id")), "")
+}
+
+// CreateSenderRule proxies POST /sender-rules to mail-ingestion-service
+func (h *MailProxyHandler) CreateSenderRule(c *gin.Context) {
+	h.forward(c, http.MethodPost, "/sender-rules", "application/json")
+}
+
+// ListSenderRules proxies GET /sender-rules
+func (h *MailProxyHandler) ListSenderRules(c *gin.Context) {
+	h.forward(c, http.MethodGet, "/sender-rules", "")
+}
+
+// GetSenderRule proxies GET /sender-rules/:id
+func (h *MailProxyHandler) GetSenderRule(c *gin.Context) {
+	h.forward(c, http.MethodGet, "/sender-rules/"+url.PathEscape(c.Param("id")), "")
+}
+
+// UpdateSenderRule proxies PUT /sender-rules/:id
+func (h *MailProxyHandler) UpdateSenderRule(c *gin.Context) {
+	h.forward(c, http.MethodPut, "/sender-rules/"+url.PathEscape(c.Param("id")), "application/json")
+}
+
+// DeleteSenderRule proxies DELETE /sender-rules/:id
+func (h *MailProxyHandler) DeleteSenderRule(c *gin.Context) {
+	h.forward(c, http.MethodDelete, "/sender-rules/"+url.PathEscape(c.Param("id")), "")
+}
+
+// ListFailedEvents proxies GET /admin/failed-events
+func (h *MailProxyHandler) ListFailedEvents(c *gin.Context) {
+	h.forward(c, http.MethodGet, "/admin/failed-events", "")
+}
+
+// GetFailedEvent proxies GET /admin/failed-events/:id
+func (h *MailProxyHandler) GetFailedEvent(c *gin.Context) {
+	h.forward(c, http.MethodGet, "/admin/failed-events/"+url.PathEscape(c.Param("id")), "")
+}
+
+// RetryFailedEvent proxies POST /admin/failed-events/:id/retry
+func (h *MailProxyHandler) RetryFailedEvent(c *gin.Context) {
+	h.forward(c, http.MethodPost, "/admin/failed-events/"+url.PathEscape(c.Param("id"))+"/retry", "")
+}
+
+// DiscardFailedEvent proxies POST /admin/failed-events/:id/discard
+func (h *MailProxyHandler) DiscardFailedEvent(c *gin.Context) {
+	h.forward(c, http.MethodPost, "/admin/failed-events/"+url.PathEscape(c.Param("id"))+"/discard", "")
+}
+
+// forward 将请求转发到 mail-ingestion-service，并附带 X-User-ID
+// contentType 为空时沿用原请求的 Content-Type
+func (h *MailProxyHandler) forward(c *gin.Context, method, path, contentType string) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
 		return
 	}
 
-	// Read request body
-	body, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
-		return
+	if contentType == "" {
+		contentType = c.GetHeader("Content-Type")
 	}
+	h.proxy(c, method, path, func(req *http.Request) {
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("X-User-ID", fmt.Sprintf("%d", userID.(int)))
+		// 传播幂等键（重复提交时返回原 email_id）
+		if key := c.GetHeader("Idempotency-Key"); key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+	})
+}
 
+// proxy 流式转发请求体，setHeaders 负责设置转发的请求头
+func (h *MailProxyHandler) proxy(c *gin.Context, method, path string, setHeaders func(req *http.Request)) {
 	// Forward to mail-ingestion-service
-	url := h.ingestionServiceURL + "/email/simulate"
-	req, err := http.NewRequestWithContext(c.Request.Context(), "POST", url, bytes.NewReader(body))
+	target := h.ingestionServiceURL + path
+	if c.Request.URL.RawQuery != "" {
+		target += "?" + c.Request.URL.RawQuery
+	}
+	// 直接流式转发请求体（导入文件可能很大，不在网关缓存）
+	req, err := http.NewRequestWithContext(c.Request.Context(), method, target, c.Request.Body)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create request"})
 		return
 	}
+	req.ContentLength = c.Request.ContentLength
 
 	// Copy headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-User-ID", fmt.Sprintf("%d", userID.(int)))
+	setHeaders(req)
 	// 传播 trace_id
 	if traceID := c.GetHeader("X-Trace-ID"); traceID != "" {
 		req.Header.Set("X-Trace-ID", traceID)
@@ -70,4 +188,3 @@ func (h *MailProxyHandler) SimulateNewEmail(c *gin.Context) {
 	// Forward response
 	c.Data(resp.StatusCode, "application/json", respBody)
 }
-
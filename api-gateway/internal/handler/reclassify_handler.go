@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"api-gateway/internal/repository"
+	"mygoproject/pkg/outbox"
+	"mygoproject/pkg/trace"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultReclassifyLimit = 100
+	maxReclassifyLimit     = 500
+)
+
+// ReclassifyHandler 重新分类已分类的邮件：重置状态并通过 outbox 发布新的 email.received.agent 事件
+// email-processor-service 写入新版本的 emails_metadata，旧决策保存在 emails_metadata_history
+type ReclassifyHandler struct {
+	db         *pgxpool.Pool
+	emailRepo  *repository.EmailRepository
+	outboxRepo *outbox.Repository
+	logger     *zap.Logger
+}
+
+func NewReclassifyHandler(db *pgxpool.Pool, emailRepo *repository.EmailRepository, logger *zap.Logger) *ReclassifyHandler {
+	return &ReclassifyHandler{
+		db:         db,
+		emailRepo:  emailRepo,
+		outboxRepo: outbox.NewRepository(db),
+		logger:     logger,
+	}
+}
+
+// ReclassifyEmail handles POST /emails/:id/reclassify
+func (h *ReclassifyHandler) ReclassifyEmail(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	emailID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email id"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	found, err := h.emailRepo.EmailExists(ctx, emailID, userID.(int))
+	if err != nil {
+		h.logger.Error("ReclassifyEmail: failed to fetch email", zap.Int("email_id", emailID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch email"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "email not found"})
+		return
+	}
+
+	queued, err := h.reclassify(ctx, userID.(int), []int{emailID})
+	if err != nil {
+		h.logger.Error("ReclassifyEmail: failed to queue re-classification", zap.Int("email_id", emailID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue re-classification"})
+		return
+	}
+	if len(queued) == 0 {
+		// 仍在分类中，或被入库过滤（不会发送给 agent）
+		c.JSON(http.StatusConflict, gin.H{"error": "email is not classified yet"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"email_id": emailID, "status": "queued"})
+}
+
+// ReclassifyEmails handles POST /emails/reclassify?since=2026-01-01T00:00:00Z&source=fallback&limit=100
+// since 为必填（RFC 3339 或 YYYY-MM-DD），source 可选：agent / rules / fallback / unknown / user
+func (h *ReclassifyHandler) ReclassifyEmails(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	since, err := parseSince(c.Query("since"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "since is required (RFC 3339 or YYYY-MM-DD)"})
+		return
+	}
+
+	source := c.Query("source")
+	switch source {
+	case "", "agent", "rules", "fallback", "unknown", "user":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source must be agent, rules, fallback, unknown or user"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultReclassifyLimit)))
+	if err != nil || limit <= 0 || limit > maxReclassifyLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	ids, err := h.emailRepo.ListReclassifyCandidates(ctx, userID.(int), since, source, limit)
+	if err != nil {
+		h.logger.Error("ReclassifyEmails: failed to list emails", zap.Int("user_id", userID.(int)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch emails"})
+		return
+	}
+
+	queued, err := h.reclassify(ctx, userID.(int), ids)
+	if err != nil {
+		h.logger.Error("ReclassifyEmails: failed to queue re-classification", zap.Int("user_id", userID.(int)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue re-classification"})
+		return
+	}
+	if queued == nil {
+		queued = []int{}
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"queued":    len(queued),
+		"email_ids": queued,
+	})
+}
+
+// GetMetadataHistory handles GET /emails/:id/metadata/history
+func (h *ReclassifyHandler) GetMetadataHistory(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	emailID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email id"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	found, err := h.emailRepo.EmailExists(ctx, emailID, userID.(int))
+	if err != nil {
+		h.logger.Error("GetMetadataHistory: failed to fetch email", zap.Int("email_id", emailID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch email"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "email not found"})
+		return
+	}
+
+	history, err := h.emailRepo.ListMetadataHistory(ctx, emailID, userID.(int))
+	if err != nil {
+		h.logger.Error("GetMetadataHistory: failed to fetch history", zap.Int("email_id", emailID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch metadata history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"email_id": emailID,
+		"history":  history,
+	})
+}
+
+// reclassify 在一个事务中重置邮件状态并写入 email.received.agent outbox 事件
+// 返回实际加入重新分类的邮件（未处于 classified 状态的邮件被跳过）
+func (h *ReclassifyHandler) reclassify(ctx context.Context, userID int, emailIDs []int) ([]int, error) {
+	if len(emailIDs) == 0 {
+		return nil, nil
+	}
+
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// 同一批次共享一个 token，processor 以此区分重新分类与重复投递
+	token := strconv.FormatInt(time.Now().UnixNano(), 36)
+	traceID := trace.FromContext(ctx)
+
+	var queued []int
+	for _, emailID := range emailIDs {
+		reset, err := h.emailRepo.ResetForReclassifyTx(ctx, tx, emailID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !reset {
+			continue
+		}
+
+		payload, err := h.emailRepo.BuildAgentPayloadTx(ctx, tx, emailID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				continue
+			}
+			return nil, err
+		}
+		payload.TraceID = traceID
+		payload.ReclassifyToken = token
+
+		emailID64 := int64(emailID)
+		if err := outbox.InsertEventInTx(ctx, tx, h.outboxRepo, "email", &emailID64, "email.received.agent", payload); err != nil {
+			return nil, err
+		}
+		queued = append(queued, emailID)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	h.logger.Info("Emails queued for re-classification",
+		zap.Int("user_id", userID),
+		zap.Ints("email_ids", queued),
+		zap.String("trace_id", traceID),
+	)
+	return queued, nil
+}
+
+// parseSince 解析 RFC 3339 时间或 YYYY-MM-DD 日期
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
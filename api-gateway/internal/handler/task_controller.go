@@ -2,14 +2,17 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"time"
 
+	"api-gateway/internal/repository"
 	mqcontracts "mygoproject/contracts/mq"
 	"mygoproject/pkg/circuitbreaker"
+	"mygoproject/pkg/deadline"
 	"mygoproject/pkg/mq"
 	"mygoproject/pkg/outbox"
 	"mygoproject/pkg/rbac"
@@ -26,6 +29,7 @@ type TaskController struct {
 	taskServiceURL  string
 	taskPublisher   *mq.Publisher
 	outboxRepo      *outbox.Repository
+	userRepo        *repository.UserRepository
 
 	httpClient *http.Client
 	logger     *zap.Logger
@@ -33,7 +37,7 @@ type TaskController struct {
 	cbProject  *circuitbreaker.CircuitBreaker // 熔断器（用于 plan-project）
 }
 
-func NewTaskController(db *pgxpool.Pool, agentURL, taskURL string, pub *mq.Publisher, logger *zap.Logger) *TaskController {
+func NewTaskController(db *pgxpool.Pool, agentURL, taskURL string, pub *mq.Publisher, userRepo *repository.UserRepository, logger *zap.Logger) *TaskController {
 	// 为 text-to-tasks 创建熔断器
 	cbConfig := circuitbreaker.Config{
 		FailureThreshold:    3,
@@ -56,6 +60,7 @@ func NewTaskController(db *pgxpool.Pool, agentURL, taskURL string, pub *mq.Publi
 		taskServiceURL:  taskURL,
 		taskPublisher:   pub,
 		outboxRepo:      outbox.NewRepository(db),
+		userRepo:        userRepo,
 		logger:          logger,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second, // LLM 可能需要更长时间
@@ -75,6 +80,16 @@ func (tc *TaskController) getUserID(c *gin.Context) (int, bool) {
 	return userID.(int), true
 }
 
+// userLocation 返回用户时区，读取失败时使用 UTC（不影响请求）
+func (tc *TaskController) userLocation(ctx context.Context, userID int) *time.Location {
+	tz, err := tc.userRepo.GetTimezone(ctx, userID)
+	if err != nil {
+		tc.logger.Warn("Failed to load user timezone, using UTC", zap.Int("user_id", userID), zap.Error(err))
+		return time.UTC
+	}
+	return deadline.LoadLocation(tz)
+}
+
 // CreateTasksFromText handles POST /tasks/from-text
 // 功能：调用 agent-service 解析文本，然后发布 task.bulk_created 事件到 MQ
 func (tc *TaskController) CreateTasksFromText(c *gin.Context) {
@@ -172,6 +187,14 @@ func (tc *TaskController) CreateTasksFromText(c *gin.Context) {
 		return
 	}
 
+	// 截止时间在发布时解析为绝对时间（用户时区当天结束），消息延迟消费不会推迟截止时间
+	now := time.Now()
+	loc := tc.userLocation(c.Request.Context(), userID)
+	for i := range agentResp.Tasks {
+		dueAt := deadline.Resolve("", agentResp.Tasks[i].DueInDays, now, loc)
+		agentResp.Tasks[i].DueAt = &dueAt
+	}
+
 	// Step 2: Publish habit.created events
 	// RBAC 验证：确保 user_id 匹配 token（已在中间件中验证，这里再次确认）
 	traceID := trace.FromContext(c.Request.Context())
@@ -447,14 +470,18 @@ func (tc *TaskController) PlanProject(c *gin.Context) {
 		return
 	}
 
-	// Step 2: Convert to MQ contract format
+	// Step 2: Convert to MQ contract format（任务截止时间解析为用户时区的绝对时间）
+	now := time.Now()
+	loc := tc.userLocation(c.Request.Context(), userID)
 	milestones := make([]mqcontracts.Milestone, len(agentResp.Project.Milestones))
 	for i, m := range agentResp.Project.Milestones {
 		tasks := make([]mqcontracts.ProjectTask, len(m.Tasks))
 		for j, t := range m.Tasks {
+			dueAt := deadline.Resolve("", t.DueInDays, now, loc)
 			tasks[j] = mqcontracts.ProjectTask{
 				Title:     t.Title,
 				DueInDays: t.DueInDays,
+				DueAt:     &dueAt,
 				Priority:  t.Priority,
 				DependsOn: t.DependsOn,
 			}
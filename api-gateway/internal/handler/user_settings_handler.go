@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"api-gateway/internal/repository"
+	"mygoproject/pkg/deadline"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// UserSettingsHandler 用户设置（时区：解析邮件中的相对截止时间、生成习惯任务；
+// decision_cache：模板化邮件是否复用缓存的 agent 决策）
+type UserSettingsHandler struct {
+	userRepo *repository.UserRepository
+	logger   *zap.Logger
+}
+
+func NewUserSettingsHandler(userRepo *repository.UserRepository, logger *zap.Logger) *UserSettingsHandler {
+	return &UserSettingsHandler{userRepo: userRepo, logger: logger}
+}
+
+// updateSettingsRequest 只更新请求中给出的字段
+type updateSettingsRequest struct {
+	Timezone      *string `json:"timezone"` // IANA 时区名，如 Asia/Shanghai
+	DecisionCache *bool   `json:"decision_cache"`
+}
+
+// GetSettings handles GET /users/me/settings
+func (h *UserSettingsHandler) GetSettings(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	settings, err := h.userRepo.GetSettings(c.Request.Context(), userID.(int))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("GetSettings: failed to fetch user", zap.Int("user_id", userID.(int)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateSettings handles PUT /users/me/settings
+func (h *UserSettingsHandler) UpdateSettings(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var req updateSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+	if req.Timezone == nil && req.DecisionCache == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "timezone or decision_cache is required"})
+		return
+	}
+	if req.Timezone != nil {
+		tz := strings.TrimSpace(*req.Timezone)
+		// "Local" 依赖服务器配置，不能作为用户时区
+		if tz == "Local" || !deadline.ValidTimezone(tz) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "timezone must be a valid IANA time zone name (e.g. Europe/Berlin)"})
+			return
+		}
+		req.Timezone = &tz
+	}
+
+	settings, err := h.userRepo.UpdateSettings(c.Request.Context(), userID.(int), req.Timezone, req.DecisionCache)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("UpdateSettings: failed to update settings", zap.Int("user_id", userID.(int)), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
@@ -26,6 +26,12 @@ func NewRouter(
 	emailQueryHandler *handler.EmailQueryHandler,
 	taskController *handler.TaskController,
 	adminHandler *handler.AdminHandler,
+	classificationRuleHandler *handler.ClassificationRuleHandler,
+	reclassifyHandler *handler.ReclassifyHandler,
+	feedbackHandler *handler.FeedbackHandler,
+	userSettingsHandler *handler.UserSettingsHandler,
+	emailActionHandler *handler.EmailActionHandler,
+	contactHandler *handler.ContactHandler,
 	jwtSecret string,
 	db *pgxpool.Pool,
 ) *Router {
@@ -101,13 +107,50 @@ func NewRouter(
 	// Public
 	r.POST("/register", authHandler.Register)
 	r.POST("/login", authHandler.Login)
+	r.POST("/webhooks/inbound/:provider", mailProxyHandler.InboundWebhook)
 
 	// Protected
 	auth := r.Group("/")
 	auth.Use(AuthMiddleware(jwtSecret))
 	{
+		auth.GET("/users/me/settings", userSettingsHandler.GetSettings)
+		auth.PUT("/users/me/settings", userSettingsHandler.UpdateSettings)
 		auth.POST("/email/simulate", mailProxyHandler.SimulateNewEmail)
+		auth.POST("/email/raw", mailProxyHandler.IngestRawEmail)
+		auth.POST("/email/import", mailProxyHandler.CreateImport)
+		auth.GET("/email/import/:id", mailProxyHandler.GetImport)
+		auth.POST("/mailboxes", mailProxyHandler.CreateMailbox)
+		auth.GET("/mailboxes", mailProxyHandler.ListMailboxes)
+		auth.GET("/mailboxes/:id", mailProxyHandler.GetMailbox)
+		auth.DELETE("/mailboxes/:id", mailProxyHandler.DeleteMailbox)
+		auth.POST("/sender-rules", mailProxyHandler.CreateSenderRule)
+		auth.GET("/sender-rules", mailProxyHandler.ListSenderRules)
+		auth.GET("/sender-rules/:id", mailProxyHandler.GetSenderRule)
+		auth.PUT("/sender-rules/:id", mailProxyHandler.UpdateSenderRule)
+		auth.DELETE("/sender-rules/:id", mailProxyHandler.DeleteSenderRule)
+		auth.POST("/classification-rules", classificationRuleHandler.CreateRule)
+		auth.GET("/classification-rules", classificationRuleHandler.ListRules)
+		auth.GET("/classification-rules/:id", classificationRuleHandler.GetRule)
+		auth.PUT("/classification-rules/:id", classificationRuleHandler.UpdateRule)
+		auth.DELETE("/classification-rules/:id", classificationRuleHandler.DeleteRule)
 		auth.GET("/emails", emailQueryHandler.GetEmails)
+		auth.GET("/emails/search", emailQueryHandler.SearchEmails)
+		auth.GET("/emails/:id", emailQueryHandler.GetEmail)
+		auth.POST("/emails/:id/archive", emailActionHandler.ArchiveEmail)
+		auth.POST("/emails/:id/unarchive", emailActionHandler.UnarchiveEmail)
+		auth.DELETE("/emails/:id", emailActionHandler.DeleteEmail)
+		auth.POST("/emails/reclassify", reclassifyHandler.ReclassifyEmails)
+		auth.POST("/emails/:id/reclassify", reclassifyHandler.ReclassifyEmail)
+		auth.GET("/emails/:id/metadata/history", reclassifyHandler.GetMetadataHistory)
+		auth.PATCH("/emails/:id/metadata", feedbackHandler.UpdateMetadata)
+		auth.POST("/emails/:id/feedback", feedbackHandler.CreateFeedback)
+		auth.GET("/emails/:id/feedback", feedbackHandler.ListFeedback)
+		auth.GET("/feedback/stats", feedbackHandler.GetFeedbackStats)
+		auth.GET("/emails/:id/attachments", emailQueryHandler.GetEmailAttachments)
+		auth.GET("/contacts", contactHandler.ListContacts)
+		auth.PATCH("/contacts/:id", contactHandler.UpdateContact)
+		auth.GET("/threads", emailQueryHandler.GetThreads)
+		auth.GET("/threads/:id", emailQueryHandler.GetThread)
 		// Task endpoints (统一由 TaskController 处理)
 		auth.GET("/tasks", taskController.GetTasks)
 		auth.POST("/tasks/:id/complete", taskController.CompleteTask)
@@ -123,6 +166,11 @@ func NewRouter(
 		// Admin endpoints (需要 admin 权限，暂时使用 user 权限)
 		auth.POST("/admin/outbox/replay", adminHandler.ReplayOutboxEvent)
 		auth.POST("/admin/outbox/replay-failed", adminHandler.ReplayFailedEvents)
+		// 失败事件由 mail-ingestion-service 按 X-User-ID 限定范围：具有 failed_event:manage 权限的管理员可管理全部，其他用户只能管理自己的
+		auth.GET("/admin/failed-events", mailProxyHandler.ListFailedEvents)
+		auth.GET("/admin/failed-events/:id", mailProxyHandler.GetFailedEvent)
+		auth.POST("/admin/failed-events/:id/retry", mailProxyHandler.RetryFailedEvent)
+		auth.POST("/admin/failed-events/:id/discard", mailProxyHandler.DiscardFailedEvent)
 	}
 
 	return &Router{Engine: r}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+
+	"mygoproject/contracts/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const classificationRuleColumns = `id, user_id, name, position, enabled, decisive, conditions, actions, created_at, updated_at`
+
+type ClassificationRuleRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewClassificationRuleRepository(db *pgxpool.Pool) *ClassificationRuleRepository {
+	return &ClassificationRuleRepository{db: db}
+}
+
+// Create inserts a classification rule and returns its id.
+func (r *ClassificationRuleRepository) Create(ctx context.Context, rule *db.ClassificationRule) (int, error) {
+	query := `
+        INSERT INTO classification_rules (user_id, name, position, enabled, decisive, conditions, actions)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id
+    `
+	var id int
+	err := r.db.QueryRow(ctx, query,
+		rule.UserID, rule.Name, rule.Position, rule.Enabled, rule.Decisive, rule.Conditions, rule.Actions,
+	).Scan(&id)
+	return id, err
+}
+
+// GetByID returns a classification rule owned by the given user.
+func (r *ClassificationRuleRepository) GetByID(ctx context.Context, id, userID int) (*db.ClassificationRule, error) {
+	query := `SELECT ` + classificationRuleColumns + ` FROM classification_rules WHERE id = $1 AND user_id = $2`
+	return scanClassificationRule(r.db.QueryRow(ctx, query, id, userID))
+}
+
+// ListByUser returns all classification rules of a user in evaluation order.
+func (r *ClassificationRuleRepository) ListByUser(ctx context.Context, userID int) ([]db.ClassificationRule, error) {
+	query := `SELECT ` + classificationRuleColumns + ` FROM classification_rules WHERE user_id = $1 ORDER BY position, id`
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []db.ClassificationRule
+	for rows.Next() {
+		rule, err := scanClassificationRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+// Update replaces a rule's definition. Returns false if the rule does not exist.
+func (r *ClassificationRuleRepository) Update(ctx context.Context, rule *db.ClassificationRule) (bool, error) {
+	query := `
+        UPDATE classification_rules
+        SET name = $3, position = $4, enabled = $5, decisive = $6, conditions = $7, actions = $8, updated_at = NOW()
+        WHERE id = $1 AND user_id = $2
+    `
+	tag, err := r.db.Exec(ctx, query,
+		rule.ID, rule.UserID, rule.Name, rule.Position, rule.Enabled, rule.Decisive, rule.Conditions, rule.Actions,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Delete removes a classification rule owned by the given user. Returns false if nothing was deleted.
+func (r *ClassificationRuleRepository) Delete(ctx context.Context, id, userID int) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM classification_rules WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func scanClassificationRule(row pgx.Row) (*db.ClassificationRule, error) {
+	var rule db.ClassificationRule
+	err := row.Scan(
+		&rule.ID,
+		&rule.UserID,
+		&rule.Name,
+		&rule.Position,
+		&rule.Enabled,
+		&rule.Decisive,
+		&rule.Conditions,
+		&rule.Actions,
+		&rule.CreatedAt,
+		&rule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+
+	"mygoproject/contracts/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const contactColumns = `id, user_id, address, display_name, message_count, avg_priority::float8, task_rate::float8,
+        last_contact_at, is_vip, created_at, updated_at`
+
+// contactOrderBy 支持的排序方式
+var contactOrderBy = map[string]string{
+	"recent":   "last_contact_at DESC NULLS LAST, id DESC",
+	"messages": "message_count DESC, id DESC",
+	"tasks":    "task_rate DESC, message_count DESC, id DESC",
+}
+
+type ContactRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewContactRepository(db *pgxpool.Pool) *ContactRepository {
+	return &ContactRepository{db: db}
+}
+
+// List returns the user's contacts ordered by the given sort key (recent / messages / tasks).
+// When vipOnly is true, only VIP contacts are returned.
+func (r *ContactRepository) List(ctx context.Context, userID int, vipOnly bool, sort string, limit, offset int) ([]db.Contact, error) {
+	orderBy, ok := contactOrderBy[sort]
+	if !ok {
+		orderBy = contactOrderBy["recent"]
+	}
+	query := `
+        SELECT ` + contactColumns + `
+        FROM contacts
+        WHERE user_id = $1 AND (NOT $2 OR is_vip)
+        ORDER BY ` + orderBy + `
+        LIMIT $3 OFFSET $4
+    `
+	rows, err := r.db.Query(ctx, query, userID, vipOnly, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contacts := []db.Contact{}
+	for rows.Next() {
+		c, err := scanContact(rows)
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, *c)
+	}
+	return contacts, rows.Err()
+}
+
+// SetVIP marks or unmarks the user's contact as VIP and returns the updated contact.
+// Returns pgx.ErrNoRows when the contact does not exist or belongs to another user.
+func (r *ContactRepository) SetVIP(ctx context.Context, id, userID int, isVIP bool) (*db.Contact, error) {
+	query := `
+        UPDATE contacts SET is_vip = $3, updated_at = NOW()
+        WHERE id = $1 AND user_id = $2
+        RETURNING ` + contactColumns
+	return scanContact(r.db.QueryRow(ctx, query, id, userID, isVIP))
+}
+
+func scanContact(row pgx.Row) (*db.Contact, error) {
+	var c db.Contact
+	err := row.Scan(
+		&c.ID, &c.UserID, &c.Address, &c.DisplayName, &c.MessageCount, &c.AvgPriority, &c.TaskRate,
+		&c.LastContactAt, &c.IsVIP, &c.CreatedAt, &c.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -2,9 +2,14 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"time"
 
 	"mygoproject/contracts/db"
+	mqcontracts "mygoproject/contracts/mq"
+	"mygoproject/pkg/emailstore"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -16,34 +21,77 @@ func NewEmailRepository(db *pgxpool.Pool) *EmailRepository {
 	return &EmailRepository{db: db}
 }
 
-func (r *EmailRepository) ListEmailsWithMetadata(ctx context.Context, userID int) ([]db.EmailWithMetadata, error) {
+// EmailCursor 游标分页位置（上一页最后一条结果）
+type EmailCursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+// EmailListOptions 邮件列表的过滤、排序和分页参数
+type EmailListOptions struct {
+	Categories  []string // 命中任意一个分类
+	Priorities  []string
+	Statuses    []string
+	Cursor      *EmailCursor // 为 nil 时从第一页开始
+	Ascending   bool         // 默认按时间倒序
+	IncludeBody bool         // false 时不返回正文（只返回摘要）
+	Archived    bool         // true 时只返回已归档的邮件，默认只返回未归档的
+	Limit       int
+}
+
+// ListEmailsWithMetadata returns one page of the user's emails with their metadata,
+// ordered by created_at (then id) in the requested direction.
+func (r *EmailRepository) ListEmailsWithMetadata(ctx context.Context, userID int, opts EmailListOptions) ([]db.EmailWithMetadata, error) {
+	order, cmp := "DESC", "<"
+	if opts.Ascending {
+		order, cmp = "ASC", ">"
+	}
 	query := `
         SELECT 
             r.id,
             r.subject,
-            r.body,
+            CASE WHEN $5 THEN r.body ELSE '' END,
             r.status,
             r.created_at,
+            r.thread_id,
             
             m.categories,
             m.priority,
-            m.summary
+            m.summary,
+            COALESCE(m.rule_hits, '[]'::jsonb),
+            COALESCE(m.decision_source, ''),
+            COALESCE(m.version, 0),
+            r.archived_at
 
         FROM emails_raw r
         LEFT JOIN emails_metadata m
             ON r.id = m.email_id
         
         WHERE r.user_id = $1
-        ORDER BY r.created_at DESC;
+          AND (r.archived_at IS NOT NULL) = $9
+          AND ($2::text[] IS NULL OR m.categories && $2::text[])
+          AND ($3::text[] IS NULL OR m.priority = ANY($3::text[]))
+          AND ($4::text[] IS NULL OR r.status::text = ANY($4::text[]))
+          AND ($6::timestamp IS NULL OR (r.created_at, r.id) ` + cmp + ` ($6::timestamp, $7::int))
+        ORDER BY r.created_at ` + order + `, r.id ` + order + `
+        LIMIT $8;
     `
 
-	rows, err := r.db.Query(ctx, query, userID)
+	var cursorAt *time.Time
+	cursorID := 0
+	if opts.Cursor != nil {
+		cursorAt, cursorID = &opts.Cursor.CreatedAt, opts.Cursor.ID
+	}
+	rows, err := r.db.Query(ctx, query,
+		userID, nilIfEmpty(opts.Categories), nilIfEmpty(opts.Priorities), nilIfEmpty(opts.Statuses),
+		opts.IncludeBody, cursorAt, cursorID, opts.Limit, opts.Archived,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var result []db.EmailWithMetadata
+	result := []db.EmailWithMetadata{}
 
 	for rows.Next() {
 		var e db.EmailWithMetadata
@@ -56,10 +104,15 @@ func (r *EmailRepository) ListEmailsWithMetadata(ctx context.Context, userID int
 			&e.Body,
 			&e.Status,
 			&e.CreatedAt,
+			&e.ThreadID,
 
 			&categories,
 			&priority,
 			&summary,
+			&e.RuleHits,
+			&e.DecisionSource,
+			&e.MetadataVersion,
+			&e.ArchivedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -76,5 +129,445 @@ func (r *EmailRepository) ListEmailsWithMetadata(ctx context.Context, userID int
 		result = append(result, e)
 	}
 
-	return result, nil
+	return result, rows.Err()
+}
+
+// EmailExists reports whether the email exists and belongs to the user.
+func (r *EmailRepository) EmailExists(ctx context.Context, emailID, userID int) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM emails_raw WHERE id = $1 AND user_id = $2)`,
+		emailID, userID,
+	).Scan(&exists)
+	return exists, err
+}
+
+// GetEmail returns a single email of the user with its metadata, header fields,
+// attachments and the tasks created from it. Returns pgx.ErrNoRows when not found.
+func (r *EmailRepository) GetEmail(ctx context.Context, emailID, userID int) (*db.EmailDetail, error) {
+	query := `
+        SELECT r.id, r.subject, r.body, r.status, r.created_at, r.thread_id, r.archived_at,
+               COALESCE(r.filter_reason, ''), r.raw_json,
+               m.categories, COALESCE(m.priority, ''), COALESCE(m.summary, ''),
+               COALESCE(m.rule_hits, '[]'::jsonb), COALESCE(m.decision_source, ''), COALESCE(m.version, 0)
+        FROM emails_raw r
+        LEFT JOIN emails_metadata m ON m.email_id = r.id
+        WHERE r.id = $1 AND r.user_id = $2
+    `
+	var e db.EmailDetail
+	var rawJSON []byte
+	err := r.db.QueryRow(ctx, query, emailID, userID).Scan(
+		&e.ID, &e.Subject, &e.Body, &e.Status, &e.CreatedAt, &e.ThreadID, &e.ArchivedAt,
+		&e.FilterReason, &rawJSON,
+		&e.Categories, &e.Priority, &e.Summary,
+		&e.RuleHits, &e.DecisionSource, &e.MetadataVersion,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var headers struct {
+		From      string   `json:"from"`
+		To        []string `json:"to"`
+		Cc        []string `json:"cc"`
+		MessageID string   `json:"message_id"`
+	}
+	if err := json.Unmarshal(rawJSON, &headers); err == nil {
+		e.From, e.To, e.Cc, e.MessageID = headers.From, headers.To, headers.Cc, headers.MessageID
+	}
+
+	if e.Attachments, err = r.ListAttachments(ctx, emailID, userID); err != nil {
+		return nil, err
+	}
+	if e.Tasks, err = r.listEmailTasks(ctx, emailID, userID); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *EmailRepository) listEmailTasks(ctx context.Context, emailID, userID int) ([]db.EmailTaskRef, error) {
+	rows, err := r.db.Query(ctx, `
+        SELECT id, title, status, COALESCE(priority, ''), due_date
+        FROM tasks
+        WHERE email_id = $1 AND user_id = $2
+        ORDER BY id
+    `, emailID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []db.EmailTaskRef{}
+	for rows.Next() {
+		var t db.EmailTaskRef
+		if err := rows.Scan(&t.ID, &t.Title, &t.Status, &t.Priority, &t.DueDate); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
+
+// SetArchived archives (or un-archives) the user's email and returns the resulting archived_at.
+// Archiving an already archived email keeps the original time. Returns pgx.ErrNoRows when not found.
+func (r *EmailRepository) SetArchived(ctx context.Context, emailID, userID int, archived bool) (*time.Time, error) {
+	query := `
+        UPDATE emails_raw
+        SET archived_at = CASE WHEN $3 THEN COALESCE(archived_at, NOW()) ELSE NULL END
+        WHERE id = $1 AND user_id = $2
+        RETURNING archived_at
+    `
+	var archivedAt *time.Time
+	err := r.db.QueryRow(ctx, query, emailID, userID, archived).Scan(&archivedAt)
+	return archivedAt, err
+}
+
+// EmailDeletion 删除邮件时清理的关联数据
+type EmailDeletion struct {
+	ThreadID             *int
+	AttachmentKeys       []string
+	DeletedTaskIDs       []int
+	DetachedTaskIDs      []int
+	DeletedNotifications int
+	CancelledEvents      int
+}
+
+// DeleteEmailTx deletes the user's email in a transaction. When deleteTasks is true, unfinished
+// tasks created from the email are deleted; all other tasks are kept and detached (email_id = NULL).
+// In-app notifications for the email are deleted and the thread counters are updated. Outbox events
+// about the email that have not been published yet are cancelled so consumers never see them.
+// Returns pgx.ErrNoRows when the email does not exist or belongs to another user.
+func (r *EmailRepository) DeleteEmailTx(ctx context.Context, tx pgx.Tx, emailID, userID int, deleteTasks bool) (*EmailDeletion, error) {
+	var d EmailDeletion
+	err := tx.QueryRow(ctx,
+		`SELECT thread_id FROM emails_raw WHERE id = $1 AND user_id = $2 FOR UPDATE`,
+		emailID, userID,
+	).Scan(&d.ThreadID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 附件记录随邮件级联删除，先记下 blob key 供 mail-ingestion-service 回收
+	if d.AttachmentKeys, err = collectStrings(tx.Query(ctx,
+		`SELECT DISTINCT storage_key FROM email_attachments WHERE email_id = $1`, emailID,
+	)); err != nil {
+		return nil, err
+	}
+
+	if deleteTasks {
+		if d.DeletedTaskIDs, err = collectInts(tx.Query(ctx,
+			`DELETE FROM tasks WHERE email_id = $1 AND user_id = $2 AND status <> 'done' RETURNING id`,
+			emailID, userID,
+		)); err != nil {
+			return nil, err
+		}
+	}
+	if d.DetachedTaskIDs, err = collectInts(tx.Query(ctx,
+		`UPDATE tasks SET email_id = NULL WHERE email_id = $1 RETURNING id`, emailID,
+	)); err != nil {
+		return nil, err
+	}
+
+	tag, err := tx.Exec(ctx, `DELETE FROM notifications WHERE email_id = $1`, emailID)
+	if err != nil {
+		return nil, err
+	}
+	d.DeletedNotifications = int(tag.RowsAffected())
+
+	// 尚未发布的事件（分类、建任务、通知）引用的邮件即将不存在，取消发布（走 idx_outbox_email_id 部分索引）
+	tag, err = tx.Exec(ctx, `
+        UPDATE outbox_events
+        SET status = 'cancelled', updated_at = NOW()
+        WHERE status IN ('pending', 'failed')
+          AND routing_key IN ('email.received.agent', 'email.received.notify', 'email.received.log',
+                              'task.created', 'task.bulk_created', 'notification.created')
+          AND payload->>'email_id' = $1::text
+    `, emailID)
+	if err != nil {
+		return nil, err
+	}
+	d.CancelledEvents = int(tag.RowsAffected())
+
+	// 元数据、历史、反馈、附件记录等级联删除；通知日志保留（email_id 置为 NULL）
+	if _, err := tx.Exec(ctx, `DELETE FROM emails_raw WHERE id = $1`, emailID); err != nil {
+		return nil, err
+	}
+
+	if d.ThreadID != nil {
+		// 线程中没有邮件时删除线程，否则更新计数和最近时间
+		_, err = tx.Exec(ctx, `
+            WITH remaining AS (
+                SELECT COUNT(*) AS n, MAX(created_at) AS last_at FROM emails_raw WHERE thread_id = $1
+            ), updated AS (
+                UPDATE email_threads t
+                SET message_count = remaining.n,
+                    last_message_at = COALESCE(remaining.last_at, t.last_message_at),
+                    updated_at = NOW()
+                FROM remaining
+                WHERE t.id = $1 AND remaining.n > 0
+            )
+            DELETE FROM email_threads
+            WHERE id = $1 AND (SELECT n FROM remaining) = 0
+        `, *d.ThreadID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &d, nil
+}
+
+// ListAttachments returns attachment metadata of an email owned by the user.
+func (r *EmailRepository) ListAttachments(ctx context.Context, emailID, userID int) ([]db.EmailAttachment, error) {
+	query := `
+        SELECT id, email_id, user_id, filename, content_type, size_bytes, sha256,
+               COALESCE(content_id, ''), is_inline, created_at
+        FROM email_attachments
+        WHERE email_id = $1 AND user_id = $2
+        ORDER BY id
+    `
+
+	rows, err := r.db.Query(ctx, query, emailID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []db.EmailAttachment{}
+	for rows.Next() {
+		var a db.EmailAttachment
+		if err := rows.Scan(
+			&a.ID, &a.EmailID, &a.UserID, &a.Filename, &a.ContentType, &a.SizeBytes, &a.SHA256,
+			&a.ContentID, &a.IsInline, &a.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		result = append(result, a)
+	}
+
+	return result, rows.Err()
+}
+
+// ListThreads returns the user's threads, most recently active first.
+func (r *EmailRepository) ListThreads(ctx context.Context, userID int) ([]db.EmailThread, error) {
+	query := `
+        SELECT id, subject, message_count, last_message_at, created_at
+        FROM email_threads
+        WHERE user_id = $1 AND message_count > 0
+        ORDER BY last_message_at DESC
+    `
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []db.EmailThread{}
+	for rows.Next() {
+		var t db.EmailThread
+		if err := rows.Scan(&t.ID, &t.Subject, &t.MessageCount, &t.LastMessageAt, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+
+	return result, rows.Err()
+}
+
+// GetThread returns a thread owned by the user. Returns pgx.ErrNoRows when not found.
+func (r *EmailRepository) GetThread(ctx context.Context, threadID, userID int) (*db.EmailThread, error) {
+	query := `
+        SELECT id, subject, message_count, last_message_at, created_at
+        FROM email_threads
+        WHERE id = $1 AND user_id = $2
+    `
+	var t db.EmailThread
+	err := r.db.QueryRow(ctx, query, threadID, userID).Scan(&t.ID, &t.Subject, &t.MessageCount, &t.LastMessageAt, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListThreadEmails returns the emails of a thread in chronological order.
+func (r *EmailRepository) ListThreadEmails(ctx context.Context, threadID, userID int) ([]db.EmailWithMetadata, error) {
+	query := `
+        SELECT 
+            r.id,
+            r.subject,
+            r.body,
+            r.status,
+            r.created_at,
+            r.thread_id,
+
+            m.categories,
+            m.priority,
+            m.summary
+
+        FROM emails_raw r
+        LEFT JOIN emails_metadata m
+            ON r.id = m.email_id
+
+        WHERE r.thread_id = $1 AND r.user_id = $2
+        ORDER BY r.created_at ASC, r.id ASC;
+    `
+
+	rows, err := r.db.Query(ctx, query, threadID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []db.EmailWithMetadata{}
+	for rows.Next() {
+		var e db.EmailWithMetadata
+		var categories []string
+		var priority, summary *string // allow null
+
+		if err := rows.Scan(
+			&e.ID,
+			&e.Subject,
+			&e.Body,
+			&e.Status,
+			&e.CreatedAt,
+			&e.ThreadID,
+
+			&categories,
+			&priority,
+			&summary,
+		); err != nil {
+			return nil, err
+		}
+
+		e.Categories = categories
+		if priority != nil {
+			e.Priority = *priority
+		}
+		if summary != nil {
+			e.Summary = *summary
+		}
+
+		result = append(result, e)
+	}
+
+	return result, rows.Err()
+}
+
+// ListReclassifyCandidates returns classified emails of the user received since the given time,
+// optionally filtered by decision source, oldest first.
+func (r *EmailRepository) ListReclassifyCandidates(ctx context.Context, userID int, since time.Time, source string, limit int) ([]int, error) {
+	query := `
+        SELECT r.id
+        FROM emails_raw r
+        JOIN emails_metadata m ON m.email_id = r.id
+        WHERE r.user_id = $1
+          AND r.status = 'classified'
+          AND r.created_at >= $2
+          AND ($3 = '' OR m.decision_source = $3)
+        ORDER BY r.created_at ASC, r.id ASC
+        LIMIT $4
+    `
+	rows, err := r.db.Query(ctx, query, userID, since, source, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ResetForReclassifyTx sets a classified email back to received and drops it from the
+// fallback re-classification queue. Returns false if the email is not classified.
+func (r *EmailRepository) ResetForReclassifyTx(ctx context.Context, tx pgx.Tx, emailID, userID int) (bool, error) {
+	tag, err := tx.Exec(ctx,
+		`UPDATE emails_raw SET status = 'received' WHERE id = $1 AND user_id = $2 AND status = 'classified'`,
+		emailID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM reclassify_queue WHERE email_id = $1`, emailID)
+	return true, err
+}
+
+// BuildAgentPayloadTx rebuilds the email.received payload of an existing email (shared with email-processor-service).
+func (r *EmailRepository) BuildAgentPayloadTx(ctx context.Context, tx pgx.Tx, emailID int) (*mqcontracts.EmailReceivedPayload, error) {
+	return emailstore.BuildAgentPayloadTx(ctx, tx, emailID)
+}
+
+// ListMetadataHistory returns previous decisions of an email owned by the user, newest first.
+func (r *EmailRepository) ListMetadataHistory(ctx context.Context, emailID, userID int) ([]db.EmailMetadataHistory, error) {
+	query := `
+        SELECT h.email_id, h.version, h.categories, h.priority, h.summary, h.rule_hits,
+               h.decision_source, h.decided_at, h.archived_at
+        FROM emails_metadata_history h
+        JOIN emails_raw r ON r.id = h.email_id
+        WHERE h.email_id = $1 AND r.user_id = $2
+        ORDER BY h.version DESC
+    `
+	rows, err := r.db.Query(ctx, query, emailID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []db.EmailMetadataHistory{}
+	for rows.Next() {
+		var h db.EmailMetadataHistory
+		if err := rows.Scan(
+			&h.EmailID, &h.Version, &h.Categories, &h.Priority, &h.Summary, &h.RuleHits,
+			&h.DecisionSource, &h.DecidedAt, &h.ArchivedAt,
+		); err != nil {
+			return nil, err
+		}
+		result = append(result, h)
+	}
+	return result, rows.Err()
+}
+
+func collectInts(rows pgx.Rows, err error) ([]int, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []int
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+func collectStrings(rows pgx.Rows, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
 }
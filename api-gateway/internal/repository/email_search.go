@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"html"
+	"strings"
+	"time"
+
+	"mygoproject/contracts/db"
+)
+
+// EmailSearchFilter 邮件搜索条件，零值字段表示不过滤
+type EmailSearchFilter struct {
+	Query        string   // websearch 语法："budget review" -draft "exact phrase"
+	Categories   []string // 命中任意一个分类
+	Priorities   []string
+	Statuses     []string
+	Since        *time.Time // created_at >= Since
+	Until        *time.Time // created_at < Until
+	SenderAddr   string     // 发件人地址（小写，完全匹配）
+	SenderDomain string     // 发件人域名（小写），同时匹配其子域名
+}
+
+// emailSearchWhere 搜索和分面计数共用的条件（$1-$9）
+// 发件人域名按 @ 之后的部分匹配：等于该域名或是其子域名（转义 LIKE 通配符，example_com 不会匹配 example-com）
+const emailSearchWhere = `
+        WHERE r.user_id = $1
+          AND ($2 = '' OR r.search_vector @@ websearch_to_tsquery('english', $2))
+          AND ($3::text[] IS NULL OR m.categories && $3::text[])
+          AND ($4::text[] IS NULL OR m.priority = ANY($4::text[]))
+          AND ($5::text[] IS NULL OR r.status::text = ANY($5::text[]))
+          AND ($6::timestamp IS NULL OR r.created_at >= $6::timestamp)
+          AND ($7::timestamp IS NULL OR r.created_at < $7::timestamp)
+          AND ($8 = '' OR lower(r.raw_json->>'from') = $8)
+          AND ($9 = '' OR split_part(lower(r.raw_json->>'from'), '@', 2) = $9
+               OR split_part(lower(r.raw_json->>'from'), '@', 2)
+                  LIKE '%.' || replace(replace(replace($9, '\', '\\'), '%', '\%'), '_', '\_') ESCAPE '\')
+`
+
+func (f EmailSearchFilter) args(userID int) []any {
+	return []any{
+		userID, f.Query, nilIfEmpty(f.Categories), nilIfEmpty(f.Priorities), nilIfEmpty(f.Statuses),
+		f.Since, f.Until, f.SenderAddr, f.SenderDomain,
+	}
+}
+
+// SearchEmails returns one page of the user's emails matching the filter, newest first,
+// with highlighted subject and body snippet. The cursor is nil for the first page.
+func (r *EmailRepository) SearchEmails(ctx context.Context, userID int, f EmailSearchFilter, cursor *EmailCursor, limit int) ([]db.EmailSearchHit, error) {
+	// 先在 CTE 中分页，ts_headline 只对当前页计算
+	query := `
+        WITH page AS (
+            SELECT r.id, r.subject, r.body, r.status::text AS status, r.created_at, r.thread_id,
+                   COALESCE(r.raw_json->>'from', '') AS sender,
+                   m.categories, m.priority, m.summary, m.decision_source
+            FROM emails_raw r
+            LEFT JOIN emails_metadata m ON m.email_id = r.id
+        ` + emailSearchWhere + `
+              AND ($10::timestamp IS NULL OR (r.created_at, r.id) < ($10::timestamp, $11::int))
+            ORDER BY r.created_at DESC, r.id DESC
+            LIMIT $12
+        )
+        SELECT p.id,
+               CASE WHEN $2 = '' THEN p.subject
+                    ELSE ts_headline('english', p.subject, websearch_to_tsquery('english', $2),
+                                     'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')
+               END,
+               CASE WHEN $2 = '' THEN left(regexp_replace(p.body, '\s+', ' ', 'g'), 200)
+                    ELSE ts_headline('english', left(p.body, 100000), websearch_to_tsquery('english', $2),
+                                     'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=" ... "')
+               END,
+               p.sender, p.status, p.created_at, p.thread_id,
+               p.categories, COALESCE(p.priority, ''), COALESCE(p.summary, ''), COALESCE(p.decision_source, '')
+        FROM page p
+        ORDER BY p.created_at DESC, p.id DESC
+    `
+
+	args := f.args(userID)
+	var cursorAt *time.Time
+	cursorID := 0
+	if cursor != nil {
+		cursorAt, cursorID = &cursor.CreatedAt, cursor.ID
+	}
+	args = append(args, cursorAt, cursorID, limit)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []db.EmailSearchHit
+	for rows.Next() {
+		var h db.EmailSearchHit
+		if err := rows.Scan(
+			&h.ID, &h.Subject, &h.Snippet, &h.From, &h.Status, &h.CreatedAt, &h.ThreadID,
+			&h.Categories, &h.Priority, &h.Summary, &h.DecisionSource,
+		); err != nil {
+			return nil, err
+		}
+		h.Subject = escapeHighlight(h.Subject)
+		h.Snippet = escapeHighlight(h.Snippet)
+		hits = append(hits, h)
+	}
+	return hits, rows.Err()
+}
+
+// SearchFacets counts all emails matching the filter (ignoring pagination)
+// per category and per priority.
+func (r *EmailRepository) SearchFacets(ctx context.Context, userID int, f EmailSearchFilter) (*db.EmailSearchFacets, error) {
+	query := `
+        WITH matched AS (
+            SELECT m.categories, m.priority
+            FROM emails_raw r
+            LEFT JOIN emails_metadata m ON m.email_id = r.id
+        ` + emailSearchWhere + `
+        )
+        SELECT 'total', '', COUNT(*) FROM matched
+        UNION ALL
+        SELECT 'category', c, COUNT(*) FROM matched, unnest(categories) AS c GROUP BY c
+        UNION ALL
+        SELECT 'priority', priority, COUNT(*) FROM matched WHERE priority IS NOT NULL GROUP BY priority
+    `
+	rows, err := r.db.Query(ctx, query, f.args(userID)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	facets := &db.EmailSearchFacets{
+		Categories: make(map[string]int),
+		Priorities: make(map[string]int),
+	}
+	for rows.Next() {
+		var kind, value string
+		var count int
+		if err := rows.Scan(&kind, &value, &count); err != nil {
+			return nil, err
+		}
+		switch kind {
+		case "total":
+			facets.Total = count
+		case "category":
+			facets.Categories[value] = count
+		case "priority":
+			facets.Priorities[value] = count
+		}
+	}
+	return facets, rows.Err()
+}
+
+// escapeHighlight 转义邮件内容中的 HTML，只保留 ts_headline 生成的 <mark> 标记
+func escapeHighlight(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, "&lt;mark&gt;", "<mark>")
+	return strings.ReplaceAll(s, "&lt;/mark&gt;", "</mark>")
+}
+
+func nilIfEmpty(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+
+	"mygoproject/contracts/db"
+	"mygoproject/pkg/emailstore"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type FeedbackRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewFeedbackRepository(db *pgxpool.Pool) *FeedbackRepository {
+	return &FeedbackRepository{db: db}
+}
+
+// GetMetadataForUpdateTx locks the current decision of an email owned by the user.
+// Returns pgx.ErrNoRows if the email does not exist or has not been classified yet.
+func (r *FeedbackRepository) GetMetadataForUpdateTx(ctx context.Context, tx pgx.Tx, emailID, userID int) (categories []string, priority, source string, err error) {
+	query := `
+        SELECT m.categories, m.priority, m.decision_source
+        FROM emails_metadata m
+        JOIN emails_raw r ON r.id = m.email_id
+        WHERE m.email_id = $1 AND r.user_id = $2
+        FOR UPDATE OF m
+    `
+	err = tx.QueryRow(ctx, query, emailID, userID).Scan(&categories, &priority, &source)
+	return categories, priority, source, err
+}
+
+// CorrectMetadataTx overwrites the decision with the user's correction. The previous decision is
+// archived to emails_metadata_history, the version is bumped, and the email is dropped from the
+// fallback re-classification queue so the correction is not overwritten.
+func (r *FeedbackRepository) CorrectMetadataTx(ctx context.Context, tx pgx.Tx, emailID int, categories []string, priority string) error {
+	if err := emailstore.ArchiveMetadataTx(ctx, tx, emailID); err != nil {
+		return err
+	}
+
+	query := `
+        UPDATE emails_metadata
+        SET categories = $2, priority = $3, decision_source = 'user', version = version + 1, updated_at = NOW()
+        WHERE email_id = $1
+    `
+	if _, err := tx.Exec(ctx, query, emailID, categories, priority); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(ctx, `DELETE FROM reclassify_queue WHERE email_id = $1`, emailID)
+	return err
+}
+
+// FindActionTx returns the titles of the tasks or the message of the notification created for an email.
+// Returns pgx.ErrNoRows if the email has no such action.
+func (r *FeedbackRepository) FindActionTx(ctx context.Context, tx pgx.Tx, feedbackType string, emailID, userID int) (string, error) {
+	var query string
+	switch feedbackType {
+	case db.FeedbackTask:
+		// 一封邮件可能提取出多个任务
+		query = `SELECT string_agg(title, '; ' ORDER BY id) FROM tasks WHERE email_id = $1 AND user_id = $2 HAVING COUNT(*) > 0`
+	case db.FeedbackNotification:
+		query = `SELECT message FROM notifications WHERE email_id = $1 AND user_id = $2 ORDER BY id DESC LIMIT 1`
+	default:
+		return "", pgx.ErrNoRows
+	}
+
+	var detail string
+	err := tx.QueryRow(ctx, query, emailID, userID).Scan(&detail)
+	return detail, err
+}
+
+// InsertTx records a feedback entry and returns its id.
+func (r *FeedbackRepository) InsertTx(ctx context.Context, tx pgx.Tx, f *db.EmailFeedback) (int, error) {
+	query := `
+        INSERT INTO email_feedback
+            (user_id, email_id, feedback_type, original_categories, original_priority, original_source,
+             corrected_categories, corrected_priority, detail, comment)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10)
+        RETURNING id
+    `
+	var id int
+	err := tx.QueryRow(ctx, query,
+		f.UserID, f.EmailID, f.FeedbackType, f.OriginalCategories, f.OriginalPriority, f.OriginalSource,
+		f.CorrectedCategories, f.CorrectedPriority, f.Detail, f.Comment,
+	).Scan(&id)
+	return id, err
+}
+
+// ListByEmail returns all feedback on an email owned by the user, newest first.
+func (r *FeedbackRepository) ListByEmail(ctx context.Context, emailID, userID int) ([]db.EmailFeedback, error) {
+	query := `
+        SELECT id, user_id, email_id, feedback_type, original_categories, original_priority, original_source,
+               COALESCE(corrected_categories, '{}'), COALESCE(corrected_priority, ''), detail, comment, created_at
+        FROM email_feedback
+        WHERE email_id = $1 AND user_id = $2
+        ORDER BY id DESC
+    `
+	rows, err := r.db.Query(ctx, query, emailID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []db.EmailFeedback{}
+	for rows.Next() {
+		var f db.EmailFeedback
+		if err := rows.Scan(
+			&f.ID, &f.UserID, &f.EmailID, &f.FeedbackType, &f.OriginalCategories, &f.OriginalPriority, &f.OriginalSource,
+			&f.CorrectedCategories, &f.CorrectedPriority, &f.Detail, &f.Comment, &f.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		result = append(result, f)
+	}
+	return result, rows.Err()
+}
+
+// CategoryPrecision returns per-category precision of the AI decisions of a user.
+// For corrected emails the first (AI) decision is compared with the latest correction.
+func (r *FeedbackRepository) CategoryPrecision(ctx context.Context, userID int) ([]db.CategoryPrecision, error) {
+	query := `
+        WITH decisions AS (
+            SELECT
+                COALESCE(first_fb.original_categories, m.categories) AS predicted,
+                last_fb.corrected_categories AS corrected
+            FROM emails_metadata m
+            JOIN emails_raw r ON r.id = m.email_id
+            LEFT JOIN LATERAL (
+                SELECT original_categories FROM email_feedback
+                WHERE email_id = m.email_id AND feedback_type = 'classification'
+                ORDER BY id ASC LIMIT 1
+            ) first_fb ON TRUE
+            LEFT JOIN LATERAL (
+                SELECT corrected_categories FROM email_feedback
+                WHERE email_id = m.email_id AND feedback_type = 'classification'
+                ORDER BY id DESC LIMIT 1
+            ) last_fb ON TRUE
+            WHERE r.user_id = $1
+              AND (m.decision_source <> 'user' OR first_fb.original_categories IS NOT NULL)
+        )
+        SELECT
+            category,
+            COUNT(*),
+            COUNT(*) FILTER (WHERE corrected IS NOT NULL AND NOT (category = ANY(corrected)))
+        FROM decisions, unnest(predicted) AS category
+        GROUP BY category
+        ORDER BY COUNT(*) DESC, category
+    `
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []db.CategoryPrecision{}
+	for rows.Next() {
+		var p db.CategoryPrecision
+		if err := rows.Scan(&p.Category, &p.Predicted, &p.Corrected); err != nil {
+			return nil, err
+		}
+		p.Precision = precision(p.Predicted, p.Corrected)
+		result = append(result, p)
+	}
+	return result, rows.Err()
+}
+
+// ActionPrecision returns how many tasks / notifications were created from emails of the user
+// and how many of those emails were marked as wrong.
+func (r *FeedbackRepository) ActionPrecision(ctx context.Context, userID int, feedbackType string) (db.ActionPrecision, error) {
+	var table string
+	switch feedbackType {
+	case db.FeedbackTask:
+		table = "tasks"
+	case db.FeedbackNotification:
+		table = "notifications"
+	default:
+		return db.ActionPrecision{}, pgx.ErrNoRows
+	}
+
+	query := `
+        SELECT
+            (SELECT COUNT(DISTINCT email_id) FROM ` + table + ` WHERE user_id = $1 AND email_id IS NOT NULL),
+            (SELECT COUNT(DISTINCT email_id) FROM email_feedback WHERE user_id = $1 AND feedback_type = $2)
+    `
+	var p db.ActionPrecision
+	if err := r.db.QueryRow(ctx, query, userID, feedbackType).Scan(&p.Total, &p.Wrong); err != nil {
+		return p, err
+	}
+	p.Precision = precision(p.Total, p.Wrong)
+	return p, nil
+}
+
+// precision 返回 (total - wrong) / total，没有样本时为 1
+func precision(total, wrong int) float64 {
+	if total == 0 {
+		return 1
+	}
+	if wrong > total {
+		wrong = total
+	}
+	return float64(total-wrong) / float64(total)
+}
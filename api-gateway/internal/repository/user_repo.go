@@ -42,3 +42,43 @@ func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*model.
 	return &u, nil
 }
 
+// GetTimezone returns the IANA timezone name of the user.
+func (r *UserRepository) GetTimezone(ctx context.Context, userID int) (string, error) {
+	var tz string
+	err := r.db.QueryRow(ctx, `SELECT timezone FROM users WHERE id = $1`, userID).Scan(&tz)
+	return tz, err
+}
+
+// UserSettings 用户可修改的设置
+type UserSettings struct {
+	Timezone      string `json:"timezone"`
+	DecisionCache bool   `json:"decision_cache"` // 是否允许模板化邮件复用缓存的 agent 决策
+}
+
+// GetSettings returns the user's settings.
+func (r *UserRepository) GetSettings(ctx context.Context, userID int) (*UserSettings, error) {
+	var s UserSettings
+	err := r.db.QueryRow(ctx,
+		`SELECT timezone, decision_cache_enabled FROM users WHERE id = $1`, userID,
+	).Scan(&s.Timezone, &s.DecisionCache)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UpdateSettings updates the given settings (nil fields are left unchanged) and returns the result.
+func (r *UserRepository) UpdateSettings(ctx context.Context, userID int, timezone *string, decisionCache *bool) (*UserSettings, error) {
+	var s UserSettings
+	err := r.db.QueryRow(ctx, `
+        UPDATE users
+        SET timezone = COALESCE($2, timezone),
+            decision_cache_enabled = COALESCE($3, decision_cache_enabled)
+        WHERE id = $1
+        RETURNING timezone, decision_cache_enabled
+    `, userID, timezone, decisionCache).Scan(&s.Timezone, &s.DecisionCache)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
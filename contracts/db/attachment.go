@@ -0,0 +1,18 @@
+package db
+
+import "time"
+
+// EmailAttachment 表示 email_attachments 表
+type EmailAttachment struct {
+	ID          int       `json:"id"`
+	EmailID     int       `json:"email_id"`
+	UserID      int       `json:"user_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	SHA256      string    `json:"sha256"`
+	StorageKey  string    `json:"-"`
+	ContentID   string    `json:"content_id,omitempty"`
+	IsInline    bool      `json:"is_inline"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,56 @@
+package db
+
+import "time"
+
+// ClassificationRule 表示 classification_rules 表（调用 agent 之前评估的用户级分类规则）
+type ClassificationRule struct {
+	ID         int            `json:"id"`
+	UserID     int            `json:"user_id"`
+	Name       string         `json:"name"`
+	Position   int            `json:"position"` // 评估顺序（升序）
+	Enabled    bool           `json:"enabled"`
+	Decisive   bool           `json:"decisive"` // 命中后不再评估后续规则，也不调用 agent
+	Conditions RuleConditions `json:"conditions"`
+	Actions    RuleActions    `json:"actions"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// RuleConditions 规则条件，所有非空条件同时满足才命中
+type RuleConditions struct {
+	Sender       string                `json:"sender,omitempty"`        // 完整地址，或域名（同时匹配子域名）
+	SubjectRegex string                `json:"subject_regex,omitempty"` // 不区分大小写
+	BodyKeywords []string              `json:"body_keywords,omitempty"` // 包含任意一个即满足（不区分大小写）
+	Headers      []RuleHeaderCondition `json:"headers,omitempty"`       // 每一项都需满足
+}
+
+// RuleHeaderCondition 邮件头条件，Pattern 为空时只要求邮件头存在
+type RuleHeaderCondition struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern,omitempty"` // 正则，不区分大小写
+}
+
+// RuleActions 规则动作
+type RuleActions struct {
+	Categories []string          `json:"categories,omitempty"`
+	Priority   string            `json:"priority,omitempty"` // HIGH / MEDIUM / LOW
+	CreateTask *RuleTaskAction   `json:"create_task,omitempty"`
+	Notify     *RuleNotifyAction `json:"notify,omitempty"`
+}
+
+type RuleTaskAction struct {
+	Title     string `json:"title"`
+	DueInDays int    `json:"due_in_days"`
+}
+
+type RuleNotifyAction struct {
+	Channel string `json:"channel"` // EMAIL / PUSH / SMS / WEBHOOK
+	Message string `json:"message"`
+}
+
+// RuleHit 记录在 emails_metadata.rule_hits 中的规则命中信息
+type RuleHit struct {
+	RuleID   int    `json:"rule_id"`
+	Name     string `json:"name"`
+	Decisive bool   `json:"decisive"`
+}
@@ -0,0 +1,18 @@
+package db
+
+import "time"
+
+// Contact 表示 contacts 表：按发件人聚合的历史统计
+type Contact struct {
+	ID            int        `json:"id"`
+	UserID        int        `json:"user_id"`
+	Address       string     `json:"address"`
+	DisplayName   string     `json:"display_name,omitempty"`
+	MessageCount  int        `json:"message_count"`
+	AvgPriority   *float64   `json:"avg_priority,omitempty"` // LOW=1, MEDIUM=2, HIGH=3
+	TaskRate      float64    `json:"task_rate"`              // 已分类邮件中产生任务的比例
+	LastContactAt *time.Time `json:"last_contact_at,omitempty"`
+	IsVIP         bool       `json:"is_vip"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
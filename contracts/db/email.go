@@ -11,6 +11,12 @@ type Email struct {
 	RawJSON   string    `json:"raw_json"`
 	Status    string    `json:"status"`
 	CreatedAt time.Time `json:"created_at"`
+
+	MessageID      string `json:"message_id,omitempty"`      // 去重键：RFC 5322 Message-ID
+	IdempotencyKey string `json:"idempotency_key,omitempty"` // 去重键：HTTP Idempotency-Key
+	ThreadID       *int   `json:"thread_id,omitempty"`
+	SpamScore      int    `json:"spam_score"`
+	FilterReason   string `json:"filter_reason,omitempty"` // blocked / spam / skip_agent
 }
 
 // EmailWithMetadata 表示带元数据的邮件（用于查询结果）
@@ -18,11 +24,61 @@ type EmailWithMetadata struct {
 	ID         int       `json:"id"`
 	UserID     int       `json:"user_id,omitempty"`
 	Subject    string    `json:"subject"`
-	Body       string    `json:"body"`
+	Body       string    `json:"body,omitempty"` // include_body=false 时为空
 	Status     string    `json:"status"`
 	CreatedAt  time.Time `json:"created_at"`
 	Categories []string  `json:"categories,omitempty"`
 	Priority   string    `json:"priority,omitempty"`
 	Summary    string    `json:"summary,omitempty"`
+	ThreadID   *int      `json:"thread_id,omitempty"`
+	RuleHits   []RuleHit `json:"rule_hits,omitempty"` // 命中的分类规则
+
+	DecisionSource  string `json:"decision_source,omitempty"`  // agent / rules / fallback / unknown / user
+	MetadataVersion int    `json:"metadata_version,omitempty"` // 重新分类后递增
+
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+}
+
+// EmailDetail 表示单封邮件详情（GET /emails/:id）
+type EmailDetail struct {
+	EmailWithMetadata
+	From         string   `json:"from,omitempty"`
+	To           []string `json:"to,omitempty"`
+	Cc           []string `json:"cc,omitempty"`
+	MessageID    string   `json:"message_id,omitempty"`
+	FilterReason string   `json:"filter_reason,omitempty"`
+
+	Attachments []EmailAttachment `json:"attachments"`
+	Tasks       []EmailTaskRef    `json:"tasks"` // 由该邮件创建的任务
 }
 
+// EmailTaskRef 表示由邮件创建的任务
+type EmailTaskRef struct {
+	ID       int        `json:"id"`
+	Title    string     `json:"title"`
+	Status   string     `json:"status"`
+	Priority string     `json:"priority,omitempty"`
+	DueDate  *time.Time `json:"due_date,omitempty"`
+}
+
+// EmailSearchHit 表示一条搜索结果（不含完整正文）
+type EmailSearchHit struct {
+	ID             int       `json:"id"`
+	Subject        string    `json:"subject"` // 命中的词用 <mark></mark> 标记
+	Snippet        string    `json:"snippet"` // 正文片段，命中的词用 <mark></mark> 标记
+	From           string    `json:"from,omitempty"`
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"created_at"`
+	ThreadID       *int      `json:"thread_id,omitempty"`
+	Categories     []string  `json:"categories,omitempty"`
+	Priority       string    `json:"priority,omitempty"`
+	Summary        string    `json:"summary,omitempty"`
+	DecisionSource string    `json:"decision_source,omitempty"`
+}
+
+// EmailSearchFacets 表示全部匹配结果（不分页）按分类和优先级的计数
+type EmailSearchFacets struct {
+	Total      int            `json:"total"`
+	Categories map[string]int `json:"categories"`
+	Priorities map[string]int `json:"priorities"`
+}
@@ -0,0 +1,48 @@
+package db
+
+import "time"
+
+// 反馈类型
+const (
+	FeedbackClassification = "classification" // 修改分类 / 优先级
+	FeedbackTask           = "task"           // 自动创建的任务是错误的
+	FeedbackNotification   = "notification"   // 发送的通知是错误的
+)
+
+// EmailFeedback 表示 email_feedback 表（用户对 AI 决策的修正）
+type EmailFeedback struct {
+	ID                  int       `json:"id"`
+	UserID              int       `json:"user_id"`
+	EmailID             int       `json:"email_id"`
+	FeedbackType        string    `json:"feedback_type"`
+	OriginalCategories  []string  `json:"original_categories"`
+	OriginalPriority    string    `json:"original_priority"`
+	OriginalSource      string    `json:"original_source"`
+	CorrectedCategories []string  `json:"corrected_categories,omitempty"`
+	CorrectedPriority   string    `json:"corrected_priority,omitempty"`
+	Detail              string    `json:"detail,omitempty"`
+	Comment             string    `json:"comment,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// CategoryPrecision 单个分类的准确率：AI 给出该分类的邮件中未被用户移除的比例
+type CategoryPrecision struct {
+	Category  string  `json:"category"`
+	Predicted int     `json:"predicted"` // AI 给出该分类的邮件数
+	Corrected int     `json:"corrected"` // 用户修正时移除了该分类的邮件数
+	Precision float64 `json:"precision"`
+}
+
+// FeedbackStats 用户反馈统计
+type FeedbackStats struct {
+	Categories    []CategoryPrecision `json:"categories"`
+	Tasks         ActionPrecision     `json:"tasks"`
+	Notifications ActionPrecision     `json:"notifications"`
+}
+
+// ActionPrecision 自动创建的任务 / 发送的通知中未被标记为错误的比例
+type ActionPrecision struct {
+	Total     int     `json:"total"`
+	Wrong     int     `json:"wrong"`
+	Precision float64 `json:"precision"`
+}
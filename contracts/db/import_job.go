@@ -0,0 +1,21 @@
+package db
+
+import "time"
+
+// ImportJob 表示 import_jobs 表（mbox / zip 批量导入任务）
+type ImportJob struct {
+	ID           int        `json:"id"`
+	UserID       int        `json:"user_id"`
+	Filename     string     `json:"filename"`
+	Format       string     `json:"format"`
+	Status       string     `json:"status"`
+	Total        int        `json:"total"`
+	Ingested     int        `json:"ingested"`
+	Duplicates   int        `json:"duplicates"`
+	Failed       int        `json:"failed"`
+	ErrorMessage *string    `json:"error_message,omitempty"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,22 @@
+package db
+
+import "time"
+
+// Mailbox 表示 mailboxes 表（用户配置的外部 IMAP 邮箱）
+type Mailbox struct {
+	ID                int        `json:"id"`
+	UserID            int        `json:"user_id"`
+	Host              string     `json:"host"`
+	Port              int        `json:"port"`
+	UseTLS            bool       `json:"use_tls"`
+	Username          string     `json:"username"`
+	PasswordEncrypted string     `json:"-"` // AES-GCM 加密后的密码，永不返回给客户端
+	Folder            string     `json:"folder"`
+	UIDValidity       *int64     `json:"uid_validity,omitempty"`
+	LastUID           int64      `json:"last_uid"`
+	LastSyncAt        *time.Time `json:"last_sync_at,omitempty"`
+	LastError         *string    `json:"last_error,omitempty"`
+	IsActive          bool       `json:"is_active"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
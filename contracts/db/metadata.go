@@ -12,3 +12,15 @@ type EmailMetadata struct {
 	UpdatedAt  time.Time `json:"updated_at"`
 }
 
+// EmailMetadataHistory 表示 emails_metadata_history 表（重新分类前的历史决策）
+type EmailMetadataHistory struct {
+	EmailID        int       `json:"email_id"`
+	Version        int       `json:"version"`
+	Categories     []string  `json:"categories"`
+	Priority       string    `json:"priority"`
+	Summary        string    `json:"summary"`
+	RuleHits       []RuleHit `json:"rule_hits"`
+	DecisionSource string    `json:"decision_source"`
+	DecidedAt      time.Time `json:"decided_at"`
+	ArchivedAt     time.Time `json:"archived_at"`
+}
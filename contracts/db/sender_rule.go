@@ -0,0 +1,29 @@
+package db
+
+import "time"
+
+// 发件人规则匹配方式
+const (
+	SenderMatchAddress = "address"
+	SenderMatchDomain  = "domain" // 同时匹配子域名
+)
+
+// 发件人规则动作
+const (
+	SenderActionAllow         = "allow"          // 白名单：跳过垃圾邮件评分
+	SenderActionBlock         = "block"          // 拦截：入库但不发布 email.received.* 事件
+	SenderActionSkipAgent     = "skip_agent"     // 不调用 AI 决策，其余流程照常
+	SenderActionForcePriority = "force_priority" // 照常决策，但覆盖优先级
+)
+
+// SenderRule 表示 sender_rules 表（入库前评估的用户级发件人规则）
+type SenderRule struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	MatchType string    `json:"match_type"`
+	Pattern   string    `json:"pattern"`
+	Action    string    `json:"action"`
+	Priority  *string   `json:"priority,omitempty"` // force_priority 时为 HIGH / MEDIUM / LOW
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,14 @@
+package db
+
+import "time"
+
+// EmailThread 表示 email_threads 表（会话线程）
+type EmailThread struct {
+	ID                int       `json:"id"`
+	UserID            int       `json:"user_id,omitempty"`
+	Subject           string    `json:"subject"`
+	NormalizedSubject string    `json:"-"`
+	MessageCount      int       `json:"message_count"`
+	LastMessageAt     time.Time `json:"last_message_at"`
+	CreatedAt         time.Time `json:"created_at"`
+}
@@ -0,0 +1,27 @@
+package mq
+
+import "time"
+
+// 删除邮件时对关联任务的处理方式
+const (
+	EmailDeleteKeepTasks   = "keep"   // 保留任务，解除与邮件的关联（email_id 置为 NULL）
+	EmailDeleteDeleteTasks = "delete" // 删除未完成的任务，已完成的任务保留并解除关联
+)
+
+// EmailDeletedPayload email.deleted 事件的 payload
+// 事件发布时邮件、元数据、附件记录和应用内通知已删除
+type EmailDeletedPayload struct {
+	EmailID   int       `json:"email_id"`
+	UserID    int       `json:"user_id"`
+	ThreadID  int       `json:"thread_id,omitempty"`
+	DeletedAt time.Time `json:"deleted_at"`
+	TraceID   string    `json:"trace_id,omitempty"`
+
+	TaskPolicy           string `json:"task_policy"` // keep / delete
+	DeletedTaskIDs       []int  `json:"deleted_task_ids,omitempty"`
+	DetachedTaskIDs      []int  `json:"detached_task_ids,omitempty"`
+	DeletedNotifications int    `json:"deleted_notifications,omitempty"`
+
+	// 附件在 blob store 中的 key（内容寻址，可能被其他邮件引用），由 mail-ingestion-service 回收
+	AttachmentKeys []string `json:"attachment_keys,omitempty"`
+}
@@ -10,4 +10,33 @@ type EmailReceivedPayload struct {
 	Body       string    `json:"body"`
 	ReceivedAt time.Time `json:"received_at"`
 	TraceID    string    `json:"trace_id,omitempty"`
+	ThreadID   int       `json:"thread_id,omitempty"` // 所属会话线程
+
+	// 邮件头信息（来自 RFC 5322 解析，模拟邮件时为空）
+	From       string     `json:"from,omitempty"`
+	To         []string   `json:"to,omitempty"`
+	Cc         []string   `json:"cc,omitempty"`
+	MessageID  string     `json:"message_id,omitempty"`
+	InReplyTo  string     `json:"in_reply_to,omitempty"`
+	References []string   `json:"references,omitempty"`
+	SentAt     *time.Time `json:"sent_at,omitempty"` // Date 头
+
+	// 附件摘要（内容存储在 mail-ingestion-service 的 blob store）
+	Attachments []AttachmentSummary `json:"attachments,omitempty"`
+
+	// 入库前过滤结果
+	ForcePriority string `json:"force_priority,omitempty"` // 发件人规则指定的优先级，覆盖 AI 决策
+	SpamScore     int    `json:"spam_score,omitempty"`
+
+	// 重新分类请求的标识（参与去重键），为空表示首次分类
+	ReclassifyToken string `json:"reclassify_token,omitempty"`
+}
+
+// AttachmentSummary 附件摘要
+type AttachmentSummary struct {
+	ID          int    `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
 }
@@ -1,19 +1,31 @@
 package mq
 
+import "time"
+
+// 截止时间：生产者按用户时区解析为绝对时间（due_at，ISO-8601 / RFC 3339），
+// 消费者直接使用，消息在队列或 DLQ 中停留不会推迟截止时间。
+// due_in_days 仅用于兼容旧事件（没有 due_at 时按消费时间计算）。
+
 type TaskCreatedPayload struct {
-	EmailID   int    `json:"email_id"`
-	UserID    int    `json:"user_id"`
-	Title     string `json:"title"`
-	DueInDays int    `json:"due_in_days"`
-	TraceID   string `json:"trace_id,omitempty"`
+	EmailID   int        `json:"email_id"`
+	UserID    int        `json:"user_id"`
+	Title     string     `json:"title"`
+	DueInDays int        `json:"due_in_days"`
+	DueAt     *time.Time `json:"due_at,omitempty"`
+	Priority  string     `json:"priority,omitempty"` // LOW / MEDIUM / HIGH
+	TraceID   string     `json:"trace_id,omitempty"`
 }
 
 type TaskItem struct {
-	Title     string `json:"title"`
-	DueInDays int    `json:"due_in_days"`
+	Title     string     `json:"title"`
+	DueInDays int        `json:"due_in_days"`
+	DueAt     *time.Time `json:"due_at,omitempty"`
+	Priority  string     `json:"priority,omitempty"` // LOW / MEDIUM / HIGH
 }
 
+// TaskBulkCreatedPayload 一次创建多个任务：文本转任务（EmailID 为 0），或从一封邮件中提取的所有任务
 type TaskBulkCreatedPayload struct {
+	EmailID int        `json:"email_id,omitempty"`
 	UserID  int        `json:"user_id"`
 	Tasks   []TaskItem `json:"tasks"`
 	TraceID string     `json:"trace_id,omitempty"`
@@ -27,10 +39,11 @@ type HabitCreatedPayload struct {
 }
 
 type ProjectTask struct {
-	Title     string   `json:"title"`
-	DueInDays int      `json:"due_in_days"`
-	Priority  string   `json:"priority"`   // LOW / MEDIUM / HIGH
-	DependsOn []string `json:"depends_on"` // List of task titles this task depends on
+	Title     string     `json:"title"`
+	DueInDays int        `json:"due_in_days"`
+	DueAt     *time.Time `json:"due_at,omitempty"`
+	Priority  string     `json:"priority"`   // LOW / MEDIUM / HIGH
+	DependsOn []string   `json:"depends_on"` // List of task titles this task depends on
 }
 
 type Milestone struct {
@@ -63,9 +76,10 @@ type TaskUnlockedPayload struct {
 }
 
 type HabitTaskGeneratedPayload struct {
-	HabitID int    `json:"habit_id"`
-	UserID  int    `json:"user_id"`
-	Title   string `json:"title"`
-	DueDate string `json:"due_date"` // YYYY-MM-DD format
-	TraceID string `json:"trace_id,omitempty"`
+	HabitID int        `json:"habit_id"`
+	UserID  int        `json:"user_id"`
+	Title   string     `json:"title"`
+	DueDate string     `json:"due_date"`         // YYYY-MM-DD format（用户时区的日期）
+	DueAt   *time.Time `json:"due_at,omitempty"` // 用户时区当天结束
+	TraceID string     `json:"trace_id,omitempty"`
 }
@@ -14,8 +14,11 @@ import (
 	"mygoproject/pkg/outbox"
 	"mygoproject/pkg/redis"
 	"mygoproject/pkg/util"
+	"email-processor-service/internal/classifier"
 	"email-processor-service/internal/config"
+	"email-processor-service/internal/decider"
 	"email-processor-service/internal/mqhandler"
+	"email-processor-service/internal/reclassify"
 	"email-processor-service/internal/repository"
 	"email-processor-service/internal/service"
 
@@ -64,10 +67,30 @@ func main() {
 	// repositories
 	emailRepo := repository.NewEmailRepository(dbConn)
 	metadataRepo := repository.NewMetadataRepository(dbConn)
+	ruleRepo := repository.NewClassificationRuleRepository(dbConn)
+	reclassifyRepo := repository.NewReclassifyRepository(dbConn)
+	feedbackRepo := repository.NewFeedbackRepository(dbConn)
+	userRepo := repository.NewUserRepository(dbConn)
+	contactRepo := repository.NewContactRepository(dbConn)
 	notiLogRepo := repository.NewNotificationLogRepository(dbConn)
 
-	// agent client
-	agentClient := service.NewAgentClient(cfg.AgentServiceURL)
+	// 决策后端（agent_backend.type：http / openai / rules / fake / stub）
+	fallbackClassifier := classifier.NewClassifier(metadataRepo, logger)
+	backend, closeBackend, err := decider.New(cfg.AgentBackend, cfg.AgentServiceURL, fallbackClassifier, logger)
+	if err != nil {
+		logger.Fatal("failed to init agent backend", zap.Error(err))
+	}
+	logger.Info("Agent backend ready", zap.String("type", cfg.AgentBackend.Type))
+
+	// agent client（不可用时使用基于用户历史决策的离线分类器；用户最近的修正作为 few-shot 示例；
+	// 模板化邮件按内容指纹复用缓存的决策）
+	agentClient := service.NewAgentClient(backend).
+		WithFallback(fallbackClassifier).
+		WithFeedback(feedbackRepo)
+	if cfg.DecisionCache.Enabled && cfg.DecisionCache.TTLSeconds > 0 {
+		ttl := time.Duration(cfg.DecisionCache.TTLSeconds) * time.Second
+		agentClient.WithCache(service.NewDecisionCache(rdb, ttl, logger))
+	}
 
 	// task publisher (also used for notification events)
 	taskPublisher, err := mq.NewPublisher(cfg.MQ.URL)
@@ -81,6 +104,10 @@ func main() {
 		dbConn,
 		emailRepo,
 		metadataRepo,
+		ruleRepo,
+		reclassifyRepo,
+		userRepo,
+		contactRepo,
 		agentClient,
 		retryCounter,
 		deduper,
@@ -93,9 +120,20 @@ func main() {
 	dispatcher := outbox.NewDispatcher(outboxRepo, taskPublisher, logger)
 	go dispatcher.Start(context.Background())
 
+	// Init Re-classification Worker（agent 恢复后重新分类离线决策）
+	reclassifyWorker := reclassify.NewWorker(dbConn, emailRepo, reclassifyRepo, agentClient, logger)
+	if cfg.Reclassify.IntervalSeconds > 0 {
+		reclassifyWorker.WithInterval(time.Duration(cfg.Reclassify.IntervalSeconds) * time.Second)
+	}
+	if cfg.Reclassify.BatchSize > 0 {
+		reclassifyWorker.WithBatchSize(cfg.Reclassify.BatchSize)
+	}
+	go reclassifyWorker.Start(context.Background())
+
 	notiLogHandler := mqhandler.NewEmailReceivedNotificationLogHandler(notiLogRepo, logger)
 	// NotificationHandler now publishes notification.created events (handled by notification-service)
-	notiHandler := mqhandler.NewEmailReceivedNotificationHandler(taskPublisher, logger, deduper)
+	notiHandler := mqhandler.NewEmailReceivedNotificationHandler(taskPublisher, logger, deduper).
+		WithContacts(contactRepo)
 
 	// -------------------------
 	// Agent Decision Consumer
@@ -110,7 +148,18 @@ func main() {
 	if err != nil {
 		logger.Fatal("Agent consumer init failed", zap.Error(err))
 	}
-	consumerAgent.SetHandler(agentHandler.Handle)
+	if cfg.AgentBatch.Enabled {
+		// micro-batching：一批邮件调用一次 /decide-batch，每封邮件单独提交事务并 ack
+		batchSize := min(max(cfg.AgentBatch.MaxSize, 1), service.MaxDecideBatchSize)
+		batchWait := time.Duration(cfg.AgentBatch.MaxWaitMs) * time.Millisecond
+		consumerAgent.SetBatchHandler(agentHandler.HandleBatch, batchSize, batchWait)
+		logger.Info("Agent consumer batching enabled",
+			zap.Int("max_size", batchSize),
+			zap.Duration("max_wait", batchWait),
+		)
+	} else {
+		consumerAgent.SetHandler(agentHandler.Handle)
+	}
 
 	go func() {
 		if err := consumerAgent.StartConsuming(); err != nil {
@@ -176,6 +225,14 @@ func main() {
 	consumerNotiLog.Stop()
 	consumerNoti.Stop()
 
+	// 停止决策后端（stub 模式下的进程内 HTTP 服务）
+	logger.Info("Closing agent backend...")
+	closeCtx, cancelClose := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := closeBackend(closeCtx); err != nil {
+		logger.Warn("Failed to close agent backend", zap.Error(err))
+	}
+	cancelClose()
+
 	// 关闭数据库连接
 	logger.Info("Closing database connection...")
 	dbConn.Close()
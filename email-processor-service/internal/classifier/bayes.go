@@ -0,0 +1,153 @@
+package classifier
+
+import (
+	"math"
+	"sort"
+
+	"email-processor-service/internal/model"
+)
+
+// naiveBayes 多项式朴素贝叶斯（Laplace 平滑）
+type naiveBayes struct {
+	docs        map[string]int            // 每个类别的文档数
+	tokenCounts map[string]map[string]int // 类别 → 词 → 次数
+	totalTokens map[string]int            // 类别 → 词总数
+	vocab       map[string]bool
+	totalDocs   int
+}
+
+func newNaiveBayes() *naiveBayes {
+	return &naiveBayes{
+		docs:        make(map[string]int),
+		tokenCounts: make(map[string]map[string]int),
+		totalTokens: make(map[string]int),
+		vocab:       make(map[string]bool),
+	}
+}
+
+func (nb *naiveBayes) add(label string, tokens []string) {
+	nb.docs[label]++
+	nb.totalDocs++
+	counts := nb.tokenCounts[label]
+	if counts == nil {
+		counts = make(map[string]int)
+		nb.tokenCounts[label] = counts
+	}
+	for _, t := range tokens {
+		counts[t]++
+		nb.totalTokens[label]++
+		nb.vocab[t] = true
+	}
+}
+
+// logScore 类别的对数后验（未归一化）
+func (nb *naiveBayes) logScore(label string, tokens []string) float64 {
+	score := math.Log(float64(nb.docs[label]) / float64(nb.totalDocs))
+	denominator := float64(nb.totalTokens[label] + len(nb.vocab))
+	counts := nb.tokenCounts[label]
+	for _, t := range tokens {
+		score += math.Log(float64(counts[t]+1) / denominator)
+	}
+	return score
+}
+
+// best 返回得分最高的类别，没有训练数据时返回空
+func (nb *naiveBayes) best(tokens []string) string {
+	labels := make([]string, 0, len(nb.docs))
+	for label := range nb.docs {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels) // 保证同分时结果稳定
+
+	best, bestScore := "", math.Inf(-1)
+	for _, label := range labels {
+		if s := nb.logScore(label, tokens); s > bestScore {
+			best, bestScore = label, s
+		}
+	}
+	return best
+}
+
+// userModel 单个用户的模型：优先级为多分类，每个分类标签为一个二分类（邮件可以有多个分类）
+type userModel struct {
+	priority   *naiveBayes
+	categories map[string]*naiveBayes // 分类 → {yes, no}
+	samples    int
+}
+
+const (
+	labelYes = "yes"
+	labelNo  = "no"
+
+	// minCategoryDocs 分类在训练集中至少出现的次数，太少时不预测
+	minCategoryDocs = 2
+)
+
+func train(samples []model.TrainingSample) *userModel {
+	m := &userModel{
+		priority:   newNaiveBayes(),
+		categories: make(map[string]*naiveBayes),
+		samples:    len(samples),
+	}
+
+	tokenized := make([][]string, len(samples))
+	seen := make(map[string]int)
+	for i, s := range samples {
+		tokenized[i] = tokenize(s.Subject + " " + s.Body)
+		if s.Priority != "" {
+			m.priority.add(s.Priority, tokenized[i])
+		}
+		for _, c := range uniqueStrings(s.Categories) {
+			seen[c]++
+		}
+	}
+
+	for category, n := range seen {
+		if n < minCategoryDocs || category == "unknown" {
+			continue
+		}
+		nb := newNaiveBayes()
+		for i, s := range samples {
+			if containsString(s.Categories, category) {
+				nb.add(labelYes, tokenized[i])
+			} else {
+				nb.add(labelNo, tokenized[i])
+			}
+		}
+		m.categories[category] = nb
+	}
+	return m
+}
+
+// predict 返回预测的分类（按名称排序）和优先级
+func (m *userModel) predict(tokens []string) ([]string, string) {
+	var categories []string
+	for category, nb := range m.categories {
+		if nb.docs[labelNo] == 0 || nb.logScore(labelYes, tokens) > nb.logScore(labelNo, tokens) {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	return categories, m.priority.best(tokens)
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	out := values[:0:0]
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package classifier
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"email-processor-service/internal/model"
+	"email-processor-service/internal/service"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMaxSamples = 500              // 每个用户最多使用的历史决策数
+	defaultMinSamples = 20               // 少于该数量时只使用关键词规则
+	defaultModelTTL   = 10 * time.Minute // 模型缓存时间
+)
+
+// SampleSource 提供用户的历史决策（emails_metadata）作为训练数据
+type SampleSource interface {
+	ListTrainingSamples(ctx context.Context, userID, limit int) ([]model.TrainingSample, error)
+}
+
+type cachedModel struct {
+	model     *userModel
+	trainedAt time.Time
+}
+
+// Classifier 离线分类器：agent-service 不可用时，基于用户历史决策的朴素贝叶斯模型
+// 给出分类、优先级和截止时间猜测；历史数据不足时退化为关键词规则
+type Classifier struct {
+	source SampleSource
+	logger *zap.Logger
+
+	maxSamples int
+	minSamples int
+	ttl        time.Duration
+	now        func() time.Time
+
+	mu     sync.Mutex
+	models map[int]*cachedModel
+}
+
+func NewClassifier(source SampleSource, logger *zap.Logger) *Classifier {
+	return &Classifier{
+		source:     source,
+		logger:     logger,
+		maxSamples: defaultMaxSamples,
+		minSamples: defaultMinSamples,
+		ttl:        defaultModelTTL,
+		now:        time.Now,
+		models:     make(map[int]*cachedModel),
+	}
+}
+
+// Classify 实现 service.FallbackClassifier
+func (c *Classifier) Classify(ctx context.Context, email service.EmailInput) (*model.AgentDecision, error) {
+	text := strings.ToLower(email.Subject + "\n" + email.Body)
+
+	categories := keywordCategories(text)
+	priority := keywordPriority(text)
+
+	m, err := c.userModel(ctx, email.UserID)
+	if err != nil {
+		// 训练数据读取失败时仍然使用关键词规则
+		c.logger.Warn("Failed to load training samples, using keyword heuristics",
+			zap.Int("user_id", email.UserID),
+			zap.Error(err),
+		)
+	} else if m.samples >= c.minSamples {
+		predicted, predictedPriority := m.predict(tokenize(text))
+		if len(predicted) > 0 {
+			categories = predicted
+		}
+		if predictedPriority != "" {
+			priority = predictedPriority
+		}
+	}
+	if categories == nil {
+		categories = []string{}
+	}
+
+	decision := &model.AgentDecision{
+		Categories: categories,
+		Priority:   priority,
+		Summary:    fmt.Sprintf("Classified offline while the agent service was unavailable: %s", email.Subject),
+		Source:     model.DecisionSourceFallback,
+	}
+
+	// 有明确截止时间且需要处理的邮件才创建任务；离线分类不发送通知，避免误报
+	// 相对时间（"by Friday"）以邮件发送时间为基准
+	base := c.now()
+	if email.SentAt != nil {
+		base = *email.SentAt
+	}
+	if days, ok := GuessDueInDays(text, base); ok && (containsString(categories, "ACTION_REQUIRED") || priority == "HIGH") {
+		title := strings.TrimSpace(email.Subject)
+		if title == "" {
+			title = "Follow up on email"
+		}
+		decision.ShouldCreateTask = true
+		decision.Tasks = []model.TaskDecision{{Title: title, DueInDays: days}}
+	}
+	return decision, nil
+}
+
+// userModel 返回用户的模型，过期时重新训练
+func (c *Classifier) userModel(ctx context.Context, userID int) (*userModel, error) {
+	c.mu.Lock()
+	cached, ok := c.models[userID]
+	c.mu.Unlock()
+	if ok && c.now().Sub(cached.trainedAt) < c.ttl {
+		return cached.model, nil
+	}
+
+	samples, err := c.source.ListTrainingSamples(ctx, userID, c.maxSamples)
+	if err != nil {
+		return nil, err
+	}
+	m := train(samples)
+
+	c.mu.Lock()
+	c.models[userID] = &cachedModel{model: m, trainedAt: c.now()}
+	c.mu.Unlock()
+	return m, nil
+}
@@ -0,0 +1,127 @@
+package classifier
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 冷启动（历史决策不足）时使用的关键词规则
+var (
+	highPriorityKeywords = []string{"urgent", "asap", "immediately", "critical", "overdue", "deadline", "action required", "紧急", "尽快", "立即", "截止"}
+	lowPriorityKeywords  = []string{"newsletter", "unsubscribe", "promotion", "digest", "no-reply", "noreply", "webinar", "退订", "促销", "订阅"}
+
+	categoryKeywords = []struct {
+		category string
+		keywords []string
+	}{
+		{"ACTION_REQUIRED", []string{"action required", "please review", "please confirm", "please approve", "please sign", "can you", "could you", "请确认", "请审批", "请回复", "需要你"}},
+		{"FINANCE", []string{"invoice", "payment", "receipt", "billing", "refund", "发票", "付款", "账单", "报销"}},
+		{"MEETING", []string{"meeting", "calendar", "invitation", "reschedule", "agenda", "会议", "日程", "邀请"}},
+		{"NEWSLETTER", []string{"newsletter", "unsubscribe", "digest", "订阅", "退订"}},
+	}
+)
+
+// keywordCategories 按关键词猜测分类
+func keywordCategories(text string) []string {
+	var categories []string
+	for _, ck := range categoryKeywords {
+		if containsAny(text, ck.keywords) {
+			categories = append(categories, ck.category)
+		}
+	}
+	return categories
+}
+
+// keywordPriority 按关键词猜测优先级
+func keywordPriority(text string) string {
+	switch {
+	case containsAny(text, highPriorityKeywords):
+		return "HIGH"
+	case containsAny(text, lowPriorityKeywords):
+		return "LOW"
+	default:
+		return "MEDIUM"
+	}
+}
+
+var (
+	inDaysRe   = regexp.MustCompile(`\b(?:in|within)\s+(\d{1,3})\s+(?:business\s+)?days?\b`)
+	cnDaysRe   = regexp.MustCompile(`(\d{1,3})\s*天内`)
+	isoDateRe  = regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`)
+	weekdayRe  = regexp.MustCompile(`\b(?:by|on|before|until|due)\s+(?:next\s+)?(monday|tuesday|wednesday|thursday|friday|saturday|sunday)\b`)
+	weekdayMap = map[string]time.Weekday{
+		"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+		"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+	}
+
+	relativeDeadlines = []struct {
+		keywords []string
+		days     func(now time.Time) int
+	}{
+		{[]string{"today", "tonight", "end of day", "eod", "asap", "immediately", "今天", "今晚", "尽快"}, func(time.Time) int { return 0 }},
+		{[]string{"day after tomorrow", "后天"}, func(time.Time) int { return 2 }},
+		{[]string{"tomorrow", "明天"}, func(time.Time) int { return 1 }},
+		{[]string{"this week", "end of week", "eow", "本周", "这周"}, func(now time.Time) int { return daysUntil(now, time.Friday) }},
+		{[]string{"next week", "下周"}, func(time.Time) int { return 7 }},
+		{[]string{"end of month", "月底"}, func(now time.Time) int {
+			last := time.Date(now.Year(), now.Month()+1, 0, 0, 0, 0, 0, now.Location())
+			return last.YearDay() - now.YearDay()
+		}},
+	}
+)
+
+// maxGuessDays 超过该天数的日期不视为截止时间
+const maxGuessDays = 365
+
+// GuessDueInDays 从文本中猜测截止时间（距 now 的天数），取最早的一个
+func GuessDueInDays(text string, now time.Time) (int, bool) {
+	text = strings.ToLower(text)
+	best, found := 0, false
+	consider := func(days int) {
+		if days < 0 || days > maxGuessDays {
+			return
+		}
+		if !found || days < best {
+			best, found = days, true
+		}
+	}
+
+	for _, rd := range relativeDeadlines {
+		if containsAny(text, rd.keywords) {
+			consider(rd.days(now))
+		}
+	}
+	for _, m := range weekdayRe.FindAllStringSubmatch(text, -1) {
+		consider(daysUntil(now, weekdayMap[m[1]]))
+	}
+	for _, re := range []*regexp.Regexp{inDaysRe, cnDaysRe} {
+		for _, m := range re.FindAllStringSubmatch(text, -1) {
+			if n, err := strconv.Atoi(m[1]); err == nil {
+				consider(n)
+			}
+		}
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for _, m := range isoDateRe.FindAllString(text, -1) {
+		if d, err := time.ParseInLocation("2006-01-02", m, now.Location()); err == nil {
+			consider(int(d.Sub(today).Hours() / 24))
+		}
+	}
+	return best, found
+}
+
+// daysUntil 距离下一个指定星期几的天数（当天为 0）
+func daysUntil(now time.Time, day time.Weekday) int {
+	return (int(day) - int(now.Weekday()) + 7) % 7
+}
+
+func containsAny(text string, keywords []string) bool {
+	for _, k := range keywords {
+		if strings.Contains(text, k) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package classifier
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopWords 常见英文停用词，不参与评分
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "you": true, "your": true, "are": true, "was": true,
+	"with": true, "this": true, "that": true, "from": true, "have": true, "has": true, "will": true,
+	"our": true, "not": true, "but": true, "all": true, "can": true, "just": true, "any": true,
+	"its": true, "it": true, "is": true, "to": true, "of": true, "in": true, "on": true, "at": true,
+	"be": true, "or": true, "an": true, "as": true, "by": true, "we": true, "if": true, "so": true,
+	"re": true, "fw": true, "fwd": true, "hi": true, "hello": true, "thanks": true, "regards": true,
+}
+
+// tokenize 将文本切分为小写词；中日韩文字没有空格分隔，按相邻两字切分
+func tokenize(text string) []string {
+	var tokens []string
+	var word []rune
+	var han []rune
+
+	flushWord := func() {
+		if len(word) >= 2 {
+			w := string(word)
+			if !stopWords[w] {
+				tokens = append(tokens, w)
+			}
+		}
+		word = word[:0]
+	}
+	flushHan := func() {
+		switch {
+		case len(han) == 1:
+			tokens = append(tokens, string(han))
+		case len(han) > 1:
+			for i := 0; i+1 < len(han); i++ {
+				tokens = append(tokens, string(han[i:i+2]))
+			}
+		}
+		han = han[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return tokens
+}
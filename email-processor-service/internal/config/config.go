@@ -13,6 +13,47 @@ type Config struct {
 	MQ              config.MQConfig     `yaml:"mq"`
 	Redis           config.RedisConfig  `yaml:"redis"`
 	AgentServiceURL string              `yaml:"agent_service_url"`
+	Reclassify      ReclassifyConfig    `yaml:"reclassify"`
+	DecisionCache   DecisionCacheConfig `yaml:"decision_cache"`
+	AgentBatch      AgentBatchConfig    `yaml:"agent_batch"`
+	AgentBackend    AgentBackendConfig  `yaml:"agent_backend"`
+}
+
+// ReclassifyConfig 离线分类（fallback）邮件的重新分类配置
+type ReclassifyConfig struct {
+	IntervalSeconds int `yaml:"interval_seconds"` // 检查 agent 是否恢复的间隔
+	BatchSize       int `yaml:"batch_size"`       // 每次重新发布的邮件数
+}
+
+// DecisionCacheConfig agent 决策缓存配置（按内容指纹缓存，模板化邮件不重复调用 LLM）
+type DecisionCacheConfig struct {
+	Enabled    bool `yaml:"enabled"`
+	TTLSeconds int  `yaml:"ttl_seconds"`
+}
+
+// AgentBackendConfig 决策后端配置
+type AgentBackendConfig struct {
+	// http（默认，agent-service）/ openai（OpenAI 兼容的 chat completions 接口）/
+	// rules（本地关键词和历史决策，不调用 LLM）/ fake（确定性决策，用于测试）/
+	// stub（进程内启动模拟 agent-service 的 HTTP 服务，离线跑通整条链路）
+	Type   string       `yaml:"type"`
+	OpenAI OpenAIConfig `yaml:"openai"`
+}
+
+// OpenAIConfig OpenAI 兼容接口配置
+type OpenAIConfig struct {
+	BaseURL        string `yaml:"base_url"` // 如 https://api.openai.com/v1
+	APIKey         string `yaml:"api_key"`
+	Model          string `yaml:"model"`
+	TimeoutSeconds int    `yaml:"timeout_seconds"`
+	Concurrency    int    `yaml:"concurrency"` // 批量决策时的并发请求数
+}
+
+// AgentBatchConfig email.received.agent 的 micro-batching 消费配置（调用 agent-service /decide-batch）
+type AgentBatchConfig struct {
+	Enabled   bool `yaml:"enabled"`
+	MaxSize   int  `yaml:"max_size"`    // 每批最多的邮件数（不超过 50）
+	MaxWaitMs int  `yaml:"max_wait_ms"` // 第一封邮件到达后最多等待的时间
 }
 
 func Load() *Config {
@@ -49,6 +90,12 @@ func Load() *Config {
 	if url := os.Getenv("AGENT_SERVICE_URL"); url != "" {
 		cfg.AgentServiceURL = url
 	}
+	if backend := os.Getenv("AGENT_BACKEND"); backend != "" {
+		cfg.AgentBackend.Type = backend
+	}
+	if key := os.Getenv("OPENAI_API_KEY"); key != "" && cfg.AgentBackend.OpenAI.APIKey == "" {
+		cfg.AgentBackend.OpenAI.APIKey = key
+	}
 
 	return &cfg
 }
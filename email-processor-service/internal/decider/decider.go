@@ -0,0 +1,73 @@
+package decider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"email-processor-service/internal/config"
+	"email-processor-service/internal/model"
+	"email-processor-service/internal/service"
+
+	"go.uber.org/zap"
+)
+
+// CloseFunc 释放决策后端持有的资源（stub 的 HTTP 服务），其他后端为空操作
+type CloseFunc func(ctx context.Context) error
+
+func noopClose(ctx context.Context) error { return nil }
+
+// New 根据配置创建决策后端。rules 需要本地分类器；stub 会在进程内启动模拟 agent-service 的 HTTP 服务，
+// 退出时需要调用返回的 CloseFunc 停止
+func New(cfg config.AgentBackendConfig, agentURL string, classifier service.FallbackClassifier, logger *zap.Logger) (service.Decider, CloseFunc, error) {
+	switch cfg.Type {
+	case "", "http":
+		return NewHTTPDecider(agentURL), noopClose, nil
+	case "openai":
+		d, err := NewOpenAIDecider(cfg.OpenAI)
+		if err != nil {
+			return nil, nil, err
+		}
+		return d, noopClose, nil
+	case "rules":
+		if classifier == nil {
+			return nil, nil, fmt.Errorf("rules agent backend requires a classifier")
+		}
+		return NewRulesDecider(classifier), noopClose, nil
+	case "fake":
+		return NewFakeDecider(), noopClose, nil
+	case "stub":
+		stub := NewStubServer(NewFakeDecider(), logger)
+		addr, err := stub.Start("127.0.0.1:0")
+		if err != nil {
+			return nil, nil, fmt.Errorf("start agent stub server: %w", err)
+		}
+		logger.Info("Started in-process agent stub server", zap.String("addr", addr))
+		return NewHTTPDecider("http://" + addr), stub.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported agent backend type: %s", cfg.Type)
+	}
+}
+
+// decideEach 没有批量接口的后端逐封决策（最多 concurrency 个并发），单封失败时对应位置为 nil
+func decideEach(ctx context.Context, d service.Decider, emails []service.EmailInput, concurrency int) []*model.AgentDecision {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	decisions := make([]*model.AgentDecision, len(emails))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range emails {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if decision, err := d.Decide(ctx, emails[i]); err == nil {
+				decisions[i] = decision
+			}
+		}(i)
+	}
+	wg.Wait()
+	return decisions
+}
@@ -0,0 +1,70 @@
+package decider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"email-processor-service/internal/model"
+	"email-processor-service/internal/service"
+)
+
+// 主题中的标记决定 FakeDecider 的输出
+const (
+	FakeMarkerUrgent = "[urgent]" // HIGH 优先级并通知
+	FakeMarkerTask   = "[task]"   // 创建一个任务（第二天截止）
+	FakeMarkerLow    = "[low]"    // LOW 优先级
+	FakeMarkerFail   = "[fail]"   // 返回错误（验证 fallback）
+)
+
+// ErrFakeFailure FakeDecider 对带 [fail] 标记的邮件返回的错误
+var ErrFakeFailure = errors.New("fake decider: forced failure")
+
+// FakeDecider 确定性的决策后端，用于测试和离线联调：相同的输入总是得到相同的决策，不依赖外部服务。
+// 默认输出分类 OTHER、MEDIUM 优先级、不创建任务也不通知，主题中的标记（不区分大小写）改变对应字段
+type FakeDecider struct{}
+
+func NewFakeDecider() *FakeDecider {
+	return &FakeDecider{}
+}
+
+// Decide 实现 service.Decider
+func (d *FakeDecider) Decide(ctx context.Context, email service.EmailInput) (*model.AgentDecision, error) {
+	subject := strings.ToLower(email.Subject)
+	if strings.Contains(subject, FakeMarkerFail) {
+		return nil, ErrFakeFailure
+	}
+
+	decision := &model.AgentDecision{
+		Categories: []string{"OTHER"},
+		Priority:   "MEDIUM",
+		Summary:    fmt.Sprintf("Fake decision for email %d: %s", email.EmailID, email.Subject),
+		Tasks:      []model.TaskDecision{},
+	}
+	switch {
+	case strings.Contains(subject, FakeMarkerUrgent):
+		decision.Priority = "HIGH"
+		decision.ShouldNotify = true
+		decision.NotificationChannel = "EMAIL"
+		decision.NotificationMessage = fmt.Sprintf("Urgent email: %s", email.Subject)
+	case strings.Contains(subject, FakeMarkerLow):
+		decision.Priority = "LOW"
+	}
+	if strings.Contains(subject, FakeMarkerTask) {
+		decision.Categories = append(decision.Categories, "ACTION_REQUIRED")
+		decision.ShouldCreateTask = true
+		decision.Tasks = []model.TaskDecision{{Title: email.Subject, DueInDays: 1, Priority: decision.Priority}}
+	}
+	return decision, nil
+}
+
+// DecideBatch 实现 service.Decider
+func (d *FakeDecider) DecideBatch(ctx context.Context, emails []service.EmailInput) ([]*model.AgentDecision, error) {
+	return decideEach(ctx, d, emails, 1), nil
+}
+
+// Ping 实现 service.Decider
+func (d *FakeDecider) Ping(ctx context.Context) error {
+	return nil
+}
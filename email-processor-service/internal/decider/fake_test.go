@@ -0,0 +1,97 @@
+package decider
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"email-processor-service/internal/service"
+)
+
+func TestFakeDecider_Markers(t *testing.T) {
+	tests := []struct {
+		subject        string
+		wantPriority   string
+		wantCategories []string
+		wantNotify     bool
+		wantTasks      int
+	}{
+		{"Weekly report", "MEDIUM", []string{"OTHER"}, false, 0},
+		{"[urgent] Server down", "HIGH", []string{"OTHER"}, true, 0},
+		{"[URGENT] server down", "HIGH", []string{"OTHER"}, true, 0},
+		{"[low] Newsletter", "LOW", []string{"OTHER"}, false, 0},
+		{"[task] Review the doc", "MEDIUM", []string{"OTHER", "ACTION_REQUIRED"}, false, 1},
+		{"[urgent][task] Sign the contract", "HIGH", []string{"OTHER", "ACTION_REQUIRED"}, true, 1},
+		{"[low][task] Tidy the wiki", "LOW", []string{"OTHER", "ACTION_REQUIRED"}, false, 1},
+		// urgent 优先于 low
+		{"[urgent][low] Mixed", "HIGH", []string{"OTHER"}, true, 0},
+	}
+	d := NewFakeDecider()
+	for _, tt := range tests {
+		t.Run(tt.subject, func(t *testing.T) {
+			decision, err := d.Decide(context.Background(), service.EmailInput{EmailID: 1, Subject: tt.subject})
+			if err != nil {
+				t.Fatalf("Decide: %v", err)
+			}
+			if decision.Priority != tt.wantPriority {
+				t.Errorf("priority = %s, want %s", decision.Priority, tt.wantPriority)
+			}
+			if !reflect.DeepEqual(decision.Categories, tt.wantCategories) {
+				t.Errorf("categories = %v, want %v", decision.Categories, tt.wantCategories)
+			}
+			if decision.ShouldNotify != tt.wantNotify {
+				t.Errorf("should_notify = %v, want %v", decision.ShouldNotify, tt.wantNotify)
+			}
+			if decision.ShouldNotify && (decision.NotificationChannel != "EMAIL" || decision.NotificationMessage == "") {
+				t.Errorf("notification = %q / %q", decision.NotificationChannel, decision.NotificationMessage)
+			}
+			if len(decision.Tasks) != tt.wantTasks || decision.ShouldCreateTask != (tt.wantTasks > 0) {
+				t.Fatalf("tasks = %+v, should_create_task = %v", decision.Tasks, decision.ShouldCreateTask)
+			}
+			if tt.wantTasks > 0 {
+				task := decision.Tasks[0]
+				if task.Title != tt.subject || task.DueInDays != 1 || task.Priority != tt.wantPriority {
+					t.Errorf("task = %+v", task)
+				}
+			}
+		})
+	}
+}
+
+func TestFakeDecider_Fail(t *testing.T) {
+	d := NewFakeDecider()
+	for _, subject := range []string{"[fail] Anything", "[FAIL][urgent] Anything"} {
+		if _, err := d.Decide(context.Background(), service.EmailInput{Subject: subject}); !errors.Is(err, ErrFakeFailure) {
+			t.Errorf("%q: err = %v, want ErrFakeFailure", subject, err)
+		}
+	}
+}
+
+func TestFakeDecider_Deterministic(t *testing.T) {
+	d := NewFakeDecider()
+	email := service.EmailInput{EmailID: 42, Subject: "[task] Book the room"}
+	first, _ := d.Decide(context.Background(), email)
+	second, _ := d.Decide(context.Background(), email)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("decisions differ: %+v vs %+v", first, second)
+	}
+}
+
+func TestFakeDecider_DecideBatch(t *testing.T) {
+	d := NewFakeDecider()
+	decisions, err := d.DecideBatch(context.Background(), []service.EmailInput{
+		{EmailID: 1, Subject: "[urgent] A"},
+		{EmailID: 2, Subject: "[fail] B"},
+		{EmailID: 3, Subject: "[low] C"},
+	})
+	if err != nil {
+		t.Fatalf("DecideBatch: %v", err)
+	}
+	if len(decisions) != 3 || decisions[0] == nil || decisions[1] != nil || decisions[2] == nil {
+		t.Fatalf("decisions = %+v, want nil only for [fail]", decisions)
+	}
+	if decisions[0].Priority != "HIGH" || decisions[2].Priority != "LOW" {
+		t.Errorf("priorities = %s, %s", decisions[0].Priority, decisions[2].Priority)
+	}
+}
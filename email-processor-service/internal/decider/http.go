@@ -0,0 +1,149 @@
+package decider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"email-processor-service/internal/model"
+	"email-processor-service/internal/service"
+	"mygoproject/pkg/metrics"
+	"mygoproject/pkg/trace"
+)
+
+// HTTPDecider 调用 Python agent-service（/decide、/decide-batch、/health）
+type HTTPDecider struct {
+	baseURL         string
+	httpClient      *http.Client
+	batchHTTPClient *http.Client // /decide-batch 处理多封邮件，超时更长
+}
+
+func NewHTTPDecider(baseURL string) *HTTPDecider {
+	return &HTTPDecider{
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second, // 5秒超时，避免 worker 卡死
+		},
+		batchHTTPClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+type batchDecisionRequest struct {
+	Emails []service.EmailInput `json:"emails"`
+}
+
+type batchDecisionResult struct {
+	EmailID  int                  `json:"email_id"`
+	Decision *model.AgentDecision `json:"decision"`
+	Error    string               `json:"error,omitempty"`
+}
+
+type batchDecisionResponse struct {
+	Results []batchDecisionResult `json:"results"` // 与请求中的 emails 顺序一致
+}
+
+// Decide 实现 service.Decider
+func (d *HTTPDecider) Decide(ctx context.Context, email service.EmailInput) (*model.AgentDecision, error) {
+	var decision model.AgentDecision
+	if err := d.postJSON(ctx, d.httpClient, "/decide", email, &decision); err != nil {
+		return nil, err
+	}
+	return &decision, nil
+}
+
+// DecideBatch 实现 service.Decider：一次 /decide-batch 调用
+func (d *HTTPDecider) DecideBatch(ctx context.Context, emails []service.EmailInput) ([]*model.AgentDecision, error) {
+	var resp batchDecisionResponse
+	if err := d.postJSON(ctx, d.batchHTTPClient, "/decide-batch", batchDecisionRequest{Emails: emails}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != len(emails) {
+		return nil, fmt.Errorf("agent service returned %d results for %d emails", len(resp.Results), len(emails))
+	}
+
+	decisions := make([]*model.AgentDecision, len(emails))
+	for i, r := range resp.Results {
+		if r.EmailID == emails[i].EmailID {
+			decisions[i] = r.Decision
+		}
+	}
+	return decisions, nil
+}
+
+// Ping 实现 service.Decider
+func (d *HTTPDecider) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.baseURL+"/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("agent service unhealthy: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// postJSON 发送 JSON 请求并解码响应，记录 agent_call_latency_ms
+func (d *HTTPDecider) postJSON(ctx context.Context, client *http.Client, path string, body, out any) error {
+	start := time.Now()
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.baseURL+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// 传播 trace_id
+	if traceID := trace.FromContext(ctx); traceID != "" {
+		req.Header.Set(trace.HeaderName(), traceID)
+	}
+
+	resp, err := client.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		metrics.RecordAgentCallLatency(path, "error", latency)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		metrics.RecordAgentCallLatency(path, "5xx", latency)
+		// 可重试错误
+		return fmt.Errorf("agent service 5xx: %d", resp.StatusCode)
+	}
+	if resp.StatusCode != 200 {
+		metrics.RecordAgentCallLatency(path, fmt.Sprintf("%d", resp.StatusCode), latency)
+		// 不可重试或者单独处理
+		return fmt.Errorf("agent service error: %d", resp.StatusCode)
+	}
+
+	metrics.RecordAgentCallLatency(path, "success", latency)
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return decodeError(err)
+	}
+	return nil
+}
+
+// decodeError 响应体不是合法的 JSON 或字段类型不符时视为响应结构无效（service.ErrInvalidDecision），
+// 读取响应体时的网络错误原样返回
+func decodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return fmt.Errorf("%w: %v", service.ErrInvalidDecision, err)
+	}
+	return err
+}
@@ -0,0 +1,141 @@
+package decider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"email-processor-service/internal/service"
+	"mygoproject/pkg/trace"
+
+	"go.uber.org/zap"
+)
+
+func newStubDecider(t *testing.T) *HTTPDecider {
+	t.Helper()
+	srv := httptest.NewServer(NewStubServer(NewFakeDecider(), zap.NewNop()).Handler())
+	t.Cleanup(srv.Close)
+	return NewHTTPDecider(srv.URL)
+}
+
+func TestHTTPDecider_Decide(t *testing.T) {
+	d := newStubDecider(t)
+
+	decision, err := d.Decide(context.Background(), service.EmailInput{EmailID: 7, Subject: "[urgent][task] Renew the certificate"})
+	if err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if decision.Priority != "HIGH" || !decision.ShouldNotify || len(decision.Tasks) != 1 {
+		t.Errorf("decision = %+v", decision)
+	}
+}
+
+func TestHTTPDecider_DecideFail(t *testing.T) {
+	d := newStubDecider(t)
+
+	_, err := d.Decide(context.Background(), service.EmailInput{EmailID: 7, Subject: "[fail] Anything"})
+	if err == nil || !strings.Contains(err.Error(), "5xx") {
+		t.Errorf("err = %v, want agent service 5xx", err)
+	}
+}
+
+func TestHTTPDecider_DecideBatch(t *testing.T) {
+	d := newStubDecider(t)
+
+	emails := []service.EmailInput{
+		{EmailID: 1, Subject: "[low] Newsletter"},
+		{EmailID: 2, Subject: "[fail] Broken"},
+		{EmailID: 3, Subject: "[task] Review"},
+	}
+	decisions, err := d.DecideBatch(context.Background(), emails)
+	if err != nil {
+		t.Fatalf("DecideBatch: %v", err)
+	}
+	if len(decisions) != len(emails) {
+		t.Fatalf("got %d decisions, want %d", len(decisions), len(emails))
+	}
+	if decisions[0] == nil || decisions[0].Priority != "LOW" {
+		t.Errorf("decision 0 = %+v", decisions[0])
+	}
+	// 单封失败只影响该邮件
+	if decisions[1] != nil {
+		t.Errorf("decision 1 = %+v, want nil for [fail]", decisions[1])
+	}
+	if decisions[2] == nil || !decisions[2].ShouldCreateTask {
+		t.Errorf("decision 2 = %+v", decisions[2])
+	}
+}
+
+func TestHTTPDecider_DecideBatchResultCountMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"results": []map[string]any{{"email_id": 1, "decision": map[string]any{"priority": "LOW"}}},
+		})
+	}))
+	defer srv.Close()
+
+	d := NewHTTPDecider(srv.URL)
+	_, err := d.DecideBatch(context.Background(), []service.EmailInput{{EmailID: 1}, {EmailID: 2}})
+	if err == nil || !strings.Contains(err.Error(), "1 results for 2 emails") {
+		t.Errorf("err = %v, want result count mismatch", err)
+	}
+}
+
+func TestHTTPDecider_DecideBatchMismatchedEmailID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"results": []map[string]any{
+				{"email_id": 1, "decision": map[string]any{"priority": "LOW"}},
+				{"email_id": 99, "decision": map[string]any{"priority": "HIGH"}},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	d := NewHTTPDecider(srv.URL)
+	decisions, err := d.DecideBatch(context.Background(), []service.EmailInput{{EmailID: 1}, {EmailID: 2}})
+	if err != nil {
+		t.Fatalf("DecideBatch: %v", err)
+	}
+	if decisions[0] == nil || decisions[1] != nil {
+		t.Errorf("decisions = %+v, want nil for the result with another email_id", decisions)
+	}
+}
+
+func TestHTTPDecider_PropagatesTraceID(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(trace.HeaderName())
+		writeJSON(w, http.StatusOK, map[string]any{"categories": []string{"OTHER"}, "priority": "LOW"})
+	}))
+	defer srv.Close()
+
+	ctx := trace.WithContext(context.Background(), "trace-123")
+	if _, err := NewHTTPDecider(srv.URL).Decide(ctx, service.EmailInput{EmailID: 1}); err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if got != "trace-123" {
+		t.Errorf("trace header = %q, want trace-123", got)
+	}
+}
+
+func TestStubServer_StartAndClose(t *testing.T) {
+	stub := NewStubServer(NewFakeDecider(), zap.NewNop())
+	addr, err := stub.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	d := NewHTTPDecider("http://" + addr)
+	if err := d.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	if err := stub.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := d.Ping(context.Background()); err == nil {
+		t.Error("Ping succeeded after Close")
+	}
+}
@@ -0,0 +1,283 @@
+package decider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"email-processor-service/internal/config"
+	"email-processor-service/internal/model"
+	"email-processor-service/internal/service"
+	"mygoproject/pkg/metrics"
+)
+
+const (
+	defaultOpenAIModel       = "gpt-4o-mini"
+	defaultOpenAITimeout     = 20 * time.Second
+	defaultOpenAIConcurrency = 4
+)
+
+// openAISystemPrompt 与 agent-service 的 JSON_SCHEMA_SYSTEM_MESSAGE 保持一致
+const openAISystemPrompt = `You are an AI assistant that analyzes emails and produces a structured JSON decision.
+
+Return ONLY a valid JSON object with the EXACT structure shown below.
+Do NOT add backticks, explanations, or commentary.
+
+Here is the REQUIRED JSON schema:
+
+{
+  "categories": ["WORK", "ACTION_REQUIRED"],
+  "priority": "LOW" | "MEDIUM" | "HIGH",
+  "summary": "short English summary, 1-3 sentences",
+
+  "should_create_task": true or false,
+  "tasks": [
+    {
+      "title": "short task title",
+      "due_in_days": integer (>=0),
+      "priority": "LOW" | "MEDIUM" | "HIGH",
+      "due_date": "YYYY-MM-DD" or "YYYY-MM-DDTHH:MM" or null
+    }
+  ],
+
+  "should_notify": true or false,
+  "notification_channel": "EMAIL" or null,
+  "notification_message": "short notification text" or null
+}
+
+Your job:
+- Classify the email
+- Determine urgency
+- Decide if follow-up tasks are needed; create one task per distinct action
+  (e.g. "review the doc by Monday and book the room for Thursday" -> 2 tasks)
+- Set "due_date" only when the email states a deadline; otherwise null.
+  Resolve relative dates ("by Friday", "tomorrow 3pm") against the email's
+  "Sent at" time, in the user's timezone. Use "YYYY-MM-DD" for a day and
+  "YYYY-MM-DDTHH:MM" only when a time of day is stated
+- Use an empty "tasks" list when should_create_task is false
+- Decide if notification is needed. When the input gives the sender's importance,
+  lean towards notifying for VIP / HIGH senders and against it for LOW senders
+- Generate a concise summary
+
+Think carefully, then output ONLY the JSON.`
+
+// OpenAIDecider 直接调用 OpenAI 兼容的 chat completions 接口（OpenAI、Azure 代理、vLLM、Ollama 等），
+// 不经过 agent-service
+type OpenAIDecider struct {
+	baseURL     string
+	apiKey      string
+	model       string
+	concurrency int
+	httpClient  *http.Client
+}
+
+func NewOpenAIDecider(cfg config.OpenAIConfig) (*OpenAIDecider, error) {
+	if cfg.BaseURL == "" {
+		return nil, errors.New("openai agent backend requires base_url")
+	}
+	d := &OpenAIDecider{
+		baseURL:     strings.TrimRight(cfg.BaseURL, "/"),
+		apiKey:      cfg.APIKey,
+		model:       cfg.Model,
+		concurrency: cfg.Concurrency,
+		httpClient:  &http.Client{Timeout: defaultOpenAITimeout},
+	}
+	if d.model == "" {
+		d.model = defaultOpenAIModel
+	}
+	if d.concurrency <= 0 {
+		d.concurrency = defaultOpenAIConcurrency
+	}
+	if cfg.TimeoutSeconds > 0 {
+		d.httpClient.Timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	return d, nil
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatCompletionRequest struct {
+	Model          string            `json:"model"`
+	Temperature    float64           `json:"temperature"`
+	Messages       []chatMessage     `json:"messages"`
+	ResponseFormat map[string]string `json:"response_format,omitempty"`
+}
+
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// Decide 实现 service.Decider
+func (d *OpenAIDecider) Decide(ctx context.Context, email service.EmailInput) (*model.AgentDecision, error) {
+	body, err := json.Marshal(chatCompletionRequest{
+		Model:       d.model,
+		Temperature: 0.2,
+		Messages: []chatMessage{
+			{Role: "system", Content: openAISystemPrompt},
+			{Role: "user", Content: buildUserMessage(email)},
+		},
+		ResponseFormat: map[string]string{"type": "json_object"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if d.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+d.apiKey)
+	}
+
+	start := time.Now()
+	resp, err := d.httpClient.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		metrics.RecordAgentCallLatency("/chat/completions", "error", latency)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		status := fmt.Sprintf("%d", resp.StatusCode)
+		if resp.StatusCode >= 500 {
+			status = "5xx"
+		}
+		metrics.RecordAgentCallLatency("/chat/completions", status, latency)
+		return nil, fmt.Errorf("chat completions error: %d", resp.StatusCode)
+	}
+	metrics.RecordAgentCallLatency("/chat/completions", "success", latency)
+
+	var completion chatCompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		return nil, decodeError(err)
+	}
+	if len(completion.Choices) == 0 {
+		return nil, fmt.Errorf("%w: chat completions returned no choices", service.ErrInvalidDecision)
+	}
+
+	var decision model.AgentDecision
+	if err := json.Unmarshal([]byte(stripCodeFence(completion.Choices[0].Message.Content)), &decision); err != nil {
+		return nil, fmt.Errorf("%w: %v", service.ErrInvalidDecision, err)
+	}
+	return &decision, nil
+}
+
+// DecideBatch 实现 service.Decider：chat completions 没有批量接口，并发逐封调用
+func (d *OpenAIDecider) DecideBatch(ctx context.Context, emails []service.EmailInput) ([]*model.AgentDecision, error) {
+	return decideEach(ctx, d, emails, d.concurrency), nil
+}
+
+// Ping 实现 service.Decider：列出模型验证地址和密钥
+func (d *OpenAIDecider) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.baseURL+"/models", nil)
+	if err != nil {
+		return err
+	}
+	if d.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+d.apiKey)
+	}
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("chat completions endpoint unhealthy: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// buildUserMessage 与 agent-service 的 chain.py 使用相同的输入格式
+func buildUserMessage(email service.EmailInput) string {
+	var b strings.Builder
+	b.WriteString("\nYou are an AI assistant that analyzes a single email and makes decisions.\n\nReturn ONLY valid JSON.\n")
+	writeCorrections(&b, email.Corrections)
+
+	sentAt := "unknown"
+	if email.SentAt != nil {
+		sentAt = email.SentAt.Format(time.RFC3339)
+	}
+	timezone := email.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	b.WriteString("--------------------\nINPUT:\n")
+	fmt.Fprintf(&b, "Email ID: %d\nUser ID: %d\nSent at: %s\nUser timezone: %s\n", email.EmailID, email.UserID, sentAt, timezone)
+	writeSender(&b, email.Sender)
+	fmt.Fprintf(&b, "Subject: %s\nBody: %s\n", email.Subject, email.Body)
+	b.WriteString("--------------------\n\nNow output the JSON.\n")
+	return b.String()
+}
+
+func writeCorrections(b *strings.Builder, corrections []model.FeedbackExample) {
+	if len(corrections) == 0 {
+		return
+	}
+	b.WriteString("\nThis user has corrected some of your previous decisions.\nFollow the same preferences for similar emails:\n")
+	for _, c := range corrections {
+		original := fmt.Sprintf("categories=%v, priority=%s", c.OriginalCategories, c.OriginalPriority)
+		var line string
+		switch c.FeedbackType {
+		case "classification":
+			fixed := fmt.Sprintf("categories=%v, priority=%s", c.CorrectedCategories, c.CorrectedPriority)
+			line = fmt.Sprintf("- Email %q: you decided %s; the user changed it to %s.", c.Subject, original, fixed)
+		case "task":
+			line = fmt.Sprintf("- Email %q: you created the task %q, the user marked it as wrong (no task was needed).", c.Subject, c.Detail)
+		case "notification":
+			line = fmt.Sprintf("- Email %q: you sent the notification %q, the user marked it as wrong (no notification was needed).", c.Subject, c.Detail)
+		default:
+			continue
+		}
+		if c.Comment != "" {
+			line += " User comment: " + c.Comment
+		}
+		b.WriteString(line + "\n")
+	}
+}
+
+func writeSender(b *strings.Builder, sender *model.SenderProfile) {
+	if sender == nil {
+		return
+	}
+	name := sender.DisplayName
+	if name == "" {
+		name = sender.Address
+	}
+	fmt.Fprintf(b, "Sender: %s <%s>\n", name, sender.Address)
+	if sender.IsVIP {
+		b.WriteString("Sender importance: VIP (marked by the user; treat as important)\n")
+	} else {
+		fmt.Fprintf(b, "Sender importance: %s\n", sender.Importance)
+	}
+
+	fmt.Fprintf(b, "Sender history: %d previous emails", sender.MessageCount)
+	if sender.AvgPriority != nil {
+		fmt.Fprintf(b, ", average priority %.1f (LOW=1, MEDIUM=2, HIGH=3)", *sender.AvgPriority)
+	}
+	fmt.Fprintf(b, ", %d%% produced tasks\n", int(math.Round(sender.TaskRate*100)))
+}
+
+// stripCodeFence 去掉模型有时仍会加上的 ```json 代码块标记
+func stripCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	s = strings.TrimPrefix(s, "json")
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+}
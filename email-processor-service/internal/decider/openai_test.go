@@ -0,0 +1,114 @@
+package decider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"email-processor-service/internal/config"
+	"email-processor-service/internal/service"
+)
+
+// newChatServer 模拟 /chat/completions，返回给定的响应体并记录请求
+func newChatServer(t *testing.T, status int, response any) (*httptest.Server, *chatCompletionRequest, *http.Header) {
+	t.Helper()
+	var req chatCompletionRequest
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		header = r.Header.Clone()
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		writeJSON(w, status, response)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &req, &header
+}
+
+func chatResponse(content string) map[string]any {
+	return map[string]any{
+		"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": content}}},
+	}
+}
+
+func newTestOpenAIDecider(t *testing.T, baseURL string) *OpenAIDecider {
+	t.Helper()
+	d, err := NewOpenAIDecider(config.OpenAIConfig{BaseURL: baseURL + "/", APIKey: "sk-test"})
+	if err != nil {
+		t.Fatalf("NewOpenAIDecider: %v", err)
+	}
+	return d
+}
+
+func TestOpenAIDecider_FencedJSON(t *testing.T) {
+	content := "```json\n" + `{"categories": ["WORK"], "priority": "HIGH", "summary": "Contract",
+ "should_create_task": true, "tasks": [{"title": "Sign the contract", "due_in_days": 2}]}` + "\n```"
+	srv, req, header := newChatServer(t, http.StatusOK, chatResponse(content))
+	d := newTestOpenAIDecider(t, srv.URL)
+
+	decision, err := d.Decide(context.Background(), service.EmailInput{EmailID: 5, Subject: "Contract"})
+	if err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if decision.Priority != "HIGH" || len(decision.Tasks) != 1 || decision.Tasks[0].Title != "Sign the contract" {
+		t.Errorf("decision = %+v", decision)
+	}
+	if header.Get("Authorization") != "Bearer sk-test" {
+		t.Errorf("authorization = %q", header.Get("Authorization"))
+	}
+	if req.Model != defaultOpenAIModel || len(req.Messages) != 2 || req.Messages[0].Content != openAISystemPrompt {
+		t.Errorf("request = %+v", req)
+	}
+}
+
+func TestOpenAIDecider_InvalidResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		response any
+	}{
+		{"no choices", map[string]any{"choices": []any{}}},
+		{"content not json", chatResponse("I think this email is important.")},
+		{"fenced content not json", chatResponse("```json\nnot json\n```")},
+		{"wrong field type", chatResponse(`{"categories": "WORK", "priority": "HIGH"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _, _ := newChatServer(t, http.StatusOK, tt.response)
+			_, err := newTestOpenAIDecider(t, srv.URL).Decide(context.Background(), service.EmailInput{EmailID: 1})
+			if !errors.Is(err, service.ErrInvalidDecision) {
+				t.Errorf("err = %v, want ErrInvalidDecision", err)
+			}
+		})
+	}
+}
+
+func TestOpenAIDecider_HTTPError(t *testing.T) {
+	srv, _, _ := newChatServer(t, http.StatusTooManyRequests, map[string]string{"error": "rate limited"})
+	_, err := newTestOpenAIDecider(t, srv.URL).Decide(context.Background(), service.EmailInput{EmailID: 1})
+	if err == nil || errors.Is(err, service.ErrInvalidDecision) {
+		t.Errorf("err = %v, want retryable HTTP error", err)
+	}
+}
+
+func TestStripCodeFence(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`{"a": 1}`, `{"a": 1}`},
+		{"  {\"a\": 1}\n", `{"a": 1}`},
+		{"```json\n{\"a\": 1}\n```", `{"a": 1}`},
+		{"```\n{\"a\": 1}\n```", `{"a": 1}`},
+		{"```json{\"a\": 1}```", `{"a": 1}`},
+	}
+	for _, tt := range tests {
+		if got := stripCodeFence(tt.in); got != tt.want {
+			t.Errorf("stripCodeFence(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
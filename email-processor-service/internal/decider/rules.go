@@ -0,0 +1,41 @@
+package decider
+
+import (
+	"context"
+	"fmt"
+
+	"email-processor-service/internal/model"
+	"email-processor-service/internal/service"
+)
+
+// RulesDecider 不调用 LLM：使用本地分类器（关键词规则和基于用户历史决策的朴素贝叶斯模型），
+// 再由处理流程叠加用户的分类规则和发件人信号。适用于没有 LLM 的部署
+type RulesDecider struct {
+	classifier service.FallbackClassifier
+}
+
+func NewRulesDecider(classifier service.FallbackClassifier) *RulesDecider {
+	return &RulesDecider{classifier: classifier}
+}
+
+// Decide 实现 service.Decider
+func (d *RulesDecider) Decide(ctx context.Context, email service.EmailInput) (*model.AgentDecision, error) {
+	decision, err := d.classifier.Classify(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	// 本地规则是配置的决策方式而不是降级，不进入重新分类队列
+	decision.Summary = fmt.Sprintf("Classified by local rules: %s", email.Subject)
+	decision.Source = model.DecisionSourceRules
+	return decision, nil
+}
+
+// DecideBatch 实现 service.Decider
+func (d *RulesDecider) DecideBatch(ctx context.Context, emails []service.EmailInput) ([]*model.AgentDecision, error) {
+	return decideEach(ctx, d, emails, 1), nil
+}
+
+// Ping 实现 service.Decider：本地分类器始终可用
+func (d *RulesDecider) Ping(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,112 @@
+package decider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+
+	"email-processor-service/internal/model"
+	"email-processor-service/internal/service"
+
+	"go.uber.org/zap"
+)
+
+// StubServer 进程内的 agent-service 模拟服务（/health、/decide、/decide-batch，协议与 agent-service 一致），
+// 决策由给定的 Decider（通常是 FakeDecider）给出。配合 HTTPDecider 可以离线跑通 HTTP 调用、熔断、
+// 批量消费等整条链路；测试中也可以把 Handler() 挂到 httptest.Server 上
+type StubServer struct {
+	decider service.Decider
+	logger  *zap.Logger
+	server  *http.Server
+}
+
+func NewStubServer(decider service.Decider, logger *zap.Logger) *StubServer {
+	return &StubServer{decider: decider, logger: logger}
+}
+
+type stubBatchResult struct {
+	EmailID  int                  `json:"email_id"`
+	Decision *model.AgentDecision `json:"decision"`
+	Error    string               `json:"error,omitempty"`
+}
+
+// Handler 返回模拟 agent-service 的路由
+func (s *StubServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("POST /decide", s.handleDecide)
+	mux.HandleFunc("POST /decide-batch", s.handleDecideBatch)
+	return mux
+}
+
+// Start 在 addr 上监听（端口为 0 时随机分配）并在后台提供服务，返回实际监听地址
+func (s *StubServer) Start(addr string) (string, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	s.server = &http.Server{Handler: s.Handler()}
+	go func() {
+		if err := s.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Agent stub server stopped", zap.Error(err))
+		}
+	}()
+	return ln.Addr().String(), nil
+}
+
+// Close 停止服务
+func (s *StubServer) Close(ctx context.Context) error {
+	if s.server == nil {
+		return nil
+	}
+	return s.server.Shutdown(ctx)
+}
+
+func (s *StubServer) handleDecide(w http.ResponseWriter, r *http.Request) {
+	var email service.EmailInput
+	if err := json.NewDecoder(r.Body).Decode(&email); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"detail": err.Error()})
+		return
+	}
+	decision, err := s.decider.Decide(r.Context(), email)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"detail": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, decision)
+}
+
+func (s *StubServer) handleDecideBatch(w http.ResponseWriter, r *http.Request) {
+	var req batchDecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"detail": err.Error()})
+		return
+	}
+	if len(req.Emails) > service.MaxDecideBatchSize {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"detail": "too many emails"})
+		return
+	}
+
+	// 逐封决策，单封失败只影响该邮件
+	results := make([]stubBatchResult, len(req.Emails))
+	for i, email := range req.Emails {
+		results[i].EmailID = email.EmailID
+		decision, err := s.decider.Decide(r.Context(), email)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Decision = decision
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -1,9 +1,24 @@
 package model
 
+import "strings"
+
+// 决策来源（写入 emails_metadata.decision_source）
+const (
+    DecisionSourceAgent    = "agent"    // agent-service（可能合并了非 decisive 规则）
+    DecisionSourceRules    = "rules"    // decisive 分类规则，未调用 agent
+    DecisionSourceFallback = "fallback" // agent 不可用时的本地分类器，之后会重新分类
+    DecisionSourceUnknown  = "unknown"  // agent 多次失败
+    DecisionSourceUser     = "user"     // 用户手动修正（api-gateway 写入）
+)
+
+// maxTasksPerDecision 单封邮件最多创建的任务数
+const maxTasksPerDecision = 10
 
 type TaskDecision struct {
     Title     string `json:"title"`
     DueInDays int    `json:"due_in_days"`
+    Priority  string `json:"priority,omitempty"` // LOW / MEDIUM / HIGH，为空时使用邮件优先级
+    DueDate   string `json:"due_date,omitempty"` // ISO-8601 日期或日期时间（用户时区），优先于 due_in_days
 }
 
 
@@ -14,9 +29,68 @@ type AgentDecision struct {
     Summary    string   `json:"summary"`
 
     ShouldCreateTask bool           `json:"should_create_task"`
-    Task             *TaskDecision  `json:"task"`
+    Tasks            []TaskDecision `json:"tasks"`
+    Task             *TaskDecision  `json:"task,omitempty"` // 旧版 agent-service 只返回单个任务
 
     ShouldNotify        bool   `json:"should_notify"`
     NotificationChannel string `json:"notification_channel"`
     NotificationMessage string `json:"notification_message"`
-}
\ No newline at end of file
+
+    Source string `json:"-"` // 决策来源，为空视为 agent
+}
+
+// TaskList 返回需要创建的任务：合并 Tasks 和旧版的 Task，去掉空标题和重复标题，
+// 校验优先级（无效时清空），最多 maxTasksPerDecision 个
+func (d *AgentDecision) TaskList() []TaskDecision {
+    candidates := d.Tasks
+    if d.Task != nil {
+        candidates = append(append([]TaskDecision{}, d.Tasks...), *d.Task)
+    }
+
+    seen := make(map[string]bool)
+    tasks := make([]TaskDecision, 0, len(candidates))
+    for _, t := range candidates {
+        t.Title = strings.TrimSpace(t.Title)
+        key := strings.ToLower(t.Title)
+        if t.Title == "" || seen[key] {
+            continue
+        }
+        seen[key] = true
+
+        t.Priority = strings.ToUpper(strings.TrimSpace(t.Priority))
+        switch t.Priority {
+        case "LOW", "MEDIUM", "HIGH":
+        default:
+            t.Priority = ""
+        }
+        if t.DueInDays < 0 {
+            t.DueInDays = 0
+        }
+
+        tasks = append(tasks, t)
+        if len(tasks) == maxTasksPerDecision {
+            break
+        }
+    }
+    return tasks
+}
+
+// TrainingSample 离线分类器的训练样本：用户历史邮件及 agent / 规则给出的决策
+type TrainingSample struct {
+    Subject    string
+    Body       string
+    Categories []string
+    Priority   string
+}
+
+// FeedbackExample 用户对历史决策的修正，作为 few-shot 示例发送给 agent-service
+type FeedbackExample struct {
+    Subject             string   `json:"subject"`
+    FeedbackType        string   `json:"feedback_type"` // classification / task / notification
+    OriginalCategories  []string `json:"original_categories"`
+    OriginalPriority    string   `json:"original_priority"`
+    CorrectedCategories []string `json:"corrected_categories,omitempty"`
+    CorrectedPriority   string   `json:"corrected_priority,omitempty"`
+    Detail              string   `json:"detail,omitempty"` // 被标记为错误的任务标题 / 通知内容
+    Comment             string   `json:"comment,omitempty"`
+}
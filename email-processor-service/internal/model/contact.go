@@ -0,0 +1,43 @@
+package model
+
+// 发件人重要性（由 SenderProfile.Importance 计算，随 /decide 请求发送给 agent-service）
+const (
+	SenderImportanceVIP    = "VIP"    // 用户标记的重要联系人
+	SenderImportanceHigh   = "HIGH"   // 历史邮件优先级高或经常产生任务
+	SenderImportanceNormal = "NORMAL" // 默认（包括往来邮件太少的发件人）
+	SenderImportanceLow    = "LOW"    // 历史邮件优先级低且几乎不产生任务
+)
+
+// minMessagesForImportance 往来邮件少于该数量时不根据统计判断重要性
+const minMessagesForImportance = 3
+
+// SenderProfile 发件人的历史统计（contacts 表）
+type SenderProfile struct {
+	Address      string   `json:"address"`
+	DisplayName  string   `json:"display_name,omitempty"`
+	MessageCount int      `json:"message_count"`
+	AvgPriority  *float64 `json:"avg_priority,omitempty"` // LOW=1, MEDIUM=2, HIGH=3
+	TaskRate     float64  `json:"task_rate"`
+	IsVIP        bool     `json:"is_vip"`
+	Importance   string   `json:"importance"`
+}
+
+// ComputeImportance 根据 VIP 标记和历史统计计算发件人重要性
+func (p *SenderProfile) ComputeImportance() string {
+	if p == nil {
+		return SenderImportanceNormal
+	}
+	if p.IsVIP {
+		return SenderImportanceVIP
+	}
+	if p.MessageCount < minMessagesForImportance {
+		return SenderImportanceNormal
+	}
+	if (p.AvgPriority != nil && *p.AvgPriority >= 2.5) || p.TaskRate >= 0.5 {
+		return SenderImportanceHigh
+	}
+	if p.AvgPriority != nil && *p.AvgPriority < 1.5 && p.TaskRate < 0.1 {
+		return SenderImportanceLow
+	}
+	return SenderImportanceNormal
+}
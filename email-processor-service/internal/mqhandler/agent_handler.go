@@ -3,12 +3,17 @@ package mqhandler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
+	"email-processor-service/internal/model"
 	"email-processor-service/internal/repository"
+	"email-processor-service/internal/rules"
 	"email-processor-service/internal/service"
+	"mygoproject/contracts/db"
 	mqcontracts "mygoproject/contracts/mq"
+	"mygoproject/pkg/deadline"
 	"mygoproject/pkg/util"
 
 	"mygoproject/pkg/logger"
@@ -17,6 +22,7 @@ import (
 	"mygoproject/pkg/outbox"
 	"mygoproject/pkg/trace"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 )
@@ -26,10 +32,15 @@ const (
 )
 
 type AgentDecisionHandler struct {
-	db           *pgxpool.Pool
-	emailRepo    *repository.EmailRepository
-	metadataRepo *repository.MetadataRepository
-	outboxRepo   *outbox.Repository
+	db             *pgxpool.Pool
+	emailRepo      *repository.EmailRepository
+	metadataRepo   *repository.MetadataRepository
+	ruleRepo       *repository.ClassificationRuleRepository
+	reclassifyRepo *repository.ReclassifyRepository
+	userRepo       *repository.UserRepository
+	contactRepo    *repository.ContactRepository
+	outboxRepo     *outbox.Repository
+	ruleEngine     *rules.Engine
 
 	agentClient  *service.AgentClient
 	retryCounter *util.RetryCounter
@@ -43,6 +54,10 @@ func NewAgentDecisionHandler(
 	db *pgxpool.Pool,
 	emailRepo *repository.EmailRepository,
 	metadataRepo *repository.MetadataRepository,
+	ruleRepo *repository.ClassificationRuleRepository,
+	reclassifyRepo *repository.ReclassifyRepository,
+	userRepo *repository.UserRepository,
+	contactRepo *repository.ContactRepository,
 	agentClient *service.AgentClient,
 	retryCounter *util.RetryCounter,
 	deduper *util.Deduper,
@@ -50,21 +65,130 @@ func NewAgentDecisionHandler(
 	logger *zap.Logger,
 ) *AgentDecisionHandler {
 	return &AgentDecisionHandler{
-		db:            db,
-		emailRepo:     emailRepo,
-		metadataRepo:  metadataRepo,
-		outboxRepo:    outbox.NewRepository(db),
-		agentClient:   agentClient,
-		retryCounter:  retryCounter,
-		deduper:       deduper,
-		taskPublisher: taskPublisher,
-		logger:        logger,
+		db:             db,
+		emailRepo:      emailRepo,
+		metadataRepo:   metadataRepo,
+		ruleRepo:       ruleRepo,
+		reclassifyRepo: reclassifyRepo,
+		userRepo:       userRepo,
+		contactRepo:    contactRepo,
+		outboxRepo:     outbox.NewRepository(db),
+		ruleEngine:     rules.NewEngine(logger),
+		agentClient:    agentClient,
+		retryCounter:   retryCounter,
+		deduper:        deduper,
+		taskPublisher:  taskPublisher,
+		logger:         logger,
 	}
 }
 
+// pendingEmail 已完成准备（去重、规则、用户设置、发件人画像），等待决策的邮件
+type pendingEmail struct {
+	ctx          context.Context
+	logger       *zap.Logger
+	payload      mqcontracts.EmailReceivedPayload
+	email        *db.Email
+	ruleResult   *rules.Result
+	sender       *model.SenderProfile
+	sentAt       time.Time
+	loc          *time.Location
+	retryKey     string
+	retryCount   int64
+	dedupHandler string             // 去重锁的 handler 名，处理失败时释放
+	input        service.EmailInput // 发送给 agent-service 的请求
+}
+
+// needsAgent 命中 decisive 规则时不需要调用 agent
+func (p *pendingEmail) needsAgent() bool {
+	return !p.ruleResult.Decisive
+}
+
 func (h *AgentDecisionHandler) Handle(ctx context.Context, raw json.RawMessage) error {
 	defer h.recoverPanic()
 
+	p, err := h.prepare(ctx, raw)
+	if p == nil {
+		return err
+	}
+
+	// --------------------------
+	// Step 5: call agent-service（规则已给出决定时跳过）
+	// --------------------------
+	var agentDecision *model.AgentDecision
+	if p.needsAgent() {
+		agentDecision, err = h.agentClient.Decide(p.ctx, p.input)
+		if err != nil {
+			return h.releaseOnError(p, h.handleAgentError(p.ctx, err, p.retryKey, p.retryCount, p.payload.EmailID))
+		}
+	}
+
+	return h.releaseOnError(p, h.commit(p, agentDecision))
+}
+
+// HandleBatch 批量处理（micro-batching 消费模式）：需要 agent 的邮件合并为一次 /decide-batch 调用，
+// 之后每封邮件在各自的事务中写入，返回值与 msgs 一一对应，由消费者分别 ack / nack。
+// ctx 随消费者停止而取消，每封邮件的 trace_id 随请求中的 EmailInput 发送
+func (h *AgentDecisionHandler) HandleBatch(ctx context.Context, msgs []mq.BatchMessage) []error {
+	errs := make([]error, len(msgs))
+	pending := make([]*pendingEmail, len(msgs))
+
+	var inputs []service.EmailInput
+	var agentIdx []int
+	for i, msg := range msgs {
+		func() {
+			defer h.recoverPanic()
+			pending[i], errs[i] = h.prepare(msg.Ctx, msg.Body)
+		}()
+		if pending[i] != nil && pending[i].needsAgent() {
+			inputs = append(inputs, pending[i].input)
+			agentIdx = append(agentIdx, i)
+		}
+	}
+
+	decisions := make([]*model.AgentDecision, len(msgs))
+	if len(inputs) > 0 {
+		results, decideErrs := h.agentClient.DecideBatch(ctx, inputs)
+		for j, i := range agentIdx {
+			if decideErrs[j] != nil {
+				p := pending[i]
+				if ctx.Err() != nil {
+					// 消费者正在停止，调用被取消不是 agent 的错误：重新入队，不写 unknown
+					errs[i] = h.releaseOnError(p, decideErrs[j])
+					pending[i] = nil
+					continue
+				}
+				errs[i] = h.releaseOnError(p, h.handleAgentError(p.ctx, decideErrs[j], p.retryKey, p.retryCount, p.payload.EmailID))
+				pending[i] = nil
+				continue
+			}
+			decisions[i] = results[j]
+		}
+		traceIDs := make([]string, len(inputs))
+		for j, input := range inputs {
+			traceIDs[j] = input.TraceID
+		}
+		h.logger.Info("Agent batch decided",
+			zap.Int("batch_size", len(msgs)),
+			zap.Int("agent_calls", len(inputs)),
+			zap.Strings("trace_ids", traceIDs),
+		)
+	}
+
+	for i, p := range pending {
+		if p == nil {
+			continue
+		}
+		func() {
+			defer h.recoverPanic()
+			errs[i] = h.releaseOnError(p, h.commit(p, decisions[i]))
+		}()
+	}
+	return errs
+}
+
+// prepare 执行 Step 1-4：解码、幂等检查和去重、重试计数、评估分类规则，并加载用户设置和发件人画像。
+// 不需要继续处理（已分类、重复、不可重试的错误）时返回 nil
+func (h *AgentDecisionHandler) prepare(ctx context.Context, raw json.RawMessage) (_ *pendingEmail, err error) {
 	// --------------------------
 	// Step 1: decode payload
 	// --------------------------
@@ -74,7 +198,7 @@ func (h *AgentDecisionHandler) Handle(ctx context.Context, raw json.RawMessage)
 			zap.String("raw", string(raw)),
 			zap.Error(err),
 		)
-		return fmt.Errorf("bad_payload: %w", err)
+		return nil, fmt.Errorf("bad_payload: %w", err)
 	}
 
 	// 从 payload 中提取 trace_id 并添加到 context（如果存在）
@@ -93,8 +217,15 @@ func (h *AgentDecisionHandler) Handle(ctx context.Context, raw json.RawMessage)
 	// Step 2: load email
 	// --------------------------
 	email, _, err := h.emailRepo.FindRawWithMetadataByID(ctx, payload.EmailID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// 邮件在分类前已被用户删除：确认并跳过
+		h.logger.Info("Email deleted before classification, skip",
+			zap.Int("email_id", payload.EmailID),
+		)
+		return nil, nil
+	}
 	if err != nil {
-		return h.handleRepoError("FindRawWithMetadataByID", err)
+		return nil, h.handleRepoError("FindRawWithMetadataByID", err)
 	}
 
 	// 幂等：已经标记 classified → 跳过
@@ -102,16 +233,26 @@ func (h *AgentDecisionHandler) Handle(ctx context.Context, raw json.RawMessage)
 		h.logger.Info("Email already classified, skip",
 			zap.Int("email_id", payload.EmailID),
 		)
-		return nil
+		return nil, nil
 	}
 
-	// Redis 去重（避免并发重复消费）
-	if !h.deduper.AcquireOnce(ctx, "agent", payload.EmailID) {
+	// Redis 去重（避免并发重复消费），重新分类请求使用独立的去重键
+	dedupHandler := "agent"
+	if payload.ReclassifyToken != "" {
+		dedupHandler = "agent:reclassify:" + payload.ReclassifyToken
+	}
+	if !h.deduper.AcquireOnce(ctx, dedupHandler, payload.EmailID) {
 		h.logger.Info("Duplicated event, skip",
 			zap.Int("email_id", payload.EmailID),
 		)
-		return nil
+		return nil, nil
 	}
+	// 之后的步骤失败时消息重新入队，释放去重锁，否则重新投递的消息会被当作重复消息跳过
+	defer func() {
+		if err != nil {
+			h.deduper.Release(context.WithoutCancel(ctx), dedupHandler, payload.EmailID)
+		}
+	}()
 
 	// --------------------------
 	// Step 3: retry count
@@ -121,21 +262,93 @@ func (h *AgentDecisionHandler) Handle(ctx context.Context, raw json.RawMessage)
 	h.logger.Info("Retry count", zap.Int64("retry", retryCount))
 
 	// --------------------------
-	// Step 4: call agent-service
+	// Step 4: evaluate user classification rules
 	// --------------------------
-	decision, err := h.agentClient.Decide(ctx, service.EmailInput{
-		EmailID: payload.EmailID,
-		UserID:  payload.UserID,
+	userRules, err := h.ruleRepo.ListEnabledByUser(ctx, payload.UserID)
+	if err != nil {
+		return nil, h.handleRepoError("ListClassificationRules", err)
+	}
+	ruleResult := h.ruleEngine.Evaluate(userRules, rules.Email{
+		From:    payload.From,
 		Subject: payload.Subject,
 		Body:    payload.Body,
+		Headers: rules.HeadersFromRawJSON(email.RawJSON),
 	})
 
+	// 相对截止时间以邮件 Date 头为基准，按用户时区解析为绝对时间
+	settings, err := h.userRepo.GetSettings(ctx, payload.UserID)
 	if err != nil {
-		return h.handleAgentError(ctx, err, retryKey, retryCount, payload.EmailID)
+		return nil, h.handleRepoError("GetUserSettings", err)
+	}
+	loc := deadline.LoadLocation(settings.Timezone)
+	sentAt := deadlineBase(payload, time.Now()).In(loc)
+
+	// 发件人历史统计和 VIP 标记（只作为参考信号，读取失败不影响分类）
+	sender, err := h.contactRepo.GetProfile(ctx, payload.UserID, payload.From)
+	if err != nil {
+		traceLogger.Warn("Failed to load sender profile", zap.String("from", payload.From), zap.Error(err))
+		sender = nil
+	}
+
+	return &pendingEmail{
+		ctx:          ctx,
+		logger:       traceLogger,
+		payload:      payload,
+		email:        email,
+		ruleResult:   ruleResult,
+		sender:       sender,
+		sentAt:       sentAt,
+		loc:          loc,
+		retryKey:     retryKey,
+		retryCount:   retryCount,
+		dedupHandler: dedupHandler,
+		input: service.EmailInput{
+			EmailID:  payload.EmailID,
+			UserID:   payload.UserID,
+			Subject:  payload.Subject,
+			Body:     payload.Body,
+			SentAt:   &sentAt,
+			Timezone: loc.String(),
+			Sender:   sender,
+			From:     payload.From,
+			TraceID:  trace.FromContext(ctx),
+			// 重新分类需要 agent 重新给出决策
+			NoCache: !settings.DecisionCacheEnabled || payload.ReclassifyToken != "",
+		},
+	}, nil
+}
+
+// commit 合并 agent 决策、规则和发件人信号，并执行 Step 6-10（单个事务）。
+// 命中 decisive 规则时 agentDecision 为 nil
+func (h *AgentDecisionHandler) commit(p *pendingEmail, agentDecision *model.AgentDecision) error {
+	ctx, traceLogger, payload, email := p.ctx, p.logger, p.payload, p.email
+	ruleResult := p.ruleResult
+
+	decision := agentDecision
+	if ruleResult.Decisive {
+		decision = ruleResult.Decision()
+		metrics.IncrementClassificationRule("decisive")
+		traceLogger.Info("Email classified by rules, skip agent",
+			zap.Int("email_id", payload.EmailID),
+			zap.Any("rule_hits", ruleResult.Hits),
+		)
+	} else {
+		applySenderImportance(decision, p.sender, payload.Subject)
+
+		// 命中的非 decisive 规则覆盖 AI 决策
+		if ruleResult.Matched() {
+			ruleResult.Apply(decision)
+			metrics.IncrementClassificationRule("merged")
+		}
+	}
+
+	// 发件人规则（force_priority）优先于 AI 给出的优先级
+	if payload.ForcePriority != "" {
+		decision.Priority = payload.ForcePriority
 	}
 
 	// --------------------------
-	// Step 5-8: 使用事务写入 metadata、outbox 事件和更新状态
+	// Step 6-9: 使用事务写入 metadata、outbox 事件和更新状态
 	// --------------------------
 	tx, err := h.db.Begin(ctx)
 	if err != nil {
@@ -143,37 +356,100 @@ func (h *AgentDecisionHandler) Handle(ctx context.Context, raw json.RawMessage)
 	}
 	defer tx.Rollback(ctx)
 
-	// Step 5: write metadata (in transaction)
-	if err := h.metadataRepo.InsertDecisionTx(ctx, tx, payload.EmailID, decision); err != nil {
+	// 重新分类：在覆盖之前读取旧决策，发件人统计中用新优先级替换旧决策计入的优先级
+	prevPriority := ""
+	if payload.ReclassifyToken != "" {
+		priority, source, err := h.metadataRepo.GetDecisionTx(ctx, tx, payload.EmailID)
+		if err != nil {
+			return h.handleRepoError("GetDecision", err)
+		}
+		if source == model.DecisionSourceAgent {
+			prevPriority = priority
+		}
+	}
+
+	// Step 6: write metadata and rule hits (in transaction)
+	if err := h.metadataRepo.InsertDecisionTx(ctx, tx, payload.EmailID, decision, ruleResult.Hits); err != nil {
 		return h.handleRepoError("InsertDecision", err)
 	}
 
-	// Step 6: insert task.created event to outbox (if needed)
+	// 离线分类的结果在 agent 恢复后重新分类
+	if decision.Source == model.DecisionSourceFallback {
+		if err := h.reclassifyRepo.EnqueueTx(ctx, tx, payload.EmailID, payload.UserID, model.DecisionSourceFallback); err != nil {
+			return h.handleRepoError("EnqueueReclassify", err)
+		}
+	}
+
+	// Step 7: insert task.bulk_created event to outbox (if needed)
+	// 一封邮件提取的所有任务合并为一个事件，task-service 按 (email_id, user_id, title) 保证幂等
 	traceID := trace.FromContext(ctx)
 	emailID64 := int64(payload.EmailID)
-	if decision.ShouldCreateTask && decision.Task != nil {
-		taskPayload := mqcontracts.TaskCreatedPayload{
-			EmailID:   payload.EmailID,
-			UserID:    payload.UserID,
-			Title:     decision.Task.Title,
-			DueInDays: decision.Task.DueInDays,
-			TraceID:   traceID,
+	tasks := decision.TaskList()
+	createTask := decision.ShouldCreateTask && len(tasks) > 0
+	if createTask && payload.ReclassifyToken != "" {
+		// 重新分类：之前的决策已经创建过任务时不再重复创建
+		exists, err := h.emailRepo.HasTaskForEmailTx(ctx, tx, payload.EmailID, payload.UserID)
+		if err != nil {
+			return h.handleRepoError("HasTaskForEmail", err)
+		}
+		createTask = !exists
+	}
+	if createTask && email.ThreadID != nil {
+		// 同一会话中已有待办任务（例如对同一请求的回复），不再重复创建
+		exists, err := h.emailRepo.HasPendingTaskInThreadTx(ctx, tx, *email.ThreadID, payload.UserID, payload.EmailID)
+		if err != nil {
+			return h.handleRepoError("HasPendingTaskInThread", err)
+		}
+		if exists {
+			traceLogger.Info("Thread already has a pending task, skip task.bulk_created",
+				zap.Int("email_id", payload.EmailID),
+				zap.Int("thread_id", *email.ThreadID),
+			)
+			createTask = false
+		}
+	}
+
+	if createTask {
+		items := make([]mqcontracts.TaskItem, 0, len(tasks))
+		titles := make([]string, 0, len(tasks))
+		for _, t := range tasks {
+			// 任务没有单独的优先级时使用邮件的优先级
+			priority := t.Priority
+			if priority == "" {
+				priority = decision.Priority
+			}
+			dueAt := deadline.Resolve(t.DueDate, t.DueInDays, p.sentAt, p.loc)
+			items = append(items, mqcontracts.TaskItem{
+				Title:     t.Title,
+				DueInDays: t.DueInDays,
+				DueAt:     &dueAt,
+				Priority:  priority,
+			})
+			titles = append(titles, t.Title)
+		}
+
+		bulkPayload := mqcontracts.TaskBulkCreatedPayload{
+			EmailID: payload.EmailID,
+			UserID:  payload.UserID,
+			Tasks:   items,
+			TraceID: traceID,
 		}
 
-		if err := outbox.InsertEventInTx(ctx, tx, h.outboxRepo, "task", &emailID64, "task.created", taskPayload); err != nil {
-			h.logger.Error("Failed to insert task.created to outbox", zap.Error(err))
+		if err := outbox.InsertEventInTx(ctx, tx, h.outboxRepo, "task", &emailID64, "task.bulk_created", bulkPayload); err != nil {
+			h.logger.Error("Failed to insert task.bulk_created to outbox", zap.Error(err))
 			return err
 		}
 
-		traceLogger.Info("Inserted task.created event to outbox",
-			zap.Int("email_id", taskPayload.EmailID),
-			zap.Int("user_id", taskPayload.UserID),
-			zap.String("title", taskPayload.Title),
+		traceLogger.Info("Inserted task.bulk_created event to outbox",
+			zap.Int("email_id", bulkPayload.EmailID),
+			zap.Int("user_id", bulkPayload.UserID),
+			zap.Strings("titles", titles),
 		)
 	}
 
-	// Step 7: insert notification.created event to outbox (if needed)
-	if decision.ShouldNotify {
+	// Step 8: insert notification.created event to outbox (if needed)
+	// 重新分类只更新分类结果，不再重复通知
+	if decision.ShouldNotify && payload.ReclassifyToken == "" {
 		notiPayload := mqcontracts.NotificationCreatedPayload{
 			UserID:    payload.UserID,
 			EmailID:   payload.EmailID,
@@ -194,7 +470,17 @@ func (h *AgentDecisionHandler) Handle(ctx context.Context, raw json.RawMessage)
 		}
 	}
 
-	// Step 8: update email status (in transaction)
+	// 增量更新发件人统计：只有 agent 给出的优先级计入平均优先级
+	contactPriority := ""
+	if decision.Source == model.DecisionSourceAgent {
+		contactPriority = decision.Priority
+	}
+	if err := h.contactRepo.RecordDecisionTx(ctx, tx, payload.EmailID, payload.UserID, payload.From,
+		contactPriority, prevPriority, createTask, payload.ReclassifyToken != ""); err != nil {
+		return h.handleRepoError("RecordContactDecision", err)
+	}
+
+	// Step 9: update email status (in transaction)
 	if err := h.emailRepo.UpdateStatusTx(ctx, tx, payload.EmailID, "classified"); err != nil {
 		return h.handleRepoError("UpdateStatus", err)
 	}
@@ -207,14 +493,16 @@ func (h *AgentDecisionHandler) Handle(ctx context.Context, raw json.RawMessage)
 	// 记录邮件处理成功
 	metrics.IncrementEmailProcessed("success")
 	// 记录任务生成（如果创建了任务）
-	if decision.ShouldCreateTask && decision.Task != nil {
-		metrics.IncrementTaskGeneration("email")
+	if createTask {
+		for range tasks {
+			metrics.IncrementTaskGeneration("email")
+		}
 	}
 
 	// --------------------------
-	// Step 9: cleanup & finish
+	// Step 10: cleanup & finish
 	// --------------------------
-	h.retryCounter.Reset(ctx, retryKey)
+	h.retryCounter.Reset(ctx, p.retryKey)
 
 	h.logger.Info("Email processed successfully",
 		zap.Int("email_id", payload.EmailID),
@@ -225,6 +513,43 @@ func (h *AgentDecisionHandler) Handle(ctx context.Context, raw json.RawMessage)
 	return nil
 }
 
+// deadlineBase 返回解析相对截止时间的基准：邮件 Date 头；缺失或明显异常（晚于当前时间）时使用入库时间
+func deadlineBase(payload mqcontracts.EmailReceivedPayload, now time.Time) time.Time {
+	if payload.SentAt != nil && !payload.SentAt.IsZero() && !payload.SentAt.After(now.Add(time.Hour)) {
+		return *payload.SentAt
+	}
+	if !payload.ReceivedAt.IsZero() {
+		return payload.ReceivedAt
+	}
+	return now
+}
+
+// applySenderImportance 根据发件人重要性调整通知决策（规则命中时由规则覆盖）：
+// VIP 发件人总是通知；低重要性发件人只有 HIGH 优先级的邮件才通知
+func applySenderImportance(decision *model.AgentDecision, sender *model.SenderProfile, subject string) {
+	if sender == nil {
+		return
+	}
+	switch sender.Importance {
+	case model.SenderImportanceVIP:
+		decision.ShouldNotify = true
+		if decision.NotificationChannel == "" {
+			decision.NotificationChannel = "EMAIL"
+		}
+		if decision.NotificationMessage == "" {
+			name := sender.DisplayName
+			if name == "" {
+				name = sender.Address
+			}
+			decision.NotificationMessage = fmt.Sprintf("重要联系人 %s 发来邮件：%s", name, subject)
+		}
+	case model.SenderImportanceLow:
+		if decision.Priority != "HIGH" {
+			decision.ShouldNotify = false
+		}
+	}
+}
+
 func (h *AgentDecisionHandler) handleRepoError(op string, err error) error {
 	isRetryable, errType := util.IsRetryableError(err)
 	h.logger.Error("Repo error",
@@ -242,6 +567,14 @@ func (h *AgentDecisionHandler) handleRepoError(op string, err error) error {
 
 func (h *AgentDecisionHandler) handleAgentError(ctx context.Context, err error, retryKey string, retryCount int64, emailID int) error {
 	isRetryable, errType := util.IsRetryableError(err)
+	if errors.Is(err, service.ErrInvalidDecision) {
+		// agent 可用但响应结构无效，重试通常得到同样的结果
+		isRetryable, errType = false, "invalid_agent_response"
+	}
+	if errors.Is(err, service.ErrBatchFailed) {
+		// 整批调用失败（如 5xx），重新入队等待 agent 恢复
+		isRetryable, errType = true, "agent_batch_failed"
+	}
 
 	h.logger.Warn("Agent service error",
 		zap.String("error", err.Error()),
@@ -275,6 +608,14 @@ func (h *AgentDecisionHandler) handleAgentError(ctx context.Context, err error,
 	return err // nack → 重试
 }
 
+// releaseOnError 处理失败（消息重新入队）时释放去重锁，返回 err
+func (h *AgentDecisionHandler) releaseOnError(p *pendingEmail, err error) error {
+	if err != nil {
+		h.deduper.Release(context.WithoutCancel(p.ctx), p.dedupHandler, p.payload.EmailID)
+	}
+	return err
+}
+
 func (h *AgentDecisionHandler) recoverPanic() {
 	if r := recover(); r != nil {
 		h.logger.Error("panic recovered in handler", zap.Any("panic", r))
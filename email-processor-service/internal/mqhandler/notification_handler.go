@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"time"
 
+	"email-processor-service/internal/model"
+	"email-processor-service/internal/repository"
 	mqcontracts "mygoproject/contracts/mq"
 	util "mygoproject/pkg/util"
 	"mygoproject/pkg/mq"
@@ -14,9 +16,10 @@ import (
 )
 
 type EmailReceivedNotificationHandler struct {
-	publisher *mq.Publisher
-	logger    *zap.Logger
-	deduper   *util.Deduper
+	publisher   *mq.Publisher
+	logger      *zap.Logger
+	deduper     *util.Deduper
+	contactRepo *repository.ContactRepository // 可选：按发件人重要性调整通知
 }
 
 func NewEmailReceivedNotificationHandler(
@@ -31,6 +34,12 @@ func NewEmailReceivedNotificationHandler(
 	}
 }
 
+// WithContacts 启用发件人重要性：低重要性发件人不通知，VIP 发件人在通知中注明
+func (h *EmailReceivedNotificationHandler) WithContacts(contactRepo *repository.ContactRepository) *EmailReceivedNotificationHandler {
+	h.contactRepo = contactRepo
+	return h
+}
+
 // HandleEmailReceived -- 发布 notification.created 事件（通知由 notification-service 处理）
 func (h *EmailReceivedNotificationHandler) HandleEmailReceived(ctx context.Context, raw json.RawMessage) error {
 	// Panic 恢复：确保 handler 是稳态的
@@ -62,6 +71,29 @@ func (h *EmailReceivedNotificationHandler) HandleEmailReceived(ctx context.Conte
 		return nil
 	}
 
+	message := fmt.Sprintf("你收到了新邮件：%s", p.Subject)
+	if h.contactRepo != nil {
+		sender, err := h.contactRepo.GetProfile(ctx, p.UserID, p.From)
+		if err != nil {
+			h.logger.Warn("Failed to load sender profile", zap.String("from", p.From), zap.Error(err))
+		}
+		switch {
+		case sender == nil:
+		case sender.Importance == model.SenderImportanceLow:
+			h.logger.Info("Skipped notification for low-importance sender",
+				zap.Int("email_id", p.EmailID),
+				zap.String("from", p.From),
+			)
+			return nil
+		case sender.Importance == model.SenderImportanceVIP:
+			name := sender.DisplayName
+			if name == "" {
+				name = sender.Address
+			}
+			message = fmt.Sprintf("重要联系人 %s 发来新邮件：%s", name, p.Subject)
+		}
+	}
+
 	h.logger.Info("Publishing notification.created event",
 		zap.Int("email_id", p.EmailID),
 		zap.Int("user_id", p.UserID),
@@ -73,7 +105,7 @@ func (h *EmailReceivedNotificationHandler) HandleEmailReceived(ctx context.Conte
 		"user_id":    p.UserID,
 		"email_id":   p.EmailID,
 		"channel":    "EMAIL",
-		"message":    fmt.Sprintf("你收到了新邮件：%s", p.Subject),
+		"message":    message,
 		"created_at": time.Now(),
 	}
 
@@ -0,0 +1,143 @@
+package reclassify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"email-processor-service/internal/repository"
+	"email-processor-service/internal/service"
+	"mygoproject/pkg/outbox"
+	"mygoproject/pkg/trace"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Worker 在 agent-service 恢复后，将离线分类（fallback）的邮件重新发布到 email.received.agent
+type Worker struct {
+	db             *pgxpool.Pool
+	emailRepo      *repository.EmailRepository
+	reclassifyRepo *repository.ReclassifyRepository
+	outboxRepo     *outbox.Repository
+	agentClient    *service.AgentClient
+	logger         *zap.Logger
+
+	interval  time.Duration
+	batchSize int
+}
+
+// NewWorker 创建新的重新分类 Worker
+func NewWorker(
+	db *pgxpool.Pool,
+	emailRepo *repository.EmailRepository,
+	reclassifyRepo *repository.ReclassifyRepository,
+	agentClient *service.AgentClient,
+	logger *zap.Logger,
+) *Worker {
+	return &Worker{
+		db:             db,
+		emailRepo:      emailRepo,
+		reclassifyRepo: reclassifyRepo,
+		outboxRepo:     outbox.NewRepository(db),
+		agentClient:    agentClient,
+		logger:         logger,
+		interval:       time.Minute, // 默认每分钟检查一次
+		batchSize:      20,          // 每次最多重新发布20封，避免 agent 刚恢复就被打满
+	}
+}
+
+// WithInterval 设置检查间隔
+func (w *Worker) WithInterval(interval time.Duration) *Worker {
+	w.interval = interval
+	return w
+}
+
+// WithBatchSize 设置批次大小
+func (w *Worker) WithBatchSize(batchSize int) *Worker {
+	w.batchSize = batchSize
+	return w
+}
+
+// Start 启动 Worker（在 goroutine 中运行）
+func (w *Worker) Start(ctx context.Context) {
+	w.logger.Info("Starting re-classification worker",
+		zap.Duration("interval", w.interval),
+		zap.Int("batch_size", w.batchSize),
+	)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("Re-classification worker stopped")
+			return
+		case <-ticker.C:
+			w.processQueue(ctx)
+		}
+	}
+}
+
+func (w *Worker) processQueue(ctx context.Context) {
+	// agent 仍不可用（或熔断打开）时不重新发布，否则只会再次走 fallback
+	if err := w.agentClient.Ping(ctx); err != nil {
+		w.logger.Debug("Agent service unavailable, skip re-classification", zap.Error(err))
+		return
+	}
+
+	n, err := w.republishBatch(ctx)
+	if err != nil {
+		w.logger.Error("Failed to republish emails for re-classification", zap.Error(err))
+		return
+	}
+	if n > 0 {
+		w.logger.Info("Republished fallback-classified emails to agent", zap.Int("count", n))
+	}
+}
+
+// republishBatch 在一个事务中：重置邮件状态、写入 email.received.agent outbox 事件、移出队列
+func (w *Worker) republishBatch(ctx context.Context) (int, error) {
+	tx, err := w.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	ids, err := w.reclassifyRepo.ClaimTx(ctx, tx, w.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim queued emails: %w", err)
+	}
+
+	token := strconv.FormatInt(time.Now().UnixNano(), 36)
+	for _, emailID := range ids {
+		payload, err := w.emailRepo.BuildAgentPayloadTx(ctx, tx, emailID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("failed to build payload for email %d: %w", emailID, err)
+		}
+		if payload != nil {
+			payload.TraceID = trace.GenerateTraceID()
+			payload.ReclassifyToken = token
+
+			if err := w.emailRepo.UpdateStatusTx(ctx, tx, emailID, "received"); err != nil {
+				return 0, fmt.Errorf("failed to reset status of email %d: %w", emailID, err)
+			}
+			emailID64 := int64(emailID)
+			if err := outbox.InsertEventInTx(ctx, tx, w.outboxRepo, "email", &emailID64, "email.received.agent", payload); err != nil {
+				return 0, fmt.Errorf("failed to insert outbox event for email %d: %w", emailID, err)
+			}
+		}
+		if err := w.reclassifyRepo.DeleteTx(ctx, tx, emailID); err != nil {
+			return 0, fmt.Errorf("failed to dequeue email %d: %w", emailID, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(ids), nil
+}
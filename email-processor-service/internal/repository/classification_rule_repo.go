@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+
+	"mygoproject/contracts/db"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ClassificationRuleRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewClassificationRuleRepository(db *pgxpool.Pool) *ClassificationRuleRepository {
+	return &ClassificationRuleRepository{db: db}
+}
+
+// ListEnabledByUser returns the user's enabled rules in evaluation order.
+func (r *ClassificationRuleRepository) ListEnabledByUser(ctx context.Context, userID int) ([]db.ClassificationRule, error) {
+	query := `
+        SELECT id, user_id, name, position, enabled, decisive, conditions, actions, created_at, updated_at
+        FROM classification_rules
+        WHERE user_id = $1 AND enabled = TRUE
+        ORDER BY position ASC, id ASC
+    `
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []db.ClassificationRule
+	for rows.Next() {
+		var rule db.ClassificationRule
+		if err := rows.Scan(
+			&rule.ID,
+			&rule.UserID,
+			&rule.Name,
+			&rule.Position,
+			&rule.Enabled,
+			&rule.Decisive,
+			&rule.Conditions,
+			&rule.Actions,
+			&rule.CreatedAt,
+			&rule.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
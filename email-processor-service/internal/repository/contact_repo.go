@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"email-processor-service/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ContactRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewContactRepository(db *pgxpool.Pool) *ContactRepository {
+	return &ContactRepository{db: db}
+}
+
+// GetProfile returns the sender's statistics with the computed importance.
+// Returns nil (and no error) for an unknown sender.
+func (r *ContactRepository) GetProfile(ctx context.Context, userID int, address string) (*model.SenderProfile, error) {
+	address = strings.ToLower(strings.TrimSpace(address))
+	if address == "" {
+		return nil, nil
+	}
+
+	query := `
+        SELECT address, display_name, message_count, avg_priority::float8, task_rate::float8, is_vip
+        FROM contacts
+        WHERE user_id = $1 AND address = $2
+    `
+	var p model.SenderProfile
+	err := r.db.QueryRow(ctx, query, userID, address).Scan(
+		&p.Address, &p.DisplayName, &p.MessageCount, &p.AvgPriority, &p.TaskRate, &p.IsVIP,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.Importance = p.ComputeImportance()
+	return &p, nil
+}
+
+// RecordDecisionTx adds one classification to the sender's running statistics in a transaction.
+// priority is counted towards avg_priority only when non-empty (agent / user decisions).
+// A re-classified email is already counted in message_count, and prevPriority (the priority counted
+// for its previous decision, empty if none) is replaced instead of added to. The VIP flag is left untouched.
+func (r *ContactRepository) RecordDecisionTx(ctx context.Context, tx pgx.Tx, emailID, userID int, address, priority, prevPriority string, createdTask, reclassified bool) error {
+	address = strings.ToLower(strings.TrimSpace(address))
+	if address == "" {
+		return nil
+	}
+
+	d := newContactDelta(priority, prevPriority, createdTask, reclassified)
+
+	query := `
+        INSERT INTO contacts (user_id, address, display_name, message_count,
+                              priority_sum, priority_count, task_count, classified_count,
+                              avg_priority, task_rate, last_contact_at, updated_at)
+        SELECT $1, $2, COALESCE(r.raw_json->>'from_name', ''), 1,
+               $4::int, $5::int, $6::int, 1,
+               CASE WHEN $5::int > 0 THEN $4::numeric / $5::int END,
+               $6::numeric,
+               r.created_at, NOW()
+        FROM emails_raw r
+        WHERE r.id = $3
+        ON CONFLICT (user_id, address) DO UPDATE SET
+            display_name     = COALESCE(NULLIF(EXCLUDED.display_name, ''), contacts.display_name),
+            message_count    = contacts.message_count + $7::int,
+            priority_sum     = contacts.priority_sum + EXCLUDED.priority_sum,
+            priority_count   = contacts.priority_count + EXCLUDED.priority_count,
+            task_count       = contacts.task_count + EXCLUDED.task_count,
+            classified_count = contacts.classified_count + $7::int,
+            avg_priority     = CASE WHEN contacts.priority_count + EXCLUDED.priority_count > 0
+                                    THEN (contacts.priority_sum + EXCLUDED.priority_sum)::numeric
+                                         / (contacts.priority_count + EXCLUDED.priority_count)
+                               END,
+            task_rate        = LEAST(1, (contacts.task_count + EXCLUDED.task_count)::numeric
+                                        / GREATEST(contacts.classified_count + $7::int, 1)),
+            last_contact_at  = GREATEST(contacts.last_contact_at, EXCLUDED.last_contact_at),
+            updated_at       = NOW()
+    `
+	_, err := tx.Exec(ctx, query, userID, address, emailID, d.prioritySum, d.priorityCount, d.tasks, d.messages)
+	return err
+}
+
+// contactDelta is the change one classification makes to a sender's statistics.
+type contactDelta struct {
+	messages      int
+	prioritySum   int
+	priorityCount int
+	tasks         int
+}
+
+func newContactDelta(priority, prevPriority string, createdTask, reclassified bool) contactDelta {
+	var d contactDelta
+	d.prioritySum, d.priorityCount = priorityScore(priority)
+	if reclassified {
+		prevSum, prevCount := priorityScore(prevPriority)
+		d.prioritySum -= prevSum
+		d.priorityCount -= prevCount
+	} else {
+		d.messages = 1
+	}
+	if createdTask {
+		d.tasks = 1
+	}
+	return d
+}
+
+// priorityScore maps a priority to its avg_priority score (LOW=1, MEDIUM=2, HIGH=3).
+// count is 0 for a priority that is not counted.
+func priorityScore(priority string) (score, count int) {
+	switch priority {
+	case "LOW":
+		return 1, 1
+	case "MEDIUM":
+		return 2, 1
+	case "HIGH":
+		return 3, 1
+	}
+	return 0, 0
+}
@@ -0,0 +1,62 @@
+package repository
+
+import "testing"
+
+// contactStats 模拟 contacts 表中 RecordDecisionTx 维护的计数
+type contactStats struct {
+	messages, prioritySum, priorityCount, tasks int
+}
+
+func (s *contactStats) record(d contactDelta) {
+	s.messages += d.messages
+	s.prioritySum += d.prioritySum
+	s.priorityCount += d.priorityCount
+	s.tasks += d.tasks
+}
+
+func (s *contactStats) avgPriority() float64 {
+	return float64(s.prioritySum) / float64(s.priorityCount)
+}
+
+func TestNewContactDelta(t *testing.T) {
+	tests := []struct {
+		name         string
+		priority     string
+		prevPriority string
+		createdTask  bool
+		reclassified bool
+		want         contactDelta
+	}{
+		{"first classification", "HIGH", "", true, false, contactDelta{messages: 1, prioritySum: 3, priorityCount: 1, tasks: 1}},
+		{"fallback decision not counted", "", "", false, false, contactDelta{messages: 1}},
+		{"reclassify fallback", "MEDIUM", "", false, true, contactDelta{prioritySum: 2, priorityCount: 1}},
+		{"reclassify agent decision", "LOW", "HIGH", false, true, contactDelta{prioritySum: -2}},
+		{"reclassify to fallback", "", "MEDIUM", false, true, contactDelta{prioritySum: -2, priorityCount: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newContactDelta(tt.priority, tt.prevPriority, tt.createdTask, tt.reclassified)
+			if got != tt.want {
+				t.Errorf("newContactDelta = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewContactDelta_ReclassifyTwiceKeepsAverage(t *testing.T) {
+	var stats contactStats
+	stats.record(newContactDelta("LOW", "", false, false))
+	stats.record(newContactDelta("HIGH", "", true, false))
+	before := stats
+
+	// 同一封 HIGH 邮件被重新分类两次，结果不变
+	stats.record(newContactDelta("HIGH", "HIGH", false, true))
+	stats.record(newContactDelta("HIGH", "HIGH", false, true))
+
+	if stats != before {
+		t.Errorf("stats = %+v, want %+v", stats, before)
+	}
+	if got := stats.avgPriority(); got != 2 {
+		t.Errorf("avg priority = %v, want 2", got)
+	}
+}
@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"mygoproject/contracts/db"
+	mqcontracts "mygoproject/contracts/mq"
+	"mygoproject/pkg/emailstore"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -28,6 +30,7 @@ func (r *EmailRepository) FindRawWithMetadataByID(ctx context.Context, id int) (
             r.raw_json,
             r.status,
             r.created_at,
+            r.thread_id,
             m.email_id
         FROM emails_raw r
         LEFT JOIN emails_metadata m ON r.id = m.email_id
@@ -43,6 +46,7 @@ func (r *EmailRepository) FindRawWithMetadataByID(ctx context.Context, id int) (
 		&e.RawJSON,
 		&e.Status,
 		&e.CreatedAt,
+		&e.ThreadID,
 		&metadataEmailID,
 	)
 	if err != nil {
@@ -72,3 +76,53 @@ func (r *EmailRepository) UpdateStatusTx(ctx context.Context, tx pgx.Tx, id int,
 	_, err := tx.Exec(ctx, query, status, id)
 	return err
 }
+
+// HasPendingTaskInThreadTx reports whether another email in the same thread already has a pending task,
+// either created by task-service or still waiting in the outbox as a task event.
+func (r *EmailRepository) HasPendingTaskInThreadTx(ctx context.Context, tx pgx.Tx, threadID, userID, excludeEmailID int) (bool, error) {
+	query := `
+        SELECT EXISTS (
+            SELECT 1
+            FROM tasks t
+            JOIN emails_raw e ON e.id = t.email_id
+            WHERE e.thread_id = $1
+              AND t.user_id = $2
+              AND t.status = 'pending'
+              AND e.id <> $3
+        ) OR EXISTS (
+            SELECT 1
+            FROM outbox_events o
+            JOIN emails_raw e ON e.id = o.aggregate_id
+            WHERE e.thread_id = $1
+              AND e.user_id = $2
+              AND o.routing_key IN ('task.created', 'task.bulk_created')
+              AND o.status = 'pending'
+              AND e.id <> $3
+        )
+    `
+	var exists bool
+	err := tx.QueryRow(ctx, query, threadID, userID, excludeEmailID).Scan(&exists)
+	return exists, err
+}
+
+// HasTaskForEmailTx reports whether a task was already created for the email,
+// either by task-service or still waiting in the outbox as a task event.
+func (r *EmailRepository) HasTaskForEmailTx(ctx context.Context, tx pgx.Tx, emailID, userID int) (bool, error) {
+	query := `
+        SELECT EXISTS (
+            SELECT 1 FROM tasks WHERE email_id = $1 AND user_id = $2
+        ) OR EXISTS (
+            SELECT 1 FROM outbox_events
+            WHERE aggregate_id = $1 AND routing_key IN ('task.created', 'task.bulk_created') AND status = 'pending'
+        )
+    `
+	var exists bool
+	err := tx.QueryRow(ctx, query, emailID, userID).Scan(&exists)
+	return exists, err
+}
+
+// BuildAgentPayloadTx rebuilds the email.received payload of an existing email for re-classification
+// (shared with api-gateway).
+func (r *EmailRepository) BuildAgentPayloadTx(ctx context.Context, tx pgx.Tx, id int) (*mqcontracts.EmailReceivedPayload, error) {
+	return emailstore.BuildAgentPayloadTx(ctx, tx, id)
+}
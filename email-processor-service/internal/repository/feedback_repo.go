@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+
+	"email-processor-service/internal/model"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type FeedbackRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewFeedbackRepository(db *pgxpool.Pool) *FeedbackRepository {
+	return &FeedbackRepository{db: db}
+}
+
+// RecentCorrections returns the user's most recent feedback on AI decisions, newest first.
+func (r *FeedbackRepository) RecentCorrections(ctx context.Context, userID, limit int) ([]model.FeedbackExample, error) {
+	query := `
+        SELECT r.subject, f.feedback_type, f.original_categories, f.original_priority,
+               COALESCE(f.corrected_categories, '{}'), COALESCE(f.corrected_priority, ''),
+               f.detail, f.comment
+        FROM email_feedback f
+        JOIN emails_raw r ON r.id = f.email_id
+        WHERE f.user_id = $1
+        ORDER BY f.created_at DESC, f.id DESC
+        LIMIT $2
+    `
+	rows, err := r.db.Query(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var examples []model.FeedbackExample
+	for rows.Next() {
+		var e model.FeedbackExample
+		if err := rows.Scan(
+			&e.Subject, &e.FeedbackType, &e.OriginalCategories, &e.OriginalPriority,
+			&e.CorrectedCategories, &e.CorrectedPriority, &e.Detail, &e.Comment,
+		); err != nil {
+			return nil, err
+		}
+		examples = append(examples, e)
+	}
+	return examples, rows.Err()
+}
@@ -3,6 +3,9 @@ package repository
 import (
 	"context"
 	"email-processor-service/internal/model"
+	"errors"
+	"mygoproject/contracts/db"
+	"mygoproject/pkg/emailstore"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -32,49 +35,88 @@ func (r *MetadataRepository) InsertDecision(
 			categories = EXCLUDED.categories,
 			priority   = EXCLUDED.priority,
 			summary    = EXCLUDED.summary,
+			version    = emails_metadata.version + 1,
 			updated_at = NOW();
 	`
 
-	_, err := r.db.Exec(ctx, sql,
-		emailID,
-		decision.Categories,
-		decision.Priority,
-		decision.Summary,
-	)
-
-	return err
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		if err := emailstore.ArchiveMetadataTx(ctx, tx, emailID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, sql,
+			emailID,
+			decision.Categories,
+			decision.Priority,
+			decision.Summary,
+		)
+		return err
+	})
 }
 
-// InsertDecisionTx inserts decision in a transaction
+// InsertDecisionTx inserts decision in a transaction, together with the classification rules that matched.
+// An existing decision is archived to emails_metadata_history and the version is bumped.
 func (r *MetadataRepository) InsertDecisionTx(
 	ctx context.Context,
 	tx pgx.Tx,
 	emailID int,
 	decision *model.AgentDecision,
+	ruleHits []db.RuleHit,
 ) error {
+	if ruleHits == nil {
+		ruleHits = []db.RuleHit{}
+	}
+	source := decision.Source
+	if source == "" {
+		source = model.DecisionSourceAgent
+	}
+
 	sql := `
 		INSERT INTO emails_metadata
-			(email_id, categories, priority, summary, created_at, updated_at)
+			(email_id, categories, priority, summary, rule_hits, decision_source, created_at, updated_at)
 		VALUES
-			($1, $2, $3, $4, NOW(), NOW())
+			($1, $2, $3, $4, $5, $6, NOW(), NOW())
 		ON CONFLICT (email_id)
 		DO UPDATE SET
-			categories = EXCLUDED.categories,
-			priority   = EXCLUDED.priority,
-			summary    = EXCLUDED.summary,
-			updated_at = NOW();
+			categories      = EXCLUDED.categories,
+			priority        = EXCLUDED.priority,
+			summary         = EXCLUDED.summary,
+			rule_hits       = EXCLUDED.rule_hits,
+			decision_source = EXCLUDED.decision_source,
+			version         = emails_metadata.version + 1,
+			updated_at      = NOW();
 	`
 
+	if err := emailstore.ArchiveMetadataTx(ctx, tx, emailID); err != nil {
+		return err
+	}
 	_, err := tx.Exec(ctx, sql,
 		emailID,
 		decision.Categories,
 		decision.Priority,
 		decision.Summary,
+		ruleHits,
+		source,
 	)
 
 	return err
 }
 
+// GetDecisionTx returns the priority and decision source of the email's current decision, locking the row.
+// Returns empty strings (and no error) for an email that has not been classified yet.
+func (r *MetadataRepository) GetDecisionTx(ctx context.Context, tx pgx.Tx, emailID int) (priority, source string, err error) {
+	sql := `
+		SELECT priority, decision_source
+		FROM emails_metadata
+		WHERE email_id = $1
+		FOR UPDATE
+	`
+	err = tx.QueryRow(ctx, sql, emailID).Scan(&priority, &source)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", "", nil
+	}
+	return priority, source, err
+}
+
 func (r *MetadataRepository) InsertUnknown(
 	ctx context.Context,
 	emailID int,
@@ -82,18 +124,53 @@ func (r *MetadataRepository) InsertUnknown(
 
 	sql := `
 		INSERT INTO emails_metadata
-			(email_id, categories, priority, summary, created_at, updated_at)
+			(email_id, categories, priority, summary, decision_source, created_at, updated_at)
 		VALUES
-			($1, ARRAY['unknown'], 'LOW', 'Classified as unknown due to AI errors', NOW(), NOW())
+			($1, ARRAY['unknown'], 'LOW', 'Classified as unknown due to AI errors', 'unknown', NOW(), NOW())
 		ON CONFLICT (email_id)
 		DO UPDATE SET
-			categories = ARRAY['unknown'],
-			priority   = 'LOW',
-			summary    = 'Classified as unknown due to AI errors',
-			updated_at = NOW();
+			categories      = ARRAY['unknown'],
+			priority        = 'LOW',
+			summary         = 'Classified as unknown due to AI errors',
+			decision_source = 'unknown',
+			version         = emails_metadata.version + 1,
+			updated_at      = NOW();
 	`
 
-	_, err := r.db.Exec(ctx, sql, emailID)
-	return err
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		if err := emailstore.ArchiveMetadataTx(ctx, tx, emailID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, sql, emailID)
+		return err
+	})
 }
 
+// ListTrainingSamples returns the user's most recent agent / rule / user-corrected decisions for the offline classifier.
+// Fallback and unknown decisions are excluded so the classifier does not learn from its own guesses.
+func (r *MetadataRepository) ListTrainingSamples(ctx context.Context, userID, limit int) ([]model.TrainingSample, error) {
+	sql := `
+		SELECT r.subject, r.body, m.categories, m.priority
+		FROM emails_metadata m
+		JOIN emails_raw r ON r.id = m.email_id
+		WHERE r.user_id = $1
+		  AND m.decision_source IN ('agent', 'rules', 'user')
+		ORDER BY m.updated_at DESC
+		LIMIT $2
+	`
+	rows, err := r.db.Query(ctx, sql, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []model.TrainingSample
+	for rows.Next() {
+		var s model.TrainingSample
+		if err := rows.Scan(&s.Subject, &s.Body, &s.Categories, &s.Priority); err != nil {
+			return nil, err
+		}
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
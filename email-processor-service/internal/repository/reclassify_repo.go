@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ReclassifyRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewReclassifyRepository(db *pgxpool.Pool) *ReclassifyRepository {
+	return &ReclassifyRepository{db: db}
+}
+
+// EnqueueTx adds an email to the re-classification queue (no-op if already queued).
+func (r *ReclassifyRepository) EnqueueTx(ctx context.Context, tx pgx.Tx, emailID, userID int, reason string) error {
+	query := `
+        INSERT INTO reclassify_queue (email_id, user_id, reason)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (email_id) DO NOTHING
+    `
+	_, err := tx.Exec(ctx, query, emailID, userID, reason)
+	return err
+}
+
+// ClaimTx locks up to limit queued emails (oldest first) so that concurrent workers skip them.
+func (r *ReclassifyRepository) ClaimTx(ctx context.Context, tx pgx.Tx, limit int) ([]int, error) {
+	query := `
+        SELECT email_id
+        FROM reclassify_queue
+        ORDER BY created_at ASC
+        LIMIT $1
+        FOR UPDATE SKIP LOCKED
+    `
+	rows, err := tx.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// DeleteTx removes an email from the queue.
+func (r *ReclassifyRepository) DeleteTx(ctx context.Context, tx pgx.Tx, emailID int) error {
+	_, err := tx.Exec(ctx, `DELETE FROM reclassify_queue WHERE email_id = $1`, emailID)
+	return err
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type UserRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewUserRepository(db *pgxpool.Pool) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+// UserSettings 分类时用到的用户设置
+type UserSettings struct {
+	Timezone             string // IANA 时区名（默认 UTC）
+	DecisionCacheEnabled bool   // 是否允许复用缓存的 agent 决策
+}
+
+// GetSettings returns the user's timezone and decision cache preference.
+func (r *UserRepository) GetSettings(ctx context.Context, userID int) (*UserSettings, error) {
+	var s UserSettings
+	err := r.db.QueryRow(ctx,
+		`SELECT timezone, decision_cache_enabled FROM users WHERE id = $1`, userID,
+	).Scan(&s.Timezone, &s.DecisionCacheEnabled)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -0,0 +1,233 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/textproto"
+	"regexp"
+	"strings"
+
+	"email-processor-service/internal/model"
+	"mygoproject/contracts/db"
+
+	"go.uber.org/zap"
+)
+
+// Email 规则评估的输入
+type Email struct {
+	From    string
+	Subject string
+	Body    string
+	Headers map[string][]string // 键为规范化的邮件头名（textproto.CanonicalMIMEHeaderKey）
+}
+
+// HeadersFromRawJSON 从 emails_raw.raw_json 中提取邮件头（模拟邮件没有邮件头时返回 nil）
+func HeadersFromRawJSON(rawJSON string) map[string][]string {
+	if rawJSON == "" {
+		return nil
+	}
+	var raw struct {
+		Headers map[string][]string `json:"headers"`
+	}
+	if err := json.Unmarshal([]byte(rawJSON), &raw); err != nil {
+		return nil
+	}
+	return raw.Headers
+}
+
+// Result 规则评估结果
+type Result struct {
+	Hits     []db.RuleHit
+	Decisive bool // 命中了 decisive 规则，不需要调用 agent
+
+	actions db.RuleActions // 按评估顺序合并后的动作
+}
+
+// Matched 是否命中了任意规则
+func (r *Result) Matched() bool {
+	return len(r.Hits) > 0
+}
+
+// Decision 由规则直接生成决策（decisive 时使用，不调用 agent）
+func (r *Result) Decision() *model.AgentDecision {
+	names := make([]string, 0, len(r.Hits))
+	for _, hit := range r.Hits {
+		names = append(names, hit.Name)
+	}
+	decision := &model.AgentDecision{
+		Categories: []string{},
+		Priority:   "MEDIUM",
+		Summary:    fmt.Sprintf("Classified by rules: %s", strings.Join(names, ", ")),
+		Source:     model.DecisionSourceRules,
+	}
+	r.Apply(decision)
+	return decision
+}
+
+// Apply 将规则动作合并到 agent 决策：分类取并集，规则的任务追加到任务列表，优先级、通知以规则为准
+func (r *Result) Apply(decision *model.AgentDecision) {
+	for _, category := range r.actions.Categories {
+		if !containsFold(decision.Categories, category) {
+			decision.Categories = append(decision.Categories, category)
+		}
+	}
+	if r.actions.Priority != "" {
+		decision.Priority = r.actions.Priority
+	}
+	if task := r.actions.CreateTask; task != nil {
+		decision.ShouldCreateTask = true
+		decision.Tasks = append(decision.Tasks, model.TaskDecision{Title: task.Title, DueInDays: task.DueInDays})
+	}
+	if notify := r.actions.Notify; notify != nil {
+		decision.ShouldNotify = true
+		decision.NotificationChannel = notify.Channel
+		decision.NotificationMessage = notify.Message
+	}
+}
+
+// Engine 确定性的规则引擎：按顺序评估用户规则，命中 decisive 规则时停止
+type Engine struct {
+	logger *zap.Logger
+}
+
+func NewEngine(logger *zap.Logger) *Engine {
+	return &Engine{logger: logger}
+}
+
+// Evaluate 按顺序评估规则（调用方保证 rules 已按 position 排序）
+// 合并动作时先命中的规则优先：后续规则只补充尚未设置的优先级、任务和通知
+func (e *Engine) Evaluate(rules []db.ClassificationRule, email Email) *Result {
+	result := &Result{}
+	for _, rule := range rules {
+		matched, err := Match(rule.Conditions, email)
+		if err != nil {
+			// 无效的正则在创建规则时已校验，这里只记录并跳过
+			e.logger.Warn("Skip classification rule with invalid condition",
+				zap.Int("rule_id", rule.ID),
+				zap.Error(err),
+			)
+			continue
+		}
+		if !matched {
+			continue
+		}
+
+		result.Hits = append(result.Hits, db.RuleHit{RuleID: rule.ID, Name: rule.Name, Decisive: rule.Decisive})
+		result.merge(rule.Actions)
+		if rule.Decisive {
+			result.Decisive = true
+			break
+		}
+	}
+	return result
+}
+
+func (r *Result) merge(actions db.RuleActions) {
+	for _, category := range actions.Categories {
+		if !containsFold(r.actions.Categories, category) {
+			r.actions.Categories = append(r.actions.Categories, category)
+		}
+	}
+	if r.actions.Priority == "" {
+		r.actions.Priority = actions.Priority
+	}
+	if r.actions.CreateTask == nil {
+		r.actions.CreateTask = actions.CreateTask
+	}
+	if r.actions.Notify == nil {
+		r.actions.Notify = actions.Notify
+	}
+}
+
+// Match 判断邮件是否满足规则的所有条件；没有任何条件的规则不匹配
+func Match(c db.RuleConditions, email Email) (bool, error) {
+	if c.Sender == "" && c.SubjectRegex == "" && len(c.BodyKeywords) == 0 && len(c.Headers) == 0 {
+		return false, nil
+	}
+
+	if c.Sender != "" && !matchSender(c.Sender, email.From) {
+		return false, nil
+	}
+
+	if c.SubjectRegex != "" {
+		re, err := CompilePattern(c.SubjectRegex)
+		if err != nil {
+			return false, err
+		}
+		if !re.MatchString(email.Subject) {
+			return false, nil
+		}
+	}
+
+	if len(c.BodyKeywords) > 0 {
+		body := strings.ToLower(email.Body)
+		found := false
+		for _, keyword := range c.BodyKeywords {
+			if keyword != "" && strings.Contains(body, strings.ToLower(keyword)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+
+	for _, hc := range c.Headers {
+		values := email.Headers[textproto.CanonicalMIMEHeaderKey(hc.Name)]
+		if len(values) == 0 {
+			return false, nil
+		}
+		if hc.Pattern == "" {
+			continue
+		}
+		re, err := CompilePattern(hc.Pattern)
+		if err != nil {
+			return false, err
+		}
+		found := false
+		for _, v := range values {
+			if re.MatchString(v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// CompilePattern 编译不区分大小写的正则（创建规则时也用于校验）
+func CompilePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// matchSender 完整地址精确匹配；域名匹配该域名及其子域名
+func matchSender(pattern, from string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	from = strings.ToLower(strings.TrimSpace(from))
+	if from == "" {
+		return false
+	}
+	if strings.Contains(pattern, "@") {
+		return pattern == from
+	}
+
+	at := strings.LastIndex(from, "@")
+	if at < 0 {
+		return false
+	}
+	domain := from[at+1:]
+	return domain == pattern || strings.HasSuffix(domain, "."+pattern)
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,25 +1,52 @@
 package service
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
 	"time"
 	"email-processor-service/internal/model"
 	"mygoproject/pkg/circuitbreaker"
 	"mygoproject/pkg/metrics"
-	"mygoproject/pkg/trace"
 )
 
+// maxFeedbackExamples 每次 /decide 请求最多携带的用户修正示例数
+const maxFeedbackExamples = 5
+
+// MaxDecideBatchSize 每次 /decide-batch 请求最多包含的邮件数（与 agent-service 的限制一致）
+const MaxDecideBatchSize = 50
+
+// Decider 邮件决策后端：agent-service（HTTP）、OpenAI 兼容接口、本地规则或测试用的 fake，
+// 由 config 中的 agent_backend.type 选择。修正示例、缓存、熔断和 fallback 由 AgentClient 处理
+type Decider interface {
+	// Decide 为单封邮件决策
+	Decide(ctx context.Context, email EmailInput) (*model.AgentDecision, error)
+	// DecideBatch 为多封邮件决策，返回值与 emails 一一对应；单封失败时对应位置为 nil
+	DecideBatch(ctx context.Context, emails []EmailInput) ([]*model.AgentDecision, error)
+	// Ping 检查后端是否可用
+	Ping(ctx context.Context) error
+}
+
+// FallbackClassifier agent-service 不可用时使用的本地分类器
+type FallbackClassifier interface {
+	Classify(ctx context.Context, email EmailInput) (*model.AgentDecision, error)
+}
+
+// FeedbackSource 提供用户最近对 AI 决策的修正
+type FeedbackSource interface {
+	RecentCorrections(ctx context.Context, userID, limit int) ([]model.FeedbackExample, error)
+}
+
+// AgentClient 在决策后端（Decider）之上统一处理修正示例、决策缓存、熔断和 fallback
 type AgentClient struct {
-	baseURL    string
-	httpClient *http.Client
-	cb         *circuitbreaker.CircuitBreaker // 熔断器
+	backend  Decider
+	cb       *circuitbreaker.CircuitBreaker // 熔断器
+	fallback FallbackClassifier
+	feedback FeedbackSource
+	cache    *DecisionCache
 }
 
-func NewAgentClient(baseURL string) *AgentClient {
+func NewAgentClient(backend Decider) *AgentClient {
 	// 创建熔断器，配置更严格的阈值以确保快速失败
 	cbConfig := circuitbreaker.Config{
 		FailureThreshold:    3,              // 连续失败3次后打开
@@ -29,95 +56,217 @@ func NewAgentClient(baseURL string) *AgentClient {
 	}
 	
 	return &AgentClient{
-		baseURL: baseURL,
-		httpClient: &http.Client{
-			Timeout: 5 * time.Second, // 5秒超时，避免 worker 卡死
-		},
-		cb: circuitbreaker.NewCircuitBreaker(cbConfig),
+		backend: backend,
+		cb:      circuitbreaker.NewCircuitBreaker(cbConfig),
 	}
 }
 
+// WithFallback 设置 agent-service 不可用时的本地分类器
+func (c *AgentClient) WithFallback(fallback FallbackClassifier) *AgentClient {
+	c.fallback = fallback
+	return c
+}
+
+// WithFeedback 设置用户修正来源，最近的修正作为 few-shot 示例随请求发送
+func (c *AgentClient) WithFeedback(feedback FeedbackSource) *AgentClient {
+	c.feedback = feedback
+	return c
+}
+
+// WithCache 设置决策缓存，内容指纹相同的邮件复用之前的 agent 决策
+func (c *AgentClient) WithCache(cache *DecisionCache) *AgentClient {
+	c.cache = cache
+	return c
+}
+
 type EmailInput struct {
     EmailID int    `json:"email_id"`
     UserID  int    `json:"user_id"`
     Subject string `json:"subject"`
     Body    string `json:"body"`
+
+    Corrections []model.FeedbackExample `json:"corrections,omitempty"` // 用户最近的修正（few-shot）
+
+    // 解析相对截止时间（"周一前"、"明天"）的基准：邮件 Date 头（用户时区）和用户时区名
+    SentAt   *time.Time `json:"sent_at,omitempty"`
+    Timezone string     `json:"timezone,omitempty"`
+
+    Sender *model.SenderProfile `json:"sender,omitempty"` // 发件人历史统计和重要性，未知发件人为空
+
+    TraceID string `json:"trace_id,omitempty"` // 批量请求中区分每封邮件的 trace（单条请求通过请求头传播）
+
+    From    string `json:"-"` // 发件人地址，只用于决策缓存的指纹
+    NoCache bool   `json:"-"` // 不读写决策缓存（用户关闭或重新分类）
 }
 
 
 
-// Decide 调用 agent-service 进行决策，带熔断器和 fallback
+// Decide 调用决策后端，带熔断器和 fallback
 func (c *AgentClient) Decide(ctx context.Context, email EmailInput) (*model.AgentDecision, error) {
-	var decision *model.AgentDecision
-	var err error
+	// 附带用户最近的修正；查询失败不影响决策
+	if c.feedback != nil && email.Corrections == nil {
+		email.Corrections = c.recentCorrections(ctx, email.UserID)
+	}
+
+	// 模板化邮件复用之前的决策
+	cacheKey, cached := c.lookupCache(ctx, email)
+	if cached != nil {
+		return cached, nil
+	}
 
 	// 使用熔断器执行请求
-	err = c.cb.Execute(func() error {
-		start := time.Now()
-		b, marshalErr := json.Marshal(email)
-		if marshalErr != nil {
-			return marshalErr
-		}
+	var decision *model.AgentDecision
+	var invalidErr error
+	err := c.cb.Execute(func() error {
+		var decideErr error
+		decision, decideErr = c.backend.Decide(ctx, email)
+		return c.separateInvalid(decideErr, &invalidErr)
+	})
+
+	// 如果失败（包括熔断器打开、响应结构无效），使用 fallback；与 DecideBatch 一致，之后由 reclassify 重新分类
+	if err != nil || invalidErr != nil || decision == nil {
+		return c.fallbackDecision(ctx, email), nil // 返回 fallback，不返回错误，确保 ingestion-service 继续运行
+	}
 
-		req, reqErr := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/decide", bytes.NewReader(b))
-		if reqErr != nil {
-			return reqErr
+	return c.accept(ctx, cacheKey, decision, email), nil
+}
+
+// DecideBatch 一次调用为多封邮件决策（agent-service 使用 /decide-batch），返回的决策和错误都与 emails 一一对应。
+// 命中缓存的邮件不发送；响应结构无效、单封邮件失败或熔断器打开时对应邮件使用 fallback。
+// 整批调用失败时每封邮件返回 ErrBatchFailed，调用被取消（ctx 已取消）时返回 ctx.Err()，不产生决策
+func (c *AgentClient) DecideBatch(ctx context.Context, emails []EmailInput) ([]*model.AgentDecision, []error) {
+	decisions := make([]*model.AgentDecision, len(emails))
+	errs := make([]error, len(emails))
+	cacheKeys := make([]string, len(emails))
+	corrections := make(map[int][]model.FeedbackExample) // 同一用户只查询一次
+
+	var batch []EmailInput
+	var batchIdx []int
+	for i, email := range emails {
+		if c.feedback != nil && email.Corrections == nil {
+			if _, ok := corrections[email.UserID]; !ok {
+				corrections[email.UserID] = c.recentCorrections(ctx, email.UserID)
+			}
+			email.Corrections = corrections[email.UserID]
 		}
-		req.Header.Set("Content-Type", "application/json")
-		// 传播 trace_id
-		if traceID := trace.FromContext(ctx); traceID != "" {
-			req.Header.Set(trace.HeaderName(), traceID)
+
+		var cached *model.AgentDecision
+		if cacheKeys[i], cached = c.lookupCache(ctx, email); cached != nil {
+			decisions[i] = cached
+			continue
 		}
+		batch = append(batch, email)
+		batchIdx = append(batchIdx, i)
+	}
+	if len(batch) == 0 {
+		return decisions, errs
+	}
+
+	var results []*model.AgentDecision
+	var invalidErr error
+	err := c.cb.Execute(func() error {
+		var decideErr error
+		results, decideErr = c.backend.DecideBatch(ctx, batch)
+		return c.separateInvalid(decideErr, &invalidErr)
+	})
 
-		resp, doErr := c.httpClient.Do(req)
-		latency := time.Since(start)
-		status := "success"
-		
-		if doErr != nil {
-			status = "error"
-			metrics.RecordAgentCallLatency("/decide", status, latency)
-			return doErr
+	for j, i := range batchIdx {
+		switch {
+		case ctx.Err() != nil:
+			// 消费者正在停止：不使用 fallback，也不写缓存，由调用方重新入队
+			errs[i] = ctx.Err()
+			continue
+		case err != nil && !errors.Is(err, circuitbreaker.ErrCircuitBreakerOpen):
+			errs[i] = fmt.Errorf("%w: %w", ErrBatchFailed, err)
+			continue
 		}
-		defer resp.Body.Close()
 
-		if resp.StatusCode >= 500 {
-			status = "5xx"
-			metrics.RecordAgentCallLatency("/decide", status, latency)
-			// 可重试错误
-			return fmt.Errorf("agent service 5xx: %d", resp.StatusCode)
+		var decision *model.AgentDecision
+		if err == nil && invalidErr == nil && j < len(results) {
+			decision = results[j]
 		}
-		if resp.StatusCode != 200 {
-			status = fmt.Sprintf("%d", resp.StatusCode)
-			metrics.RecordAgentCallLatency("/decide", status, latency)
-			// 不可重试或者单独处理
-			return fmt.Errorf("agent service error: %d", resp.StatusCode)
+		if decision == nil {
+			decisions[i] = c.fallbackDecision(ctx, batch[j])
+			continue
 		}
+		decisions[i] = c.accept(ctx, cacheKeys[i], decision, batch[j])
+	}
+	return decisions, errs
+}
 
-		metrics.RecordAgentCallLatency("/decide", status, latency)
-		var decodeErr error
-		decision, decodeErr = c.decodeDecision(resp)
-		return decodeErr
-	})
+// separateInvalid 把响应结构无效的错误从传输错误中分离出来：后端可用，不计入熔断器失败
+func (c *AgentClient) separateInvalid(err error, invalidErr *error) error {
+	if errors.Is(err, ErrInvalidDecision) {
+		metrics.IncrementDecisionValidation("response", "rejected")
+		*invalidErr = err
+		return nil
+	}
+	return err
+}
 
-	// 如果失败（包括熔断器打开），使用 fallback
+// recentCorrections 查询用户最近的修正，失败时返回 nil
+func (c *AgentClient) recentCorrections(ctx context.Context, userID int) []model.FeedbackExample {
+	corrections, err := c.feedback.RecentCorrections(ctx, userID, maxFeedbackExamples)
 	if err != nil {
-		return c.fallbackDecision(email), nil // 返回 fallback，不返回错误，确保 ingestion-service 继续运行
+		return nil
 	}
+	return corrections
+}
 
-	return decision, nil
+// lookupCache 返回缓存键和命中的决策；未启用缓存或不允许使用缓存时缓存键为空
+func (c *AgentClient) lookupCache(ctx context.Context, email EmailInput) (string, *model.AgentDecision) {
+	if c.cache == nil {
+		return "", nil
+	}
+	if email.NoCache {
+		metrics.IncrementDecisionCache("bypass")
+		return "", nil
+	}
+
+	key := decisionCacheKey(email)
+	if cached := c.cache.Get(ctx, key); cached != nil {
+		metrics.IncrementDecisionCache("hit")
+		cached.Source = model.DecisionSourceAgent
+		return key, cached
+	}
+	metrics.IncrementDecisionCache("miss")
+	return key, nil
 }
 
-// decodeDecision 解码响应
-func (c *AgentClient) decodeDecision(resp *http.Response) (*model.AgentDecision, error) {
-	var decision model.AgentDecision
-	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
-		return nil, err
+// accept 标记后端给出的决策（未指定来源时视为 agent），校验模型给出的决策并写入缓存，返回最终使用的决策。
+// 校验不通过（如无法识别的优先级）时使用 fallback，来源为 fallback 的邮件之后会重新分类。
+// 本地规则给出的决策不经过校验（可能包含用户历史中的自定义分类）
+func (c *AgentClient) accept(ctx context.Context, cacheKey string, decision *model.AgentDecision, email EmailInput) *model.AgentDecision {
+	if decision.Source == "" {
+		decision.Source = model.DecisionSourceAgent
+	}
+	if decision.Source == model.DecisionSourceAgent {
+		if err := validateDecision(decision, email); err != nil {
+			return c.fallbackDecision(ctx, email)
+		}
+	}
+	if cacheKey != "" && cacheable(decision, email) {
+		c.cache.Set(ctx, cacheKey, decision)
 	}
-	return &decision, nil
+	return decision
+}
+
+// Ping 检查决策后端是否可用（经过熔断器，熔断打开时直接返回错误）
+func (c *AgentClient) Ping(ctx context.Context) error {
+	return c.cb.Execute(func() error {
+		return c.backend.Ping(ctx)
+	})
 }
 
 // fallbackDecision 返回默认决策（当 agent-service 不可用时）
-func (c *AgentClient) fallbackDecision(email EmailInput) *model.AgentDecision {
+// 优先使用本地分类器，未配置或分类失败时返回保守的默认决策
+func (c *AgentClient) fallbackDecision(ctx context.Context, email EmailInput) *model.AgentDecision {
+	if c.fallback != nil {
+		if decision, err := c.fallback.Classify(ctx, email); err == nil {
+			return decision
+		}
+	}
+
 	// 返回一个保守的默认决策：
 	// - 不创建任务（避免误操作）
 	// - 不发送通知（避免骚扰）
@@ -128,9 +277,10 @@ func (c *AgentClient) fallbackDecision(email EmailInput) *model.AgentDecision {
 		Priority:          "MEDIUM",    // 默认中等优先级
 		Summary:           fmt.Sprintf("Agent service unavailable, email not processed: %s", email.Subject),
 		ShouldCreateTask:  false,       // 不创建任务，避免误操作
-		Task:              nil,
+		Tasks:             nil,
 		ShouldNotify:      false,       // 不发送通知，避免骚扰
 		NotificationChannel: "",
 		NotificationMessage: "",
+		Source:              model.DecisionSourceFallback,
 	}
 }
\ No newline at end of file
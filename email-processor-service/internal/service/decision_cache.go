@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"email-processor-service/internal/model"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var (
+	// 含数字的词（订单号、时间、构建号、哈希、UUID）视为模板中的变量
+	fingerprintVariable   = regexp.MustCompile(`[\p{L}\p{N}_-]*\p{N}[\p{L}\p{N}_-]*`)
+	fingerprintWhitespace = regexp.MustCompile(`\s+`)
+	fingerprintNumber     = regexp.MustCompile(`\p{Nd}+`)
+)
+
+// DecisionCache 按邮件内容指纹缓存 agent 决策（Redis），
+// 告警、收据、CI 通知等模板化邮件重复出现时复用之前的决策，不再调用 LLM
+type DecisionCache struct {
+	rdb    *redis.Client
+	ttl    time.Duration
+	logger *zap.Logger
+}
+
+func NewDecisionCache(rdb *redis.Client, ttl time.Duration, logger *zap.Logger) *DecisionCache {
+	return &DecisionCache{
+		rdb:    rdb,
+		ttl:    ttl,
+		logger: logger,
+	}
+}
+
+// Get 返回指纹对应的决策，未命中或 Redis 不可用时返回 nil
+func (c *DecisionCache) Get(ctx context.Context, key string) *model.AgentDecision {
+	raw, err := c.rdb.Get(ctx, key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			c.logger.Warn("Decision cache lookup failed", zap.String("key", key), zap.Error(err))
+		}
+		return nil
+	}
+
+	var decision model.AgentDecision
+	if err := json.Unmarshal(raw, &decision); err != nil {
+		c.logger.Warn("Invalid cached decision, ignoring", zap.String("key", key), zap.Error(err))
+		return nil
+	}
+	return &decision
+}
+
+// Set 缓存决策；写入失败只记录日志
+func (c *DecisionCache) Set(ctx context.Context, key string, decision *model.AgentDecision) {
+	raw, err := json.Marshal(decision)
+	if err != nil {
+		return
+	}
+	if err := c.rdb.Set(ctx, key, raw, c.ttl).Err(); err != nil {
+		c.logger.Warn("Failed to store decision in cache", zap.String("key", key), zap.Error(err))
+	}
+}
+
+// decisionCacheKey 计算邮件的内容指纹：发件人、归一化后的主题和正文、用户时区和最近的修正。
+// 用户有新的修正时指纹随之变化，之前缓存的决策不再命中
+func decisionCacheKey(email EmailInput) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00",
+		strings.ToLower(strings.TrimSpace(email.From)),
+		normalizeForFingerprint(email.Subject),
+		normalizeForFingerprint(email.Body),
+		email.Timezone,
+	)
+	if len(email.Corrections) > 0 {
+		corrections, _ := json.Marshal(email.Corrections)
+		h.Write(corrections)
+	}
+	return fmt.Sprintf("decision_cache:%d:%s", email.UserID, hex.EncodeToString(h.Sum(nil)))
+}
+
+// normalizeForFingerprint 小写、把含数字的词替换为占位符、合并空白，
+// 只有订单号、时间等变量不同的邮件得到相同的指纹
+func normalizeForFingerprint(s string) string {
+	s = strings.ToLower(s)
+	s = fingerprintVariable.ReplaceAllString(s, "#")
+	s = fingerprintWhitespace.ReplaceAllString(s, " ")
+	return strings.TrimSpace(s)
+}
+
+// cacheable 只缓存 agent 给出的、可以原样复用到同模板其他邮件的决策。
+// 指纹把含数字的词归一化为占位符，但缓存的决策会原样回放：摘要、通知、任务标题中
+// 出现含数字的词（订单号、金额、时间），或 due_in_days 取自邮件中的数字时，
+// 同模板的其他邮件会拿到错误的内容，这类决策不缓存。
+// 任务带绝对截止日期时依赖邮件的发送时间，同样不能复用
+func cacheable(decision *model.AgentDecision, email EmailInput) bool {
+	if decision.Source != model.DecisionSourceAgent {
+		return false
+	}
+	if fingerprintVariable.MatchString(decision.Summary) || fingerprintVariable.MatchString(decision.NotificationMessage) {
+		return false
+	}
+
+	numbers := make(map[string]bool)
+	for _, n := range fingerprintNumber.FindAllString(email.Subject+"\n"+email.Body, -1) {
+		numbers[strings.TrimLeft(n, "0")] = true
+	}
+	tasks := decision.Tasks
+	if decision.Task != nil {
+		tasks = append(append([]model.TaskDecision{}, tasks...), *decision.Task)
+	}
+	for _, t := range tasks {
+		if t.DueDate != "" || fingerprintVariable.MatchString(t.Title) {
+			return false
+		}
+		if t.DueInDays > 0 && numbers[strconv.Itoa(t.DueInDays)] {
+			return false
+		}
+	}
+	return true
+}
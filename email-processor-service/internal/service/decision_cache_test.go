@@ -0,0 +1,141 @@
+package service
+
+import (
+	"testing"
+
+	"email-processor-service/internal/model"
+)
+
+func TestNormalizeForFingerprint(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"lowercase and whitespace", "  Your  Order\n\tShipped ", "your order shipped"},
+		{"order number", "Order #12345 shipped", "order ## shipped"},
+		{"mixed token", "Build ci-4521b failed on main", "build # failed on main"},
+		{"time and date", "Alert at 10:42 on 2024-05-01", "alert at #:# on #"},
+		{"uuid", "Job 3f2a9c1e-77aa-4b1d ready", "job # ready"},
+		{"non-latin digits", "订单 ٣٤٥ 已发货", "订单 # 已发货"},
+		{"no variables", "Weekly newsletter", "weekly newsletter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeForFingerprint(tt.in); got != tt.want {
+				t.Errorf("normalizeForFingerprint(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecisionCacheKey(t *testing.T) {
+	base := EmailInput{
+		UserID:   7,
+		From:     "alerts@example.com",
+		Subject:  "Order 1001 shipped",
+		Body:     "Your order 1001 will arrive in 3 days.",
+		Timezone: "Europe/Berlin",
+	}
+	key := decisionCacheKey(base)
+
+	same := []struct {
+		name   string
+		modify func(e *EmailInput)
+	}{
+		{"other order number", func(e *EmailInput) {
+			e.Subject = "Order 2002 shipped"
+			e.Body = "Your order 2002 will arrive in 5 days."
+		}},
+		{"sender case and spaces", func(e *EmailInput) { e.From = " Alerts@Example.com " }},
+		{"body whitespace", func(e *EmailInput) { e.Body = "Your order 1001\nwill arrive in 3 days." }},
+	}
+	for _, tt := range same {
+		t.Run("same/"+tt.name, func(t *testing.T) {
+			e := base
+			tt.modify(&e)
+			if got := decisionCacheKey(e); got != key {
+				t.Errorf("key changed: %s != %s", got, key)
+			}
+		})
+	}
+
+	different := []struct {
+		name   string
+		modify func(e *EmailInput)
+	}{
+		{"other user", func(e *EmailInput) { e.UserID = 8 }},
+		{"other sender", func(e *EmailInput) { e.From = "billing@example.com" }},
+		{"other subject", func(e *EmailInput) { e.Subject = "Order 1001 cancelled" }},
+		{"other timezone", func(e *EmailInput) { e.Timezone = "Asia/Shanghai" }},
+		{"new correction", func(e *EmailInput) {
+			e.Corrections = []model.FeedbackExample{{Subject: "Order 1000 shipped", FeedbackType: "classification"}}
+		}},
+	}
+	for _, tt := range different {
+		t.Run("different/"+tt.name, func(t *testing.T) {
+			e := base
+			tt.modify(&e)
+			if got := decisionCacheKey(e); got == key {
+				t.Errorf("key did not change: %s", got)
+			}
+		})
+	}
+}
+
+func TestCacheable(t *testing.T) {
+	email := EmailInput{
+		Subject: "Invoice INV-2024-0042 due",
+		Body:    "Please pay EUR 120 within 14 days.",
+	}
+
+	tests := []struct {
+		name     string
+		decision model.AgentDecision
+		want     bool
+	}{
+		{"template-independent decision", model.AgentDecision{
+			Categories: []string{"FINANCE"},
+			Priority:   "MEDIUM",
+			Summary:    "Invoice payment request",
+		}, true},
+		{"rules decision", model.AgentDecision{Summary: "Invoice", Source: model.DecisionSourceRules}, false},
+		{"fallback decision", model.AgentDecision{Summary: "Invoice", Source: model.DecisionSourceFallback}, false},
+		{"summary copies invoice number", model.AgentDecision{Summary: "Invoice INV-2024-0042 is due"}, false},
+		{"notification copies amount", model.AgentDecision{
+			ShouldNotify:        true,
+			NotificationMessage: "Pay EUR 120",
+		}, false},
+		{"task title copies amount", model.AgentDecision{
+			ShouldCreateTask: true,
+			Tasks:            []model.TaskDecision{{Title: "Pay 120 EUR"}},
+		}, false},
+		{"due in days taken from email", model.AgentDecision{
+			ShouldCreateTask: true,
+			Tasks:            []model.TaskDecision{{Title: "Pay invoice", DueInDays: 14}},
+		}, false},
+		{"due in days not in email", model.AgentDecision{
+			ShouldCreateTask: true,
+			Tasks:            []model.TaskDecision{{Title: "Pay invoice", DueInDays: 7}},
+		}, true},
+		{"legacy task copies number", model.AgentDecision{
+			ShouldCreateTask: true,
+			Task:             &model.TaskDecision{Title: "Pay invoice", DueInDays: 14},
+		}, false},
+		{"absolute due date", model.AgentDecision{
+			ShouldCreateTask: true,
+			Tasks:            []model.TaskDecision{{Title: "Pay invoice", DueDate: "2024-06-01"}},
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.decision
+			if d.Source == "" {
+				d.Source = model.DecisionSourceAgent
+			}
+			if got := cacheable(&d, email); got != tt.want {
+				t.Errorf("cacheable = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"email-processor-service/internal/model"
+	"mygoproject/pkg/deadline"
+	"mygoproject/pkg/metrics"
+)
+
+// 与网络错误不同：后端是可用的，重试同一封邮件通常得到同样的结果，因此不重试、不计入熔断器失败，AgentClient 直接使用 fallback
+// 与网络错误不同：后端是可用的，重试同一封邮件通常得到同样的结果，因此不重试、不计入熔断器失败
+var ErrInvalidDecision = errors.New("invalid agent decision")
+
+// ErrBatchFailed 整批调用失败（网络错误、5xx、结果数量不符），DecideBatch 为批中每封邮件返回此错误。
+// 与单封调用不同不使用 fallback：消费者重新入队，按重试次数决定是否写入 unknown
+var ErrBatchFailed = errors.New("agent batch call failed")
+
+const (
+	maxDecisionCategories        = 5
+	maxSummaryLength             = 1000
+	maxTaskTitleLength           = 255 // tasks.title VARCHAR(255)
+	maxDueInDays                 = 365
+	maxNotificationMessageLength = 500
+	defaultNotificationChannel   = "EMAIL"
+	otherCategory                = "OTHER"
+)
+
+// knownCategories 分类体系，agent 返回的分类规范化后必须属于其中（或是用户修正中用过的分类）
+var knownCategories = map[string]bool{
+	"WORK":            true,
+	"PERSONAL":        true,
+	"ACTION_REQUIRED": true,
+	"MEETING":         true,
+	"FINANCE":         true,
+	"NEWSLETTER":      true,
+	"PROMOTION":       true,
+	"NOTIFICATION":    true,
+	"SOCIAL":          true,
+	"TRAVEL":          true,
+	"SECURITY":        true,
+	otherCategory:     true,
+}
+
+// categoryAliases 常见的同义写法
+var categoryAliases = map[string]string{
+	"ACTION":        "ACTION_REQUIRED",
+	"TODO":          "ACTION_REQUIRED",
+	"MEETINGS":      "MEETING",
+	"CALENDAR":      "MEETING",
+	"INVOICE":       "FINANCE",
+	"BILLING":       "FINANCE",
+	"PAYMENT":       "FINANCE",
+	"NEWSLETTERS":   "NEWSLETTER",
+	"PROMOTIONS":    "PROMOTION",
+	"MARKETING":     "PROMOTION",
+	"NOTIFICATIONS": "NOTIFICATION",
+	"ALERT":         "NOTIFICATION",
+	"ALERTS":        "NOTIFICATION",
+}
+
+// priorityAliases 模型常用的其他优先级写法
+var priorityAliases = map[string]string{
+	"URGENT":    "HIGH",
+	"CRITICAL":  "HIGH",
+	"IMPORTANT": "HIGH",
+	"P0":        "HIGH",
+	"P1":        "HIGH",
+	"NORMAL":    "MEDIUM",
+	"MODERATE":  "MEDIUM",
+	"MED":       "MEDIUM",
+	"P2":        "MEDIUM",
+	"MINOR":     "LOW",
+	"P3":        "LOW",
+}
+
+// supportedNotificationChannels 与 notification-service 的 NotificationSender 支持的渠道一致
+var supportedNotificationChannels = map[string]bool{
+	"EMAIL":   true,
+	"PUSH":    true,
+	"SMS":     true,
+	"WEBHOOK": true,
+}
+
+// validateDecision 校验并规范化模型给出的决策（就地修改）。
+// 可以修正的字段（优先级同义词和数字、未知分类、超长文本、越界的天数、无效的截止日期和任务、不支持的通知渠道）
+// 修正后继续使用；优先级缺失或无法识别时决策不可用，返回 ErrInvalidDecision（调用方改用 fallback）。
+// 每个被修正或拒绝的字段记录一次 agent_decision_validation_count 指标
+func validateDecision(decision *model.AgentDecision, email EmailInput) error {
+	sanitized := make(map[string]bool)
+	note := func(field string) { sanitized[field] = true }
+	defer func() {
+		for field := range sanitized {
+			metrics.IncrementDecisionValidation(field, "sanitized")
+		}
+	}()
+
+	priority, ok := normalizePriority(decision.Priority)
+	if !ok || priority == "" {
+		metrics.IncrementDecisionValidation("priority", "rejected")
+		return fmt.Errorf("%w: priority %q", ErrInvalidDecision, truncateRunes(decision.Priority, 20))
+	}
+	if priority != decision.Priority {
+		note("priority")
+	}
+	decision.Priority = priority
+
+	decision.Categories = normalizeCategories(decision.Categories, email.Corrections, note)
+
+	decision.Summary = strings.TrimSpace(decision.Summary)
+	if utf8.RuneCountInString(decision.Summary) > maxSummaryLength {
+		decision.Summary = truncateRunes(decision.Summary, maxSummaryLength)
+		note("summary")
+	}
+
+	tasks := make([]model.TaskDecision, 0, len(decision.Tasks))
+	for _, t := range decision.Tasks {
+		if sanitizeTask(&t, note) {
+			tasks = append(tasks, t)
+		}
+	}
+	decision.Tasks = tasks
+	if decision.Task != nil && !sanitizeTask(decision.Task, note) {
+		decision.Task = nil
+	}
+	if decision.ShouldCreateTask && len(decision.TaskList()) == 0 {
+		decision.ShouldCreateTask = false
+		note("should_create_task")
+	}
+
+	if decision.ShouldNotify {
+		channel := strings.ToUpper(strings.TrimSpace(decision.NotificationChannel))
+		if channel != "" && !supportedNotificationChannels[channel] {
+			note("notification_channel")
+			channel = ""
+		}
+		if channel == "" {
+			channel = defaultNotificationChannel
+		}
+		decision.NotificationChannel = channel
+
+		decision.NotificationMessage = strings.TrimSpace(decision.NotificationMessage)
+		if utf8.RuneCountInString(decision.NotificationMessage) > maxNotificationMessageLength {
+			decision.NotificationMessage = truncateRunes(decision.NotificationMessage, maxNotificationMessageLength)
+			note("notification_message")
+		}
+	}
+	return nil
+}
+
+// normalizeCategories 大写、统一分隔符、映射同义词并去重；未知分类丢弃（用户修正中用过的自定义分类保留），
+// 全部丢弃时归为 OTHER
+func normalizeCategories(categories []string, corrections []model.FeedbackExample, note func(string)) []string {
+	userCategories := make(map[string]bool)
+	for _, c := range corrections {
+		for _, category := range c.CorrectedCategories {
+			userCategories[normalizeCategory(category)] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	result := make([]string, 0, len(categories))
+	for _, raw := range categories {
+		category := normalizeCategory(raw)
+		if !knownCategories[category] && !userCategories[category] {
+			note("categories")
+			continue
+		}
+		if seen[category] {
+			continue
+		}
+		seen[category] = true
+		result = append(result, category)
+	}
+
+	if len(result) > maxDecisionCategories {
+		result = result[:maxDecisionCategories]
+		note("categories")
+	}
+	if len(result) == 0 && len(categories) > 0 {
+		result = append(result, otherCategory)
+	}
+	return result
+}
+
+// normalizeCategory 大写、空格和连字符统一为下划线并映射同义词；agent 的分类和用户修正中的分类使用同一规则
+func normalizeCategory(raw string) string {
+	category := strings.ToUpper(strings.TrimSpace(raw))
+	category = strings.NewReplacer(" ", "_", "-", "_").Replace(category)
+	if alias, ok := categoryAliases[category]; ok {
+		return alias
+	}
+	return category
+}
+
+// sanitizeTask 规范化单个任务，标题为空时返回 false（丢弃该任务）
+func sanitizeTask(t *model.TaskDecision, note func(string)) bool {
+	t.Title = strings.TrimSpace(t.Title)
+	if t.Title == "" {
+		note("task_title")
+		return false
+	}
+	if utf8.RuneCountInString(t.Title) > maxTaskTitleLength {
+		t.Title = truncateRunes(t.Title, maxTaskTitleLength)
+		note("task_title")
+	}
+
+	if t.DueInDays < 0 {
+		t.DueInDays = 0
+		note("task_due_in_days")
+	} else if t.DueInDays > maxDueInDays {
+		t.DueInDays = maxDueInDays
+		note("task_due_in_days")
+	}
+
+	if t.DueDate != "" {
+		if _, ok := deadline.Parse(t.DueDate, time.UTC); !ok {
+			t.DueDate = ""
+			note("task_due_date")
+		}
+	}
+
+	priority, ok := normalizePriority(t.Priority)
+	if !ok {
+		note("task_priority")
+	}
+	t.Priority = priority
+	return true
+}
+
+// normalizePriority 规范化为 LOW / MEDIUM / HIGH：映射同义词（urgent、critical → HIGH，normal → MEDIUM），
+// 数字按 LOW=1、MEDIUM=2、HIGH=3 限制到该范围。空值返回 ("", true)，无法识别时返回 ("", false)
+func normalizePriority(raw string) (string, bool) {
+	priority := strings.ToUpper(strings.TrimSpace(raw))
+	switch priority {
+	case "", "LOW", "MEDIUM", "HIGH":
+		return priority, true
+	}
+	if alias, ok := priorityAliases[priority]; ok {
+		return alias, true
+	}
+	if n, err := strconv.Atoi(priority); err == nil {
+		switch {
+		case n <= 1:
+			return "LOW", true
+		case n == 2:
+			return "MEDIUM", true
+		default:
+			return "HIGH", true
+		}
+	}
+	return "", false
+}
+
+// truncateRunes 按字符（而不是字节）截断，避免切断多字节字符
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return strings.TrimSpace(string([]rune(s)[:n]))
+}
@@ -0,0 +1,399 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"email-processor-service/internal/model"
+)
+
+// noteRecorder 记录被修正的字段
+type noteRecorder map[string]bool
+
+func (r noteRecorder) note(field string) { r[field] = true }
+
+func TestNormalizePriority(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   string
+		wantOK bool
+	}{
+		{"HIGH", "HIGH", true},
+		{" medium ", "MEDIUM", true},
+		{"low", "LOW", true},
+		{"", "", true},
+		{"urgent", "HIGH", true},
+		{"Critical", "HIGH", true},
+		{"P1", "HIGH", true},
+		{"normal", "MEDIUM", true},
+		{"minor", "LOW", true},
+		{"1", "LOW", true},
+		{"0", "LOW", true},
+		{"2", "MEDIUM", true},
+		{"3", "HIGH", true},
+		{"10", "HIGH", true},
+		{"-4", "LOW", true},
+		{"whenever", "", false},
+		{"HIGH!", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := normalizePriority(tt.in)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("normalizePriority(%q) = (%q, %v), want (%q, %v)", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestNormalizeCategories(t *testing.T) {
+	corrections := []model.FeedbackExample{
+		{FeedbackType: "classification", CorrectedCategories: []string{"Side-Project", "FINANCE"}},
+	}
+	tests := []struct {
+		name        string
+		in          []string
+		corrections []model.FeedbackExample
+		want        []string
+		wantNote    bool
+	}{
+		{"known categories", []string{"WORK", "MEETING"}, nil, []string{"WORK", "MEETING"}, false},
+		{"case and separators", []string{" work ", "action required", "action-required"}, nil, []string{"WORK", "ACTION_REQUIRED"}, false},
+		{"aliases", []string{"invoice", "Meetings", "todo", "alerts"}, nil, []string{"FINANCE", "MEETING", "ACTION_REQUIRED", "NOTIFICATION"}, false},
+		{"alias duplicates canonical", []string{"FINANCE", "billing", "payment"}, nil, []string{"FINANCE"}, false},
+		{"unknown dropped", []string{"WORK", "GARDENING"}, nil, []string{"WORK"}, true},
+		{"all unknown become OTHER", []string{"GARDENING", "COOKING"}, nil, []string{"OTHER"}, true},
+		{"user correction category kept", []string{"SIDE_PROJECT", "WORK"}, corrections, []string{"SIDE_PROJECT", "WORK"}, false},
+		{"user correction matched case-insensitively", []string{"side-project"}, corrections, []string{"SIDE_PROJECT"}, false},
+		{"at most five", []string{"WORK", "PERSONAL", "MEETING", "FINANCE", "TRAVEL", "SECURITY"}, nil, []string{"WORK", "PERSONAL", "MEETING", "FINANCE", "TRAVEL"}, true},
+		{"empty stays empty", []string{}, nil, []string{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notes := noteRecorder{}
+			got := normalizeCategories(tt.in, tt.corrections, notes.note)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("categories = %v, want %v", got, tt.want)
+			}
+			if notes["categories"] != tt.wantNote {
+				t.Errorf("categories noted = %v, want %v", notes["categories"], tt.wantNote)
+			}
+		})
+	}
+}
+
+func TestSanitizeTask(t *testing.T) {
+	longTitle := strings.Repeat("审", maxTaskTitleLength+10)
+
+	tests := []struct {
+		name     string
+		in       model.TaskDecision
+		want     model.TaskDecision
+		wantKeep bool
+		wantNote string
+	}{
+		{"valid task", model.TaskDecision{Title: "Review doc", DueInDays: 3, Priority: "high"},
+			model.TaskDecision{Title: "Review doc", DueInDays: 3, Priority: "HIGH"}, true, ""},
+		{"empty title dropped", model.TaskDecision{Title: "   "}, model.TaskDecision{}, false, "task_title"},
+		{"title trimmed", model.TaskDecision{Title: "  Book room  "},
+			model.TaskDecision{Title: "Book room"}, true, ""},
+		{"negative due days", model.TaskDecision{Title: "A", DueInDays: -2},
+			model.TaskDecision{Title: "A", DueInDays: 0}, true, "task_due_in_days"},
+		{"due days clamped", model.TaskDecision{Title: "A", DueInDays: 5000},
+			model.TaskDecision{Title: "A", DueInDays: maxDueInDays}, true, "task_due_in_days"},
+		{"valid due date", model.TaskDecision{Title: "A", DueDate: "2024-06-01T15:00"},
+			model.TaskDecision{Title: "A", DueDate: "2024-06-01T15:00"}, true, ""},
+		{"invalid due date cleared", model.TaskDecision{Title: "A", DueDate: "next friday"},
+			model.TaskDecision{Title: "A"}, true, "task_due_date"},
+		{"priority alias", model.TaskDecision{Title: "A", Priority: "urgent"},
+			model.TaskDecision{Title: "A", Priority: "HIGH"}, true, ""},
+		{"invalid priority cleared", model.TaskDecision{Title: "A", Priority: "someday"},
+			model.TaskDecision{Title: "A"}, true, "task_priority"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notes := noteRecorder{}
+			task := tt.in
+			keep := sanitizeTask(&task, notes.note)
+			if keep != tt.wantKeep {
+				t.Fatalf("keep = %v, want %v", keep, tt.wantKeep)
+			}
+			if keep && task != tt.want {
+				t.Errorf("task = %+v, want %+v", task, tt.want)
+			}
+			if tt.wantNote != "" && !notes[tt.wantNote] {
+				t.Errorf("%s not noted, notes = %v", tt.wantNote, notes)
+			}
+			if tt.wantNote == "" && len(notes) > 0 {
+				t.Errorf("unexpected notes %v", notes)
+			}
+		})
+	}
+
+	t.Run("long title truncated by rune", func(t *testing.T) {
+		notes := noteRecorder{}
+		task := model.TaskDecision{Title: longTitle}
+		if !sanitizeTask(&task, notes.note) {
+			t.Fatal("task dropped")
+		}
+		if n := utf8.RuneCountInString(task.Title); n != maxTaskTitleLength {
+			t.Errorf("title length = %d runes, want %d", n, maxTaskTitleLength)
+		}
+		if !utf8.ValidString(task.Title) {
+			t.Error("title is not valid UTF-8 after truncation")
+		}
+		if !notes["task_title"] {
+			t.Error("task_title not noted")
+		}
+	})
+}
+
+func TestValidateDecision(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      model.AgentDecision
+		check   func(t *testing.T, d *model.AgentDecision)
+		wantErr bool
+	}{
+		{
+			name: "priority synonym",
+			in:   model.AgentDecision{Priority: "urgent", Categories: []string{"WORK"}},
+			check: func(t *testing.T, d *model.AgentDecision) {
+				if d.Priority != "HIGH" {
+					t.Errorf("priority = %s, want HIGH", d.Priority)
+				}
+			},
+		},
+		{
+			name: "numeric priority clamped",
+			in:   model.AgentDecision{Priority: "7"},
+			check: func(t *testing.T, d *model.AgentDecision) {
+				if d.Priority != "HIGH" {
+					t.Errorf("priority = %s, want HIGH", d.Priority)
+				}
+			},
+		},
+		{name: "missing priority", in: model.AgentDecision{Categories: []string{"WORK"}}, wantErr: true},
+		{name: "unrecognised priority", in: model.AgentDecision{Priority: "whenever"}, wantErr: true},
+		{
+			name: "summary truncated by rune",
+			in:   model.AgentDecision{Priority: "LOW", Summary: strings.Repeat("é", maxSummaryLength+5)},
+			check: func(t *testing.T, d *model.AgentDecision) {
+				if n := utf8.RuneCountInString(d.Summary); n != maxSummaryLength {
+					t.Errorf("summary length = %d runes, want %d", n, maxSummaryLength)
+				}
+			},
+		},
+		{
+			name: "no valid tasks disables task creation",
+			in: model.AgentDecision{
+				Priority:         "MEDIUM",
+				ShouldCreateTask: true,
+				Tasks:            []model.TaskDecision{{Title: " "}},
+				Task:             &model.TaskDecision{Title: ""},
+			},
+			check: func(t *testing.T, d *model.AgentDecision) {
+				if d.ShouldCreateTask || len(d.Tasks) != 0 || d.Task != nil {
+					t.Errorf("tasks = %+v / %+v, should_create_task = %v", d.Tasks, d.Task, d.ShouldCreateTask)
+				}
+			},
+		},
+		{
+			name: "unsupported channel falls back to EMAIL",
+			in:   model.AgentDecision{Priority: "HIGH", ShouldNotify: true, NotificationChannel: "carrier-pigeon"},
+			check: func(t *testing.T, d *model.AgentDecision) {
+				if d.NotificationChannel != "EMAIL" {
+					t.Errorf("channel = %s, want EMAIL", d.NotificationChannel)
+				}
+			},
+		},
+		{
+			name: "supported channel normalised",
+			in:   model.AgentDecision{Priority: "HIGH", ShouldNotify: true, NotificationChannel: " push "},
+			check: func(t *testing.T, d *model.AgentDecision) {
+				if d.NotificationChannel != "PUSH" {
+					t.Errorf("channel = %s, want PUSH", d.NotificationChannel)
+				}
+			},
+		},
+		{
+			name: "notification message truncated",
+			in: model.AgentDecision{
+				Priority:            "HIGH",
+				ShouldNotify:        true,
+				NotificationMessage: strings.Repeat("通", maxNotificationMessageLength+1),
+			},
+			check: func(t *testing.T, d *model.AgentDecision) {
+				if n := utf8.RuneCountInString(d.NotificationMessage); n != maxNotificationMessageLength {
+					t.Errorf("message length = %d runes, want %d", n, maxNotificationMessageLength)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.in
+			err := validateDecision(&d, EmailInput{})
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidDecision) {
+					t.Errorf("err = %v, want ErrInvalidDecision", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateDecision: %v", err)
+			}
+			tt.check(t, &d)
+		})
+	}
+}
+
+// stubDecider 返回固定结果的决策后端；cancel 非空时在批量调用返回前调用（模拟调用途中消费者停止）
+type stubDecider struct {
+	decision  *model.AgentDecision
+	decisions []*model.AgentDecision
+	err       error
+	cancel    context.CancelFunc
+}
+
+func (d *stubDecider) Decide(ctx context.Context, email EmailInput) (*model.AgentDecision, error) {
+	if d.decision == nil {
+		return nil, d.err
+	}
+	decision := *d.decision
+	return &decision, d.err
+}
+
+func (d *stubDecider) DecideBatch(ctx context.Context, emails []EmailInput) ([]*model.AgentDecision, error) {
+	if d.cancel != nil {
+		d.cancel()
+	}
+	return d.decisions, d.err
+}
+
+func (d *stubDecider) Ping(ctx context.Context) error { return nil }
+
+func TestAgentClient_InvalidPriorityUsesFallback(t *testing.T) {
+	c := NewAgentClient(&stubDecider{decision: &model.AgentDecision{Priority: "whenever", Categories: []string{"WORK"}}})
+
+	decision, err := c.Decide(context.Background(), EmailInput{EmailID: 1, Subject: "Hello"})
+	if err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if decision.Source != model.DecisionSourceFallback {
+		t.Errorf("source = %s, want fallback", decision.Source)
+	}
+}
+
+func TestAgentClient_InvalidResponseUsesFallback(t *testing.T) {
+	invalid := fmt.Errorf("%w: bad json", ErrInvalidDecision)
+	c := NewAgentClient(&stubDecider{err: invalid})
+
+	decision, err := c.Decide(context.Background(), EmailInput{EmailID: 1})
+	if err != nil {
+		t.Fatalf("err = %v, want nil", err)
+	}
+	if decision.Source != model.DecisionSourceFallback {
+		t.Errorf("source = %s, want fallback", decision.Source)
+	}
+}
+
+// 单封和批量调用对无效响应的处理一致：都使用 fallback
+func TestAgentClient_DecideBatchDoesNotFailWholeBatch(t *testing.T) {
+	emails := []EmailInput{{EmailID: 1}, {EmailID: 2}, {EmailID: 3}}
+
+	t.Run("one invalid decision", func(t *testing.T) {
+		c := NewAgentClient(&stubDecider{decisions: []*model.AgentDecision{
+			{Priority: "urgent", Categories: []string{"WORK"}},
+			{Priority: "whenever"},
+			nil,
+		}})
+		decisions, errs := c.DecideBatch(context.Background(), emails)
+		for i, err := range errs {
+			if err != nil {
+				t.Errorf("errs[%d] = %v", i, err)
+			}
+		}
+		want := []string{model.DecisionSourceAgent, model.DecisionSourceFallback, model.DecisionSourceFallback}
+		for i, d := range decisions {
+			if d == nil || d.Source != want[i] {
+				t.Errorf("decision %d = %+v, want source %s", i, d, want[i])
+			}
+		}
+		if decisions[0].Priority != "HIGH" {
+			t.Errorf("decision 0 priority = %s, want HIGH", decisions[0].Priority)
+		}
+	})
+
+	t.Run("invalid batch response", func(t *testing.T) {
+		c := NewAgentClient(&stubDecider{err: fmt.Errorf("%w: bad json", ErrInvalidDecision)})
+		decisions, errs := c.DecideBatch(context.Background(), emails)
+		for i := range emails {
+			if errs[i] != nil {
+				t.Errorf("errs[%d] = %v", i, errs[i])
+			}
+			if decisions[i] == nil || decisions[i].Source != model.DecisionSourceFallback {
+				t.Errorf("decision %d = %+v, want fallback", i, decisions[i])
+			}
+		}
+	})
+}
+
+// countingFallback 记录本地分类器被调用的次数
+type countingFallback struct {
+	calls int
+}
+
+func (f *countingFallback) Classify(ctx context.Context, email EmailInput) (*model.AgentDecision, error) {
+	f.calls++
+	return &model.AgentDecision{Priority: "MEDIUM", Source: model.DecisionSourceFallback}, nil
+}
+
+func TestAgentClient_DecideBatchFailures(t *testing.T) {
+	emails := []EmailInput{{EmailID: 1}, {EmailID: 2}}
+	valid := []*model.AgentDecision{
+		{Priority: "HIGH", Categories: []string{"WORK"}},
+		{Priority: "LOW", Categories: []string{"NEWSLETTER"}},
+	}
+
+	tests := []struct {
+		name    string
+		decider func(cancel context.CancelFunc) *stubDecider
+		wantErr error
+	}{
+		{"whole batch fails", func(context.CancelFunc) *stubDecider {
+			return &stubDecider{err: errors.New("agent service 5xx: 503")}
+		}, ErrBatchFailed},
+		{"cancelled mid-batch", func(cancel context.CancelFunc) *stubDecider {
+			return &stubDecider{err: context.Canceled, cancel: cancel}
+		}, context.Canceled},
+		{"cancelled after results", func(cancel context.CancelFunc) *stubDecider {
+			return &stubDecider{decisions: valid, cancel: cancel}
+		}, context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			fallback := &countingFallback{}
+			c := NewAgentClient(tt.decider(cancel)).WithFallback(fallback)
+
+			decisions, errs := c.DecideBatch(ctx, emails)
+			for i := range emails {
+				if !errors.Is(errs[i], tt.wantErr) {
+					t.Errorf("errs[%d] = %v, want %v", i, errs[i], tt.wantErr)
+				}
+				// 没有决策就不会写入 metadata，消息重新入队
+				if decisions[i] != nil {
+					t.Errorf("decision %d = %+v, want nil", i, decisions[i])
+				}
+			}
+			if fallback.calls != 0 {
+				t.Errorf("fallback called %d times, want 0", fallback.calls)
+			}
+		})
+	}
+}
@@ -2,19 +2,33 @@ package main
 
 import (
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"mail-ingestion-service/internal/blobstore"
 	"mail-ingestion-service/internal/config"
 	"mail-ingestion-service/internal/handler"
 	"mail-ingestion-service/internal/httpserver"
+	"mail-ingestion-service/internal/imappoller"
+	"mail-ingestion-service/internal/importer"
+	"mail-ingestion-service/internal/mqhandler"
+	"mail-ingestion-service/internal/quota"
 	"mail-ingestion-service/internal/repository"
+	"mail-ingestion-service/internal/retryworker"
 	"mail-ingestion-service/internal/service/ingest"
+	"mail-ingestion-service/internal/smtpserver"
+	"mail-ingestion-service/internal/webhook"
 	"context"
 	"mygoproject/pkg/db"
 	"mygoproject/pkg/logger"
 	"mygoproject/pkg/mq"
 	"mygoproject/pkg/otel"
 	"mygoproject/pkg/outbox"
+	"mygoproject/pkg/redis"
+	"mygoproject/pkg/util"
 
 	"go.uber.org/zap"
 )
@@ -58,25 +72,173 @@ func main() {
 
 	// Init Repositories
 	emailRepo := repository.NewEmailRepository(dbConn)
+	userRepo := repository.NewUserRepository(dbConn)
+	mailboxRepo := repository.NewMailboxRepository(dbConn)
+
+	attachmentRepo := repository.NewAttachmentRepository(dbConn)
+	threadRepo := repository.NewThreadRepository(dbConn)
+	importJobRepo := repository.NewImportJobRepository(dbConn)
+	senderRuleRepo := repository.NewSenderRuleRepository(dbConn)
+	failedEventRepo := repository.NewFailedEventRepository(dbConn)
+
+	// Init Blob Store（附件存储）
+	blobStore, err := blobstore.New(cfg.Blob)
+	if err != nil {
+		logger.Fatal("Blob store initialization failed", zap.Error(err))
+	}
 
 	// Init Services
-	ingestService := ingest.NewService(dbConn, emailRepo, logger)
+	ingestService := ingest.NewService(dbConn, emailRepo, attachmentRepo, threadRepo, senderRuleRepo, blobStore, logger)
+	if cfg.Filter.SpamThreshold > 0 {
+		ingestService.WithSpamThreshold(cfg.Filter.SpamThreshold)
+	}
+	ingestService.WithSkipAgentForBulk(cfg.Filter.SkipAgentForBulk)
+
+	// Init Redis（入库限额、webhook 重放保护；连接在首次使用时建立）
+	rdb := redis.NewRedisClient(cfg.Redis)
+	defer rdb.Close()
+
+	// Init Quota（每用户入库限额，Redis 令牌桶）
+	if cfg.Quota.Enabled {
+		rateLimiter := util.NewRateLimiter(rdb, logger)
+		ingestService.WithQuota(quota.NewLimiter(repository.NewQuotaRepository(dbConn), rateLimiter, cfg.Quota, logger))
+		logger.Info("Ingestion quota enabled",
+			zap.Int("emails_per_minute", cfg.Quota.EmailsPerMinute),
+			zap.Int("emails_per_day", cfg.Quota.EmailsPerDay),
+			zap.Int64("max_body_bytes", cfg.Quota.MaxBodyBytes),
+		)
+	}
 
 	// Init Outbox Dispatcher
 	outboxRepo := outbox.NewRepository(dbConn)
 	dispatcher := outbox.NewDispatcher(outboxRepo, publisher, logger)
+
+	// Init Retry Worker（outbox 放弃的事件写入 failed_events，按指数退避重新发布）
+	retryWorker := retryworker.NewWorker(failedEventRepo, outboxRepo, publisher, logger)
+	if cfg.Retry.IntervalSeconds > 0 {
+		retryWorker.WithInterval(time.Duration(cfg.Retry.IntervalSeconds) * time.Second)
+	}
+	if cfg.Retry.BatchSize > 0 {
+		retryWorker.WithBatchSize(cfg.Retry.BatchSize)
+	}
+	if cfg.Retry.MaxRetries > 0 {
+		retryWorker.WithMaxRetries(cfg.Retry.MaxRetries)
+	}
+	if cfg.Retry.BaseBackoffSeconds > 0 {
+		retryWorker.WithBaseBackoff(time.Duration(cfg.Retry.BaseBackoffSeconds) * time.Second)
+	}
+	dispatcher.WithFailureHandler(retryWorker.RecordOutboxFailure)
 	go dispatcher.Start(context.Background())
+	go retryWorker.Start(context.Background())
+
+	// MQ Consumer for email.deleted（回收已删除邮件不再被引用的附件 blob）
+	emailDeletedHandler := mqhandler.NewEmailDeletedHandler(dbConn, attachmentRepo, blobStore, logger)
+	emailDeletedConsumer, err := mq.NewConsumer(cfg.MQ.URL, "email.deleted.q", "email.deleted", logger)
+	if err != nil {
+		logger.Fatal("Failed to init email.deleted consumer", zap.Error(err))
+	}
+	defer emailDeletedConsumer.Close()
+	emailDeletedConsumer.SetHandler(emailDeletedHandler.Handle)
+	go func() {
+		if err := emailDeletedConsumer.StartConsuming(); err != nil {
+			logger.Fatal("email.deleted consumer failed", zap.Error(err))
+		}
+	}()
+
+	// Start SMTP listener（可选）
+	if cfg.SMTP.Enabled {
+		smtpServer := smtpserver.NewServer(cfg.SMTP, ingestService, userRepo, logger)
+		defer smtpServer.Close()
+		go func() {
+			if err := smtpServer.ListenAndServe(); err != nil {
+				logger.Fatal("SMTP server start failed", zap.Error(err))
+			}
+		}()
+	}
+
+	// Start IMAP Poller（可选）
+	if cfg.IMAP.Enabled {
+		if cfg.IMAP.SecretKey == "" {
+			logger.Fatal("imap.secret_key is required when IMAP polling is enabled")
+		}
+		poller := imappoller.NewPoller(
+			imappoller.NewPostgresStore(dbConn, mailboxRepo, ingestService),
+			imappoller.NewNetDialer(time.Duration(cfg.IMAP.TimeoutSeconds)*time.Second),
+			cfg.IMAP.SecretKey,
+			logger,
+		)
+		if cfg.IMAP.PollIntervalSeconds > 0 {
+			poller.WithInterval(time.Duration(cfg.IMAP.PollIntervalSeconds) * time.Second)
+		}
+		if cfg.IMAP.BatchSize > 0 {
+			poller.WithBatchSize(cfg.IMAP.BatchSize)
+		}
+		go poller.Start(context.Background())
+	}
+
+	// Init Importer（mbox / zip 批量导入）
+	// 上一个进程遗留的 pending/running 任务无法恢复，标记为失败
+	if n, err := importJobRepo.FailInterrupted(context.Background()); err != nil {
+		logger.Warn("Failed to clean up interrupted import jobs", zap.Error(err))
+	} else if n > 0 {
+		logger.Warn("Marked interrupted import jobs as failed", zap.Int64("count", n))
+	}
+	emailImporter := importer.NewImporter(ingestService, importJobRepo, outboxRepo, publisher, logger)
+	if cfg.Import.MaxQueueDepth > 0 {
+		emailImporter.WithMaxQueueDepth(cfg.Import.MaxQueueDepth)
+	}
+	if cfg.Import.MaxConcurrentJobs > 0 {
+		emailImporter.WithMaxConcurrentJobs(cfg.Import.MaxConcurrentJobs)
+	}
+
+	// Init Webhook Adapters（只启用配置了密钥的服务商）
+	webhookAdapters, err := webhook.NewAdapters(cfg.Webhook, webhook.NewRedisReplayGuard(rdb, logger))
+	if err != nil {
+		logger.Fatal("Webhook adapter initialization failed", zap.Error(err))
+	}
+	for name := range webhookAdapters {
+		logger.Info("Inbound webhook enabled", zap.String("provider", name))
+	}
 
 	// Init Handlers
 	ingestHandler := handler.NewIngestHandler(ingestService)
+	mailboxHandler := handler.NewMailboxHandler(mailboxRepo, cfg.IMAP.SecretKey, logger)
+	importHandler := handler.NewImportHandler(importJobRepo, emailImporter, cfg.Import.TempDir, cfg.Import.MaxUploadBytes, logger)
+	webhookHandler := handler.NewWebhookHandler(webhookAdapters, ingestService, userRepo, logger)
+	senderRuleHandler := handler.NewSenderRuleHandler(senderRuleRepo, logger)
+	failedEventHandler := handler.NewFailedEventHandler(failedEventRepo, retryWorker, logger)
 
 	// Router
-	router := httpserver.NewRouter(ingestHandler, dbConn, publisher)
+	router := httpserver.NewRouter(ingestHandler, mailboxHandler, importHandler, webhookHandler, senderRuleHandler, failedEventHandler, dbConn, publisher)
 
 	// Start server
-	logger.Info("Starting mail ingestion service", zap.String("port", cfg.Server.Port))
-	if err := router.Run(cfg.Server.Port); err != nil {
-		log.Fatalf("server start failed: %v", err)
+	srv := &http.Server{
+		Addr:    cfg.Server.Port,
+		Handler: router.Engine,
+	}
+	go func() {
+		logger.Info("Starting mail ingestion service", zap.String("port", cfg.Server.Port))
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("server start failed: %v", err)
+		}
+	}()
+
+	// 优雅退出：先停止接收新请求，再中断运行中的导入任务并记录原因
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	logger.Info("Shutting down mail ingestion service gracefully...")
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer shutdownCancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("HTTP server shutdown error", zap.Error(err))
 	}
+	if err := emailImporter.Stop(shutdownCtx); err != nil {
+		logger.Error("Import jobs did not stop in time", zap.Error(err))
+	}
+
+	logger.Info("mail ingestion service shutdown complete")
 }
 
@@ -0,0 +1,40 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"mail-ingestion-service/internal/config"
+)
+
+// ErrNotFound 表示对象不存在
+var ErrNotFound = errors.New("blob not found")
+
+// Store 附件对象存储（接口与 S3 兼容存储保持一致：按 key 读写对象）
+type Store interface {
+	// Put 写入对象；key 已存在时覆盖
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get 读取对象，不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Exists 判断对象是否存在
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete 删除对象，不存在时不报错
+	Delete(ctx context.Context, key string) error
+}
+
+// New 根据配置创建 Store
+func New(cfg config.BlobStoreConfig) (Store, error) {
+	switch cfg.Type {
+	case "", "local":
+		return NewLocalStore(cfg.LocalDir)
+	default:
+		return nil, fmt.Errorf("unsupported blob store type: %s", cfg.Type)
+	}
+}
+
+// ContentKey 内容寻址的对象 key：相同内容的附件只存储一份
+func ContentKey(sha256Hex string) string {
+	return fmt.Sprintf("sha256/%s/%s/%s", sha256Hex[:2], sha256Hex[2:4], sha256Hex)
+}
@@ -2,22 +2,109 @@ package config
 
 import (
 	"log"
+	"os"
+	"strconv"
 
 	"gopkg.in/yaml.v3"
 	"mygoproject/pkg/config"
 )
 
 type Config struct {
-	DB     config.DBConfig     `yaml:"db"`
-	MQ     config.MQConfig     `yaml:"mq"`
-	Server config.ServerConfig `yaml:"server"`
+	DB      config.DBConfig     `yaml:"db"`
+	MQ      config.MQConfig     `yaml:"mq"`
+	Redis   config.RedisConfig  `yaml:"redis"`
+	Server  config.ServerConfig `yaml:"server"`
+	SMTP    SMTPConfig          `yaml:"smtp"`
+	IMAP    IMAPConfig          `yaml:"imap"`
+	Blob    BlobStoreConfig     `yaml:"blob_store"`
+	Import  ImportConfig        `yaml:"import"`
+	Webhook WebhookConfig       `yaml:"webhooks"`
+	Filter  FilterConfig        `yaml:"filter"`
+	Quota   QuotaConfig         `yaml:"quota"`
+	Retry   RetryWorkerConfig   `yaml:"retry_worker"`
+}
+
+// SMTPConfig 内置 SMTP/LMTP 监听配置
+type SMTPConfig struct {
+	Enabled         bool   `yaml:"enabled"`
+	Port            string `yaml:"port"`
+	Domain          string `yaml:"domain"`
+	LMTP            bool   `yaml:"lmtp"`
+	MaxMessageBytes int64  `yaml:"max_message_bytes"`
+	MaxRecipients   int    `yaml:"max_recipients"`
+}
+
+// IMAPConfig IMAP 邮箱轮询配置
+type IMAPConfig struct {
+	Enabled             bool   `yaml:"enabled"`
+	PollIntervalSeconds int    `yaml:"poll_interval_seconds"`
+	BatchSize           int    `yaml:"batch_size"`
+	TimeoutSeconds      int    `yaml:"timeout_seconds"`
+	SecretKey           string `yaml:"secret_key"` // 用于加密邮箱密码
+}
+
+// BlobStoreConfig 附件存储配置
+type BlobStoreConfig struct {
+	Type     string `yaml:"type"` // local（默认）
+	LocalDir string `yaml:"local_dir"`
+}
+
+// ImportConfig mbox / zip 批量导入配置
+type ImportConfig struct {
+	MaxUploadBytes    int64  `yaml:"max_upload_bytes"`
+	MaxQueueDepth     int    `yaml:"max_queue_depth"` // 下游积压超过该值时暂停导入
+	MaxConcurrentJobs int    `yaml:"max_concurrent_jobs"`
+	TempDir           string `yaml:"temp_dir"` // 为空时使用系统临时目录
+}
+
+// FilterConfig 入库前垃圾邮件过滤配置（发件人规则由用户通过 /sender-rules 管理）
+type FilterConfig struct {
+	SpamThreshold    int  `yaml:"spam_threshold"`      // 评分达到该值时不发布事件
+	SkipAgentForBulk bool `yaml:"skip_agent_for_bulk"` // 群发邮件不调用 AI 决策
+}
+
+// QuotaConfig 每用户入库限额默认值（0 表示不限制），可在 user_ingestion_quotas 中按用户覆盖
+type QuotaConfig struct {
+	Enabled         bool  `yaml:"enabled"`
+	EmailsPerMinute int   `yaml:"emails_per_minute"`
+	EmailsPerDay    int   `yaml:"emails_per_day"`
+	MaxBodyBytes    int64 `yaml:"max_body_bytes"` // 正文 + 附件大小
+}
+
+// RetryWorkerConfig failed_events 重试 worker 配置（指数退避：base, 2*base, 4*base...）
+type RetryWorkerConfig struct {
+	IntervalSeconds    int `yaml:"interval_seconds"`
+	BatchSize          int `yaml:"batch_size"`
+	MaxRetries         int `yaml:"max_retries"`
+	BaseBackoffSeconds int `yaml:"base_backoff_seconds"`
+}
+
+// WebhookConfig 邮件服务商入站 webhook 配置，未配置密钥的服务商不启用
+type WebhookConfig struct {
+	SendGrid                SendGridWebhookConfig `yaml:"sendgrid"`
+	Mailgun                 MailgunWebhookConfig  `yaml:"mailgun"`
+	Postmark                PostmarkWebhookConfig `yaml:"postmark"`
+	MaxTimestampSkewSeconds int                   `yaml:"max_timestamp_skew_seconds"` // 签名时间戳允许的偏差
+}
+
+type SendGridWebhookConfig struct {
+	VerificationKey string `yaml:"verification_key"` // base64 编码的 ECDSA 公钥
+}
+
+type MailgunWebhookConfig struct {
+	SigningKey string `yaml:"signing_key"`
+}
+
+type PostmarkWebhookConfig struct {
+	Username string `yaml:"username"` // webhook URL 中的 Basic 认证
+	Password string `yaml:"password"`
 }
 
 func Load() *Config {
 	// 使用统一配置中心
 	env := config.GetConfigEnv()
 	configDir := config.GetEnv("CONFIG_DIR", "config")
-	
+
 	cfgMap, err := config.LoadConfig(env, configDir)
 	if err != nil {
 		log.Fatalf("failed to load config: %v", err)
@@ -36,7 +123,57 @@ func Load() *Config {
 	// 环境变量覆盖（优先级最高）
 	config.OverrideDBFromEnv(&cfg.DB)
 	config.OverrideMQFromEnv(&cfg.MQ)
+	config.OverrideRedisFromEnv(&cfg.Redis)
 	config.OverrideServerFromEnv(&cfg.Server)
+	overrideSMTPFromEnv(&cfg.SMTP)
+	overrideIMAPFromEnv(&cfg.IMAP)
+	overrideWebhookFromEnv(&cfg.Webhook)
+	if dir := os.Getenv("BLOB_STORE_DIR"); dir != "" {
+		cfg.Blob.LocalDir = dir
+	}
 
 	return &cfg
 }
+
+// overrideSMTPFromEnv 从环境变量覆盖 SMTP 配置
+func overrideSMTPFromEnv(cfg *SMTPConfig) {
+	if enabled := os.Getenv("SMTP_ENABLED"); enabled != "" {
+		if b, err := strconv.ParseBool(enabled); err == nil {
+			cfg.Enabled = b
+		}
+	}
+	if port := os.Getenv("SMTP_PORT"); port != "" {
+		cfg.Port = port
+	}
+	if domain := os.Getenv("SMTP_DOMAIN"); domain != "" {
+		cfg.Domain = domain
+	}
+}
+
+// overrideIMAPFromEnv 从环境变量覆盖 IMAP 配置
+func overrideIMAPFromEnv(cfg *IMAPConfig) {
+	if enabled := os.Getenv("IMAP_ENABLED"); enabled != "" {
+		if b, err := strconv.ParseBool(enabled); err == nil {
+			cfg.Enabled = b
+		}
+	}
+	if secretKey := os.Getenv("IMAP_SECRET_KEY"); secretKey != "" {
+		cfg.SecretKey = secretKey
+	}
+}
+
+// overrideWebhookFromEnv 从环境变量覆盖 webhook 密钥
+func overrideWebhookFromEnv(cfg *WebhookConfig) {
+	if key := os.Getenv("SENDGRID_WEBHOOK_VERIFICATION_KEY"); key != "" {
+		cfg.SendGrid.VerificationKey = key
+	}
+	if key := os.Getenv("MAILGUN_WEBHOOK_SIGNING_KEY"); key != "" {
+		cfg.Mailgun.SigningKey = key
+	}
+	if username := os.Getenv("POSTMARK_WEBHOOK_USERNAME"); username != "" {
+		cfg.Postmark.Username = username
+	}
+	if password := os.Getenv("POSTMARK_WEBHOOK_PASSWORD"); password != "" {
+		cfg.Postmark.Password = password
+	}
+}
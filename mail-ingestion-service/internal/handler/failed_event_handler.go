@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mail-ingestion-service/internal/repository"
+	"mail-ingestion-service/internal/retryworker"
+	"mygoproject/pkg/rbac"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+type FailedEventHandler struct {
+	failedEventRepo *repository.FailedEventRepository
+	worker          *retryworker.Worker
+	logger          *zap.Logger
+}
+
+func NewFailedEventHandler(failedEventRepo *repository.FailedEventRepository, worker *retryworker.Worker, logger *zap.Logger) *FailedEventHandler {
+	return &FailedEventHandler{
+		failedEventRepo: failedEventRepo,
+		worker:          worker,
+		logger:          logger,
+	}
+}
+
+// ListFailedEvents handles GET /admin/failed-events?status=pending&limit=50&offset=0
+// 没有 failed_event:manage 权限的用户只能看到自己的事件
+func (h *FailedEventHandler) ListFailedEvents(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+	status := c.Query("status")
+	switch status {
+	case "", "pending", "retrying", "retried", "failed", "discarded":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, retrying, retried, failed or discarded"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return
+	}
+
+	events, err := h.failedEventRepo.List(c.Request.Context(), scopeUserID(userID), status, limit, offset)
+	if err != nil {
+		h.logger.Error("ListFailedEvents: failed to fetch failed events", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch failed events"})
+		return
+	}
+	if events == nil {
+		events = []repository.FailedEvent{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events, "limit": limit, "offset": offset})
+}
+
+// GetFailedEvent handles GET /admin/failed-events/:id
+func (h *FailedEventHandler) GetFailedEvent(c *gin.Context) {
+	event, ok := h.loadEvent(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, event)
+}
+
+// RetryFailedEvent handles POST /admin/failed-events/:id/retry
+// 立即重新发布，失败时计入重试次数并返回 502
+func (h *FailedEventHandler) RetryFailedEvent(c *gin.Context) {
+	event, ok := h.loadEvent(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := h.worker.Retry(ctx, event); err != nil {
+		if errors.Is(err, retryworker.ErrNotRetryable) {
+			c.JSON(http.StatusConflict, gin.H{"error": "failed event is already " + event.Status})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to republish event", "details": err.Error()})
+		return
+	}
+
+	updated, err := h.failedEventRepo.GetByID(ctx, event.ID)
+	if err != nil {
+		h.logger.Error("RetryFailedEvent: failed to reload failed event", zap.Int("id", event.ID), zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"status": "retried"})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DiscardFailedEvent handles POST /admin/failed-events/:id/discard
+func (h *FailedEventHandler) DiscardFailedEvent(c *gin.Context) {
+	event, ok := h.loadEvent(c)
+	if !ok {
+		return
+	}
+
+	discarded, err := h.failedEventRepo.MarkAsDiscarded(c.Request.Context(), event.ID)
+	if err != nil {
+		h.logger.Error("DiscardFailedEvent: failed to discard failed event", zap.Int("id", event.ID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to discard failed event"})
+		return
+	}
+	if !discarded {
+		c.JSON(http.StatusConflict, gin.H{"error": "failed event is already " + event.Status})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "discarded"})
+}
+
+// scopeUserID 返回查询失败事件时限定的用户：管理员为 0（不限用户），其他用户为自己
+func scopeUserID(userID int) int {
+	if rbac.HasPermission(userID, rbac.PermissionManageFailedEvents) {
+		return 0
+	}
+	return userID
+}
+
+// loadEvent 解析路径中的 id 并加载事件，失败时已写入响应
+// 其他用户的事件按不存在处理，不暴露事件是否存在
+func (h *FailedEventHandler) loadEvent(c *gin.Context) (*repository.FailedEvent, bool) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return nil, false
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid failed event id"})
+		return nil, false
+	}
+
+	event, err := h.failedEventRepo.GetByID(c.Request.Context(), id)
+	if err == nil && scopeUserID(userID) != 0 && event.UserID != userID {
+		err = pgx.ErrNoRows
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "failed event not found"})
+			return nil, false
+		}
+		h.logger.Error("Failed to fetch failed event", zap.Int("id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch failed event"})
+		return nil, false
+	}
+	return event, true
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"mail-ingestion-service/internal/importer"
+	"mail-ingestion-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultMaxImportBytes 导入文件大小上限（1GB）
+	defaultMaxImportBytes = 1 << 30
+)
+
+type ImportHandler struct {
+	jobRepo        *repository.ImportJobRepository
+	importer       *importer.Importer
+	tempDir        string
+	maxUploadBytes int64
+	logger         *zap.Logger
+}
+
+func NewImportHandler(
+	jobRepo *repository.ImportJobRepository,
+	importer *importer.Importer,
+	tempDir string,
+	maxUploadBytes int64,
+	logger *zap.Logger,
+) *ImportHandler {
+	if maxUploadBytes <= 0 {
+		maxUploadBytes = defaultMaxImportBytes
+	}
+	return &ImportHandler{
+		jobRepo:        jobRepo,
+		importer:       importer,
+		tempDir:        tempDir,
+		maxUploadBytes: maxUploadBytes,
+		logger:         logger,
+	}
+}
+
+// CreateImport handles POST /email/import
+// multipart/form-data，字段 file 为 mbox 文件或包含 .eml 的 zip
+func (h *ImportHandler) CreateImport(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUploadBytes)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "import file too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+
+	src, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read upload"})
+		return
+	}
+	defer src.Close()
+
+	// 识别格式
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(src, head)
+	format, err := importer.DetectFormat(fileHeader.Filename, head[:n])
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported file, expected mbox or zip of .eml files"})
+		return
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read upload"})
+		return
+	}
+
+	// 请求结束后 multipart 临时文件会被删除，先复制一份交给后台任务
+	tmpPath, err := h.saveTemp(src)
+	if err != nil {
+		h.logger.Error("CreateImport: failed to save upload", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save upload"})
+		return
+	}
+
+	filename := filepath.Base(fileHeader.Filename)
+	jobID, err := h.jobRepo.Create(c.Request.Context(), userID, filename, format)
+	if err != nil {
+		os.Remove(tmpPath)
+		h.logger.Error("CreateImport: failed to create job", zap.Int("user_id", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create import job"})
+		return
+	}
+
+	h.importer.Submit(jobID, userID, tmpPath, format)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id": jobID,
+		"format": format,
+		"status": "pending",
+	})
+}
+
+// GetImport handles GET /email/import/:id
+func (h *ImportHandler) GetImport(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	jobID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return
+	}
+
+	job, err := h.jobRepo.GetByID(c.Request.Context(), jobID, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "import job not found"})
+			return
+		}
+		h.logger.Error("GetImport: failed to fetch job", zap.Int("job_id", jobID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch import job"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+func (h *ImportHandler) saveTemp(src io.Reader) (string, error) {
+	dst, err := os.CreateTemp(h.tempDir, "ezmail-import-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return "", err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return "", err
+	}
+	return dst.Name(), nil
+}
@@ -1,13 +1,24 @@
 package handler
 
 import (
+	"errors"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"mail-ingestion-service/internal/quota"
 	"mail-ingestion-service/internal/service/ingest"
 )
 
+const (
+	// maxRawEmailBytes 原始邮件大小上限（25MB，与常见 MTA 默认值一致）
+	maxRawEmailBytes = 25 << 20
+	// maxIdempotencyKeyLen 与 emails_raw.idempotency_key 列长度一致
+	maxIdempotencyKeyLen = 255
+)
+
 type IngestHandler struct {
 	ingestService *ingest.Service
 }
@@ -30,28 +41,119 @@ func (h *IngestHandler) SimulateNewEmail(c *gin.Context) {
 		return
 	}
 
-	// Get user ID from header (set by api-gateway)
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+	idempotencyKey, ok := getIdempotencyKey(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.ingestService.CreateRawAndPublish(c.Request.Context(), userID, ingest.NewSimpleMessage(req.Subject, req.Body), idempotencyKey)
+	if err != nil {
+		writeIngestError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"email_id": result.EmailID,
+		"status":   ingestStatus(result),
+	})
+}
+
+// IngestRawEmail handles POST /email/raw
+// 请求体为 message/rfc822 原始邮件
+func (h *IngestHandler) IngestRawEmail(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+	idempotencyKey, ok := getIdempotencyKey(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRawEmailBytes)
+	msg, err := ingest.ParseMessage(c.Request.Body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "email too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rfc822 message", "details": err.Error()})
+		return
+	}
+
+	result, err := h.ingestService.CreateRawAndPublish(c.Request.Context(), userID, msg, idempotencyKey)
+	if err != nil {
+		writeIngestError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"email_id":   result.EmailID,
+		"message_id": msg.MessageID,
+		"status":     ingestStatus(result),
+	})
+}
+
+// getUserID 从 X-User-ID 头读取用户 ID（由 api-gateway 设置）
+func getUserID(c *gin.Context) (int, bool) {
 	userIDStr := c.GetHeader("X-User-ID")
 	if userIDStr == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
-		return
+		return 0, false
 	}
 
 	userID, err := strconv.Atoi(userIDStr)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
-		return
+		return 0, false
 	}
+	return userID, true
+}
 
-	emailID, err := h.ingestService.CreateRawAndPublish(c.Request.Context(), userID, req.Subject, req.Body)
-	if err != nil {
+// getIdempotencyKey 读取可选的 Idempotency-Key 头
+func getIdempotencyKey(c *gin.Context) (string, bool) {
+	key := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+	if len(key) > maxIdempotencyKeyLen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "idempotency key too long"})
+		return "", false
+	}
+	return key, true
+}
+
+// writeIngestError 超出入库限额时返回 429（带 Retry-After）或 413，其余返回 500
+func writeIngestError(c *gin.Context, err error) {
+	exceeded, ok := quota.AsExceeded(err)
+	if !ok {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create email"})
 		return
 	}
+	if exceeded.RetryAfter <= 0 {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "email exceeds size quota"})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"email_id": emailID,
-		"status":   "queued",
+	// Retry-After 以秒为单位，向上取整
+	retryAfter := int(math.Ceil(exceeded.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "ingestion rate limit exceeded",
+		"reason":      exceeded.Reason,
+		"retry_after": retryAfter,
 	})
 }
 
+// ingestStatus 重复投递返回 duplicate，被规则 / 垃圾邮件评分过滤返回 filtered，否则 queued
+func ingestStatus(result *ingest.Result) string {
+	if result.Duplicate {
+		return "duplicate"
+	}
+	if result.FilterReason != "" {
+		return "filtered"
+	}
+	return "queued"
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newIngestTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	// 超限和格式错误的请求在调用 ingest.Service 之前返回
+	r.POST("/email/raw", NewIngestHandler(nil).IngestRawEmail)
+	return r
+}
+
+func postRawEmail(r http.Handler, body io.Reader) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/email/raw", body)
+	req.Header.Set("Content-Type", "message/rfc822")
+	req.Header.Set("X-User-ID", "1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIngestRawEmail_TooLarge(t *testing.T) {
+	header := "From: alice@example.org\r\nSubject: Big\r\nContent-Type: text/plain\r\n\r\n"
+	body := io.MultiReader(strings.NewReader(header), bytes.NewReader(bytes.Repeat([]byte("a"), maxRawEmailBytes)))
+
+	w := postRawEmail(newIngestTestRouter(), body)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want 413; body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestIngestRawEmail_Invalid(t *testing.T) {
+	w := postRawEmail(newIngestTestRouter(), strings.NewReader("Content-Type: multipart/mixed\r\n\r\nno boundary\r\n"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400; body = %s", w.Code, w.Body.String())
+	}
+}
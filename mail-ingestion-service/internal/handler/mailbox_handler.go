@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mail-ingestion-service/internal/repository"
+	dbcontracts "mygoproject/contracts/db"
+	"mygoproject/pkg/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+type MailboxHandler struct {
+	mailboxRepo *repository.MailboxRepository
+	secretKey   string
+	logger      *zap.Logger
+}
+
+func NewMailboxHandler(mailboxRepo *repository.MailboxRepository, secretKey string, logger *zap.Logger) *MailboxHandler {
+	return &MailboxHandler{
+		mailboxRepo: mailboxRepo,
+		secretKey:   secretKey,
+		logger:      logger,
+	}
+}
+
+// CreateMailbox handles POST /mailboxes
+func (h *MailboxHandler) CreateMailbox(c *gin.Context) {
+	var req struct {
+		Host     string `json:"host"`
+		Port     int    `json:"port"`
+		UseTLS   *bool  `json:"use_tls"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Folder   string `json:"folder"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	req.Host = strings.TrimSpace(req.Host)
+	if req.Host == "" || req.Username == "" || req.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "host, username and password are required"})
+		return
+	}
+
+	// 默认使用 IMAPS（993），显式关闭 TLS 时默认 143
+	useTLS := true
+	if req.UseTLS != nil {
+		useTLS = *req.UseTLS
+	}
+	if req.Port == 0 {
+		req.Port = 993
+		if !useTLS {
+			req.Port = 143
+		}
+	}
+	if req.Port < 1 || req.Port > 65535 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid port"})
+		return
+	}
+	if req.Folder == "" {
+		req.Folder = "INBOX"
+	}
+
+	encrypted, err := util.EncryptSecret(req.Password, h.secretKey)
+	if err != nil {
+		h.logger.Error("CreateMailbox: failed to encrypt password", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store credentials"})
+		return
+	}
+
+	mailbox := &dbcontracts.Mailbox{
+		UserID:            userID,
+		Host:              req.Host,
+		Port:              req.Port,
+		UseTLS:            useTLS,
+		Username:          req.Username,
+		PasswordEncrypted: encrypted,
+		Folder:            req.Folder,
+	}
+
+	id, err := h.mailboxRepo.Create(c.Request.Context(), mailbox)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "mailbox already exists"})
+			return
+		}
+		h.logger.Error("CreateMailbox: failed to create mailbox", zap.Int("user_id", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create mailbox"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mailbox_id": id,
+		"status":     "active",
+	})
+}
+
+// ListMailboxes handles GET /mailboxes
+// 返回每个邮箱的同步状态（last_sync_at、last_error、last_uid）
+func (h *MailboxHandler) ListMailboxes(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	mailboxes, err := h.mailboxRepo.ListByUser(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("ListMailboxes: failed to fetch mailboxes", zap.Int("user_id", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch mailboxes"})
+		return
+	}
+	if mailboxes == nil {
+		mailboxes = []dbcontracts.Mailbox{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mailboxes": mailboxes})
+}
+
+// GetMailbox handles GET /mailboxes/:id
+func (h *MailboxHandler) GetMailbox(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mailbox id"})
+		return
+	}
+
+	mailbox, err := h.mailboxRepo.GetByID(c.Request.Context(), id, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "mailbox not found"})
+			return
+		}
+		h.logger.Error("GetMailbox: failed to fetch mailbox", zap.Int("mailbox_id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch mailbox"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mailbox)
+}
+
+// DeleteMailbox handles DELETE /mailboxes/:id
+func (h *MailboxHandler) DeleteMailbox(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mailbox id"})
+		return
+	}
+
+	deleted, err := h.mailboxRepo.Delete(c.Request.Context(), id, userID)
+	if err != nil {
+		h.logger.Error("DeleteMailbox: failed to delete mailbox", zap.Int("mailbox_id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete mailbox"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "mailbox not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
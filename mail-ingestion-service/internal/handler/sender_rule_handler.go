@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"mail-ingestion-service/internal/repository"
+	dbcontracts "mygoproject/contracts/db"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+type SenderRuleHandler struct {
+	ruleRepo *repository.SenderRuleRepository
+	logger   *zap.Logger
+}
+
+func NewSenderRuleHandler(ruleRepo *repository.SenderRuleRepository, logger *zap.Logger) *SenderRuleHandler {
+	return &SenderRuleHandler{
+		ruleRepo: ruleRepo,
+		logger:   logger,
+	}
+}
+
+// senderRuleRequest 创建 / 更新规则的请求体
+type senderRuleRequest struct {
+	MatchType string `json:"match_type"` // address / domain
+	Pattern   string `json:"pattern"`
+	Action    string `json:"action"`   // allow / block / skip_agent / force_priority
+	Priority  string `json:"priority"` // force_priority 时必填
+}
+
+// CreateRule handles POST /sender-rules
+func (h *SenderRuleHandler) CreateRule(c *gin.Context) {
+	var req senderRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	rule, err := buildSenderRule(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule.UserID = userID
+
+	id, err := h.ruleRepo.Create(c.Request.Context(), rule)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "rule for this sender already exists"})
+			return
+		}
+		h.logger.Error("CreateRule: failed to create sender rule", zap.Int("user_id", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create sender rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rule_id": id})
+}
+
+// ListRules handles GET /sender-rules
+func (h *SenderRuleHandler) ListRules(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	rules, err := h.ruleRepo.ListByUser(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("ListRules: failed to fetch sender rules", zap.Int("user_id", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sender rules"})
+		return
+	}
+	if rules == nil {
+		rules = []dbcontracts.SenderRule{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// GetRule handles GET /sender-rules/:id
+func (h *SenderRuleHandler) GetRule(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule id"})
+		return
+	}
+
+	rule, err := h.ruleRepo.GetByID(c.Request.Context(), id, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "sender rule not found"})
+			return
+		}
+		h.logger.Error("GetRule: failed to fetch sender rule", zap.Int("rule_id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sender rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// UpdateRule handles PUT /sender-rules/:id
+func (h *SenderRuleHandler) UpdateRule(c *gin.Context) {
+	var req senderRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule id"})
+		return
+	}
+
+	rule, err := buildSenderRule(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule.ID = id
+	rule.UserID = userID
+
+	updated, err := h.ruleRepo.Update(c.Request.Context(), rule)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "rule for this sender already exists"})
+			return
+		}
+		h.logger.Error("UpdateRule: failed to update sender rule", zap.Int("rule_id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update sender rule"})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sender rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// DeleteRule handles DELETE /sender-rules/:id
+func (h *SenderRuleHandler) DeleteRule(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule id"})
+		return
+	}
+
+	deleted, err := h.ruleRepo.Delete(c.Request.Context(), id, userID)
+	if err != nil {
+		h.logger.Error("DeleteRule: failed to delete sender rule", zap.Int("rule_id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete sender rule"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sender rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// buildSenderRule 校验并规范化请求（地址 / 域名统一小写）
+func buildSenderRule(req senderRuleRequest) (*dbcontracts.SenderRule, error) {
+	pattern := strings.ToLower(strings.TrimSpace(req.Pattern))
+	matchType := strings.ToLower(strings.TrimSpace(req.MatchType))
+
+	switch matchType {
+	case dbcontracts.SenderMatchAddress:
+		addr, err := mail.ParseAddress(pattern)
+		if err != nil {
+			return nil, errors.New("invalid sender address")
+		}
+		pattern = addr.Address
+	case dbcontracts.SenderMatchDomain:
+		pattern = strings.TrimPrefix(strings.TrimPrefix(pattern, "@"), "*.")
+		if pattern == "" || !strings.Contains(pattern, ".") || strings.ContainsAny(pattern, "@ ") {
+			return nil, errors.New("invalid sender domain")
+		}
+	default:
+		return nil, errors.New("match_type must be address or domain")
+	}
+
+	rule := &dbcontracts.SenderRule{
+		MatchType: matchType,
+		Pattern:   pattern,
+		Action:    strings.ToLower(strings.TrimSpace(req.Action)),
+	}
+
+	switch rule.Action {
+	case dbcontracts.SenderActionAllow, dbcontracts.SenderActionBlock, dbcontracts.SenderActionSkipAgent:
+	case dbcontracts.SenderActionForcePriority:
+		priority := strings.ToUpper(strings.TrimSpace(req.Priority))
+		if priority != "HIGH" && priority != "MEDIUM" && priority != "LOW" {
+			return nil, errors.New("priority must be HIGH, MEDIUM or LOW for force_priority")
+		}
+		rule.Priority = &priority
+	default:
+		return nil, errors.New("action must be allow, block, skip_agent or force_priority")
+	}
+	return rule, nil
+}
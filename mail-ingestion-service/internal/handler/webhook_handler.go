@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"mail-ingestion-service/internal/repository"
+	"mail-ingestion-service/internal/service/ingest"
+	"mail-ingestion-service/internal/webhook"
+	"mygoproject/pkg/trace"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxWebhookBytes webhook 请求体上限：邮件上限 + 表单 / base64 编码开销
+	maxWebhookBytes = 36 << 20
+)
+
+type WebhookHandler struct {
+	adapters      map[string]webhook.Adapter
+	ingestService *ingest.Service
+	userRepo      *repository.UserRepository
+	logger        *zap.Logger
+}
+
+func NewWebhookHandler(
+	adapters map[string]webhook.Adapter,
+	ingestService *ingest.Service,
+	userRepo *repository.UserRepository,
+	logger *zap.Logger,
+) *WebhookHandler {
+	return &WebhookHandler{
+		adapters:      adapters,
+		ingestService: ingestService,
+		userRepo:      userRepo,
+		logger:        logger,
+	}
+}
+
+// InboundWebhook handles POST /webhooks/inbound/:provider
+// 由邮件服务商直接调用（不经过用户认证），按信封收件人确定所属用户
+func (h *WebhookHandler) InboundWebhook(c *gin.Context) {
+	provider := c.Param("provider")
+	adapter, ok := h.adapters[provider]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown webhook provider"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBytes)
+	inbound, err := adapter.Parse(c.Request)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxErr):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "email too large"})
+		case errors.Is(err, webhook.ErrUnauthorized):
+			h.logger.Warn("Rejected inbound webhook with invalid signature",
+				zap.String("provider", provider),
+				zap.String("remote_addr", c.ClientIP()),
+			)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
+		default:
+			h.logger.Warn("Invalid inbound webhook payload", zap.String("provider", provider), zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload", "details": err.Error()})
+		}
+		return
+	}
+
+	ctx := c.Request.Context()
+	userIDs, err := h.resolveUsers(ctx, inbound.Recipients)
+	if err != nil {
+		h.logger.Error("Failed to resolve webhook recipients", zap.String("provider", provider), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve recipients"})
+		return
+	}
+	// 没有匹配的用户时返回 200，避免服务商反复重试
+	if len(userIDs) == 0 {
+		h.logger.Info("Ignored inbound webhook for unknown recipients",
+			zap.String("provider", provider),
+			zap.Strings("recipients", inbound.Recipients),
+		)
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	// 任一收件人失败时返回错误，服务商重试整个请求；已入库的收件人按幂等键去重，不会重复入库
+	idempotencyKey := webhook.IdempotencyKey(adapter.Name(), inbound)
+	emailIDs := make([]int, 0, len(userIDs))
+	for _, userID := range userIDs {
+		result, err := h.ingestService.CreateRawAndPublish(ctx, userID, inbound.Message, idempotencyKey)
+		if err != nil {
+			h.logger.Error("Failed to ingest webhook email",
+				zap.String("provider", provider),
+				zap.Int("user_id", userID),
+				zap.Ints("ingested_email_ids", emailIDs),
+				zap.String("trace_id", trace.FromContext(ctx)),
+				zap.Error(err),
+			)
+			// 超出限额时返回 429 + Retry-After，服务商会稍后重试
+			writeIngestError(c, err)
+			return
+		}
+		emailIDs = append(emailIDs, result.EmailID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"email_ids":  emailIDs,
+		"message_id": inbound.Message.MessageID,
+		"status":     "queued",
+	})
+}
+
+// resolveUsers 将收件地址映射为用户 ID，同一用户只返回一次
+func (h *WebhookHandler) resolveUsers(ctx context.Context, recipients []string) ([]int, error) {
+	var userIDs []int
+	seen := make(map[int]bool)
+	for _, rcpt := range recipients {
+		rcpt = strings.TrimSpace(rcpt)
+		if rcpt == "" {
+			continue
+		}
+		userID, err := h.userRepo.FindIDByEmail(ctx, rcpt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !seen[userID] {
+			seen[userID] = true
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs, nil
+}
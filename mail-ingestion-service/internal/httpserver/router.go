@@ -18,7 +18,7 @@ type Router struct {
 	Engine *gin.Engine
 }
 
-func NewRouter(ingestHandler *handler.IngestHandler, db *pgxpool.Pool, publisher *mq.Publisher) *Router {
+func NewRouter(ingestHandler *handler.IngestHandler, mailboxHandler *handler.MailboxHandler, importHandler *handler.ImportHandler, webhookHandler *handler.WebhookHandler, senderRuleHandler *handler.SenderRuleHandler, failedEventHandler *handler.FailedEventHandler, db *pgxpool.Pool, publisher *mq.Publisher) *Router {
 	r := gin.Default()
 
 	// OpenTelemetry 追踪中间件（必须在最前面）
@@ -80,6 +80,33 @@ func NewRouter(ingestHandler *handler.IngestHandler, db *pgxpool.Pool, publisher
 
 	// Email ingestion endpoint
 	r.POST("/email/simulate", ingestHandler.SimulateNewEmail)
+	r.POST("/email/raw", ingestHandler.IngestRawEmail)
+
+	// Bulk import endpoints
+	r.POST("/email/import", importHandler.CreateImport)
+	r.GET("/email/import/:id", importHandler.GetImport)
+
+	// Inbound provider webhooks（SendGrid / Mailgun / Postmark）
+	r.POST("/webhooks/inbound/:provider", webhookHandler.InboundWebhook)
+
+	// IMAP mailbox endpoints
+	r.POST("/mailboxes", mailboxHandler.CreateMailbox)
+	r.GET("/mailboxes", mailboxHandler.ListMailboxes)
+	r.GET("/mailboxes/:id", mailboxHandler.GetMailbox)
+	r.DELETE("/mailboxes/:id", mailboxHandler.DeleteMailbox)
+
+	// Sender rule endpoints（入库前过滤）
+	r.POST("/sender-rules", senderRuleHandler.CreateRule)
+	r.GET("/sender-rules", senderRuleHandler.ListRules)
+	r.GET("/sender-rules/:id", senderRuleHandler.GetRule)
+	r.PUT("/sender-rules/:id", senderRuleHandler.UpdateRule)
+	r.DELETE("/sender-rules/:id", senderRuleHandler.DeleteRule)
+
+	// Admin: failed event endpoints（查看 / 重试 / 放弃发布失败的事件）
+	r.GET("/admin/failed-events", failedEventHandler.ListFailedEvents)
+	r.GET("/admin/failed-events/:id", failedEventHandler.GetFailedEvent)
+	r.POST("/admin/failed-events/:id/retry", failedEventHandler.RetryFailedEvent)
+	r.POST("/admin/failed-events/:id/discard", failedEventHandler.DiscardFailedEvent)
 
 	return &Router{Engine: r}
 }
@@ -0,0 +1,145 @@
+package imappoller
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// FetchedMessage 从 IMAP 服务器拉取的一封原始邮件
+type FetchedMessage struct {
+	UID uint32
+	Raw []byte
+}
+
+// Client 已登录的 IMAP 连接
+type Client interface {
+	// Select 选中文件夹并返回其 UIDVALIDITY
+	Select(folder string) (uint32, error)
+	// FetchNew 按 UID 升序返回 UID 大于 lastUID 的邮件，最多 limit 封
+	FetchNew(lastUID uint32, limit int) ([]FetchedMessage, error)
+	Logout() error
+}
+
+// Dialer 建立 IMAP 连接并登录
+// 测试时可以指向进程内的 IMAP 替身（例如 go-imap 的 backend/memory + server，use_tls=false）
+type Dialer interface {
+	Dial(addr string, useTLS bool, username, password string) (Client, error)
+}
+
+// NetDialer 基于 go-imap 客户端的 Dialer 实现
+type NetDialer struct {
+	Timeout time.Duration
+}
+
+func NewNetDialer(timeout time.Duration) *NetDialer {
+	return &NetDialer{Timeout: timeout}
+}
+
+func (d *NetDialer) Dial(addr string, useTLS bool, username, password string) (Client, error) {
+	netDialer := &net.Dialer{Timeout: d.Timeout}
+
+	var (
+		c   *client.Client
+		err error
+	)
+	if useTLS {
+		host, _, _ := net.SplitHostPort(addr)
+		c, err = client.DialWithDialerTLS(netDialer, addr, &tls.Config{ServerName: host})
+	} else {
+		c, err = client.DialWithDialer(netDialer, addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	c.Timeout = d.Timeout
+
+	if err := c.Login(username, password); err != nil {
+		c.Logout()
+		return nil, fmt.Errorf("imap login failed: %w", err)
+	}
+	return &imapClient{c: c}, nil
+}
+
+type imapClient struct {
+	c *client.Client
+}
+
+func (ic *imapClient) Select(folder string) (uint32, error) {
+	status, err := ic.c.Select(folder, true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to select %s: %w", folder, err)
+	}
+	return status.UidValidity, nil
+}
+
+func (ic *imapClient) FetchNew(lastUID uint32, limit int) ([]FetchedMessage, error) {
+	criteria := imap.NewSearchCriteria()
+	criteria.Uid = new(imap.SeqSet)
+	criteria.Uid.AddRange(lastUID+1, 0) // 0 表示 "*"
+
+	uids, err := ic.c.UidSearch(criteria)
+	if err != nil {
+		return nil, fmt.Errorf("uid search failed: %w", err)
+	}
+
+	// "n:*" 在没有新邮件时仍会返回最后一封，需要再过滤一次
+	newUIDs := make([]uint32, 0, len(uids))
+	for _, uid := range uids {
+		if uid > lastUID {
+			newUIDs = append(newUIDs, uid)
+		}
+	}
+	if len(newUIDs) == 0 {
+		return nil, nil
+	}
+	sort.Slice(newUIDs, func(i, j int) bool { return newUIDs[i] < newUIDs[j] })
+	if limit > 0 && len(newUIDs) > limit {
+		newUIDs = newUIDs[:limit]
+	}
+
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(newUIDs...)
+	section := &imap.BodySectionName{Peek: true}
+	items := []imap.FetchItem{imap.FetchUid, section.FetchItem()}
+
+	ch := make(chan *imap.Message, len(newUIDs))
+	done := make(chan error, 1)
+	go func() {
+		done <- ic.c.UidFetch(seqset, items, ch)
+	}()
+
+	var messages []FetchedMessage
+	var readErr error
+	for msg := range ch {
+		body := msg.GetBody(section)
+		if body == nil {
+			continue
+		}
+		raw, err := io.ReadAll(body)
+		if err != nil && readErr == nil {
+			readErr = err
+			continue
+		}
+		messages = append(messages, FetchedMessage{UID: msg.Uid, Raw: raw})
+	}
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("uid fetch failed: %w", err)
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("failed to read message body: %w", readErr)
+	}
+
+	sort.Slice(messages, func(i, j int) bool { return messages[i].UID < messages[j].UID })
+	return messages, nil
+}
+
+func (ic *imapClient) Logout() error {
+	return ic.c.Logout()
+}
@@ -0,0 +1,188 @@
+package imappoller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"mail-ingestion-service/internal/service/ingest"
+	dbcontracts "mygoproject/contracts/db"
+	"mygoproject/pkg/trace"
+	"mygoproject/pkg/util"
+
+	"go.uber.org/zap"
+)
+
+// Poller 定时轮询用户配置的 IMAP 邮箱，将新邮件写入 emails_raw + outbox
+// 通过 mailboxes.uid_validity / last_uid 保证同一封邮件不会重复入库
+type Poller struct {
+	store     Store
+	dialer    Dialer
+	secretKey string
+	logger    *zap.Logger
+	interval  time.Duration
+	batchSize int
+}
+
+// NewPoller 创建新的 Poller
+func NewPoller(
+	store Store,
+	dialer Dialer,
+	secretKey string,
+	logger *zap.Logger,
+) *Poller {
+	return &Poller{
+		store:     store,
+		dialer:    dialer,
+		secretKey: secretKey,
+		logger:    logger,
+		interval:  60 * time.Second, // 默认每分钟轮询一次
+		batchSize: 50,               // 默认每个邮箱每轮最多拉取50封
+	}
+}
+
+// WithInterval 设置轮询间隔
+func (p *Poller) WithInterval(interval time.Duration) *Poller {
+	p.interval = interval
+	return p
+}
+
+// WithBatchSize 设置每个邮箱每轮拉取的最大邮件数
+func (p *Poller) WithBatchSize(batchSize int) *Poller {
+	p.batchSize = batchSize
+	return p
+}
+
+// Start 启动 Poller（在 goroutine 中运行）
+func (p *Poller) Start(ctx context.Context) {
+	p.logger.Info("Starting IMAP Poller",
+		zap.Duration("interval", p.interval),
+		zap.Int("batch_size", p.batchSize),
+	)
+
+	// 启动后立即同步一次，不等待第一个轮询间隔
+	p.SyncAll(ctx)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.logger.Info("IMAP Poller stopped")
+			return
+		case <-ticker.C:
+			p.SyncAll(ctx)
+		}
+	}
+}
+
+// SyncAll 同步所有启用的邮箱，并记录每个邮箱的同步状态
+func (p *Poller) SyncAll(ctx context.Context) {
+	mailboxes, err := p.store.ListActive(ctx)
+	if err != nil {
+		p.logger.Error("Failed to list active mailboxes", zap.Error(err))
+		return
+	}
+
+	for i := range mailboxes {
+		mb := &mailboxes[i]
+		errMsg := ""
+		ingested, err := p.SyncMailbox(ctx, mb)
+		if err != nil {
+			errMsg = err.Error()
+			p.logger.Warn("IMAP mailbox sync failed",
+				zap.Int("mailbox_id", mb.ID),
+				zap.Int("user_id", mb.UserID),
+				zap.String("host", mb.Host),
+				zap.Error(err),
+			)
+		} else if ingested > 0 {
+			p.logger.Info("IMAP mailbox synced",
+				zap.Int("mailbox_id", mb.ID),
+				zap.Int("user_id", mb.UserID),
+				zap.Int("ingested", ingested),
+			)
+		}
+
+		if err := p.store.MarkSynced(ctx, mb.ID, errMsg); err != nil {
+			p.logger.Error("Failed to update mailbox sync status",
+				zap.Int("mailbox_id", mb.ID),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// SyncMailbox 拉取单个邮箱的新邮件，返回本轮入库的邮件数
+func (p *Poller) SyncMailbox(ctx context.Context, mb *dbcontracts.Mailbox) (int, error) {
+	password, err := util.DecryptSecret(mb.PasswordEncrypted, p.secretKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decrypt credentials: %w", err)
+	}
+
+	addr := net.JoinHostPort(mb.Host, strconv.Itoa(mb.Port))
+	client, err := p.dialer.Dial(addr, mb.UseTLS, mb.Username, password)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Logout()
+
+	uidValidity, err := client.Select(mb.Folder)
+	if err != nil {
+		return 0, err
+	}
+
+	// UIDVALIDITY 变化说明服务器上的 UID 已失效，需要从头开始
+	lastUID := mb.LastUID
+	if mb.UIDValidity == nil || *mb.UIDValidity != int64(uidValidity) {
+		if mb.UIDValidity != nil {
+			p.logger.Warn("IMAP UIDVALIDITY changed, resetting cursor",
+				zap.Int("mailbox_id", mb.ID),
+				zap.Int64("old_uid_validity", *mb.UIDValidity),
+				zap.Uint32("new_uid_validity", uidValidity),
+			)
+		}
+		if err := p.store.ResetCursor(ctx, mb.ID, int64(uidValidity)); err != nil {
+			return 0, fmt.Errorf("failed to reset cursor: %w", err)
+		}
+		lastUID = 0
+	}
+
+	messages, err := client.FetchNew(uint32(lastUID), p.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	ingested := 0
+	for _, fm := range messages {
+		created, err := p.ingestMessage(ctx, mb, int64(uidValidity), fm)
+		if err != nil {
+			return ingested, fmt.Errorf("failed to ingest uid %d: %w", fm.UID, err)
+		}
+		if created {
+			ingested++
+		}
+	}
+	return ingested, nil
+}
+
+// ingestMessage 写入邮件并推进 last_uid
+// 无法解析的邮件只推进游标，避免每轮都卡在同一封邮件上
+func (p *Poller) ingestMessage(ctx context.Context, mb *dbcontracts.Mailbox, uidValidity int64, fm FetchedMessage) (bool, error) {
+	msg, parseErr := ingest.ParseMessage(bytes.NewReader(fm.Raw))
+	if parseErr != nil {
+		p.logger.Warn("Skipping unparsable IMAP message",
+			zap.Int("mailbox_id", mb.ID),
+			zap.Uint32("uid", fm.UID),
+			zap.Error(parseErr),
+		)
+		msg = nil
+	}
+
+	ctx = trace.WithContext(ctx, trace.GenerateTraceID())
+	return p.store.IngestMessage(ctx, mb, uidValidity, fm.UID, msg)
+}
@@ -0,0 +1,275 @@
+package imappoller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"mail-ingestion-service/internal/service/ingest"
+	dbcontracts "mygoproject/contracts/db"
+	"mygoproject/pkg/util"
+
+	"github.com/emersion/go-imap/backend/memory"
+	"github.com/emersion/go-imap/server"
+	"go.uber.org/zap"
+)
+
+const testSecretKey = "test-imap-secret"
+
+// memoryStore 内存中的 Store 实现，按 Message-ID 去重
+type memoryStore struct {
+	mu        sync.Mutex
+	mailboxes map[int]*dbcontracts.Mailbox
+	seen      map[string]bool
+	ingested  []string // 新入库邮件的 Message-ID，按入库顺序
+	resets    int
+	syncErrs  []string
+}
+
+func newMemoryStore(mailboxes ...dbcontracts.Mailbox) *memoryStore {
+	s := &memoryStore{mailboxes: make(map[int]*dbcontracts.Mailbox), seen: make(map[string]bool)}
+	for i := range mailboxes {
+		mb := mailboxes[i]
+		s.mailboxes[mb.ID] = &mb
+	}
+	return s
+}
+
+func (s *memoryStore) ListActive(ctx context.Context) ([]dbcontracts.Mailbox, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []dbcontracts.Mailbox
+	for _, mb := range s.mailboxes {
+		result = append(result, *mb)
+	}
+	return result, nil
+}
+
+func (s *memoryStore) MarkSynced(ctx context.Context, mailboxID int, errMsg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if errMsg != "" {
+		s.syncErrs = append(s.syncErrs, errMsg)
+	}
+	return nil
+}
+
+func (s *memoryStore) ResetCursor(ctx context.Context, mailboxID int, uidValidity int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mb := s.mailboxes[mailboxID]
+	mb.UIDValidity = &uidValidity
+	mb.LastUID = 0
+	s.resets++
+	return nil
+}
+
+func (s *memoryStore) IngestMessage(ctx context.Context, mb *dbcontracts.Mailbox, uidValidity int64, uid uint32, msg *ingest.Message) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	created := false
+	if msg != nil && !s.seen[msg.MessageID] {
+		s.seen[msg.MessageID] = true
+		s.ingested = append(s.ingested, msg.MessageID)
+		created = true
+	}
+	stored := s.mailboxes[mb.ID]
+	stored.UIDValidity = &uidValidity
+	stored.LastUID = int64(uid)
+	return created, nil
+}
+
+func (s *memoryStore) mailbox(id int) dbcontracts.Mailbox {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.mailboxes[id]
+}
+
+func (s *memoryStore) ingestedIDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ingested...)
+}
+
+// startIMAPServer 启动进程内的 IMAP 服务（go-imap memory backend，INBOX 中预置一封 UID 为 6 的邮件）
+func startIMAPServer(t *testing.T) (*memory.Backend, string, int) {
+	t.Helper()
+	be := memory.New()
+	srv := server.New(be)
+	srv.AllowInsecureAuth = true
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	host, portStr, _ := net.SplitHostPort(ln.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	return be, host, port
+}
+
+func appendMessage(t *testing.T, be *memory.Backend, messageID string) {
+	t.Helper()
+	user, err := be.Login(nil, "username", "password")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	mbox, err := user.GetMailbox("INBOX")
+	if err != nil {
+		t.Fatalf("get mailbox: %v", err)
+	}
+	body := fmt.Sprintf("From: sender@example.org\r\n"+
+		"To: user@example.org\r\n"+
+		"Subject: Message %s\r\n"+
+		"Date: Wed, 11 May 2016 14:31:59 +0000\r\n"+
+		"Message-ID: %s\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"\r\n"+
+		"Hello", messageID, messageID)
+	if err := mbox.CreateMessage(nil, time.Now(), bytes.NewBufferString(body)); err != nil {
+		t.Fatalf("create message: %v", err)
+	}
+}
+
+func testMailbox(t *testing.T, host string, port int) dbcontracts.Mailbox {
+	t.Helper()
+	encrypted, err := util.EncryptSecret("password", testSecretKey)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	return dbcontracts.Mailbox{
+		ID:                1,
+		UserID:            42,
+		Host:              host,
+		Port:              port,
+		Username:          "username",
+		PasswordEncrypted: encrypted,
+		Folder:            "INBOX",
+		IsActive:          true,
+	}
+}
+
+func newTestPoller(store Store) *Poller {
+	return NewPoller(store, NewNetDialer(5*time.Second), testSecretKey, zap.NewNop())
+}
+
+func TestSyncAll_FirstSync(t *testing.T) {
+	_, host, port := startIMAPServer(t)
+	store := newMemoryStore(testMailbox(t, host, port))
+
+	newTestPoller(store).SyncAll(context.Background())
+
+	if got := store.ingestedIDs(); len(got) != 1 {
+		t.Fatalf("ingested %v, want the single preloaded message", got)
+	}
+	mb := store.mailbox(1)
+	if mb.UIDValidity == nil || *mb.UIDValidity != 1 {
+		t.Errorf("uid_validity = %v, want 1", mb.UIDValidity)
+	}
+	if mb.LastUID != 6 {
+		t.Errorf("last_uid = %d, want 6", mb.LastUID)
+	}
+	if len(store.syncErrs) != 0 {
+		t.Errorf("unexpected sync errors: %v", store.syncErrs)
+	}
+}
+
+func TestSyncAll_IncrementalUIDSync(t *testing.T) {
+	be, host, port := startIMAPServer(t)
+	store := newMemoryStore(testMailbox(t, host, port))
+	poller := newTestPoller(store).WithBatchSize(2)
+
+	poller.SyncAll(context.Background())
+	appendMessage(t, be, "<m7@example.org>")
+	appendMessage(t, be, "<m8@example.org>")
+	appendMessage(t, be, "<m9@example.org>")
+
+	// 每轮最多 2 封
+	poller.SyncAll(context.Background())
+	if got := store.ingestedIDs(); len(got) != 3 {
+		t.Fatalf("after second sync ingested %v, want 3 messages", got)
+	}
+	if mb := store.mailbox(1); mb.LastUID != 8 {
+		t.Errorf("last_uid = %d, want 8", mb.LastUID)
+	}
+
+	poller.SyncAll(context.Background())
+	got := store.ingestedIDs()
+	want := []string{"m7@example.org", "m8@example.org", "m9@example.org"}
+	if len(got) != 4 {
+		t.Fatalf("after third sync ingested %v, want 4 messages", got)
+	}
+	for i, id := range want {
+		if got[i+1] != id {
+			t.Errorf("ingested[%d] = %s, want %s", i+1, got[i+1], id)
+		}
+	}
+
+	// 没有新邮件时不再入库
+	poller.SyncAll(context.Background())
+	if got := store.ingestedIDs(); len(got) != 4 {
+		t.Errorf("idle sync ingested %v", got)
+	}
+	if mb := store.mailbox(1); mb.LastUID != 9 {
+		t.Errorf("last_uid = %d, want 9", mb.LastUID)
+	}
+}
+
+func TestSyncAll_UIDValidityReset(t *testing.T) {
+	_, host, port := startIMAPServer(t)
+	mb := testMailbox(t, host, port)
+	staleValidity := int64(99)
+	mb.UIDValidity = &staleValidity
+	mb.LastUID = 100 // 旧 UIDVALIDITY 下的游标，大于服务器上所有 UID
+	store := newMemoryStore(mb)
+	// 预置邮件在 UIDVALIDITY 变化前已经入库过
+	store.seen["0000000@localhost/"] = true
+
+	newTestPoller(store).SyncAll(context.Background())
+
+	if store.resets != 1 {
+		t.Fatalf("resets = %d, want 1", store.resets)
+	}
+	got := store.mailbox(1)
+	if got.UIDValidity == nil || *got.UIDValidity != 1 {
+		t.Errorf("uid_validity = %v, want 1", got.UIDValidity)
+	}
+	if got.LastUID != 6 {
+		t.Errorf("last_uid = %d, want 6 (re-fetched from the start)", got.LastUID)
+	}
+	if ids := store.ingestedIDs(); len(ids) != 0 {
+		t.Errorf("re-fetched message should be deduplicated, ingested %v", ids)
+	}
+}
+
+func TestStart_SyncsImmediately(t *testing.T) {
+	_, host, port := startIMAPServer(t)
+	store := newMemoryStore(testMailbox(t, host, port))
+	poller := newTestPoller(store).WithInterval(time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		poller.Start(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(store.ingestedIDs()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Start did not sync before the first interval elapsed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,79 @@
+package imappoller
+
+import (
+	"context"
+	"fmt"
+
+	"mail-ingestion-service/internal/repository"
+	"mail-ingestion-service/internal/service/ingest"
+	dbcontracts "mygoproject/contracts/db"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Store 邮箱游标和邮件入库
+type Store interface {
+	// ListActive 返回所有启用的邮箱
+	ListActive(ctx context.Context) ([]dbcontracts.Mailbox, error)
+	// MarkSynced 记录同步时间和错误（errMsg 为空表示成功）
+	MarkSynced(ctx context.Context, mailboxID int, errMsg string) error
+	// ResetCursor UIDVALIDITY 变化时重置游标
+	ResetCursor(ctx context.Context, mailboxID int, uidValidity int64) error
+	// IngestMessage 原子地写入邮件（msg 为 nil 时只推进游标）并推进 last_uid，返回是否新入库（非重复）
+	IngestMessage(ctx context.Context, mb *dbcontracts.Mailbox, uidValidity int64, uid uint32, msg *ingest.Message) (bool, error)
+}
+
+// PostgresStore 邮件、outbox 事件和 mailboxes.last_uid 在同一个事务中写入
+type PostgresStore struct {
+	db            *pgxpool.Pool
+	mailboxRepo   *repository.MailboxRepository
+	ingestService *ingest.Service
+}
+
+func NewPostgresStore(db *pgxpool.Pool, mailboxRepo *repository.MailboxRepository, ingestService *ingest.Service) *PostgresStore {
+	return &PostgresStore{
+		db:            db,
+		mailboxRepo:   mailboxRepo,
+		ingestService: ingestService,
+	}
+}
+
+func (s *PostgresStore) ListActive(ctx context.Context) ([]dbcontracts.Mailbox, error) {
+	return s.mailboxRepo.ListActive(ctx)
+}
+
+func (s *PostgresStore) MarkSynced(ctx context.Context, mailboxID int, errMsg string) error {
+	return s.mailboxRepo.MarkSynced(ctx, mailboxID, errMsg)
+}
+
+func (s *PostgresStore) ResetCursor(ctx context.Context, mailboxID int, uidValidity int64) error {
+	return s.mailboxRepo.ResetCursor(ctx, mailboxID, uidValidity)
+}
+
+func (s *PostgresStore) IngestMessage(ctx context.Context, mb *dbcontracts.Mailbox, uidValidity int64, uid uint32, msg *ingest.Message) (bool, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var result *ingest.Result
+	if msg != nil {
+		result, err = s.ingestService.CreateRawAndPublishTx(ctx, tx, mb.UserID, msg, "")
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if err := s.mailboxRepo.UpdateCursorTx(ctx, tx, mb.ID, uidValidity, int64(uid)); err != nil {
+		s.ingestService.RefundQuota(ctx, mb.UserID, result)
+		return false, fmt.Errorf("failed to update cursor: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		s.ingestService.RefundQuota(ctx, mb.UserID, result)
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	// UIDVALIDITY 重置后重新拉取的邮件会按 Message-ID 去重
+	return result != nil && !result.Duplicate, nil
+}
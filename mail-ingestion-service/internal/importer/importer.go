@@ -0,0 +1,314 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"mail-ingestion-service/internal/quota"
+	"mail-ingestion-service/internal/repository"
+	"mail-ingestion-service/internal/service/ingest"
+	"mygoproject/pkg/outbox"
+	"mygoproject/pkg/trace"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// agentQueueName / agentRoutingKey 下游 AI 决策队列，导入时根据其积压程度限速
+	agentQueueName  = "email.received.agent.q"
+	agentRoutingKey = "email.received.agent"
+	// progressEvery 每处理多少封邮件写一次进度
+	progressEvery = 20
+	// maxRateLimitAttempts / maxRateLimitWait 单封邮件因分钟级限流重试的次数和总等待时间上限
+	maxRateLimitAttempts = 10
+	maxRateLimitWait     = 10 * time.Minute
+)
+
+// errRateLimited 分钟级限流在重试上限内一直没有恢复
+var errRateLimited = errors.New("per-minute ingestion quota still exceeded")
+
+// errShutdown 服务退出时中断的任务记录此原因
+var errShutdown = errors.New("import interrupted by service shutdown")
+
+// QueueInspector 查询队列积压深度（由 mq.Publisher 实现）
+type QueueInspector interface {
+	QueueDepth(queueName string) (int, error)
+}
+
+// Importer 在后台执行 mbox / zip 导入任务
+// 积压 = outbox 中未发布的 email.received.agent 事件 + email.received.agent.q 中未消费的消息，
+// 超过阈值时暂停导入，避免一次导入把下游队列打满
+type Importer struct {
+	ingestService *ingest.Service
+	jobRepo       *repository.ImportJobRepository
+	outboxRepo    *outbox.Repository
+	queue         QueueInspector
+	logger        *zap.Logger
+
+	maxQueueDepth int
+	checkEvery    int
+	waitInterval  time.Duration
+	slots         chan struct{}
+
+	// ctx 在 Stop 时取消，中断所有运行中和排队中的任务
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewImporter 创建新的 Importer
+func NewImporter(
+	ingestService *ingest.Service,
+	jobRepo *repository.ImportJobRepository,
+	outboxRepo *outbox.Repository,
+	queue QueueInspector,
+	logger *zap.Logger,
+) *Importer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Importer{
+		ingestService: ingestService,
+		jobRepo:       jobRepo,
+		outboxRepo:    outboxRepo,
+		queue:         queue,
+		logger:        logger,
+		maxQueueDepth: 500,             // 默认积压超过500条时暂停导入
+		checkEvery:    10,              // 默认每10封检查一次队列深度
+		waitInterval:  2 * time.Second, // 默认暂停后每2秒重新检查
+		slots:         make(chan struct{}, 2),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+// WithMaxQueueDepth 设置触发背压的队列深度
+func (im *Importer) WithMaxQueueDepth(depth int) *Importer {
+	im.maxQueueDepth = depth
+	return im
+}
+
+// WithMaxConcurrentJobs 设置同时运行的导入任务数
+func (im *Importer) WithMaxConcurrentJobs(n int) *Importer {
+	im.slots = make(chan struct{}, n)
+	return im
+}
+
+// Submit 异步执行导入任务，完成后删除上传的临时文件
+func (im *Importer) Submit(jobID, userID int, filePath, format string) {
+	im.wg.Add(1)
+	go func() {
+		defer im.wg.Done()
+		defer os.Remove(filePath)
+
+		ctx := trace.WithContext(im.ctx, trace.GenerateTraceID())
+		err := im.acquireAndRun(ctx, jobID, userID, filePath, format)
+		if err != nil && ctx.Err() != nil && !errors.Is(err, errShutdown) {
+			err = fmt.Errorf("%w: %w", errShutdown, err)
+		}
+
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+			im.logger.Error("Import job failed",
+				zap.Int("job_id", jobID),
+				zap.Int("user_id", userID),
+				zap.Error(err),
+			)
+		}
+		// 任务被 Stop 中断时 ctx 已取消，结束状态仍需写入
+		if err := im.jobRepo.Finish(context.WithoutCancel(ctx), jobID, errMsg); err != nil {
+			im.logger.Error("Failed to finish import job", zap.Int("job_id", jobID), zap.Error(err))
+		}
+	}()
+}
+
+// Stop 取消所有运行中和排队中的任务，并等待它们记录中断原因
+func (im *Importer) Stop(ctx context.Context) error {
+	im.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		im.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// acquireAndRun 限制并发任务数，排队期间任务保持 pending
+func (im *Importer) acquireAndRun(ctx context.Context, jobID, userID int, filePath, format string) error {
+	select {
+	case im.slots <- struct{}{}:
+	case <-ctx.Done():
+		return errShutdown
+	}
+	defer func() { <-im.slots }()
+
+	return im.run(ctx, jobID, userID, filePath, format)
+}
+
+func (im *Importer) run(ctx context.Context, jobID, userID int, filePath, format string) error {
+	total, err := Count(filePath, format)
+	if err != nil {
+		return err
+	}
+	if err := im.jobRepo.MarkRunning(ctx, jobID, total); err != nil {
+		return fmt.Errorf("failed to mark job running: %w", err)
+	}
+
+	src, err := Open(filePath, format)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	im.logger.Info("Import job started",
+		zap.Int("job_id", jobID),
+		zap.Int("user_id", userID),
+		zap.String("format", format),
+		zap.Int("total", total),
+	)
+
+	var ingested, duplicates, failed, processed int
+	for {
+		if ctx.Err() != nil {
+			_ = im.jobRepo.UpdateProgress(context.WithoutCancel(ctx), jobID, ingested, duplicates, failed)
+			return fmt.Errorf("%w after %d messages", errShutdown, processed)
+		}
+
+		raw, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !errors.Is(err, errMessageTooLarge) {
+			// 归档本身损坏，无法继续读取
+			_ = im.jobRepo.UpdateProgress(ctx, jobID, ingested, duplicates, failed)
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		if processed%im.checkEvery == 0 {
+			im.waitForCapacity(ctx, jobID)
+		}
+		processed++
+
+		if err != nil {
+			failed++
+		} else {
+			result, err := im.ingestOne(ctx, userID, raw)
+			if err != nil && ctx.Err() != nil {
+				// 被 Stop 中断的邮件不计为失败，由循环开头记录中断原因
+				processed--
+				continue
+			}
+			if exceeded, ok := quota.AsExceeded(err); ok && exceeded.Reason == quota.ReasonPerDay {
+				// 当日配额用完，继续导入只会全部失败
+				_ = im.jobRepo.UpdateProgress(ctx, jobID, ingested, duplicates, failed)
+				return fmt.Errorf("daily ingestion quota exhausted after %d messages", processed-1)
+			}
+			if errors.Is(err, errRateLimited) {
+				// 令牌长时间不补充（配额被改小或 Redis 异常），中止任务并记录原因，而不是逐封等待
+				_ = im.jobRepo.UpdateProgress(ctx, jobID, ingested, duplicates, failed)
+				return fmt.Errorf("import aborted after %d messages: %w", processed-1, err)
+			}
+			switch {
+			case err != nil:
+				failed++
+				im.logger.Warn("Failed to import message",
+					zap.Int("job_id", jobID),
+					zap.Int("index", processed),
+					zap.Error(err),
+				)
+			case result.Duplicate:
+				duplicates++
+			default:
+				ingested++
+			}
+		}
+
+		if processed%progressEvery == 0 {
+			if err := im.jobRepo.UpdateProgress(ctx, jobID, ingested, duplicates, failed); err != nil {
+				im.logger.Warn("Failed to update import progress", zap.Int("job_id", jobID), zap.Error(err))
+			}
+		}
+	}
+
+	if err := im.jobRepo.UpdateProgress(ctx, jobID, ingested, duplicates, failed); err != nil {
+		return fmt.Errorf("failed to update progress: %w", err)
+	}
+
+	im.logger.Info("Import job completed",
+		zap.Int("job_id", jobID),
+		zap.Int("ingested", ingested),
+		zap.Int("duplicates", duplicates),
+		zap.Int("failed", failed),
+	)
+	return nil
+}
+
+func (im *Importer) ingestOne(ctx context.Context, userID int, raw []byte) (*ingest.Result, error) {
+	msg, err := ingest.ParseMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	// 分钟级限流：等待令牌补充后重试同一封邮件，次数和总等待时间都有上限
+	waitCtx, cancel := context.WithTimeout(ctx, maxRateLimitWait)
+	defer cancel()
+	for attempt := 1; ; attempt++ {
+		result, err := im.ingestService.CreateRawAndPublish(ctx, userID, msg, "")
+		exceeded, ok := quota.AsExceeded(err)
+		if !ok || exceeded.Reason != quota.ReasonPerMinute {
+			return result, err
+		}
+		if attempt >= maxRateLimitAttempts {
+			return nil, fmt.Errorf("%w after %d attempts: %w", errRateLimited, attempt, err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-waitCtx.Done():
+			return nil, fmt.Errorf("%w after waiting %s: %w", errRateLimited, maxRateLimitWait, err)
+		case <-time.After(exceeded.RetryAfter):
+		}
+	}
+}
+
+// waitForCapacity 积压超过阈值时阻塞，直到下游消费跟上
+// 无法获取积压深度时（例如 MQ 暂时断开）不阻塞，outbox 会在 MQ 恢复后补发
+func (im *Importer) waitForCapacity(ctx context.Context, jobID int) {
+	for {
+		pending, err := im.outboxRepo.CountPending(ctx, agentRoutingKey)
+		if err != nil {
+			im.logger.Warn("Failed to count pending outbox events", zap.Error(err))
+			return
+		}
+		depth, err := im.queue.QueueDepth(agentQueueName)
+		if err != nil {
+			im.logger.Warn("Failed to inspect queue depth", zap.Error(err))
+			return
+		}
+		if pending+depth < im.maxQueueDepth {
+			return
+		}
+
+		im.logger.Debug("Import throttled by downstream backlog",
+			zap.Int("job_id", jobID),
+			zap.Int("outbox_pending", pending),
+			zap.Int("queue_depth", depth),
+		)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(im.waitInterval):
+		}
+	}
+}
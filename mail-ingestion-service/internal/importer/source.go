@@ -0,0 +1,276 @@
+package importer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	FormatMbox = "mbox"
+	FormatZip  = "zip"
+
+	// maxMessageBytes 单封邮件大小上限（与 /email/raw 一致）
+	maxMessageBytes = 25 << 20
+)
+
+// ErrUnsupportedFormat 表示上传文件既不是 mbox 也不是 zip
+var ErrUnsupportedFormat = errors.New("unsupported archive format")
+
+// errMessageTooLarge 单封邮件超过上限，计为失败并继续
+var errMessageTooLarge = errors.New("message too large")
+
+// Source 逐封读取归档中的原始邮件，结束时返回 io.EOF
+type Source interface {
+	Next() ([]byte, error)
+	Close() error
+}
+
+// DetectFormat 根据文件头（优先）和扩展名判断归档格式
+func DetectFormat(filename string, head []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return FormatZip, nil
+	case bytes.HasPrefix(head, []byte("From ")):
+		return FormatMbox, nil
+	}
+
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".mbox", ".mbx":
+		return FormatMbox, nil
+	case ".zip":
+		return FormatZip, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// Open 打开归档文件
+func Open(filePath, format string) (Source, error) {
+	switch format {
+	case FormatMbox:
+		f, err := os.Open(filePath)
+		if err != nil {
+			return nil, err
+		}
+		return newMboxSource(f), nil
+	case FormatZip:
+		zr, err := zip.OpenReader(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid zip archive: %w", err)
+		}
+		return newZipSource(zr), nil
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// Count 统计归档中的邮件数（用于进度展示）
+func Count(filePath, format string) (int, error) {
+	switch format {
+	case FormatZip:
+		zr, err := zip.OpenReader(filePath)
+		if err != nil {
+			return 0, fmt.Errorf("invalid zip archive: %w", err)
+		}
+		defer zr.Close()
+		n := 0
+		for _, f := range zr.File {
+			if isEMLFile(f) {
+				n++
+			}
+		}
+		return n, nil
+	case FormatMbox:
+		f, err := os.Open(filePath)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		n := 0
+		br := bufio.NewReader(f)
+		atLineStart := true
+		firstLine := true
+		prevBlank := false
+		for {
+			line, err := br.ReadSlice('\n')
+			// ReadSlice 在行过长时返回 ErrBufferFull，此时下一段不是行首
+			complete := err != bufio.ErrBufferFull
+			if atLineStart && len(line) > 0 {
+				// 与 mboxSource 相同的规则：文件开头（即使不是 "From " 行）算作第一封邮件，
+				// 之后只有前一行为空行的 "From " 行才是分隔行
+				if firstLine || (prevBlank && isMboxSeparator(line)) {
+					n++
+				}
+				firstLine = false
+			}
+			prevBlank = atLineStart && complete && isBlankLine(line)
+			atLineStart = complete
+			if err == io.EOF {
+				return n, nil
+			}
+			if err != nil && err != bufio.ErrBufferFull {
+				return 0, err
+			}
+		}
+	default:
+		return 0, ErrUnsupportedFormat
+	}
+}
+
+// mboxSource 解析 mbox（mboxo / mboxrd）：以文件开头或空行之后的 "From " 行分隔，去掉 ">From " 转义。
+// 正文中未转义、前面没有空行的 "From " 行保留在邮件中
+type mboxSource struct {
+	f         *os.File
+	br        *bufio.Reader
+	started   bool
+	done      bool
+	prevBlank bool // 上一行是空行
+	midLine   bool // 上一段是被 ErrBufferFull 截断的长行，下一段不在行首
+	skipLine  bool // 正在跳过过长分隔行的剩余部分
+}
+
+func newMboxSource(f *os.File) *mboxSource {
+	return &mboxSource{f: f, br: bufio.NewReaderSize(f, 64<<10)}
+}
+
+func (s *mboxSource) Next() ([]byte, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+
+	var buf bytes.Buffer
+	size := 0
+	tooLarge := false
+	for {
+		// 与 Count 相同按缓冲区分段读取，超长的行不会被整行读入内存
+		line, err := s.br.ReadSlice('\n')
+		complete := err != bufio.ErrBufferFull
+		atLineStart := !s.midLine
+		s.midLine = !complete
+		if len(line) > 0 {
+			switch {
+			case s.skipLine:
+				s.skipLine = !complete
+			case atLineStart && isMboxSeparator(line) && (!s.started || s.prevBlank):
+				s.prevBlank = false
+				s.skipLine = !complete
+				// 分隔行已被消费，下一次 Next 直接从下一封邮件的邮件头开始读
+				if s.started && (tooLarge || buf.Len() > 0) {
+					if tooLarge {
+						return nil, errMessageTooLarge
+					}
+					return trimTrailingBlankLine(buf.Bytes()), nil
+				}
+				s.started = true
+			default:
+				// 文件开头不是 "From " 行时按单封邮件处理
+				s.started = true
+				s.prevBlank = atLineStart && complete && isBlankLine(line)
+				// mboxrd: ">From " / ">>From " → 去掉一个 '>'
+				if atLineStart && line[0] == '>' && bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) {
+					line = line[1:]
+				}
+				size += len(line)
+				if size > maxMessageBytes {
+					// 超限后只继续读到下一个分隔行，不再保留内容
+					tooLarge = true
+					buf.Reset()
+				} else {
+					buf.Write(line)
+				}
+			}
+		}
+		if err == io.EOF {
+			s.done = true
+			if tooLarge {
+				return nil, errMessageTooLarge
+			}
+			if buf.Len() == 0 {
+				return nil, io.EOF
+			}
+			return trimTrailingBlankLine(buf.Bytes()), nil
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return nil, err
+		}
+	}
+}
+
+func (s *mboxSource) Close() error {
+	return s.f.Close()
+}
+
+func isMboxSeparator(line []byte) bool {
+	return bytes.HasPrefix(line, []byte("From "))
+}
+
+func isBlankLine(line []byte) bool {
+	return len(bytes.TrimRight(line, "\r\n")) == 0
+}
+
+// trimTrailingBlankLine 去掉 mbox 在每封邮件后追加的空行
+func trimTrailingBlankLine(b []byte) []byte {
+	b = bytes.TrimSuffix(b, []byte("\r\n"))
+	return bytes.TrimSuffix(b, []byte("\n"))
+}
+
+// zipSource 读取 zip 中所有 .eml 文件
+type zipSource struct {
+	zr  *zip.ReadCloser
+	idx int
+}
+
+func newZipSource(zr *zip.ReadCloser) *zipSource {
+	return &zipSource{zr: zr}
+}
+
+func (s *zipSource) Next() ([]byte, error) {
+	for s.idx < len(s.zr.File) {
+		f := s.zr.File[s.idx]
+		s.idx++
+		if !isEMLFile(f) {
+			continue
+		}
+		if f.UncompressedSize64 > maxMessageBytes {
+			return nil, errMessageTooLarge
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		// 不信任 zip 头中的大小声明，读取时再限制一次
+		data, err := io.ReadAll(io.LimitReader(rc, maxMessageBytes+1))
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxMessageBytes {
+			return nil, errMessageTooLarge
+		}
+		return data, nil
+	}
+	return nil, io.EOF
+}
+
+func (s *zipSource) Close() error {
+	return s.zr.Close()
+}
+
+func isEMLFile(f *zip.File) bool {
+	if f.FileInfo().IsDir() {
+		return false
+	}
+	name := path.Base(f.Name)
+	// 跳过 macOS 打包产生的元数据文件
+	if strings.HasPrefix(name, "._") || strings.HasPrefix(f.Name, "__MACOSX/") {
+		return false
+	}
+	return strings.EqualFold(path.Ext(name), ".eml")
+}
@@ -0,0 +1,112 @@
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeMbox(t *testing.T, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "test.mbox")
+	if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// readAll 返回每封邮件的内容，超限的邮件记为 "<too large>"
+func readAll(t *testing.T, p string) []string {
+	t.Helper()
+	src, err := Open(p, FormatMbox)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	var got []string
+	for {
+		raw, err := src.Next()
+		if err == io.EOF {
+			return got
+		}
+		if errors.Is(err, errMessageTooLarge) {
+			got = append(got, "<too large>")
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(raw))
+	}
+}
+
+func TestMboxSource_Next(t *testing.T) {
+	longLine := strings.Repeat("x", 200<<10)
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			"separators and mboxrd escape",
+			"From a@example.com Mon Jan 1 00:00:00 2024\nSubject: one\n\n>From here\n\nFrom b@example.com Mon Jan 1 00:00:00 2024\nSubject: two\n\nbody\n",
+			[]string{"Subject: one\n\nFrom here\n", "Subject: two\n\nbody"},
+		},
+		{
+			"from line without blank line stays in body",
+			"From a@example.com\nSubject: one\n\nline\nFrom the team\n",
+			[]string{"Subject: one\n\nline\nFrom the team"},
+		},
+		{
+			"long body line",
+			"From a@example.com\nSubject: one\n\n" + longLine + "\n\nFrom b@example.com\nSubject: two\n",
+			[]string{"Subject: one\n\n" + longLine + "\n", "Subject: two"},
+		},
+		{
+			"long line starting with from is not a separator",
+			"From a@example.com\nSubject: one\n\n" + longLine + "From x\n\nFrom b@example.com\nSubject: two\n",
+			[]string{"Subject: one\n\n" + longLine + "From x\n", "Subject: two"},
+		},
+		{
+			"long separator line",
+			"From " + longLine + "\nSubject: one\n\nFrom " + longLine + "\nSubject: two\n",
+			[]string{"Subject: one\n", "Subject: two"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := writeMbox(t, tt.content)
+			got := readAll(t, p)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d messages, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("message %d = %.80q, want %.80q", i, got[i], tt.want[i])
+				}
+			}
+			if n, err := Count(p, FormatMbox); err != nil || n != len(tt.want) {
+				t.Errorf("Count = %d, %v; want %d", n, err, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestMboxSource_TooLarge(t *testing.T) {
+	// 单行超过上限：不能整行读入，且之后的邮件照常读取
+	var b bytes.Buffer
+	b.WriteString("From a@example.com\nSubject: big\n\n")
+	b.Write(bytes.Repeat([]byte("y"), maxMessageBytes+1))
+	b.WriteString("\n\nFrom b@example.com\nSubject: small\n")
+
+	got := readAll(t, writeMbox(t, b.String()))
+	want := []string{"<too large>", "Subject: small"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got %.80q, want %q", got, want)
+	}
+}
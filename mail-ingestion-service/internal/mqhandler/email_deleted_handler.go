@@ -0,0 +1,93 @@
+package mqhandler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"mail-ingestion-service/internal/blobstore"
+	"mail-ingestion-service/internal/repository"
+	mqcontracts "mygoproject/contracts/mq"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// EmailDeletedHandler 处理 email.deleted：回收不再被任何附件引用的 blob
+// blob 按内容寻址，可能被其他邮件共享，只删除没有引用的对象（操作幂等，无需去重）
+type EmailDeletedHandler struct {
+	db             *pgxpool.Pool
+	attachmentRepo *repository.AttachmentRepository
+	blobStore      blobstore.Store
+	logger         *zap.Logger
+}
+
+func NewEmailDeletedHandler(
+	db *pgxpool.Pool,
+	attachmentRepo *repository.AttachmentRepository,
+	blobStore blobstore.Store,
+	logger *zap.Logger,
+) *EmailDeletedHandler {
+	return &EmailDeletedHandler{
+		db:             db,
+		attachmentRepo: attachmentRepo,
+		blobStore:      blobStore,
+		logger:         logger,
+	}
+}
+
+func (h *EmailDeletedHandler) Handle(ctx context.Context, raw json.RawMessage) error {
+	var payload mqcontracts.EmailDeletedPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		h.logger.Error("Failed to unmarshal EmailDeletedPayload", zap.Error(err))
+		return nil // 格式错误的消息重试也无法处理
+	}
+
+	deleted := 0
+	for _, key := range payload.AttachmentKeys {
+		removed, err := h.releaseBlob(ctx, key)
+		if err != nil {
+			h.logger.Error("Failed to release attachment blob",
+				zap.Int("email_id", payload.EmailID),
+				zap.String("storage_key", key),
+				zap.Error(err),
+			)
+			return err // nack → 重试
+		}
+		if removed {
+			deleted++
+		}
+	}
+
+	h.logger.Info("Handled email.deleted",
+		zap.Int("email_id", payload.EmailID),
+		zap.Int("user_id", payload.UserID),
+		zap.Int("attachment_keys", len(payload.AttachmentKeys)),
+		zap.Int("blobs_deleted", deleted),
+	)
+	return nil
+}
+
+// releaseBlob 在持有 key 锁的事务中确认没有引用后删除 blob
+func (h *EmailDeletedHandler) releaseBlob(ctx context.Context, key string) (bool, error) {
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := h.attachmentRepo.LockStorageKeyTx(ctx, tx, key); err != nil {
+		return false, err
+	}
+	referenced, err := h.attachmentRepo.IsStorageKeyReferencedTx(ctx, tx, key)
+	if err != nil {
+		return false, err
+	}
+	if referenced {
+		return false, nil
+	}
+	if err := h.blobStore.Delete(ctx, key); err != nil {
+		return false, fmt.Errorf("delete blob: %w", err)
+	}
+	return true, tx.Commit(ctx)
+}
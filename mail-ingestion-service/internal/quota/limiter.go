@@ -0,0 +1,156 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"mail-ingestion-service/internal/config"
+	"mail-ingestion-service/internal/repository"
+	"mygoproject/pkg/metrics"
+	"mygoproject/pkg/util"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// 限流原因（同时作为 ingestion_throttled_count 指标的 reason 标签）
+const (
+	ReasonPerMinute = "per_minute"
+	ReasonPerDay    = "per_day"
+	ReasonBodySize  = "body_size"
+)
+
+// ExceededError 超出入库限额
+// RetryAfter 为 0 表示重试也不会成功（例如邮件超过大小上限）
+type ExceededError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *ExceededError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("ingestion quota exceeded (%s), retry after %s", e.Reason, e.RetryAfter)
+	}
+	return fmt.Sprintf("ingestion quota exceeded (%s)", e.Reason)
+}
+
+// AsExceeded 判断错误是否为超出限额
+func AsExceeded(err error) (*ExceededError, bool) {
+	var e *ExceededError
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
+// Limits 单个用户的限额，0 表示不限制
+type Limits struct {
+	EmailsPerMinute int
+	EmailsPerDay    int
+	MaxBodyBytes    int64
+}
+
+// Limiter 按用户限制入库速率（Redis 令牌桶）和单封邮件大小
+type Limiter struct {
+	quotaRepo   *repository.QuotaRepository
+	rateLimiter *util.RateLimiter
+	defaults    Limits
+	logger      *zap.Logger
+}
+
+func NewLimiter(
+	quotaRepo *repository.QuotaRepository,
+	rateLimiter *util.RateLimiter,
+	cfg config.QuotaConfig,
+	logger *zap.Logger,
+) *Limiter {
+	return &Limiter{
+		quotaRepo:   quotaRepo,
+		rateLimiter: rateLimiter,
+		defaults: Limits{
+			EmailsPerMinute: cfg.EmailsPerMinute,
+			EmailsPerDay:    cfg.EmailsPerDay,
+			MaxBodyBytes:    cfg.MaxBodyBytes,
+		},
+		logger: logger,
+	}
+}
+
+// Check 校验邮件大小并消费一个令牌，超出限额时返回 *ExceededError
+func (l *Limiter) Check(ctx context.Context, userID int, bodyBytes int64) error {
+	limits := l.limitsFor(ctx, userID)
+
+	if limits.MaxBodyBytes > 0 && bodyBytes > limits.MaxBodyBytes {
+		metrics.IncrementIngestionThrottled(ReasonBodySize)
+		return &ExceededError{Reason: ReasonBodySize}
+	}
+
+	result := l.rateLimiter.Allow(ctx, rateBuckets(userID, limits)...)
+	if result.Allowed {
+		return nil
+	}
+
+	reason := ReasonPerMinute
+	if result.Bucket.Per > time.Minute {
+		reason = ReasonPerDay
+	}
+	metrics.IncrementIngestionThrottled(reason)
+	l.logger.Info("Ingestion throttled",
+		zap.Int("user_id", userID),
+		zap.String("reason", reason),
+		zap.Duration("retry_after", result.RetryAfter),
+	)
+	return &ExceededError{Reason: reason, RetryAfter: result.RetryAfter}
+}
+
+// Refund 归还 Check 消费的令牌，用于入库事务回滚时不占用用户配额
+// 按当前限额计算桶，期间限额被修改时归还的桶可能与消费时不同（只影响一封邮件）
+func (l *Limiter) Refund(ctx context.Context, userID int) {
+	l.rateLimiter.Refund(ctx, rateBuckets(userID, l.limitsFor(ctx, userID))...)
+}
+
+// rateBuckets 返回用户的分钟级和天级令牌桶，不限制的窗口不建桶
+func rateBuckets(userID int, limits Limits) []util.Bucket {
+	var buckets []util.Bucket
+	if limits.EmailsPerMinute > 0 {
+		buckets = append(buckets, util.Bucket{
+			Key:   util.FormatRateLimitKey("ingest", ReasonPerMinute, userID),
+			Limit: limits.EmailsPerMinute,
+			Per:   time.Minute,
+		})
+	}
+	if limits.EmailsPerDay > 0 {
+		buckets = append(buckets, util.Bucket{
+			Key:   util.FormatRateLimitKey("ingest", ReasonPerDay, userID),
+			Limit: limits.EmailsPerDay,
+			Per:   24 * time.Hour,
+		})
+	}
+	return buckets
+}
+
+// limitsFor 合并默认限额和用户覆盖，查询失败时使用默认值
+func (l *Limiter) limitsFor(ctx context.Context, userID int) Limits {
+	limits := l.defaults
+
+	override, err := l.quotaRepo.GetOverride(ctx, userID)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			l.logger.Warn("Failed to load quota override, using defaults", zap.Int("user_id", userID), zap.Error(err))
+		}
+		return limits
+	}
+
+	if override.EmailsPerMinute != nil {
+		limits.EmailsPerMinute = *override.EmailsPerMinute
+	}
+	if override.EmailsPerDay != nil {
+		limits.EmailsPerDay = *override.EmailsPerDay
+	}
+	if override.MaxBodyBytes != nil {
+		limits.MaxBodyBytes = *override.MaxBodyBytes
+	}
+	return limits
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"mygoproject/contracts/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AttachmentRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAttachmentRepository(db *pgxpool.Pool) *AttachmentRepository {
+	return &AttachmentRepository{db: db}
+}
+
+// CreateTx inserts attachment metadata in a transaction.
+func (r *AttachmentRepository) CreateTx(ctx context.Context, tx pgx.Tx, a *db.EmailAttachment) (int, error) {
+	query := `
+        INSERT INTO email_attachments
+            (email_id, user_id, filename, content_type, size_bytes, sha256, storage_key, content_id, is_inline)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9)
+        RETURNING id
+    `
+	var id int
+	err := tx.QueryRow(ctx, query,
+		a.EmailID, a.UserID, a.Filename, a.ContentType, a.SizeBytes, a.SHA256, a.StorageKey, a.ContentID, a.IsInline,
+	).Scan(&id)
+	return id, err
+}
+
+// LockStorageKeyTx takes a transaction-scoped advisory lock on a blob key. Ingestion and
+// blob cleanup both hold it, so a blob is never deleted while a new reference is being written.
+func (r *AttachmentRepository) LockStorageKeyTx(ctx context.Context, tx pgx.Tx, key string) error {
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('email_attachments:' || $1))`, key)
+	return err
+}
+
+// IsStorageKeyReferencedTx reports whether any attachment still points to the blob key.
+func (r *AttachmentRepository) IsStorageKeyReferencedTx(ctx context.Context, tx pgx.Tx, key string) (bool, error) {
+	var referenced bool
+	err := tx.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM email_attachments WHERE storage_key = $1)`, key,
+	).Scan(&referenced)
+	return referenced, err
+}
@@ -29,14 +29,32 @@ func (r *EmailRepository) CreateRawEmail(ctx context.Context, e *db.Email) (int,
 }
 
 // CreateRawEmailTx inserts the raw email in a transaction.
+// Returns pgx.ErrNoRows when (user_id, message_id) or (user_id, idempotency_key) already exists.
 func (r *EmailRepository) CreateRawEmailTx(ctx context.Context, tx pgx.Tx, e *db.Email) (int, error) {
 	query := `
-        INSERT INTO emails_raw (user_id, subject, body, raw_json, status, created_at)
-        VALUES ($1, $2, $3, $4, 'received', NOW())
+        INSERT INTO emails_raw (user_id, subject, body, raw_json, status, message_id, idempotency_key, spam_score, filter_reason, created_at)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8, NULLIF($9, ''), NOW())
+        ON CONFLICT DO NOTHING
         RETURNING id
     `
 	var id int
-	err := tx.QueryRow(ctx, query, e.UserID, e.Subject, e.Body, e.RawJSON).Scan(&id)
+	err := tx.QueryRow(ctx, query,
+		e.UserID, e.Subject, e.Body, e.RawJSON, e.Status, e.MessageID, e.IdempotencyKey, e.SpamScore, e.FilterReason,
+	).Scan(&id)
 	return id, err
 }
 
+// FindDuplicateTx returns the id of an existing email with the same message_id or idempotency_key.
+func (r *EmailRepository) FindDuplicateTx(ctx context.Context, tx pgx.Tx, userID int, messageID, idempotencyKey string) (int, error) {
+	query := `
+        SELECT id
+        FROM emails_raw
+        WHERE user_id = $1
+          AND (message_id = NULLIF($2, '') OR idempotency_key = NULLIF($3, ''))
+        ORDER BY id
+        LIMIT 1
+    `
+	var id int
+	err := tx.QueryRow(ctx, query, userID, messageID, idempotencyKey).Scan(&id)
+	return id, err
+}
@@ -3,10 +3,17 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const failedEventColumns = `
+		id, email_id, user_id, event_type, routing_key, payload, COALESCE(error_message, ''),
+		retry_count, status, outbox_event_id, next_retry_at, created_at, updated_at
+	`
+
 type FailedEventRepository struct {
 	db *pgxpool.Pool
 }
@@ -16,12 +23,14 @@ func NewFailedEventRepository(db *pgxpool.Pool) *FailedEventRepository {
 }
 
 // InsertFailedEvent 插入失败的事件记录
+// outboxEventID 为对应的 outbox 事件（可为 nil），同一 outbox 事件只记录一次
 func (r *FailedEventRepository) InsertFailedEvent(
 	ctx context.Context,
 	emailID, userID int,
 	eventType, routingKey string,
 	payload interface{},
 	errorMsg string,
+	outboxEventID *int64,
 ) error {
 	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
@@ -29,71 +38,153 @@ func (r *FailedEventRepository) InsertFailedEvent(
 	}
 
 	query := `
-		INSERT INTO failed_events (email_id, user_id, event_type, routing_key, payload, error_message, status)
-		VALUES ($1, $2, $3, $4, $5, $6, 'pending')
+		INSERT INTO failed_events (email_id, user_id, event_type, routing_key, payload, error_message, status, outbox_event_id)
+		VALUES ($1, $2, $3, $4, $5, $6, 'pending', $7)
 		ON CONFLICT DO NOTHING
 	`
-	_, err = r.db.Exec(ctx, query, emailID, userID, eventType, routingKey, payloadJSON, errorMsg)
+	_, err = r.db.Exec(ctx, query, emailID, userID, eventType, routingKey, payloadJSON, errorMsg, outboxEventID)
 	return err
 }
 
-// GetPendingEvents 获取待重试的事件
-func (r *FailedEventRepository) GetPendingEvents(ctx context.Context, limit int) ([]FailedEvent, error) {
+// GetPendingEvents 获取到达重试时间的待重试事件，以及认领超过 staleAfter 仍未完成的 retrying 事件
+func (r *FailedEventRepository) GetPendingEvents(ctx context.Context, limit int, staleAfter time.Duration) ([]FailedEvent, error) {
 	query := `
-		SELECT id, email_id, user_id, event_type, routing_key, payload, error_message, retry_count, status
+		SELECT ` + failedEventColumns + `
 		FROM failed_events
-		WHERE status = 'pending' AND retry_count < 3
+		WHERE (status = 'pending' AND (next_retry_at IS NULL OR next_retry_at <= NOW()))
+		   OR (status = 'retrying' AND updated_at < NOW() - make_interval(secs => $2))
 		ORDER BY created_at ASC
 		LIMIT $1
 	`
-	rows, err := r.db.Query(ctx, query, limit)
+	rows, err := r.db.Query(ctx, query, limit, staleAfter.Seconds())
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
+	return collectFailedEvents(rows)
+}
 
-	var events []FailedEvent
-	for rows.Next() {
-		var e FailedEvent
-		if err := rows.Scan(&e.ID, &e.EmailID, &e.UserID, &e.EventType, &e.RoutingKey, &e.Payload, &e.ErrorMessage, &e.RetryCount, &e.Status); err != nil {
-			return nil, err
-		}
-		events = append(events, e)
+// List 按状态分页查询失败事件（status 为空时返回全部；userID 为 0 时不限用户），用于管理接口
+func (r *FailedEventRepository) List(ctx context.Context, userID int, status string, limit, offset int) ([]FailedEvent, error) {
+	query := `
+		SELECT ` + failedEventColumns + `
+		FROM failed_events
+		WHERE ($1 = 0 OR user_id = $1)
+		  AND ($2 = '' OR status = $2)
+		ORDER BY id DESC
+		LIMIT $3 OFFSET $4
+	`
+	rows, err := r.db.Query(ctx, query, userID, status, limit, offset)
+	if err != nil {
+		return nil, err
 	}
-	return events, rows.Err()
+	defer rows.Close()
+	return collectFailedEvents(rows)
+}
+
+// GetByID 获取单个失败事件
+func (r *FailedEventRepository) GetByID(ctx context.Context, id int) (*FailedEvent, error) {
+	query := `SELECT ` + failedEventColumns + ` FROM failed_events WHERE id = $1`
+	return scanFailedEvent(r.db.QueryRow(ctx, query, id))
+}
+
+// Claim 将待重试或已放弃自动重试的事件标记为 retrying 并返回最新状态。
+// 已被其他调用方认领（或已重试成功 / 已放弃）时返回 pgx.ErrNoRows；
+// 认领后进程退出遗留的 retrying 事件在 staleAfter 之后可被重新认领
+func (r *FailedEventRepository) Claim(ctx context.Context, id int, staleAfter time.Duration) (*FailedEvent, error) {
+	query := `
+		UPDATE failed_events
+		SET status = 'retrying', updated_at = NOW()
+		WHERE id = $1
+		  AND (status IN ('pending', 'failed')
+		       OR (status = 'retrying' AND updated_at < NOW() - make_interval(secs => $2)))
+		RETURNING ` + failedEventColumns
+	return scanFailedEvent(r.db.QueryRow(ctx, query, id, staleAfter.Seconds()))
 }
 
 // MarkAsRetried 标记事件为已重试
 func (r *FailedEventRepository) MarkAsRetried(ctx context.Context, id int) error {
 	query := `
 		UPDATE failed_events
-		SET status = 'retried', retry_count = retry_count + 1, updated_at = NOW()
+		SET status = 'retried', retry_count = retry_count + 1, next_retry_at = NULL, updated_at = NOW()
 		WHERE id = $1
 	`
 	_, err := r.db.Exec(ctx, query, id)
 	return err
 }
 
+// RecordRetryFailure 记录一次重试失败，释放认领并设置下次重试时间
+func (r *FailedEventRepository) RecordRetryFailure(ctx context.Context, id int, errorMsg string, nextRetryAt time.Time) error {
+	query := `
+		UPDATE failed_events
+		SET status = 'pending', retry_count = retry_count + 1, error_message = $2, next_retry_at = $3, updated_at = NOW()
+		WHERE id = $1
+	`
+	_, err := r.db.Exec(ctx, query, id, errorMsg, nextRetryAt)
+	return err
+}
+
 // MarkAsFailed 标记事件为最终失败（超过最大重试次数）
 func (r *FailedEventRepository) MarkAsFailed(ctx context.Context, id int) error {
 	query := `
 		UPDATE failed_events
-		SET status = 'failed', updated_at = NOW()
+		SET status = 'failed', next_retry_at = NULL, updated_at = NOW()
 		WHERE id = $1
 	`
 	_, err := r.db.Exec(ctx, query, id)
 	return err
 }
 
-type FailedEvent struct {
-	ID          int
-	EmailID     int
-	UserID      int
-	EventType   string
-	RoutingKey  string
-	Payload     json.RawMessage
-	ErrorMessage string
-	RetryCount  int
-	Status      string
+// MarkAsDiscarded 人工放弃重试。已重试成功的事件不能放弃，返回 false
+func (r *FailedEventRepository) MarkAsDiscarded(ctx context.Context, id int) (bool, error) {
+	query := `
+		UPDATE failed_events
+		SET status = 'discarded', next_retry_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND status IN ('pending', 'failed')
+	`
+	tag, err := r.db.Exec(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func scanFailedEvent(row pgx.Row) (*FailedEvent, error) {
+	var e FailedEvent
+	err := row.Scan(
+		&e.ID, &e.EmailID, &e.UserID, &e.EventType, &e.RoutingKey, &e.Payload, &e.ErrorMessage,
+		&e.RetryCount, &e.Status, &e.OutboxEventID, &e.NextRetryAt, &e.CreatedAt, &e.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func collectFailedEvents(rows pgx.Rows) ([]FailedEvent, error) {
+	var events []FailedEvent
+	for rows.Next() {
+		e, err := scanFailedEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *e)
+	}
+	return events, rows.Err()
 }
 
+type FailedEvent struct {
+	ID            int             `json:"id"`
+	EmailID       int             `json:"email_id"`
+	UserID        int             `json:"user_id"`
+	EventType     string          `json:"event_type"`
+	RoutingKey    string          `json:"routing_key"`
+	Payload       json.RawMessage `json:"payload"`
+	ErrorMessage  string          `json:"error_message"`
+	RetryCount    int             `json:"retry_count"`
+	Status        string          `json:"status"` // pending / retrying / retried / failed / discarded
+	OutboxEventID *int64          `json:"outbox_event_id,omitempty"`
+	NextRetryAt   *time.Time      `json:"next_retry_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"mygoproject/contracts/db"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ImportJobRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewImportJobRepository(db *pgxpool.Pool) *ImportJobRepository {
+	return &ImportJobRepository{db: db}
+}
+
+// Create inserts a pending import job.
+func (r *ImportJobRepository) Create(ctx context.Context, userID int, filename, format string) (int, error) {
+	query := `
+        INSERT INTO import_jobs (user_id, filename, format, status)
+        VALUES ($1, $2, $3, 'pending')
+        RETURNING id
+    `
+	var id int
+	err := r.db.QueryRow(ctx, query, userID, filename, format).Scan(&id)
+	return id, err
+}
+
+// GetByID returns an import job owned by the user.
+func (r *ImportJobRepository) GetByID(ctx context.Context, id, userID int) (*db.ImportJob, error) {
+	query := `
+        SELECT id, user_id, filename, format, status, total, ingested, duplicates, failed,
+               error_message, started_at, finished_at, created_at, updated_at
+        FROM import_jobs
+        WHERE id = $1 AND user_id = $2
+    `
+	var j db.ImportJob
+	err := r.db.QueryRow(ctx, query, id, userID).Scan(
+		&j.ID, &j.UserID, &j.Filename, &j.Format, &j.Status, &j.Total, &j.Ingested, &j.Duplicates, &j.Failed,
+		&j.ErrorMessage, &j.StartedAt, &j.FinishedAt, &j.CreatedAt, &j.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// MarkRunning sets the job to running with the total message count.
+func (r *ImportJobRepository) MarkRunning(ctx context.Context, id, total int) error {
+	query := `
+        UPDATE import_jobs
+        SET status = 'running', total = $2, started_at = NOW(), updated_at = NOW()
+        WHERE id = $1
+    `
+	_, err := r.db.Exec(ctx, query, id, total)
+	return err
+}
+
+// UpdateProgress stores the current counters.
+func (r *ImportJobRepository) UpdateProgress(ctx context.Context, id, ingested, duplicates, failed int) error {
+	query := `
+        UPDATE import_jobs
+        SET ingested = $2, duplicates = $3, failed = $4, updated_at = NOW()
+        WHERE id = $1
+    `
+	_, err := r.db.Exec(ctx, query, id, ingested, duplicates, failed)
+	return err
+}
+
+// Finish marks the job as completed or failed (errMsg non-empty).
+func (r *ImportJobRepository) Finish(ctx context.Context, id int, errMsg string) error {
+	query := `
+        UPDATE import_jobs
+        SET status = CASE WHEN $2::text = '' THEN 'completed' ELSE 'failed' END,
+            error_message = NULLIF($2, ''),
+            finished_at = NOW(),
+            updated_at = NOW()
+        WHERE id = $1
+    `
+	_, err := r.db.Exec(ctx, query, id, errMsg)
+	return err
+}
+
+// FailInterrupted marks jobs left pending/running by a previous process as failed.
+func (r *ImportJobRepository) FailInterrupted(ctx context.Context) (int64, error) {
+	query := `
+        UPDATE import_jobs
+        SET status = 'failed',
+            error_message = 'interrupted by service restart',
+            finished_at = NOW(),
+            updated_at = NOW()
+        WHERE status IN ('pending', 'running')
+    `
+	tag, err := r.db.Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
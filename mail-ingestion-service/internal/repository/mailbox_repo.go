@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"mygoproject/contracts/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const mailboxColumns = `
+        id, user_id, host, port, use_tls, username, password_encrypted, folder,
+        uid_validity, last_uid, last_sync_at, last_error, is_active, created_at, updated_at
+    `
+
+type MailboxRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewMailboxRepository(db *pgxpool.Pool) *MailboxRepository {
+	return &MailboxRepository{db: db}
+}
+
+// Create inserts a mailbox configuration and returns its id.
+func (r *MailboxRepository) Create(ctx context.Context, m *db.Mailbox) (int, error) {
+	query := `
+        INSERT INTO mailboxes (user_id, host, port, use_tls, username, password_encrypted, folder, is_active)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, TRUE)
+        RETURNING id
+    `
+	var id int
+	err := r.db.QueryRow(ctx, query,
+		m.UserID, m.Host, m.Port, m.UseTLS, m.Username, m.PasswordEncrypted, m.Folder,
+	).Scan(&id)
+	return id, err
+}
+
+// GetByID returns a mailbox owned by the given user.
+func (r *MailboxRepository) GetByID(ctx context.Context, id, userID int) (*db.Mailbox, error) {
+	query := `SELECT ` + mailboxColumns + ` FROM mailboxes WHERE id = $1 AND user_id = $2`
+	return scanMailbox(r.db.QueryRow(ctx, query, id, userID))
+}
+
+// ListByUser returns all mailboxes of a user.
+func (r *MailboxRepository) ListByUser(ctx context.Context, userID int) ([]db.Mailbox, error) {
+	query := `SELECT ` + mailboxColumns + ` FROM mailboxes WHERE user_id = $1 ORDER BY id`
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return collectMailboxes(rows)
+}
+
+// ListActive returns all mailboxes that should be polled.
+func (r *MailboxRepository) ListActive(ctx context.Context) ([]db.Mailbox, error) {
+	query := `SELECT ` + mailboxColumns + ` FROM mailboxes WHERE is_active = TRUE ORDER BY id`
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return collectMailboxes(rows)
+}
+
+// Delete removes a mailbox owned by the given user. Returns false if nothing was deleted.
+func (r *MailboxRepository) Delete(ctx context.Context, id, userID int) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM mailboxes WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// UpdateCursorTx advances uid_validity/last_uid in the same transaction as the ingested email.
+func (r *MailboxRepository) UpdateCursorTx(ctx context.Context, tx pgx.Tx, id int, uidValidity, lastUID int64) error {
+	query := `
+        UPDATE mailboxes
+        SET uid_validity = $2, last_uid = $3, updated_at = NOW()
+        WHERE id = $1
+    `
+	_, err := tx.Exec(ctx, query, id, uidValidity, lastUID)
+	return err
+}
+
+// ResetCursor stores a new UIDVALIDITY and restarts from UID 0.
+func (r *MailboxRepository) ResetCursor(ctx context.Context, id int, uidValidity int64) error {
+	query := `
+        UPDATE mailboxes
+        SET uid_validity = $2, last_uid = 0, updated_at = NOW()
+        WHERE id = $1
+    `
+	_, err := r.db.Exec(ctx, query, id, uidValidity)
+	return err
+}
+
+// MarkSynced records a sync attempt; errMsg is empty on success.
+func (r *MailboxRepository) MarkSynced(ctx context.Context, id int, errMsg string) error {
+	query := `
+        UPDATE mailboxes
+        SET last_sync_at = NOW(), last_error = NULLIF($2, ''), updated_at = NOW()
+        WHERE id = $1
+    `
+	_, err := r.db.Exec(ctx, query, id, errMsg)
+	return err
+}
+
+func scanMailbox(row pgx.Row) (*db.Mailbox, error) {
+	var m db.Mailbox
+	err := row.Scan(
+		&m.ID, &m.UserID, &m.Host, &m.Port, &m.UseTLS, &m.Username, &m.PasswordEncrypted, &m.Folder,
+		&m.UIDValidity, &m.LastUID, &m.LastSyncAt, &m.LastError, &m.IsActive, &m.CreatedAt, &m.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func collectMailboxes(rows pgx.Rows) ([]db.Mailbox, error) {
+	var mailboxes []db.Mailbox
+	for rows.Next() {
+		m, err := scanMailbox(rows)
+		if err != nil {
+			return nil, err
+		}
+		mailboxes = append(mailboxes, *m)
+	}
+	return mailboxes, rows.Err()
+}
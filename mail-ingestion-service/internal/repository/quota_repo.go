@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// QuotaOverride 单个用户的限额覆盖，nil 表示使用默认值
+type QuotaOverride struct {
+	EmailsPerMinute *int
+	EmailsPerDay    *int
+	MaxBodyBytes    *int64
+}
+
+type QuotaRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewQuotaRepository(db *pgxpool.Pool) *QuotaRepository {
+	return &QuotaRepository{db: db}
+}
+
+// GetOverride returns the quota override of a user.
+// Returns pgx.ErrNoRows when the user has no override.
+func (r *QuotaRepository) GetOverride(ctx context.Context, userID int) (*QuotaOverride, error) {
+	query := `
+        SELECT emails_per_minute, emails_per_day, max_body_bytes
+        FROM user_ingestion_quotas
+        WHERE user_id = $1
+    `
+	var o QuotaOverride
+	err := r.db.QueryRow(ctx, query, userID).Scan(&o.EmailsPerMinute, &o.EmailsPerDay, &o.MaxBodyBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"mygoproject/contracts/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const senderRuleColumns = `id, user_id, match_type, pattern, action, priority, created_at, updated_at`
+
+type SenderRuleRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSenderRuleRepository(db *pgxpool.Pool) *SenderRuleRepository {
+	return &SenderRuleRepository{db: db}
+}
+
+// Create inserts a sender rule and returns its id.
+func (r *SenderRuleRepository) Create(ctx context.Context, rule *db.SenderRule) (int, error) {
+	query := `
+        INSERT INTO sender_rules (user_id, match_type, pattern, action, priority)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id
+    `
+	var id int
+	err := r.db.QueryRow(ctx, query, rule.UserID, rule.MatchType, rule.Pattern, rule.Action, rule.Priority).Scan(&id)
+	return id, err
+}
+
+// GetByID returns a sender rule owned by the given user.
+func (r *SenderRuleRepository) GetByID(ctx context.Context, id, userID int) (*db.SenderRule, error) {
+	query := `SELECT ` + senderRuleColumns + ` FROM sender_rules WHERE id = $1 AND user_id = $2`
+	return scanSenderRule(r.db.QueryRow(ctx, query, id, userID))
+}
+
+// ListByUser returns all sender rules of a user.
+func (r *SenderRuleRepository) ListByUser(ctx context.Context, userID int) ([]db.SenderRule, error) {
+	query := `SELECT ` + senderRuleColumns + ` FROM sender_rules WHERE user_id = $1 ORDER BY match_type, pattern`
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []db.SenderRule
+	for rows.Next() {
+		rule, err := scanSenderRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+// Update changes the match, action and priority of a rule. Returns false if the rule does not exist.
+func (r *SenderRuleRepository) Update(ctx context.Context, rule *db.SenderRule) (bool, error) {
+	query := `
+        UPDATE sender_rules
+        SET match_type = $3, pattern = $4, action = $5, priority = $6, updated_at = NOW()
+        WHERE id = $1 AND user_id = $2
+    `
+	tag, err := r.db.Exec(ctx, query, rule.ID, rule.UserID, rule.MatchType, rule.Pattern, rule.Action, rule.Priority)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Delete removes a sender rule owned by the given user. Returns false if nothing was deleted.
+func (r *SenderRuleRepository) Delete(ctx context.Context, id, userID int) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM sender_rules WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// MatchTx returns the most specific rule for a sender: address rules first, then the longest matching domain.
+// Returns pgx.ErrNoRows when no rule matches.
+func (r *SenderRuleRepository) MatchTx(ctx context.Context, tx pgx.Tx, userID int, address string, domains []string) (*db.SenderRule, error) {
+	query := `
+        SELECT ` + senderRuleColumns + `
+        FROM sender_rules
+        WHERE user_id = $1
+          AND ((match_type = 'address' AND pattern = $2)
+            OR (match_type = 'domain' AND pattern = ANY($3)))
+        ORDER BY (match_type = 'address') DESC, LENGTH(pattern) DESC
+        LIMIT 1
+    `
+	return scanSenderRule(tx.QueryRow(ctx, query, userID, address, domains))
+}
+
+func scanSenderRule(row pgx.Row) (*db.SenderRule, error) {
+	var rule db.SenderRule
+	err := row.Scan(
+		&rule.ID, &rule.UserID, &rule.MatchType, &rule.Pattern, &rule.Action, &rule.Priority,
+		&rule.CreatedAt, &rule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
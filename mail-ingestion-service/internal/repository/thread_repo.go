@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ThreadRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewThreadRepository(db *pgxpool.Pool) *ThreadRepository {
+	return &ThreadRepository{db: db}
+}
+
+// FindByMessageIDsTx returns the thread of the most recent email whose message_id is in messageIDs.
+// Returns pgx.ErrNoRows when none of them is known.
+func (r *ThreadRepository) FindByMessageIDsTx(ctx context.Context, tx pgx.Tx, userID int, messageIDs []string) (int, error) {
+	query := `
+        SELECT thread_id
+        FROM emails_raw
+        WHERE user_id = $1
+          AND message_id = ANY($2)
+          AND thread_id IS NOT NULL
+        ORDER BY id DESC
+        LIMIT 1
+    `
+	var threadID int
+	err := tx.QueryRow(ctx, query, userID, messageIDs).Scan(&threadID)
+	return threadID, err
+}
+
+// FindBySubjectTx returns the most recently active thread with the same normalized subject since the given time.
+// Returns pgx.ErrNoRows when no thread matches.
+func (r *ThreadRepository) FindBySubjectTx(ctx context.Context, tx pgx.Tx, userID int, normalizedSubject string, since time.Time) (int, error) {
+	query := `
+        SELECT id
+        FROM email_threads
+        WHERE user_id = $1
+          AND normalized_subject = $2
+          AND last_message_at >= $3
+        ORDER BY last_message_at DESC
+        LIMIT 1
+    `
+	var threadID int
+	err := tx.QueryRow(ctx, query, userID, normalizedSubject, since).Scan(&threadID)
+	return threadID, err
+}
+
+// CreateTx creates an empty thread.
+func (r *ThreadRepository) CreateTx(ctx context.Context, tx pgx.Tx, userID int, subject, normalizedSubject string) (int, error) {
+	query := `
+        INSERT INTO email_threads (user_id, subject, normalized_subject, message_count, last_message_at)
+        VALUES ($1, $2, $3, 0, NOW())
+        RETURNING id
+    `
+	var threadID int
+	err := tx.QueryRow(ctx, query, userID, subject, normalizedSubject).Scan(&threadID)
+	return threadID, err
+}
+
+// AttachEmailTx links an email to a thread and bumps the thread counters.
+func (r *ThreadRepository) AttachEmailTx(ctx context.Context, tx pgx.Tx, threadID, emailID int) error {
+	if _, err := tx.Exec(ctx, `UPDATE emails_raw SET thread_id = $1 WHERE id = $2`, threadID, emailID); err != nil {
+		return err
+	}
+	query := `
+        UPDATE email_threads
+        SET message_count = message_count + 1, last_message_at = NOW(), updated_at = NOW()
+        WHERE id = $1
+    `
+	_, err := tx.Exec(ctx, query, threadID)
+	return err
+}
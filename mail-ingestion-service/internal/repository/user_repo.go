@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type UserRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewUserRepository(db *pgxpool.Pool) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+// FindIDByEmail returns the user id owning the given address (case-insensitive).
+// Returns pgx.ErrNoRows when no user matches.
+func (r *UserRepository) FindIDByEmail(ctx context.Context, email string) (int, error) {
+	query := `
+        SELECT id
+        FROM users
+        WHERE LOWER(email) = LOWER($1)
+    `
+	var id int
+	err := r.db.QueryRow(ctx, query, email).Scan(&id)
+	return id, err
+}
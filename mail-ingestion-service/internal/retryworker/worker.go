@@ -0,0 +1,243 @@
+package retryworker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"mail-ingestion-service/internal/repository"
+	mqcontracts "mygoproject/contracts/mq"
+	"mygoproject/pkg/mq"
+	"mygoproject/pkg/outbox"
+	"mygoproject/pkg/trace"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// claimTimeout 认领（retrying）超过该时间仍未完成的事件视为进程中断遗留，可被重新认领
+const claimTimeout = 5 * time.Minute
+
+// ErrNotRetryable 事件已重试成功或已被放弃
+var ErrNotRetryable = errors.New("failed event is not retryable")
+
+// Worker 定期重新发布 failed_events 中的待重试事件（指数退避）
+// outbox Dispatcher 放弃的事件通过 RecordOutboxFailure 写入 failed_events
+type Worker struct {
+	failedEventRepo *repository.FailedEventRepository
+	outboxRepo      *outbox.Repository
+	publisher       *mq.Publisher
+	logger          *zap.Logger
+
+	interval    time.Duration
+	batchSize   int
+	maxRetries  int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+}
+
+// NewWorker 创建新的重试 Worker
+func NewWorker(
+	failedEventRepo *repository.FailedEventRepository,
+	outboxRepo *outbox.Repository,
+	publisher *mq.Publisher,
+	logger *zap.Logger,
+) *Worker {
+	return &Worker{
+		failedEventRepo: failedEventRepo,
+		outboxRepo:      outboxRepo,
+		publisher:       publisher,
+		logger:          logger,
+		interval:        30 * time.Second, // 默认每30秒扫描一次
+		batchSize:       50,               // 默认每次处理50个事件
+		maxRetries:      5,                // 默认最多重试5次
+		baseBackoff:     time.Minute,      // 退避：1m, 2m, 4m, 8m...
+		maxBackoff:      time.Hour,
+	}
+}
+
+// WithInterval 设置扫描间隔
+func (w *Worker) WithInterval(interval time.Duration) *Worker {
+	w.interval = interval
+	return w
+}
+
+// WithBatchSize 设置批次大小
+func (w *Worker) WithBatchSize(batchSize int) *Worker {
+	w.batchSize = batchSize
+	return w
+}
+
+// WithMaxRetries 设置最大重试次数
+func (w *Worker) WithMaxRetries(maxRetries int) *Worker {
+	w.maxRetries = maxRetries
+	return w
+}
+
+// WithBaseBackoff 设置首次重试的退避时间
+func (w *Worker) WithBaseBackoff(backoff time.Duration) *Worker {
+	w.baseBackoff = backoff
+	return w
+}
+
+// Start 启动 Worker（在 goroutine 中运行）
+func (w *Worker) Start(ctx context.Context) {
+	w.logger.Info("Starting failed event retry worker",
+		zap.Duration("interval", w.interval),
+		zap.Int("batch_size", w.batchSize),
+		zap.Int("max_retries", w.maxRetries),
+	)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("Failed event retry worker stopped")
+			return
+		case <-ticker.C:
+			w.processPending(ctx)
+		}
+	}
+}
+
+func (w *Worker) processPending(ctx context.Context) {
+	events, err := w.failedEventRepo.GetPendingEvents(ctx, w.batchSize, claimTimeout)
+	if err != nil {
+		w.logger.Error("Failed to get pending failed events", zap.Error(err))
+		return
+	}
+
+	// 管理接口可能同时在重试同一事件，Retry 只发布认领成功的事件；发布失败已由 recordFailure 记录
+	for i := range events {
+		_ = w.Retry(ctx, &events[i])
+	}
+}
+
+// Retry 认领并立即重试单个事件（管理接口和定时扫描共用），失败时同样计入重试次数
+// 已被其他调用方认领、已重试成功或已放弃的事件返回 ErrNotRetryable
+func (w *Worker) Retry(ctx context.Context, event *repository.FailedEvent) error {
+	claimed, err := w.failedEventRepo.Claim(ctx, event.ID, claimTimeout)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotRetryable
+	}
+	if err != nil {
+		w.logger.Error("Failed to claim failed event", zap.Int("id", event.ID), zap.Error(err))
+		return fmt.Errorf("failed to claim failed event: %w", err)
+	}
+	*event = *claimed
+
+	if err := w.publish(ctx, event); err != nil {
+		w.recordFailure(ctx, event, err)
+		return err
+	}
+	w.markRetried(ctx, event)
+	return nil
+}
+
+// RecordOutboxFailure 作为 outbox.Dispatcher 的 FailureHandler：将放弃的 email 事件写入 failed_events
+func (w *Worker) RecordOutboxFailure(ctx context.Context, event *outbox.Event, publishErr error) {
+	var payload mqcontracts.EmailReceivedPayload
+	if err := json.Unmarshal(event.Payload, &payload); err != nil || payload.EmailID == 0 || payload.UserID == 0 {
+		w.logger.Warn("Outbox event exhausted retries but cannot be recorded as failed event",
+			zap.Int64("event_id", event.ID),
+			zap.String("routing_key", event.RoutingKey),
+		)
+		return
+	}
+
+	outboxEventID := event.ID
+	err := w.failedEventRepo.InsertFailedEvent(ctx,
+		payload.EmailID, payload.UserID,
+		event.RoutingKey, event.RoutingKey,
+		event.Payload,
+		publishErr.Error(),
+		&outboxEventID,
+	)
+	if err != nil {
+		w.logger.Error("Failed to record failed event",
+			zap.Int64("event_id", event.ID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	w.logger.Warn("Outbox event exhausted retries, recorded for retry",
+		zap.Int64("event_id", event.ID),
+		zap.Int("email_id", payload.EmailID),
+		zap.String("routing_key", event.RoutingKey),
+	)
+}
+
+func (w *Worker) publish(ctx context.Context, event *repository.FailedEvent) error {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+	if traceID, ok := payload["trace_id"].(string); ok && traceID != "" {
+		ctx = trace.WithContext(ctx, traceID)
+	}
+	return w.publisher.PublishWithContext(ctx, event.RoutingKey, payload)
+}
+
+func (w *Worker) markRetried(ctx context.Context, event *repository.FailedEvent) {
+	if err := w.failedEventRepo.MarkAsRetried(ctx, event.ID); err != nil {
+		w.logger.Error("Failed to mark failed event as retried", zap.Int("id", event.ID), zap.Error(err))
+	}
+	// 同步 outbox 状态，避免再次被 /admin/outbox/replay-failed 重放
+	if event.OutboxEventID != nil {
+		if err := w.outboxRepo.MarkAsSent(ctx, *event.OutboxEventID); err != nil {
+			w.logger.Warn("Failed to mark outbox event as sent", zap.Int64("event_id", *event.OutboxEventID), zap.Error(err))
+		}
+	}
+	w.logger.Info("Failed event republished",
+		zap.Int("id", event.ID),
+		zap.Int("email_id", event.EmailID),
+		zap.String("routing_key", event.RoutingKey),
+	)
+}
+
+func (w *Worker) recordFailure(ctx context.Context, event *repository.FailedEvent, publishErr error) {
+	attempts := event.RetryCount + 1
+	nextRetryAt := time.Now().Add(w.backoff(attempts))
+
+	if err := w.failedEventRepo.RecordRetryFailure(ctx, event.ID, publishErr.Error(), nextRetryAt); err != nil {
+		w.logger.Error("Failed to record retry failure", zap.Int("id", event.ID), zap.Error(err))
+		return
+	}
+
+	if attempts >= w.maxRetries {
+		if err := w.failedEventRepo.MarkAsFailed(ctx, event.ID); err != nil {
+			w.logger.Error("Failed to mark failed event as failed", zap.Int("id", event.ID), zap.Error(err))
+		}
+		w.logger.Error("Failed event exhausted retries",
+			zap.Int("id", event.ID),
+			zap.Int("email_id", event.EmailID),
+			zap.String("routing_key", event.RoutingKey),
+			zap.Error(publishErr),
+		)
+		return
+	}
+
+	w.logger.Warn("Failed event retry failed",
+		zap.Int("id", event.ID),
+		zap.Int("attempt", attempts),
+		zap.Time("next_retry_at", nextRetryAt),
+		zap.Error(publishErr),
+	)
+}
+
+// backoff 指数退避：baseBackoff * 2^(attempts-1)，不超过 maxBackoff
+func (w *Worker) backoff(attempts int) time.Duration {
+	d := w.baseBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= w.maxBackoff {
+			return w.maxBackoff
+		}
+	}
+	return d
+}
@@ -0,0 +1,184 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	dbcontracts "mygoproject/contracts/db"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// 过滤原因（写入 emails_raw.filter_reason，邮件状态为 filtered）
+const (
+	FilterReasonBlocked   = "blocked"    // 命中 block 规则，不发布事件
+	FilterReasonSpam      = "spam"       // 垃圾邮件评分超过阈值，不发布事件
+	FilterReasonSkipAgent = "skip_agent" // 不发布 email.received.agent，其余事件照常
+)
+
+const (
+	// defaultSpamThreshold 评分达到该值视为垃圾邮件
+	defaultSpamThreshold = 5
+)
+
+// authResultRe 匹配 Authentication-Results 中的 spf=fail / dkim=pass / dmarc=fail 等结果
+var authResultRe = regexp.MustCompile(`\b(spf|dkim|dmarc)\s*=\s*([a-z]+)`)
+
+// Verdict 入库前的过滤结果
+type Verdict struct {
+	Reason        string // 为空表示正常处理
+	SpamScore     int
+	ForcePriority string // force_priority 规则指定的优先级
+	RuleID        int    // 命中的规则，0 表示未命中
+}
+
+// eventRoutingKeys 返回需要写入 outbox 的路由键
+func (v *Verdict) eventRoutingKeys() []string {
+	switch v.Reason {
+	case FilterReasonBlocked, FilterReasonSpam:
+		return nil
+	case FilterReasonSkipAgent:
+		keys := make([]string, 0, len(routingKeys))
+		for _, rk := range routingKeys {
+			if rk != agentRoutingKey {
+				keys = append(keys, rk)
+			}
+		}
+		return keys
+	default:
+		return routingKeys
+	}
+}
+
+// evaluateFilterTx 在写入 outbox 之前评估发件人规则和垃圾邮件评分
+// 用户规则优先：命中任意规则时不再按评分 / 群发头过滤
+func (s *Service) evaluateFilterTx(ctx context.Context, tx pgx.Tx, userID int, msg *Message) (*Verdict, error) {
+	v := &Verdict{SpamScore: SpamScore(msg.Header)}
+
+	sender := strings.ToLower(strings.TrimSpace(msg.From))
+	if sender != "" {
+		rule, err := s.senderRuleRepo.MatchTx(ctx, tx, userID, sender, senderDomains(sender))
+		if err == nil {
+			v.RuleID = rule.ID
+			switch rule.Action {
+			case dbcontracts.SenderActionBlock:
+				v.Reason = FilterReasonBlocked
+			case dbcontracts.SenderActionSkipAgent:
+				v.Reason = FilterReasonSkipAgent
+			case dbcontracts.SenderActionForcePriority:
+				if rule.Priority != nil {
+					v.ForcePriority = *rule.Priority
+				}
+			}
+			return v, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("failed to match sender rules: %w", err)
+		}
+	}
+
+	switch {
+	case v.SpamScore >= s.spamThreshold:
+		v.Reason = FilterReasonSpam
+	case s.skipAgentForBulk && IsBulk(msg.Header):
+		v.Reason = FilterReasonSkipAgent
+	}
+	return v, nil
+}
+
+// SpamScore 基于邮件头的简单启发式评分：
+// SPF/DKIM/DMARC 失败、上游过滤器标记为垃圾邮件、群发邮件头
+func SpamScore(h mail.Header) int {
+	if h == nil {
+		return 0
+	}
+
+	// 同一机制可能出现在多个头中（多跳转发），只取最差的结果
+	results := map[string]string{}
+	record := func(mechanism, result string) {
+		if rank(result) > rank(results[mechanism]) {
+			results[mechanism] = result
+		}
+	}
+	for _, value := range h["Authentication-Results"] {
+		for _, m := range authResultRe.FindAllStringSubmatch(strings.ToLower(value), -1) {
+			record(m[1], m[2])
+		}
+	}
+	for _, value := range h["Received-Spf"] {
+		if fields := strings.Fields(strings.ToLower(value)); len(fields) > 0 {
+			record("spf", fields[0])
+		}
+	}
+
+	score := 0
+	for _, result := range results {
+		switch result {
+		case "fail", "permerror":
+			score += 3
+		case "softfail":
+			score++
+		}
+	}
+
+	if strings.EqualFold(strings.TrimSpace(h.Get("X-Spam-Flag")), "yes") ||
+		strings.HasPrefix(strings.ToLower(strings.TrimSpace(h.Get("X-Spam-Status"))), "yes") {
+		score += 5
+	}
+	if IsBulk(h) {
+		score++
+	}
+	if strings.EqualFold(strings.TrimSpace(h.Get("Precedence")), "junk") {
+		score += 2
+	}
+	return score
+}
+
+// IsBulk 判断是否为群发邮件（邮件列表、营销邮件）
+func IsBulk(h mail.Header) bool {
+	if h == nil {
+		return false
+	}
+	if h.Get("List-Unsubscribe") != "" || h.Get("List-Id") != "" {
+		return true
+	}
+	switch strings.ToLower(strings.TrimSpace(h.Get("Precedence"))) {
+	case "bulk", "list", "junk":
+		return true
+	}
+	return false
+}
+
+// rank 认证结果的严重程度，用于多个结果取最差值
+func rank(result string) int {
+	switch result {
+	case "fail", "permerror":
+		return 3
+	case "softfail":
+		return 2
+	case "":
+		return 0
+	default:
+		return 1
+	}
+}
+
+// senderDomains 返回发件人域名及其父域名，例如 a@mail.example.com → [mail.example.com example.com]
+func senderDomains(address string) []string {
+	at := strings.LastIndex(address, "@")
+	if at < 0 || at == len(address)-1 {
+		return nil
+	}
+	domain := address[at+1:]
+
+	var domains []string
+	for strings.Contains(domain, ".") {
+		domains = append(domains, domain)
+		domain = domain[strings.Index(domain, ".")+1:]
+	}
+	return domains
+}
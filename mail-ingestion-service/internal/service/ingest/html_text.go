@@ -0,0 +1,78 @@
+package ingest
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// blockTags 在转换为纯文本时需要换行的标签
+var blockTags = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"table": true, "ul": true, "ol": true, "blockquote": true, "hr": true,
+}
+
+// HTMLToText 将 HTML 正文转换为纯文本（text/plain 缺失时的 fallback）
+func HTMLToText(s string) string {
+	z := html.NewTokenizer(strings.NewReader(s))
+
+	var b strings.Builder
+	skip := 0 // 位于 script/style/head 内部时跳过文本
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return collapseBlankLines(b.String())
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			switch {
+			case tag == "script" || tag == "style" || tag == "head":
+				skip++
+			case blockTags[tag]:
+				b.WriteString("\n")
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			switch {
+			case tag == "script" || tag == "style" || tag == "head":
+				if skip > 0 {
+					skip--
+				}
+			case blockTags[tag]:
+				b.WriteString("\n")
+			}
+		case html.TextToken:
+			if skip == 0 {
+				// Tokenizer 已经处理了 &amp; 等实体；标签之间只有空白时不重复写入空格
+				if words := strings.Fields(string(z.Text())); len(words) > 0 {
+					b.WriteString(strings.Join(words, " "))
+					b.WriteString(" ")
+				} else if out := b.String(); out != "" && !strings.HasSuffix(out, " ") && !strings.HasSuffix(out, "\n") {
+					b.WriteString(" ")
+				}
+			}
+		}
+	}
+}
+
+// collapseBlankLines 去除行尾空白并合并连续空行
+func collapseBlankLines(s string) string {
+	lines := strings.Split(s, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		out = append(out, line)
+		blank = false
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
@@ -0,0 +1,28 @@
+package ingest
+
+import "testing"
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"paragraphs", "<p>One</p><p>Two</p>", "One\n\nTwo"},
+		{"line break", "first<br>second", "first\nsecond"},
+		{"entities", "<p>Tom &amp; Jerry &lt;3 &quot;cheese&quot;</p>", `Tom & Jerry <3 "cheese"`},
+		{"whitespace collapsed", "<div>  lots   of\n\n  space </div>", "lots of space"},
+		{"script and style skipped", "<style>p{color:red}</style><script>alert(1)</script><p>Visible</p>", "Visible"},
+		{"head skipped", "<html><head><title>Title</title></head><body>Body</body></html>", "Body"},
+		{"list items", "<ul><li>a</li><li>b</li></ul>", "a\n\nb"},
+		{"inline tags joined", "<p>Hello <b>bold</b> <a href=\"#\">link</a></p>", "Hello bold link"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTMLToText(tt.in); got != tt.want {
+				t.Errorf("HTMLToText(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
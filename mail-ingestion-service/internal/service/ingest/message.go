@@ -0,0 +1,374 @@
+package ingest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+const (
+	// maxMIMEDepth 限制 multipart 嵌套深度，防止恶意构造的邮件
+	maxMIMEDepth = 10
+	// maxAttachments 单封邮件最多提取的附件数
+	maxAttachments = 100
+	// maxMessageIDLen 超过该长度（字节）的 Message-ID 以 SHA-256 摘要存储，
+	// 避免超出 emails_raw.message_id 列长度和唯一索引的键长限制
+	maxMessageIDLen = 255
+)
+
+// ErrInvalidMessage 表示原始邮件无法按 RFC 5322 解析（同时保留原始错误，例如 http.MaxBytesError）
+var ErrInvalidMessage = errors.New("invalid rfc822 message")
+
+// Message 表示解析后的邮件（HTTP / SMTP / IMAP 等入口统一使用）
+type Message struct {
+	From       string // 发件人地址（仅 addr-spec）
+	FromName   string // 发件人显示名
+	To         []string
+	Cc         []string
+	Subject    string
+	Date       *time.Time
+	MessageID  string // 去掉尖括号的 Message-ID
+	InReplyTo  string
+	References []string
+
+	Body     string // 纯文本正文：优先 text/plain，否则由 text/html 转换
+	HTMLBody string
+
+	Attachments []Attachment
+
+	ContentType string
+	Header      mail.Header // 原始邮件头
+}
+
+// Attachment 邮件附件（包括日历邀请 text/calendar 和带文件名的内联图片）
+type Attachment struct {
+	Filename    string
+	ContentType string
+	ContentID   string // 去掉尖括号的 Content-ID（内联引用）
+	Inline      bool
+	Data        []byte
+}
+
+// mimeParts walkPart 的收集结果
+type mimeParts struct {
+	text        string
+	html        string
+	attachments []Attachment
+}
+
+// NewSimpleMessage 用于只有 subject/body 的模拟邮件
+func NewSimpleMessage(subject, body string) *Message {
+	return &Message{
+		Subject: subject,
+		Body:    body,
+	}
+}
+
+// ParseMessage 解析 message/rfc822 原始邮件
+func ParseMessage(r io.Reader) (*Message, error) {
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+	}
+
+	parts := &mimeParts{}
+	if err := walkPart(textproto.MIMEHeader(m.Header), m.Body, 0, parts); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+	}
+
+	return NewMessageFromParts(m.Header, parts.text, parts.html, parts.attachments), nil
+}
+
+// NewMessageFromParts 由邮件头和已解码的正文、附件构造 Message
+// 用于服务商 webhook 等已经拆分好 MIME 结构的入口
+func NewMessageFromParts(h mail.Header, text, html string, attachments []Attachment) *Message {
+	dec := &mime.WordDecoder{CharsetReader: charsetReader}
+
+	msg := &Message{
+		Header:      h,
+		Subject:     decodeHeader(dec, h.Get("Subject")),
+		MessageID:   trimMsgID(h.Get("Message-Id")),
+		InReplyTo:   firstMsgID(h.Get("In-Reply-To")),
+		References:  splitMsgIDs(h.Get("References")),
+		ContentType: h.Get("Content-Type"),
+	}
+
+	if from := parseAddressList(h, "From"); len(from) > 0 {
+		msg.From = from[0].Address
+		msg.FromName = from[0].Name
+	}
+	for _, a := range parseAddressList(h, "To") {
+		msg.To = append(msg.To, a.Address)
+	}
+	for _, a := range parseAddressList(h, "Cc") {
+		msg.Cc = append(msg.Cc, a.Address)
+	}
+	if date, err := h.Date(); err == nil {
+		msg.Date = &date
+	}
+
+	msg.HTMLBody = html
+	msg.Body = strings.TrimSpace(text)
+	if msg.Body == "" && html != "" {
+		msg.Body = HTMLToText(html)
+	}
+	if len(attachments) > maxAttachments {
+		attachments = attachments[:maxAttachments]
+	}
+	msg.Attachments = attachments
+
+	return msg
+}
+
+// Size 返回正文和附件的总字节数（用于入库限额）
+func (m *Message) Size() int64 {
+	size := int64(len(m.Body) + len(m.HTMLBody))
+	for _, a := range m.Attachments {
+		size += int64(len(a.Data))
+	}
+	return size
+}
+
+// RawJSON 返回写入 emails_raw.raw_json 的邮件头信息
+func (m *Message) RawJSON() (string, error) {
+	raw := struct {
+		From        string              `json:"from,omitempty"`
+		FromName    string              `json:"from_name,omitempty"`
+		To          []string            `json:"to,omitempty"`
+		Cc          []string            `json:"cc,omitempty"`
+		Subject     string              `json:"subject,omitempty"`
+		Date        *time.Time          `json:"date,omitempty"`
+		MessageID   string              `json:"message_id,omitempty"`
+		InReplyTo   string              `json:"in_reply_to,omitempty"`
+		References  []string            `json:"references,omitempty"`
+		ContentType string              `json:"content_type,omitempty"`
+		Headers     map[string][]string `json:"headers,omitempty"`
+	}{
+		From:        m.From,
+		FromName:    m.FromName,
+		To:          m.To,
+		Cc:          m.Cc,
+		Subject:     m.Subject,
+		Date:        m.Date,
+		MessageID:   m.MessageID,
+		InReplyTo:   m.InReplyTo,
+		References:  m.References,
+		ContentType: m.ContentType,
+		Headers:     m.Header,
+	}
+
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// walkPart 递归遍历 MIME 结构，收集第一个 text/plain、text/html 正文和所有附件
+func walkPart(header textproto.MIMEHeader, body io.Reader, depth int, parts *mimeParts) error {
+	if depth > maxMIMEDepth {
+		return fmt.Errorf("mime nesting too deep")
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		// 缺失或无法解析的 Content-Type 按 RFC 2045 视为 text/plain
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		boundary := params["boundary"]
+		if boundary == "" {
+			return fmt.Errorf("multipart without boundary")
+		}
+		mr := multipart.NewReader(body, boundary)
+		for {
+			// NextRawPart 不会自动解码 quoted-printable，统一由 decodeTransfer 处理
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := walkPart(part.Header, part, depth+1, parts); err != nil {
+				return err
+			}
+		}
+	}
+
+	isBodyText := mediaType == "text/plain" || mediaType == "text/html"
+	disposition, dispParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := decodeHeader(&mime.WordDecoder{CharsetReader: charsetReader}, firstNonEmpty(dispParams["filename"], params["name"]))
+
+	// 正文：未声明为附件、且没有文件名的 text/plain / text/html
+	if isBodyText && disposition != "attachment" && filename == "" {
+		content, err := decodePart(header, params, body)
+		if err != nil {
+			return err
+		}
+		switch mediaType {
+		case "text/plain":
+			if parts.text == "" {
+				parts.text = content
+			}
+		case "text/html":
+			if parts.html == "" {
+				parts.html = content
+			}
+		}
+		return nil
+	}
+
+	if len(parts.attachments) >= maxAttachments {
+		return nil
+	}
+
+	data, err := io.ReadAll(decodeTransfer(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return err
+	}
+	if filename == "" {
+		filename = defaultFilename(mediaType, len(parts.attachments)+1)
+	}
+	parts.attachments = append(parts.attachments, Attachment{
+		Filename:    filename,
+		ContentType: mediaType,
+		ContentID:   trimMsgID(header.Get("Content-Id")),
+		Inline:      disposition == "inline",
+		Data:        data,
+	})
+	return nil
+}
+
+// defaultFilename 为没有文件名的附件生成名称（如日历邀请 invite.ics）
+func defaultFilename(mediaType string, n int) string {
+	if mediaType == "text/calendar" {
+		return "invite.ics"
+	}
+	ext := ".bin"
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		ext = exts[0]
+	}
+	if mediaType == "message/rfc822" {
+		ext = ".eml"
+	}
+	return fmt.Sprintf("attachment-%d%s", n, ext)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// decodePart 处理 Content-Transfer-Encoding 和 charset
+func decodePart(header textproto.MIMEHeader, params map[string]string, body io.Reader) (string, error) {
+	r := decodeTransfer(header.Get("Content-Transfer-Encoding"), body)
+
+	if charset := strings.ToLower(params["charset"]); charset != "" && charset != "utf-8" && charset != "us-ascii" {
+		if cr, err := charsetReader(charset, r); err == nil {
+			r = cr
+		}
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.ToValidUTF8(b, []byte("�"))), nil
+}
+
+func decodeTransfer(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	default:
+		return body
+	}
+}
+
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, err
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+func decodeHeader(dec *mime.WordDecoder, value string) string {
+	decoded, err := dec.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// parseAddressList 解析地址头，格式不规范时退化为逐个解析
+func parseAddressList(h mail.Header, key string) []*mail.Address {
+	value := h.Get(key)
+	if value == "" {
+		return nil
+	}
+
+	parser := &mail.AddressParser{WordDecoder: &mime.WordDecoder{CharsetReader: charsetReader}}
+	if list, err := parser.ParseList(value); err == nil {
+		return list
+	}
+
+	var list []*mail.Address
+	for _, part := range strings.Split(value, ",") {
+		if a, err := parser.Parse(strings.TrimSpace(part)); err == nil {
+			list = append(list, a)
+		}
+	}
+	return list
+}
+
+// trimMsgID 去掉尖括号；过长的 Message-ID 替换为 "sha256:<hex>"，
+// In-Reply-To / References 使用同样的规则，会话关联不受影响
+func trimMsgID(id string) string {
+	id = strings.Trim(strings.TrimSpace(id), "<>")
+	if len(id) > maxMessageIDLen {
+		sum := sha256.Sum256([]byte(id))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return id
+}
+
+func firstMsgID(value string) string {
+	ids := splitMsgIDs(value)
+	if len(ids) == 0 {
+		return ""
+	}
+	return ids[0]
+}
+
+func splitMsgIDs(value string) []string {
+	var ids []string
+	for _, f := range strings.Fields(value) {
+		if id := trimMsgID(f); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,278 @@
+package ingest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// crlf 把测试中用 \n 书写的邮件转换为 CRLF 行尾
+func crlf(s string) string {
+	return strings.ReplaceAll(s, "\n", "\r\n")
+}
+
+func TestParseMessage_Bodies(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		wantBody string
+		wantHTML string
+	}{
+		{
+			name: "plain text without content type",
+			raw: `From: Alice <alice@example.org>
+Subject: Hello
+
+Plain body.
+`,
+			wantBody: "Plain body.",
+		},
+		{
+			name: "multipart/alternative prefers text/plain",
+			raw: `From: alice@example.org
+Subject: Alt
+Content-Type: multipart/alternative; boundary="alt"
+
+--alt
+Content-Type: text/plain; charset=utf-8
+
+Plain version
+--alt
+Content-Type: text/html; charset=utf-8
+
+<p>HTML version</p>
+--alt--
+`,
+			wantBody: "Plain version",
+			wantHTML: "<p>HTML version</p>",
+		},
+		{
+			name: "html only converted to text",
+			raw: `From: alice@example.org
+Subject: HTML
+Content-Type: multipart/alternative; boundary="alt"
+
+--alt
+Content-Type: text/html; charset=utf-8
+
+<html><head><title>x</title></head><body><p>First</p><p>Second &amp; last</p></body></html>
+--alt--
+`,
+			wantBody: "First\n\nSecond & last",
+			wantHTML: "<html><head><title>x</title></head><body><p>First</p><p>Second &amp; last</p></body></html>",
+		},
+		{
+			name: "nested multipart/mixed with alternative",
+			raw: `From: alice@example.org
+Subject: Nested
+Content-Type: multipart/mixed; boundary="mixed"
+
+--mixed
+Content-Type: multipart/alternative; boundary="alt"
+
+--alt
+Content-Type: text/plain
+
+Nested plain
+--alt
+Content-Type: text/html
+
+<b>Nested html</b>
+--alt--
+--mixed
+Content-Type: text/plain; name="notes.txt"
+Content-Disposition: attachment; filename="notes.txt"
+
+attached text
+--mixed--
+`,
+			wantBody: "Nested plain",
+			wantHTML: "<b>Nested html</b>",
+		},
+		{
+			name: "quoted-printable latin-1",
+			raw: `From: alice@example.org
+Subject: =?ISO-8859-1?Q?Caf=E9?=
+Content-Type: text/plain; charset=ISO-8859-1
+Content-Transfer-Encoding: quoted-printable
+
+Caf=E9 cr=E8me, soft line=
+ break
+`,
+			wantBody: "Café crème, soft line break",
+		},
+		{
+			name: "base64 gbk",
+			raw: `From: alice@example.org
+Subject: GBK
+Content-Type: text/plain; charset=gbk
+Content-Transfer-Encoding: base64
+
+xOO6ww==
+`,
+			wantBody: "你好",
+		},
+		{
+			name: "unknown charset kept as utf-8",
+			raw: `From: alice@example.org
+Subject: Unknown
+Content-Type: text/plain; charset=x-made-up
+
+still readable
+`,
+			wantBody: "still readable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := ParseMessage(strings.NewReader(crlf(tt.raw)))
+			if err != nil {
+				t.Fatalf("ParseMessage: %v", err)
+			}
+			if msg.Body != tt.wantBody {
+				t.Errorf("body = %q, want %q", msg.Body, tt.wantBody)
+			}
+			if strings.TrimSpace(msg.HTMLBody) != tt.wantHTML {
+				t.Errorf("html = %q, want %q", msg.HTMLBody, tt.wantHTML)
+			}
+		})
+	}
+}
+
+func TestParseMessage_Headers(t *testing.T) {
+	raw := crlf(`From: =?UTF-8?B?5byg5LiJ?= <zhang@example.org>
+To: bob@example.com, "Carol" <carol@example.com>
+Cc: dave@example.com
+Subject: Re: Plan
+Date: Tue, 02 Jan 2024 10:00:00 +0800
+Message-ID: <abc@example.org>
+In-Reply-To: <parent@example.org>
+References: <root@example.org> <parent@example.org>
+
+Body
+`)
+	msg, err := ParseMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+	if msg.From != "zhang@example.org" || msg.FromName != "张三" {
+		t.Errorf("from = %q <%q>", msg.FromName, msg.From)
+	}
+	if strings.Join(msg.To, ",") != "bob@example.com,carol@example.com" || strings.Join(msg.Cc, ",") != "dave@example.com" {
+		t.Errorf("to = %v, cc = %v", msg.To, msg.Cc)
+	}
+	if msg.MessageID != "abc@example.org" || msg.InReplyTo != "parent@example.org" {
+		t.Errorf("message id = %q, in-reply-to = %q", msg.MessageID, msg.InReplyTo)
+	}
+	if strings.Join(msg.References, " ") != "root@example.org parent@example.org" {
+		t.Errorf("references = %v", msg.References)
+	}
+	if msg.Date == nil || msg.Date.UTC().Hour() != 2 {
+		t.Errorf("date = %v", msg.Date)
+	}
+}
+
+func TestParseMessage_Attachments(t *testing.T) {
+	raw := crlf(`From: alice@example.org
+Subject: Files
+Content-Type: multipart/mixed; boundary="mixed"
+
+--mixed
+Content-Type: text/plain
+
+See attachments.
+--mixed
+Content-Type: multipart/related; boundary="rel"
+
+--rel
+Content-Type: text/html
+
+<img src="cid:logo@example.org">
+--rel
+Content-Type: image/png; name="logo.png"
+Content-Disposition: inline; filename="logo.png"
+Content-ID: <logo@example.org>
+Content-Transfer-Encoding: base64
+
+iVBORw0KGgo=
+--rel--
+--mixed
+Content-Type: text/calendar; method=REQUEST
+Content-Transfer-Encoding: quoted-printable
+
+BEGIN:VCALENDAR=0D=0AEND:VCALENDAR
+--mixed
+Content-Type: application/pdf; name="=?UTF-8?Q?r=C3=A9sum=C3=A9.pdf?="
+Content-Disposition: attachment
+
+%PDF-1.4
+--mixed--
+`)
+	msg, err := ParseMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+	if msg.Body != "See attachments." {
+		t.Errorf("body = %q", msg.Body)
+	}
+	if len(msg.Attachments) != 3 {
+		t.Fatalf("attachments = %+v", msg.Attachments)
+	}
+	logo, invite, pdf := msg.Attachments[0], msg.Attachments[1], msg.Attachments[2]
+	if logo.Filename != "logo.png" || !logo.Inline || logo.ContentID != "logo@example.org" || string(logo.Data[:4]) != "\x89PNG" {
+		t.Errorf("inline image = %+v", logo)
+	}
+	if invite.Filename != "invite.ics" || invite.ContentType != "text/calendar" || string(invite.Data) != "BEGIN:VCALENDAR\r\nEND:VCALENDAR" {
+		t.Errorf("calendar invite = %+v", invite)
+	}
+	if pdf.Filename != "résumé.pdf" || pdf.ContentType != "application/pdf" || pdf.Inline {
+		t.Errorf("pdf = %+v", pdf)
+	}
+}
+
+func TestParseMessage_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"no header separator", "not a message"},
+		{"multipart without boundary", "Content-Type: multipart/mixed\n\nbody\n"},
+		{"too deeply nested", nestedMultipart(maxMIMEDepth + 2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseMessage(strings.NewReader(crlf(tt.raw))); !errors.Is(err, ErrInvalidMessage) {
+				t.Errorf("err = %v, want ErrInvalidMessage", err)
+			}
+		})
+	}
+}
+
+// nestedMultipart 构造嵌套 depth 层的 multipart/mixed 邮件
+func nestedMultipart(depth int) string {
+	var b strings.Builder
+	b.WriteString("Subject: deep\n")
+	for i := 0; i < depth; i++ {
+		b.WriteString("Content-Type: multipart/mixed; boundary=\"b" + string(rune('a'+i)) + "\"\n\n")
+		b.WriteString("--b" + string(rune('a'+i)) + "\n")
+	}
+	b.WriteString("Content-Type: text/plain\n\ninner\n")
+	for i := depth - 1; i >= 0; i-- {
+		b.WriteString("--b" + string(rune('a'+i)) + "--\n")
+	}
+	return b.String()
+}
+
+func TestTrimMsgID_HashesLongIDs(t *testing.T) {
+	long := "<" + strings.Repeat("x", maxMessageIDLen+1) + "@example.org>"
+	got := trimMsgID(long)
+	if !strings.HasPrefix(got, "sha256:") || len(got) != len("sha256:")+64 {
+		t.Errorf("trimMsgID(long) = %q", got)
+	}
+	if trimMsgID(long) != got {
+		t.Error("hash is not stable")
+	}
+	if got := trimMsgID(" <short@example.org> "); got != "short@example.org" {
+		t.Errorf("trimMsgID(short) = %q", got)
+	}
+}
@@ -1,91 +1,252 @@
 package ingest
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
+	"mail-ingestion-service/internal/blobstore"
+	"mail-ingestion-service/internal/quota"
 	"mail-ingestion-service/internal/repository"
 	dbcontracts "mygoproject/contracts/db"
 	mqcontracts "mygoproject/contracts/mq"
+	"mygoproject/pkg/metrics"
 	"mygoproject/pkg/outbox"
 	"mygoproject/pkg/trace"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 )
 
+// agentRoutingKey 触发 AI 决策的路由键（skip_agent 时不发布）
+const agentRoutingKey = "email.received.agent"
+
+// routingKeys email.received 事件的所有路由键
+var routingKeys = []string{
+	agentRoutingKey,
+	"email.received.log",
+	"email.received.notify",
+}
+
 type Service struct {
-	db         *pgxpool.Pool
-	emailRepo  *repository.EmailRepository
-	outboxRepo *outbox.Repository
-	logger     *zap.Logger
+	db             *pgxpool.Pool
+	emailRepo      *repository.EmailRepository
+	attachmentRepo *repository.AttachmentRepository
+	threadRepo     *repository.ThreadRepository
+	senderRuleRepo *repository.SenderRuleRepository
+	blobStore      blobstore.Store
+	outboxRepo     *outbox.Repository
+	quota          *quota.Limiter // 可选，nil 表示不限额
+	logger         *zap.Logger
+
+	spamThreshold    int
+	skipAgentForBulk bool
 }
 
 func NewService(
 	db *pgxpool.Pool,
 	emailRepo *repository.EmailRepository,
+	attachmentRepo *repository.AttachmentRepository,
+	threadRepo *repository.ThreadRepository,
+	senderRuleRepo *repository.SenderRuleRepository,
+	blobStore blobstore.Store,
 	logger *zap.Logger,
 ) *Service {
 	return &Service{
-		db:         db,
-		emailRepo:  emailRepo,
-		outboxRepo: outbox.NewRepository(db),
-		logger:     logger,
+		db:             db,
+		emailRepo:      emailRepo,
+		attachmentRepo: attachmentRepo,
+		threadRepo:     threadRepo,
+		senderRuleRepo: senderRuleRepo,
+		blobStore:      blobStore,
+		outboxRepo:     outbox.NewRepository(db),
+		logger:         logger,
+		spamThreshold:  defaultSpamThreshold,
 	}
 }
 
+// WithSpamThreshold 设置垃圾邮件评分阈值
+func (s *Service) WithSpamThreshold(threshold int) *Service {
+	s.spamThreshold = threshold
+	return s
+}
+
+// WithQuota 启用每用户入库限额
+func (s *Service) WithQuota(limiter *quota.Limiter) *Service {
+	s.quota = limiter
+	return s
+}
+
+// WithSkipAgentForBulk 未命中规则的群发邮件（List-Unsubscribe / Precedence: bulk）不调用 AI 决策
+func (s *Service) WithSkipAgentForBulk(skip bool) *Service {
+	s.skipAgentForBulk = skip
+	return s
+}
+
+// Result 入库结果
+type Result struct {
+	EmailID  int
+	ThreadID int
+	// Duplicate 为 true 表示已存在相同 Message-ID / Idempotency-Key 的邮件，
+	// EmailID 为原邮件 ID，且没有重新发布 email.received.* 事件
+	Duplicate bool
+	// FilterReason 非空表示邮件被发件人规则或垃圾邮件评分过滤（blocked / spam / skip_agent）
+	FilterReason string
+
+	// quotaConsumed 为 true 表示已消费入库令牌，事务未提交时需通过 RefundQuota 归还
+	quotaConsumed bool
+}
+
 // CreateRawAndPublish 使用 Outbox 模式：在事务中写入 email 和 outbox 事件
-func (s *Service) CreateRawAndPublish(ctx context.Context, userID int, subject, body string) (int, error) {
+// idempotencyKey 可为空（来自 HTTP Idempotency-Key 头、webhook 或 SMTP 内容摘要）
+func (s *Service) CreateRawAndPublish(ctx context.Context, userID int, msg *Message, idempotencyKey string) (*Result, error) {
 	// 开始事务
 	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	// 1. Insert raw email（在事务中）
+	result, err := s.CreateRawAndPublishTx(ctx, tx, userID, msg, idempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// 提交事务（email 和 outbox 事件一起提交）
+	if err := tx.Commit(ctx); err != nil {
+		s.RefundQuota(ctx, userID, result)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if result.Duplicate {
+		s.logger.Info("Duplicate email suppressed",
+			zap.Int("email_id", result.EmailID),
+			zap.Int("user_id", userID),
+			zap.String("message_id", msg.MessageID),
+			zap.String("idempotency_key", idempotencyKey),
+		)
+		return result, nil
+	}
+
+	s.logger.Info("Email created and outbox events inserted successfully",
+		zap.Int("email_id", result.EmailID),
+		zap.Int("thread_id", result.ThreadID),
+		zap.Int("user_id", userID),
+		zap.String("filter_reason", result.FilterReason),
+	)
+
+	return result, nil
+}
+
+// CreateRawAndPublishTx 在调用方的事务中写入 email 和 outbox 事件
+// 调用方可以在同一事务中更新自己的状态（如 IMAP 的 last_uid），由调用方负责提交；
+// 返回错误时已消费的令牌会自动归还，之后事务未能提交时调用方需要调用 RefundQuota
+func (s *Service) CreateRawAndPublishTx(ctx context.Context, tx pgx.Tx, userID int, msg *Message, idempotencyKey string) (_ *Result, err error) {
+	rawJSON, err := msg.RawJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal raw headers: %w", err)
+	}
+
+	// 1. 评估发件人规则和垃圾邮件评分（在写入 outbox 之前）
+	verdict, err := s.evaluateFilterTx(ctx, tx, userID, msg)
+	if err != nil {
+		return nil, err
+	}
+	status := "received"
+	if verdict.Reason != "" {
+		status = "filtered"
+	}
+
+	// 2. Insert raw email（在事务中，冲突时不插入）
 	raw := &dbcontracts.Email{
-		UserID:    userID,
-		Subject:   subject,
-		Body:      body,
-		RawJSON:   "{}",
-		Status:    "received",
-		CreatedAt: time.Now(),
+		UserID:         userID,
+		Subject:        msg.Subject,
+		Body:           msg.Body,
+		RawJSON:        rawJSON,
+		Status:         status,
+		CreatedAt:      time.Now(),
+		MessageID:      msg.MessageID,
+		IdempotencyKey: idempotencyKey,
+		SpamScore:      verdict.SpamScore,
+		FilterReason:   verdict.Reason,
 	}
 
 	emailID, err := s.emailRepo.CreateRawEmailTx(ctx, tx, raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// 重复投递：返回原邮件，不再写 outbox 事件
+		existingID, err := s.emailRepo.FindDuplicateTx(ctx, tx, userID, msg.MessageID, idempotencyKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find duplicate email: %w", err)
+		}
+		return &Result{EmailID: existingID, Duplicate: true}, nil
+	}
 	if err != nil {
 		s.logger.Error("Failed to create raw email", zap.Error(err))
-		return 0, fmt.Errorf("failed to create email: %w", err)
+		return nil, fmt.Errorf("failed to create email: %w", err)
+	}
+
+	// 限额检查放在去重之后：重复投递不消耗配额；超出限额时返回 *quota.ExceededError，由调用方回滚事务
+	if s.quota != nil {
+		if err := s.quota.Check(ctx, userID, msg.Size()); err != nil {
+			return nil, err
+		}
+		// 之后的步骤失败时事务会回滚，归还令牌
+		defer func() {
+			if err != nil {
+				s.refundQuota(ctx, userID)
+			}
+		}()
 	}
 
-	// 2. Construct event payload
+	// 3. 归并到会话线程（在事务中）
+	threadID, err := s.assignThreadTx(ctx, tx, userID, emailID, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. 存储附件内容并写入附件元数据（在事务中）
+	attachments, err := s.storeAttachmentsTx(ctx, tx, emailID, userID, msg.Attachments)
+	if err != nil {
+		return nil, err
+	}
+
+	// 5. Construct event payload
 	traceID := trace.FromContext(ctx)
 	payload := mqcontracts.EmailReceivedPayload{
-		EmailID:    emailID,
-		UserID:     userID,
-		Subject:    subject,
-		Body:       body,
-		ReceivedAt: time.Now(),
-		TraceID:    traceID,
-	}
+		EmailID:     emailID,
+		UserID:      userID,
+		Subject:     msg.Subject,
+		Body:        msg.Body,
+		ReceivedAt:  time.Now(),
+		TraceID:     traceID,
+		ThreadID:    threadID,
+		From:        msg.From,
+		To:          msg.To,
+		Cc:          msg.Cc,
+		MessageID:   msg.MessageID,
+		InReplyTo:   msg.InReplyTo,
+		References:  msg.References,
+		SentAt:      msg.Date,
+		Attachments: attachments,
 
-	// 3. 将事件写入 outbox（在同一个事务中）
-	routingKeys := []string{
-		"email.received.agent",
-		"email.received.log",
-		"email.received.notify",
+		ForcePriority: verdict.ForcePriority,
+		SpamScore:     verdict.SpamScore,
 	}
 
 	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
-		return 0, fmt.Errorf("failed to marshal payload: %w", err)
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
+	// 6. 将事件写入 outbox（在同一个事务中），被拦截的邮件不发布事件
 	emailID64 := int64(emailID)
-	for _, rk := range routingKeys {
+	for _, rk := range verdict.eventRoutingKeys() {
 		event := &outbox.Event{
 			AggregateType: "email",
 			AggregateID:   &emailID64,
@@ -99,20 +260,87 @@ func (s *Service) CreateRawAndPublish(ctx context.Context, userID int, subject,
 				zap.String("routing_key", rk),
 				zap.Error(err),
 			)
-			return 0, fmt.Errorf("failed to insert outbox event: %w", err)
+			return nil, fmt.Errorf("failed to insert outbox event: %w", err)
 		}
 	}
 
-	// 4. 提交事务（email 和 outbox 事件一起提交）
-	if err := tx.Commit(ctx); err != nil {
-		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	if verdict.Reason != "" {
+		metrics.IncrementEmailFiltered(verdict.Reason)
+		s.logger.Info("Email filtered before agent",
+			zap.Int("email_id", emailID),
+			zap.Int("user_id", userID),
+			zap.String("reason", verdict.Reason),
+			zap.Int("spam_score", verdict.SpamScore),
+			zap.Int("rule_id", verdict.RuleID),
+		)
 	}
 
-	s.logger.Info("Email created and outbox events inserted successfully",
-		zap.Int("email_id", emailID),
-		zap.Int("user_id", userID),
-		zap.Any("routing_keys", routingKeys),
-	)
+	return &Result{EmailID: emailID, ThreadID: threadID, FilterReason: verdict.Reason, quotaConsumed: s.quota != nil}, nil
+}
 
-	return emailID, nil
+// RefundQuota 归还 CreateRawAndPublishTx 消费的入库令牌，用于调用方事务提交失败的情况
+func (s *Service) RefundQuota(ctx context.Context, userID int, result *Result) {
+	if result == nil || !result.quotaConsumed {
+		return
+	}
+	s.refundQuota(ctx, userID)
+}
+
+// refundQuota 在 ctx 已取消时也要归还令牌
+func (s *Service) refundQuota(ctx context.Context, userID int) {
+	s.quota.Refund(context.WithoutCancel(ctx), userID)
+}
+
+// storeAttachmentsTx 将附件写入 blob store（按 sha256 内容寻址，相同内容只存一份），
+// 并在事务中写入 email_attachments。事务回滚时 blob 可能残留，但因内容寻址不会产生脏数据
+func (s *Service) storeAttachmentsTx(ctx context.Context, tx pgx.Tx, emailID, userID int, attachments []Attachment) ([]mqcontracts.AttachmentSummary, error) {
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+
+	summaries := make([]mqcontracts.AttachmentSummary, 0, len(attachments))
+	for _, a := range attachments {
+		sum := sha256.Sum256(a.Data)
+		digest := hex.EncodeToString(sum[:])
+		key := blobstore.ContentKey(digest)
+
+		// 与 email.deleted 的 blob 回收互斥，直到本事务提交
+		if err := s.attachmentRepo.LockStorageKeyTx(ctx, tx, key); err != nil {
+			return nil, fmt.Errorf("failed to lock attachment blob: %w", err)
+		}
+		exists, err := s.blobStore.Exists(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check attachment blob: %w", err)
+		}
+		if !exists {
+			if err := s.blobStore.Put(ctx, key, bytes.NewReader(a.Data), int64(len(a.Data)), a.ContentType); err != nil {
+				return nil, fmt.Errorf("failed to store attachment: %w", err)
+			}
+		}
+
+		record := &dbcontracts.EmailAttachment{
+			EmailID:     emailID,
+			UserID:      userID,
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			SizeBytes:   int64(len(a.Data)),
+			SHA256:      digest,
+			StorageKey:  key,
+			ContentID:   a.ContentID,
+			IsInline:    a.Inline,
+		}
+		id, err := s.attachmentRepo.CreateTx(ctx, tx, record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert attachment: %w", err)
+		}
+
+		summaries = append(summaries, mqcontracts.AttachmentSummary{
+			ID:          id,
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			Size:        record.SizeBytes,
+			SHA256:      digest,
+		})
+	}
+	return summaries, nil
 }
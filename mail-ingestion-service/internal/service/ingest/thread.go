@@ -0,0 +1,94 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// subjectThreadWindow 按主题归并时只考虑最近活跃的线程，避免把很久以前的同名邮件串在一起
+	subjectThreadWindow = 30 * 24 * time.Hour
+)
+
+var (
+	// replyPrefixRe 回复/转发前缀（含常见的本地化写法，如 AW:/WG:/回复：/转发：）
+	replyPrefixRe = regexp.MustCompile(`(?i)^(re|fw|fwd|aw|wg|sv|vs|antw|回复|答复|转发)\s*(\[\d+\]|\(\d+\))?\s*[:：]\s*`)
+	// listTagRe 邮件列表标签，如 [dev-list]
+	listTagRe = regexp.MustCompile(`^\[[^\]]*\]\s*`)
+)
+
+// NormalizeSubject 去掉 Re:/Fwd:/[tag] 前缀并转小写，返回规范化主题以及是否带有回复/转发前缀
+// （migrations 中的 normalize_email_subject 使用相同的规则回填历史线程，修改时需同步）
+func NormalizeSubject(subject string) (string, bool) {
+	s := strings.TrimSpace(subject)
+	isReply := false
+	for {
+		if loc := replyPrefixRe.FindStringIndex(s); loc != nil {
+			s = s[loc[1]:]
+			isReply = true
+			continue
+		}
+		if loc := listTagRe.FindStringIndex(s); loc != nil {
+			s = s[loc[1]:]
+			continue
+		}
+		break
+	}
+	return strings.ToLower(strings.Join(strings.Fields(s), " ")), isReply
+}
+
+// assignThreadTx 为新入库的邮件确定会话线程：
+//  1. 按 In-Reply-To / References 找到已入库的父邮件所在线程
+//  2. 否则对回复类邮件按规范化主题归并到最近活跃的线程
+//  3. 否则新建线程
+func (s *Service) assignThreadTx(ctx context.Context, tx pgx.Tx, userID, emailID int, msg *Message) (int, error) {
+	threadID, err := s.resolveThreadTx(ctx, tx, userID, msg)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.threadRepo.AttachEmailTx(ctx, tx, threadID, emailID); err != nil {
+		return 0, fmt.Errorf("failed to attach email to thread: %w", err)
+	}
+	return threadID, nil
+}
+
+func (s *Service) resolveThreadTx(ctx context.Context, tx pgx.Tx, userID int, msg *Message) (int, error) {
+	parents := make([]string, 0, len(msg.References)+1)
+	if msg.InReplyTo != "" {
+		parents = append(parents, msg.InReplyTo)
+	}
+	parents = append(parents, msg.References...)
+
+	if len(parents) > 0 {
+		threadID, err := s.threadRepo.FindByMessageIDsTx(ctx, tx, userID, parents)
+		if err == nil {
+			return threadID, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("failed to find thread by references: %w", err)
+		}
+	}
+
+	normalized, isReply := NormalizeSubject(msg.Subject)
+	if normalized != "" && (isReply || len(parents) > 0) {
+		threadID, err := s.threadRepo.FindBySubjectTx(ctx, tx, userID, normalized, time.Now().Add(-subjectThreadWindow))
+		if err == nil {
+			return threadID, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("failed to find thread by subject: %w", err)
+		}
+	}
+
+	threadID, err := s.threadRepo.CreateTx(ctx, tx, userID, msg.Subject, normalized)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create thread: %w", err)
+	}
+	return threadID, nil
+}
@@ -0,0 +1,302 @@
+package smtpserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"time"
+
+	"mail-ingestion-service/internal/config"
+	"mail-ingestion-service/internal/quota"
+	"mail-ingestion-service/internal/repository"
+	"mail-ingestion-service/internal/service/ingest"
+	"mygoproject/pkg/trace"
+
+	"github.com/emersion/go-smtp"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMaxMessageBytes = 25 << 20
+	defaultMaxRecipients   = 50
+	ioTimeout              = 60 * time.Second
+	// ingestTimeout 单封邮件入库（含所有收件人）的超时时间
+	ingestTimeout = 30 * time.Second
+)
+
+var (
+	errUnknownRecipient = &smtp.SMTPError{
+		Code:         550,
+		EnhancedCode: smtp.EnhancedCode{5, 1, 1},
+		Message:      "No such user here",
+	}
+	errTemporaryFailure = &smtp.SMTPError{
+		Code:         451,
+		EnhancedCode: smtp.EnhancedCode{4, 3, 0},
+		Message:      "Temporary local error, please try again later",
+	}
+	errNoRecipients = &smtp.SMTPError{
+		Code:         554,
+		EnhancedCode: smtp.EnhancedCode{5, 5, 1},
+		Message:      "No valid recipients",
+	}
+	// 超出入库速率限额：临时失败，发送方稍后重试
+	errRateLimited = &smtp.SMTPError{
+		Code:         451,
+		EnhancedCode: smtp.EnhancedCode{4, 7, 1},
+		Message:      "Rate limit exceeded, please try again later",
+	}
+	errQuotaSizeExceeded = &smtp.SMTPError{
+		Code:         552,
+		EnhancedCode: smtp.EnhancedCode{5, 3, 4},
+		Message:      "Message size exceeds quota",
+	}
+	errInvalidMessage = &smtp.SMTPError{
+		Code:         554,
+		EnhancedCode: smtp.EnhancedCode{5, 6, 0},
+		Message:      "Message could not be parsed",
+	}
+)
+
+// Server 内置 SMTP/LMTP 监听，接收的邮件与 HTTP 入口走同一条 emails_raw + outbox 事务
+type Server struct {
+	srv    *smtp.Server
+	logger *zap.Logger
+}
+
+func NewServer(
+	cfg config.SMTPConfig,
+	ingestService *ingest.Service,
+	userRepo *repository.UserRepository,
+	logger *zap.Logger,
+) *Server {
+	be := &backend{
+		ingestService: ingestService,
+		userRepo:      userRepo,
+		logger:        logger,
+	}
+
+	srv := smtp.NewServer(be)
+	srv.Addr = cfg.Port
+	srv.Domain = cfg.Domain
+	srv.LMTP = cfg.LMTP
+	srv.MaxMessageBytes = cfg.MaxMessageBytes
+	if srv.MaxMessageBytes <= 0 {
+		srv.MaxMessageBytes = defaultMaxMessageBytes
+	}
+	srv.MaxRecipients = cfg.MaxRecipients
+	if srv.MaxRecipients <= 0 {
+		srv.MaxRecipients = defaultMaxRecipients
+	}
+	srv.ReadTimeout = ioTimeout
+	srv.WriteTimeout = ioTimeout
+
+	return &Server{srv: srv, logger: logger}
+}
+
+// ListenAndServe 阻塞监听，直到 Close 被调用
+func (s *Server) ListenAndServe() error {
+	protocol := "smtp"
+	if s.srv.LMTP {
+		protocol = "lmtp"
+	}
+	s.logger.Info("Starting SMTP listener",
+		zap.String("addr", s.srv.Addr),
+		zap.String("protocol", protocol),
+		zap.Int64("max_message_bytes", s.srv.MaxMessageBytes),
+	)
+	if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, smtp.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
+
+type backend struct {
+	ingestService *ingest.Service
+	userRepo      *repository.UserRepository
+	logger        *zap.Logger
+}
+
+func (b *backend) NewSession(c *smtp.Conn) (smtp.Session, error) {
+	return &session{backend: b, remoteAddr: c.Conn().RemoteAddr().String()}, nil
+}
+
+// session 一次 SMTP 会话，可包含多封邮件（以 RSET / MAIL FROM 分隔）
+type session struct {
+	backend    *backend
+	remoteAddr string
+
+	from    string
+	rcpts   []recipient // 每个 RCPT TO 一项（LMTP 按此顺序返回状态）
+	userIDs []int       // 去重后的收件用户
+}
+
+// recipient 已接受的 RCPT TO 地址及其对应的用户
+type recipient struct {
+	address string
+	userID  int
+}
+
+var _ smtp.LMTPSession = (*session)(nil)
+
+func (s *session) Reset() {
+	s.from = ""
+	s.rcpts = nil
+	s.userIDs = nil
+}
+
+func (s *session) Logout() error {
+	return nil
+}
+
+func (s *session) Mail(from string, opts *smtp.MailOptions) error {
+	s.from = from
+	return nil
+}
+
+// Rcpt 将 RCPT TO 地址映射到 users.email，未知收件人直接拒绝
+func (s *session) Rcpt(to string, opts *smtp.RcptOptions) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ioTimeout)
+	defer cancel()
+
+	userID, err := s.backend.userRepo.FindIDByEmail(ctx, to)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			s.backend.logger.Info("Rejected unknown SMTP recipient",
+				zap.String("rcpt", to),
+				zap.String("remote_addr", s.remoteAddr),
+			)
+			return errUnknownRecipient
+		}
+		s.backend.logger.Error("Failed to look up SMTP recipient", zap.String("rcpt", to), zap.Error(err))
+		return errTemporaryFailure
+	}
+	s.rcpts = append(s.rcpts, recipient{address: to, userID: userID})
+
+	// 同一用户的多个地址只入库一次
+	for _, id := range s.userIDs {
+		if id == userID {
+			return nil
+		}
+	}
+	s.userIDs = append(s.userIDs, userID)
+	return nil
+}
+
+// Data 解析邮件并为每个收件用户调用 ingest.Service。SMTP 只能返回一个状态，任一用户失败时整封邮件返回失败
+// （重试时已入库的用户按 Message-ID 或内容摘要去重）
+func (s *session) Data(r io.Reader) error {
+	msg, key, err := s.readMessage(r)
+	if err != nil {
+		return err
+	}
+
+	traceID := trace.GenerateTraceID()
+	ctx, cancel := context.WithTimeout(trace.WithContext(context.Background(), traceID), ingestTimeout)
+	defer cancel()
+
+	for _, userID := range s.userIDs {
+		if err := s.ingest(ctx, userID, msg, key, traceID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LMTPData LMTP 模式下为每个收件人分别返回状态（RFC 2033），
+// 一个用户入库失败只让该用户的收件人重试，不影响已成功的收件人
+func (s *session) LMTPData(r io.Reader, status smtp.StatusCollector) error {
+	msg, key, err := s.readMessage(r)
+	if err != nil {
+		return err
+	}
+
+	traceID := trace.GenerateTraceID()
+	ctx, cancel := context.WithTimeout(trace.WithContext(context.Background(), traceID), ingestTimeout)
+	defer cancel()
+
+	results := make(map[int]error, len(s.userIDs))
+	for _, userID := range s.userIDs {
+		results[userID] = s.ingest(ctx, userID, msg, key, traceID)
+	}
+	for _, rcpt := range s.rcpts {
+		status.SetStatus(rcpt.address, results[rcpt.userID])
+	}
+	return nil
+}
+
+// readMessage 读取并解析 DATA 内容，同时返回幂等键
+func (s *session) readMessage(r io.Reader) (*ingest.Message, string, error) {
+	if len(s.userIDs) == 0 {
+		return nil, "", errNoRecipients
+	}
+
+	// 超过 MaxMessageBytes 时 reader 返回 smtp.ErrDataTooLarge（552）
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, "", err
+	}
+
+	msg, err := ingest.ParseMessage(bytes.NewReader(raw))
+	if err != nil {
+		s.backend.logger.Warn("Failed to parse SMTP message",
+			zap.String("remote_addr", s.remoteAddr),
+			zap.Error(err),
+		)
+		return nil, "", errInvalidMessage
+	}
+	if msg.From == "" {
+		msg.From = s.from
+	}
+	return msg, s.idempotencyKey(raw), nil
+}
+
+// idempotencyKey 由信封发件人和原始邮件内容计算：发送方在 451 后重试同一封邮件时，
+// 已入库的用户即使邮件没有 Message-ID 也不会重复入库（去重按用户进行，因此不包含收件人）
+func (s *session) idempotencyKey(raw []byte) string {
+	h := sha256.New()
+	h.Write([]byte(s.from))
+	h.Write([]byte{0})
+	h.Write(raw)
+	return "smtp:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// ingest 为一个收件用户入库，返回对应的 SMTP 状态（nil 表示成功）
+func (s *session) ingest(ctx context.Context, userID int, msg *ingest.Message, idempotencyKey, traceID string) error {
+	result, err := s.backend.ingestService.CreateRawAndPublish(ctx, userID, msg, idempotencyKey)
+	if exceeded, ok := quota.AsExceeded(err); ok {
+		s.backend.logger.Warn("SMTP message rejected by ingestion quota",
+			zap.Int("user_id", userID),
+			zap.String("reason", exceeded.Reason),
+			zap.String("trace_id", traceID),
+		)
+		if exceeded.RetryAfter <= 0 {
+			return errQuotaSizeExceeded
+		}
+		return errRateLimited
+	}
+	if err != nil {
+		s.backend.logger.Error("Failed to ingest SMTP message",
+			zap.Int("user_id", userID),
+			zap.String("trace_id", traceID),
+			zap.Error(err),
+		)
+		return errTemporaryFailure
+	}
+	s.backend.logger.Info("SMTP message accepted",
+		zap.Int("email_id", result.EmailID),
+		zap.Bool("duplicate", result.Duplicate),
+		zap.Int("user_id", userID),
+		zap.String("message_id", msg.MessageID),
+		zap.String("trace_id", traceID),
+	)
+	return nil
+}
@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"mail-ingestion-service/internal/service/ingest"
+)
+
+// MailgunAdapter 解析 Mailgun Routes 的 forward() 推送
+// 签名：HMAC-SHA256(signing key, timestamp + token)，十六进制编码
+type MailgunAdapter struct {
+	signingKey []byte
+	maxSkew    time.Duration
+	now        func() time.Time
+	replay     ReplayGuard
+}
+
+// NewMailgunAdapter 创建 Mailgun 适配器，signingKey 为 HTTP webhook signing key
+func NewMailgunAdapter(signingKey string, maxSkew time.Duration) *MailgunAdapter {
+	return &MailgunAdapter{signingKey: []byte(signingKey), maxSkew: maxSkew, now: time.Now}
+}
+
+// WithReplayGuard 记录已使用的 token，拒绝时间窗口内的重放请求
+func (a *MailgunAdapter) WithReplayGuard(guard ReplayGuard) *MailgunAdapter {
+	a.replay = guard
+	return a
+}
+
+func (a *MailgunAdapter) Name() string {
+	return "mailgun"
+}
+
+func (a *MailgunAdapter) Parse(r *http.Request) (*Inbound, error) {
+	if err := parseForm(r); err != nil {
+		return nil, err
+	}
+	if err := a.verify(r.Context(), r.FormValue("timestamp"), r.FormValue("token"), r.FormValue("signature")); err != nil {
+		return nil, err
+	}
+
+	var (
+		msg *ingest.Message
+		err error
+	)
+	// 路由目标 URL 以 mime 结尾时，Mailgun 在 body-mime 字段中提交完整原始邮件
+	if raw := r.FormValue("body-mime"); raw != "" {
+		msg, err = ingest.ParseMessage(strings.NewReader(raw))
+		if err != nil {
+			return nil, invalidPayload(err)
+		}
+	} else {
+		msg, err = a.parseFields(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if msg.From == "" {
+		msg.From = r.FormValue("sender")
+	}
+
+	return &Inbound{Recipients: splitAddresses(r.FormValue("recipient")), Message: msg}, nil
+}
+
+func (a *MailgunAdapter) verify(ctx context.Context, timestamp, token, signature string) error {
+	if timestamp == "" || token == "" || signature == "" {
+		return ErrUnauthorized
+	}
+	if err := checkTimestamp(timestamp, a.maxSkew, a.now()); err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha256.New, a.signingKey)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte(token))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return ErrUnauthorized
+	}
+
+	// 签名有效的请求在时间窗口内可被原样重放，token 需记住整个窗口（时间戳前后各 maxSkew）
+	if a.replay != nil && !a.replay.FirstUse(ctx, "mailgun:"+token, 2*a.maxSkew) {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// parseFields 解析已拆分的字段：message-headers 为 [[name, value], ...] 形式的 JSON
+func (a *MailgunAdapter) parseFields(r *http.Request) (*ingest.Message, error) {
+	header := mail.Header{}
+	if raw := r.FormValue("message-headers"); raw != "" {
+		var pairs [][]string
+		if err := json.Unmarshal([]byte(raw), &pairs); err != nil {
+			return nil, invalidPayload(fmt.Errorf("message-headers: %w", err))
+		}
+		for _, kv := range pairs {
+			if len(kv) == 2 {
+				textproto.MIMEHeader(header).Add(kv[0], kv[1])
+			}
+		}
+	}
+	if header.Get("Subject") == "" && r.FormValue("subject") != "" {
+		header["Subject"] = []string{r.FormValue("subject")}
+	}
+	if header.Get("From") == "" && r.FormValue("from") != "" {
+		header["From"] = []string{r.FormValue("from")}
+	}
+
+	attachments, err := a.parseAttachments(r)
+	if err != nil {
+		return nil, err
+	}
+	return ingest.NewMessageFromParts(header, r.FormValue("body-plain"), r.FormValue("body-html"), attachments), nil
+}
+
+func (a *MailgunAdapter) parseAttachments(r *http.Request) ([]ingest.Attachment, error) {
+	count, _ := strconv.Atoi(r.FormValue("attachment-count"))
+	if count <= 0 {
+		return nil, nil
+	}
+
+	// content-id-map: {"<cid>": "attachment-1"}，用于识别内联图片
+	contentIDs := map[string]string{}
+	if raw := r.FormValue("content-id-map"); raw != "" {
+		var cidMap map[string]string
+		if err := json.Unmarshal([]byte(raw), &cidMap); err == nil {
+			for cid, field := range cidMap {
+				contentIDs[field] = strings.Trim(cid, "<>")
+			}
+		}
+	}
+
+	var attachments []ingest.Attachment
+	for i := 1; i <= count; i++ {
+		field := fmt.Sprintf("attachment-%d", i)
+		data, fh, err := readFormFile(r.MultipartForm, field)
+		if err != nil {
+			return nil, invalidPayload(fmt.Errorf("%s: %w", field, err))
+		}
+		if fh == nil {
+			continue
+		}
+
+		att := ingest.Attachment{
+			Filename:    fh.Filename,
+			ContentType: firstNonEmpty(fh.Header.Get("Content-Type"), "application/octet-stream"),
+			ContentID:   contentIDs[field],
+			Data:        data,
+		}
+		att.Inline = att.ContentID != ""
+		attachments = append(attachments, att)
+	}
+	return attachments, nil
+}
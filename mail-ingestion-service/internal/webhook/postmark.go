@@ -0,0 +1,150 @@
+package webhook
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"mail-ingestion-service/internal/service/ingest"
+)
+
+// PostmarkAdapter 解析 Postmark inbound webhook（JSON 请求体）
+// Postmark 不对请求签名，需在 webhook URL 中配置 HTTP Basic 认证
+type PostmarkAdapter struct {
+	username string
+	password string
+}
+
+// NewPostmarkAdapter 创建 Postmark 适配器
+func NewPostmarkAdapter(username, password string) *PostmarkAdapter {
+	return &PostmarkAdapter{username: username, password: password}
+}
+
+func (a *PostmarkAdapter) Name() string {
+	return "postmark"
+}
+
+type postmarkAddress struct {
+	Email string `json:"Email"`
+	Name  string `json:"Name"`
+}
+
+type postmarkHeader struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+}
+
+type postmarkAttachment struct {
+	Name        string `json:"Name"`
+	Content     string `json:"Content"` // base64
+	ContentType string `json:"ContentType"`
+	ContentID   string `json:"ContentID"`
+}
+
+type postmarkPayload struct {
+	MessageID         string               `json:"MessageID"` // Postmark 自己的消息 ID，不是邮件头中的 Message-ID
+	From              string               `json:"From"`
+	FromFull          postmarkAddress      `json:"FromFull"`
+	To                string               `json:"To"`
+	ToFull            []postmarkAddress    `json:"ToFull"`
+	Cc                string               `json:"Cc"`
+	CcFull            []postmarkAddress    `json:"CcFull"`
+	BccFull           []postmarkAddress    `json:"BccFull"`
+	OriginalRecipient string               `json:"OriginalRecipient"`
+	Subject           string               `json:"Subject"`
+	Date              string               `json:"Date"`
+	TextBody          string               `json:"TextBody"`
+	HtmlBody          string               `json:"HtmlBody"`
+	Headers           []postmarkHeader     `json:"Headers"`
+	Attachments       []postmarkAttachment `json:"Attachments"`
+}
+
+func (a *PostmarkAdapter) Parse(r *http.Request) (*Inbound, error) {
+	user, pass, ok := r.BasicAuth()
+	if !ok ||
+		subtle.ConstantTimeCompare([]byte(user), []byte(a.username)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(pass), []byte(a.password)) != 1 {
+		return nil, ErrUnauthorized
+	}
+
+	var payload postmarkPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		return nil, invalidPayload(err)
+	}
+
+	header := mail.Header{}
+	for _, h := range payload.Headers {
+		textproto.MIMEHeader(header).Add(h.Name, h.Value)
+	}
+	// Headers 中通常不包含以下标准头，由顶层字段补齐
+	setIfMissing(header, "From", formatPostmarkAddresses([]postmarkAddress{payload.FromFull}, payload.From))
+	setIfMissing(header, "To", formatPostmarkAddresses(payload.ToFull, payload.To))
+	setIfMissing(header, "Cc", formatPostmarkAddresses(payload.CcFull, payload.Cc))
+	setIfMissing(header, "Subject", payload.Subject)
+	setIfMissing(header, "Date", payload.Date)
+
+	attachments := make([]ingest.Attachment, 0, len(payload.Attachments))
+	for _, att := range payload.Attachments {
+		data, err := base64.StdEncoding.DecodeString(att.Content)
+		if err != nil {
+			return nil, invalidPayload(fmt.Errorf("attachment %q: %w", att.Name, err))
+		}
+		contentID := strings.Trim(att.ContentID, "<>")
+		attachments = append(attachments, ingest.Attachment{
+			Filename:    att.Name,
+			ContentType: firstNonEmpty(att.ContentType, "application/octet-stream"),
+			ContentID:   contentID,
+			Inline:      contentID != "",
+			Data:        data,
+		})
+	}
+
+	msg := ingest.NewMessageFromParts(header, payload.TextBody, payload.HtmlBody, attachments)
+
+	// OriginalRecipient 为实际投递地址（转发到 Postmark 入站地址前的收件人）
+	var recipients []string
+	if payload.OriginalRecipient != "" {
+		recipients = []string{payload.OriginalRecipient}
+	} else {
+		for _, list := range [][]postmarkAddress{payload.ToFull, payload.CcFull, payload.BccFull} {
+			for _, addr := range list {
+				if addr.Email != "" {
+					recipients = append(recipients, addr.Email)
+				}
+			}
+		}
+	}
+
+	inbound := &Inbound{Recipients: recipients, Message: msg}
+	if payload.MessageID != "" {
+		// Postmark 重试时 MessageID 不变
+		inbound.IdempotencyKey = "postmark:" + payload.MessageID
+	}
+	return inbound, nil
+}
+
+func setIfMissing(h mail.Header, key, value string) {
+	if value != "" && h.Get(key) == "" {
+		textproto.MIMEHeader(h).Set(key, value)
+	}
+}
+
+// formatPostmarkAddresses 优先使用结构化地址，缺失时退回原始字符串
+func formatPostmarkAddresses(list []postmarkAddress, fallback string) string {
+	parts := make([]string, 0, len(list))
+	for _, a := range list {
+		if a.Email == "" {
+			continue
+		}
+		parts = append(parts, (&mail.Address{Name: a.Name, Address: a.Email}).String())
+	}
+	if len(parts) == 0 {
+		return fallback
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,91 @@
+package webhook
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newPostmarkRequest(body []byte, user, pass string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/webhooks/inbound/postmark", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	if user != "" || pass != "" {
+		r.SetBasicAuth(user, pass)
+	}
+	return r
+}
+
+func TestPostmarkParse_ValidAuth(t *testing.T) {
+	a := NewPostmarkAdapter("postmark", "s3cret")
+	body := readFixture(t, "postmark_inbound.json")
+
+	in, err := a.Parse(newPostmarkRequest(body, "postmark", "s3cret"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(in.Recipients) != 1 || in.Recipients[0] != "bob@inbound.example.com" {
+		t.Errorf("recipients = %v, want OriginalRecipient", in.Recipients)
+	}
+	if in.IdempotencyKey != "postmark:73e6d360-66eb-11e1-8e72-a8904824019b" {
+		t.Errorf("idempotency key = %q", in.IdempotencyKey)
+	}
+	msg := in.Message
+	if msg.From != "alice@example.org" || msg.FromName != "Alice Example" {
+		t.Errorf("from = %q <%q>", msg.FromName, msg.From)
+	}
+	if msg.MessageID != "CAN4HSjx7t3pz@mail.example.org" {
+		t.Errorf("message id = %q", msg.MessageID)
+	}
+	if msg.Date == nil {
+		t.Error("date not parsed from top-level Date field")
+	}
+	if len(msg.Attachments) != 1 || string(msg.Attachments[0].Data) != "region,total\nemea,42\n" {
+		t.Errorf("attachments = %+v", msg.Attachments)
+	}
+}
+
+func TestPostmarkParse_Rejected(t *testing.T) {
+	a := NewPostmarkAdapter("postmark", "s3cret")
+	body := readFixture(t, "postmark_inbound.json")
+
+	tests := []struct {
+		name string
+		user string
+		pass string
+	}{
+		{"no credentials", "", ""},
+		{"wrong password", "postmark", "guess"},
+		{"wrong user", "admin", "s3cret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.Parse(newPostmarkRequest(body, tt.user, tt.pass))
+			if !errors.Is(err, ErrUnauthorized) {
+				t.Errorf("err = %v, want ErrUnauthorized", err)
+			}
+		})
+	}
+}
+
+func TestPostmarkParse_MalformedBody(t *testing.T) {
+	a := NewPostmarkAdapter("postmark", "s3cret")
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"not json", "From=alice%40example.org"},
+		{"truncated json", `{"From": "alice@example.org", "Subject": `},
+		{"attachment not base64", `{"From": "alice@example.org", "Attachments": [{"Name": "a.bin", "Content": "%%%"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.Parse(newPostmarkRequest([]byte(tt.body), "postmark", "s3cret"))
+			if !errors.Is(err, ErrInvalidPayload) {
+				t.Errorf("err = %v, want ErrInvalidPayload", err)
+			}
+		})
+	}
+}
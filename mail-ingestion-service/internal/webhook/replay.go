@@ -0,0 +1,36 @@
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// ReplayGuard 记录已使用过的签名 token，同一 token 在有效期内只能使用一次
+type ReplayGuard interface {
+	// FirstUse 首次使用返回 true，重放返回 false
+	FirstUse(ctx context.Context, key string, ttl time.Duration) bool
+}
+
+// RedisReplayGuard 基于 Redis SETNX 的 ReplayGuard，多个实例共享同一份记录
+type RedisReplayGuard struct {
+	rdb    *redis.Client
+	logger *zap.Logger
+}
+
+// NewRedisReplayGuard 创建基于 Redis 的 ReplayGuard
+func NewRedisReplayGuard(rdb *redis.Client, logger *zap.Logger) *RedisReplayGuard {
+	return &RedisReplayGuard{rdb: rdb, logger: logger}
+}
+
+func (g *RedisReplayGuard) FirstUse(ctx context.Context, key string, ttl time.Duration) bool {
+	ok, err := g.rdb.SetNX(ctx, "webhook:replay:"+key, 1, ttl).Result()
+	if err != nil {
+		// 与 Deduper 一致：Redis 不可用时不阻止处理（签名和时间戳校验仍然生效）
+		g.logger.Warn("Webhook replay check failed, allowing request", zap.Error(err))
+		return true
+	}
+	return ok
+}
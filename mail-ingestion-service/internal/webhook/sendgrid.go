@@ -0,0 +1,213 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mail-ingestion-service/internal/service/ingest"
+)
+
+const (
+	sendGridSignatureHeader = "X-Twilio-Email-Event-Webhook-Signature"
+	sendGridTimestampHeader = "X-Twilio-Email-Event-Webhook-Timestamp"
+)
+
+// SendGridAdapter 解析 SendGrid Inbound Parse 推送
+// 签名：ECDSA(SHA-256(timestamp + 原始请求体))，公钥为控制台提供的 base64 DER
+type SendGridAdapter struct {
+	publicKey *ecdsa.PublicKey
+	maxSkew   time.Duration
+	now       func() time.Time
+}
+
+// NewSendGridAdapter 创建 SendGrid 适配器，verificationKey 为 base64 编码的 ECDSA 公钥
+func NewSendGridAdapter(verificationKey string, maxSkew time.Duration) (*SendGridAdapter, error) {
+	der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(verificationKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid sendgrid verification key: %w", err)
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sendgrid verification key: %w", err)
+	}
+	pub, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid sendgrid verification key: not an ECDSA public key")
+	}
+	return &SendGridAdapter{publicKey: pub, maxSkew: maxSkew, now: time.Now}, nil
+}
+
+func (a *SendGridAdapter) Name() string {
+	return "sendgrid"
+}
+
+// sendGridEnvelope envelope 字段（JSON）
+type sendGridEnvelope struct {
+	To   []string `json:"to"`
+	From string   `json:"from"`
+}
+
+// sendGridAttachmentInfo attachment-info 字段中单个附件的描述
+type sendGridAttachmentInfo struct {
+	Filename  string `json:"filename"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	ContentID string `json:"content-id"`
+}
+
+func (a *SendGridAdapter) Parse(r *http.Request) (*Inbound, error) {
+	// 签名覆盖原始请求体，先完整读取再交给表单解析
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, invalidPayload(err)
+	}
+	if err := a.verify(r.Header, body); err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err := parseForm(r); err != nil {
+		return nil, err
+	}
+
+	var envelope sendGridEnvelope
+	if raw := r.FormValue("envelope"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &envelope); err != nil {
+			return nil, invalidPayload(fmt.Errorf("envelope: %w", err))
+		}
+	}
+
+	// 开启 "POST the raw, full MIME message" 时，email 字段为完整原始邮件
+	var msg *ingest.Message
+	if raw := r.FormValue("email"); raw != "" {
+		msg, err = ingest.ParseMessage(strings.NewReader(raw))
+		if err != nil {
+			return nil, invalidPayload(err)
+		}
+	} else {
+		msg, err = a.parseFields(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	recipients := envelope.To
+	if len(recipients) == 0 {
+		recipients = splitAddresses(r.FormValue("to"))
+	}
+	if msg.From == "" {
+		msg.From = envelope.From
+	}
+
+	return &Inbound{Recipients: recipients, Message: msg}, nil
+}
+
+func (a *SendGridAdapter) verify(h http.Header, body []byte) error {
+	signature := h.Get(sendGridSignatureHeader)
+	timestamp := h.Get(sendGridTimestampHeader)
+	if signature == "" || timestamp == "" {
+		return ErrUnauthorized
+	}
+	if err := checkTimestamp(timestamp, a.maxSkew, a.now()); err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrUnauthorized
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(timestamp))
+	hash.Write(body)
+	if !ecdsa.VerifyASN1(a.publicKey, hash.Sum(nil), sig) {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// parseFields 解析默认模式：headers / text / html 分字段提交，附件为 attachmentN 文件
+func (a *SendGridAdapter) parseFields(r *http.Request) (*ingest.Message, error) {
+	// charsets 记录各字段的原始字符集，例如 {"text":"iso-8859-1","subject":"UTF-8"}
+	charsets := map[string]string{}
+	if raw := r.FormValue("charsets"); raw != "" {
+		_ = json.Unmarshal([]byte(raw), &charsets)
+	}
+
+	header := parseHeaderBlock(r.FormValue("headers"))
+	if header.Get("Subject") == "" && r.FormValue("subject") != "" {
+		header["Subject"] = []string{decodeCharset(r.FormValue("subject"), charsets["subject"])}
+	}
+	if header.Get("From") == "" && r.FormValue("from") != "" {
+		header["From"] = []string{r.FormValue("from")}
+	}
+	if header.Get("To") == "" && r.FormValue("to") != "" {
+		header["To"] = []string{r.FormValue("to")}
+	}
+	if header.Get("Cc") == "" && r.FormValue("cc") != "" {
+		header["Cc"] = []string{r.FormValue("cc")}
+	}
+
+	text := decodeCharset(r.FormValue("text"), charsets["text"])
+	html := decodeCharset(r.FormValue("html"), charsets["html"])
+
+	attachments, err := a.parseAttachments(r)
+	if err != nil {
+		return nil, err
+	}
+	return ingest.NewMessageFromParts(header, text, html, attachments), nil
+}
+
+func (a *SendGridAdapter) parseAttachments(r *http.Request) ([]ingest.Attachment, error) {
+	count, _ := strconv.Atoi(r.FormValue("attachments"))
+	if count <= 0 {
+		return nil, nil
+	}
+
+	info := map[string]sendGridAttachmentInfo{}
+	if raw := r.FormValue("attachment-info"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &info); err != nil {
+			return nil, invalidPayload(fmt.Errorf("attachment-info: %w", err))
+		}
+	}
+
+	var attachments []ingest.Attachment
+	for i := 1; i <= count; i++ {
+		field := fmt.Sprintf("attachment%d", i)
+		data, fh, err := readFormFile(r.MultipartForm, field)
+		if err != nil {
+			return nil, invalidPayload(fmt.Errorf("%s: %w", field, err))
+		}
+		if fh == nil {
+			continue
+		}
+
+		meta := info[field]
+		att := ingest.Attachment{
+			Filename:    firstNonEmpty(meta.Filename, meta.Name, fh.Filename),
+			ContentType: firstNonEmpty(meta.Type, fh.Header.Get("Content-Type"), "application/octet-stream"),
+			ContentID:   strings.Trim(meta.ContentID, "<>"),
+			Data:        data,
+		}
+		att.Inline = att.ContentID != ""
+		attachments = append(attachments, att)
+	}
+	return attachments, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,153 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const sendGridBoundary = "xYzZY"
+
+var testNow = time.Unix(1469652786, 0)
+
+func newTestSendGridAdapter(t *testing.T) (*SendGridAdapter, *ecdsa.PrivateKey) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	a, err := NewSendGridAdapter(base64.StdEncoding.EncodeToString(der), 5*time.Minute)
+	if err != nil {
+		t.Fatalf("new adapter: %v", err)
+	}
+	a.now = func() time.Time { return testNow }
+	return a, priv
+}
+
+func signSendGrid(t *testing.T, key *ecdsa.PrivateKey, timestamp string, body []byte) string {
+	t.Helper()
+	hash := sha256.New()
+	hash.Write([]byte(timestamp))
+	hash.Write(body)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash.Sum(nil))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func newSendGridRequest(body []byte, timestamp, signature string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/webhooks/inbound/sendgrid", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "multipart/form-data; boundary="+sendGridBoundary)
+	r.Header.Set(sendGridTimestampHeader, timestamp)
+	r.Header.Set(sendGridSignatureHeader, signature)
+	return r
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	return data
+}
+
+func TestSendGridParse_ValidSignature(t *testing.T) {
+	a, key := newTestSendGridAdapter(t)
+	body := readFixture(t, "sendgrid_inbound.multipart")
+	ts := strconv.FormatInt(testNow.Unix(), 10)
+
+	in, err := a.Parse(newSendGridRequest(body, ts, signSendGrid(t, key, ts, body)))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(in.Recipients) != 1 || in.Recipients[0] != "bob@inbound.example.com" {
+		t.Errorf("recipients = %v", in.Recipients)
+	}
+	msg := in.Message
+	if msg.From != "alice@example.org" || msg.Subject != "Quarterly report" {
+		t.Errorf("from/subject = %q / %q", msg.From, msg.Subject)
+	}
+	if msg.MessageID != "CAN4HSjx7t3pz@mail.example.org" {
+		t.Errorf("message id = %q", msg.MessageID)
+	}
+	if msg.Body != "Please find the report attached." {
+		t.Errorf("body = %q", msg.Body)
+	}
+	if len(msg.Attachments) != 1 || msg.Attachments[0].Filename != "report.csv" || msg.Attachments[0].ContentType != "text/csv" {
+		t.Fatalf("attachments = %+v", msg.Attachments)
+	}
+	if string(msg.Attachments[0].Data) != "region,total\nemea,42\n" {
+		t.Errorf("attachment data = %q", msg.Attachments[0].Data)
+	}
+}
+
+func TestSendGridParse_Rejected(t *testing.T) {
+	a, key := newTestSendGridAdapter(t)
+	_, otherKey := newTestSendGridAdapter(t)
+	body := readFixture(t, "sendgrid_inbound.multipart")
+	ts := strconv.FormatInt(testNow.Unix(), 10)
+	stale := strconv.FormatInt(testNow.Add(-10*time.Minute).Unix(), 10)
+	tampered := bytes.Replace(body, []byte("emea,42"), []byte("emea,99"), 1)
+
+	tests := []struct {
+		name      string
+		body      []byte
+		timestamp string
+		signature string
+	}{
+		{"missing signature", body, ts, ""},
+		{"signed by another key", body, ts, signSendGrid(t, otherKey, ts, body)},
+		{"tampered body", tampered, ts, signSendGrid(t, key, ts, body)},
+		{"signature not base64", body, ts, "not-base64!"},
+		{"stale timestamp", body, stale, signSendGrid(t, key, stale, body)},
+		{"timestamp not numeric", body, "yesterday", signSendGrid(t, key, "yesterday", body)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.Parse(newSendGridRequest(tt.body, tt.timestamp, tt.signature))
+			if !errors.Is(err, ErrUnauthorized) {
+				t.Errorf("err = %v, want ErrUnauthorized", err)
+			}
+		})
+	}
+}
+
+func TestSendGridParse_MalformedBody(t *testing.T) {
+	a, key := newTestSendGridAdapter(t)
+	ts := strconv.FormatInt(testNow.Unix(), 10)
+
+	tests := []struct {
+		name string
+		body []byte
+	}{
+		{"truncated multipart", readFixture(t, "sendgrid_inbound.multipart")[:200]},
+		{"invalid envelope", []byte("--" + sendGridBoundary + "\r\n" +
+			"Content-Disposition: form-data; name=\"envelope\"\r\n\r\n{not json\r\n" +
+			"--" + sendGridBoundary + "--\r\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.Parse(newSendGridRequest(tt.body, ts, signSendGrid(t, key, ts, tt.body)))
+			if !errors.Is(err, ErrInvalidPayload) {
+				t.Errorf("err = %v, want ErrInvalidPayload", err)
+			}
+		})
+	}
+}
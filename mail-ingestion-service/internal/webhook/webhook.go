@@ -0,0 +1,204 @@
+package webhook
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"mail-ingestion-service/internal/config"
+	"mail-ingestion-service/internal/service/ingest"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+const (
+	// maxFormMemory multipart 表单在内存中保留的大小，超出部分写入临时文件（请求结束后由 net/http 清理）
+	maxFormMemory = 8 << 20
+	// defaultMaxTimestampSkew 签名时间戳允许的最大偏差，超出视为重放
+	defaultMaxTimestampSkew = 5 * time.Minute
+)
+
+var (
+	// ErrUnauthorized 签名或认证信息校验失败
+	ErrUnauthorized = errors.New("webhook verification failed")
+	// ErrInvalidPayload 请求体不是该服务商的 inbound parse 格式
+	ErrInvalidPayload = errors.New("invalid webhook payload")
+)
+
+// Inbound 服务商推送的一封入站邮件
+type Inbound struct {
+	Recipients     []string // 信封收件人，用于确定所属用户
+	Message        *ingest.Message
+	IdempotencyKey string // 服务商提供的唯一 ID（可选）
+}
+
+// Adapter 将某个服务商的 inbound parse 请求校验并转换为 Inbound
+type Adapter interface {
+	Name() string
+	Parse(r *http.Request) (*Inbound, error)
+}
+
+// NewAdapters 根据配置创建已启用的适配器，未配置密钥的服务商不启用。
+// replay 用于拒绝 Mailgun 签名 token 的重放，可为 nil
+func NewAdapters(cfg config.WebhookConfig, replay ReplayGuard) (map[string]Adapter, error) {
+	maxSkew := defaultMaxTimestampSkew
+	if cfg.MaxTimestampSkewSeconds > 0 {
+		maxSkew = time.Duration(cfg.MaxTimestampSkewSeconds) * time.Second
+	}
+
+	adapters := make(map[string]Adapter)
+	if cfg.SendGrid.VerificationKey != "" {
+		a, err := NewSendGridAdapter(cfg.SendGrid.VerificationKey, maxSkew)
+		if err != nil {
+			return nil, err
+		}
+		adapters[a.Name()] = a
+	}
+	if cfg.Mailgun.SigningKey != "" {
+		a := NewMailgunAdapter(cfg.Mailgun.SigningKey, maxSkew)
+		if replay != nil {
+			a.WithReplayGuard(replay)
+		}
+		adapters[a.Name()] = a
+	}
+	if cfg.Postmark.Username != "" && cfg.Postmark.Password != "" {
+		a := NewPostmarkAdapter(cfg.Postmark.Username, cfg.Postmark.Password)
+		adapters[a.Name()] = a
+	}
+	return adapters, nil
+}
+
+// IdempotencyKey 返回入站邮件的幂等键：优先使用服务商提供的 ID，否则由邮件内容计算。
+// 服务商在部分收件人入库失败时会重试整个请求，已入库的收件人依靠该键（以及 Message-ID）去重，
+// 没有 Message-ID 的邮件也不会重复入库
+func IdempotencyKey(provider string, in *Inbound) string {
+	if in.IdempotencyKey != "" {
+		return in.IdempotencyKey
+	}
+	msg := in.Message
+	h := sha256.New()
+	for _, field := range []string{msg.MessageID, msg.From, strings.Join(msg.To, ","), msg.Subject, msg.Header.Get("Date"), msg.Body, msg.HTMLBody} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	for _, att := range msg.Attachments {
+		h.Write([]byte(att.Filename))
+		h.Write([]byte{0})
+		h.Write(att.Data)
+	}
+	return provider + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// invalidPayload 包装解析错误，保留原始错误（例如 http.MaxBytesError）
+func invalidPayload(err error) error {
+	return fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+}
+
+// parseForm 解析 multipart/form-data 或 application/x-www-form-urlencoded 表单
+func parseForm(r *http.Request) error {
+	err := r.ParseMultipartForm(maxFormMemory)
+	if errors.Is(err, http.ErrNotMultipart) {
+		err = r.ParseForm()
+	}
+	if err != nil {
+		return invalidPayload(err)
+	}
+	return nil
+}
+
+// checkTimestamp 校验 Unix 秒级时间戳是否在允许的偏差范围内
+func checkTimestamp(ts string, maxSkew time.Duration, now time.Time) error {
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrUnauthorized
+	}
+	skew := now.Sub(time.Unix(sec, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxSkew {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// readFormFile 读取表单中的上传文件，返回文件内容和声明的 Content-Type
+func readFormFile(form *multipart.Form, field string) ([]byte, *multipart.FileHeader, error) {
+	if form == nil || len(form.File[field]) == 0 {
+		return nil, nil, nil
+	}
+	fh := form.File[field][0]
+	f, err := fh.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, fh, nil
+}
+
+// parseHeaderBlock 解析原始邮件头文本（"Name: value" 按行排列）
+func parseHeaderBlock(raw string) mail.Header {
+	raw = strings.TrimRight(raw, "\r\n")
+	if raw == "" {
+		return mail.Header{}
+	}
+	m, err := mail.ReadMessage(strings.NewReader(raw + "\r\n\r\n"))
+	if err != nil {
+		return mail.Header{}
+	}
+	return m.Header
+}
+
+// splitAddresses 解析逗号分隔的地址列表，只保留 addr-spec
+func splitAddresses(value string) []string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	if list, err := mail.ParseAddressList(value); err == nil {
+		addrs := make([]string, 0, len(list))
+		for _, a := range list {
+			addrs = append(addrs, a.Address)
+		}
+		return addrs
+	}
+
+	var addrs []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if a, err := mail.ParseAddress(part); err == nil {
+			addrs = append(addrs, a.Address)
+		} else if part != "" {
+			addrs = append(addrs, strings.Trim(part, "<>"))
+		}
+	}
+	return addrs
+}
+
+// decodeCharset 将以 charset 编码的文本转换为 UTF-8，未知字符集时原样返回
+func decodeCharset(s, charset string) string {
+	if charset == "" || strings.EqualFold(charset, "utf-8") || strings.EqualFold(charset, "us-ascii") {
+		return s
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return s
+	}
+	decoded, err := enc.NewDecoder().String(s)
+	if err != nil {
+		return s
+	}
+	return decoded
+}
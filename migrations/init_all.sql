@@ -222,7 +222,7 @@ CREATE TABLE IF NOT EXISTS outbox_events (
     aggregate_id BIGINT,               -- 关联对象ID, optional
     routing_key VARCHAR(100) NOT NULL, -- MQ 路由键
     payload JSONB NOT NULL,            -- 最终要发布的事件
-    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending/sent/failed
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending/sent/failed/cancelled（邮件删除时取消未发布的事件）
     retry_count INT NOT NULL DEFAULT 0,
     next_retry_at TIMESTAMP,           -- 失败后的重试时间
     created_at TIMESTAMP NOT NULL DEFAULT NOW(),
@@ -243,6 +243,469 @@ CREATE INDEX IF NOT EXISTS idx_outbox_failed
 ON outbox_events (status) 
 WHERE status = 'failed';
 
+-- 索引：删除邮件时按 payload 中的 email_id 取消未发布的事件
+CREATE INDEX IF NOT EXISTS idx_outbox_email_id 
+ON outbox_events ((payload->>'email_id')) 
+WHERE status IN ('pending', 'failed');
+
+-- ==========================================================
+-- Migration 003: IMAP Mailboxes
+-- ==========================================================
+
+-- 用户配置的外部 IMAP 邮箱（mail-ingestion-service 定时轮询）
+CREATE TABLE IF NOT EXISTS mailboxes (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    host VARCHAR(255) NOT NULL,
+    port INT NOT NULL DEFAULT 993,
+    use_tls BOOLEAN NOT NULL DEFAULT TRUE,
+    username VARCHAR(255) NOT NULL,
+    password_encrypted TEXT NOT NULL,  -- AES-GCM 加密，密钥来自 imap.secret_key
+    folder VARCHAR(255) NOT NULL DEFAULT 'INBOX',
+    uid_validity BIGINT,               -- 服务器 UIDVALIDITY，变化时重置 last_uid
+    last_uid BIGINT NOT NULL DEFAULT 0, -- 已入库的最大 UID
+    last_sync_at TIMESTAMP,
+    last_error TEXT,                   -- 最近一次同步错误，成功后清空
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT mailboxes_unique_account UNIQUE (user_id, host, username, folder)
+);
+
+CREATE INDEX IF NOT EXISTS idx_mailboxes_user ON mailboxes(user_id);
+CREATE INDEX IF NOT EXISTS idx_mailboxes_active ON mailboxes(is_active) WHERE is_active = TRUE;
+
+-- ==========================================================
+-- Migration 004: Idempotent Ingestion
+-- ==========================================================
+
+-- Message-ID（去掉尖括号）和 HTTP Idempotency-Key，用于重复投递去重
+ALTER TABLE emails_raw ADD COLUMN IF NOT EXISTS message_id VARCHAR(998);
+ALTER TABLE emails_raw ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(255);
+
+-- 同一用户的同一封邮件只入库一次（模拟邮件没有 Message-ID，不参与去重）
+CREATE UNIQUE INDEX IF NOT EXISTS idx_emails_raw_unique_user_message_id
+    ON emails_raw(user_id, message_id) WHERE message_id IS NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_emails_raw_unique_user_idempotency_key
+    ON emails_raw(user_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+
+-- ==========================================================
+-- Migration 005: Email Attachments
+-- ==========================================================
+
+-- 附件元数据（内容存储在 blob store，按 sha256 内容寻址）
+CREATE TABLE IF NOT EXISTS email_attachments (
+    id SERIAL PRIMARY KEY,
+    email_id INT NOT NULL REFERENCES emails_raw(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    filename TEXT NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    storage_key TEXT NOT NULL,          -- blob store 中的对象 key
+    content_id VARCHAR(255),            -- 内联引用的 Content-ID
+    is_inline BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_attachments_email ON email_attachments(email_id);
+CREATE INDEX IF NOT EXISTS idx_email_attachments_sha256 ON email_attachments(sha256);
+
+-- ==========================================================
+-- Migration 006: Conversation Threads
+-- ==========================================================
+
+-- 会话线程（按 In-Reply-To / References 归并，缺失时按规范化主题归并）
+CREATE TABLE IF NOT EXISTS email_threads (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    subject TEXT NOT NULL,              -- 线程首封邮件的主题
+    normalized_subject TEXT NOT NULL,   -- 去掉 Re:/Fwd:/[tag] 前缀后的小写主题
+    message_count INT NOT NULL DEFAULT 0,
+    last_message_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE emails_raw ADD COLUMN IF NOT EXISTS thread_id INT REFERENCES email_threads(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_emails_raw_thread ON emails_raw(thread_id);
+CREATE INDEX IF NOT EXISTS idx_email_threads_user_last ON email_threads(user_id, last_message_at DESC);
+CREATE INDEX IF NOT EXISTS idx_email_threads_user_subject ON email_threads(user_id, normalized_subject);
+
+-- 与 mail-ingestion-service ingest.NormalizeSubject 相同的规范化：反复去掉回复/转发前缀和 [tag] 列表标签，
+-- 合并空白并转小写。回填的线程因此能和之后的回复按主题归并
+CREATE OR REPLACE FUNCTION normalize_email_subject(subject TEXT) RETURNS TEXT AS $$
+DECLARE
+    s TEXT := regexp_replace(COALESCE(subject, ''), '^\s+|\s+$', '', 'g');
+    prev TEXT;
+BEGIN
+    LOOP
+        prev := s;
+        s := regexp_replace(s, '^(re|fw|fwd|aw|wg|sv|vs|antw|回复|答复|转发)\s*(\[\d+\]|\(\d+\))?\s*[：:]\s*', '', 'i');  -- 冒号放在后面，避免 "[:" 被解析为字符类
+        s := regexp_replace(s, '^\[[^\]]*\]\s*', '');
+        EXIT WHEN s = prev;
+    END LOOP;
+    RETURN lower(btrim(regexp_replace(s, '\s+', ' ', 'g')));
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- 之前按 LOWER(TRIM(subject)) 回填的线程重新规范化
+UPDATE email_threads SET normalized_subject = normalize_email_subject(subject)
+WHERE normalized_subject <> normalize_email_subject(subject);
+
+-- 回填：已有邮件各自成为一个线程
+DO $$
+DECLARE
+    r RECORD;
+    new_thread_id INT;
+BEGIN
+    FOR r IN SELECT id, user_id, subject, created_at FROM emails_raw WHERE thread_id IS NULL LOOP
+        INSERT INTO email_threads (user_id, subject, normalized_subject, message_count, last_message_at, created_at)
+        VALUES (r.user_id, r.subject, normalize_email_subject(r.subject), 1, r.created_at, r.created_at)
+        RETURNING id INTO new_thread_id;
+        UPDATE emails_raw SET thread_id = new_thread_id WHERE id = r.id;
+    END LOOP;
+END $$;
+
+-- ==========================================================
+-- Migration 007: Bulk Import Jobs
+-- ==========================================================
+
+-- mbox / zip(.eml) 批量导入任务
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    filename TEXT NOT NULL,
+    format VARCHAR(10) NOT NULL,                    -- mbox / zip
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',  -- pending / running / completed / failed
+    total INT NOT NULL DEFAULT 0,
+    ingested INT NOT NULL DEFAULT 0,
+    duplicates INT NOT NULL DEFAULT 0,
+    failed INT NOT NULL DEFAULT 0,
+    error_message TEXT,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_jobs_user ON import_jobs(user_id);
+CREATE INDEX IF NOT EXISTS idx_import_jobs_status ON import_jobs(status);
+
+-- ==========================================================
+-- Migration 008: Sender Rules & Spam Filtering
+-- ==========================================================
+
+-- 被规则拦截 / 判定为垃圾邮件 / 跳过 AI 决策的邮件
+ALTER TYPE email_status ADD VALUE IF NOT EXISTS 'filtered';
+
+ALTER TABLE emails_raw ADD COLUMN IF NOT EXISTS spam_score INT NOT NULL DEFAULT 0;
+ALTER TABLE emails_raw ADD COLUMN IF NOT EXISTS filter_reason VARCHAR(50);  -- blocked / spam / skip_agent
+
+-- 用户级发件人规则（入库前评估，地址规则优先于域名规则）
+CREATE TABLE IF NOT EXISTS sender_rules (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    match_type VARCHAR(10) NOT NULL,   -- address / domain（domain 同时匹配子域名）
+    pattern VARCHAR(255) NOT NULL,     -- 小写地址或域名
+    action VARCHAR(20) NOT NULL,       -- allow / block / skip_agent / force_priority
+    priority VARCHAR(10),              -- force_priority 时使用：HIGH / MEDIUM / LOW
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, match_type, pattern)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sender_rules_user ON sender_rules(user_id);
+
+-- ==========================================================
+-- Migration 009: Per-user Ingestion Quotas
+-- ==========================================================
+
+-- 单个用户的入库限额覆盖（列为 NULL 时使用 quota 配置中的默认值）
+CREATE TABLE IF NOT EXISTS user_ingestion_quotas (
+    user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    emails_per_minute INT,
+    emails_per_day INT,
+    max_body_bytes BIGINT,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- ==========================================================
+-- Migration 010: Failed Event Retry
+-- ==========================================================
+
+-- outbox 事件达到最大重试次数后写入 failed_events，由重试 worker 按指数退避重新发布
+-- status: pending / retried / failed / discarded
+ALTER TABLE failed_events ADD COLUMN IF NOT EXISTS outbox_event_id BIGINT;
+ALTER TABLE failed_events ADD COLUMN IF NOT EXISTS next_retry_at TIMESTAMP;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_failed_events_outbox_event
+    ON failed_events(outbox_event_id) WHERE outbox_event_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_failed_events_next_retry
+    ON failed_events(next_retry_at) WHERE status = 'pending';
+
+-- ==========================================================
+-- Migration 011: Classification Rules
+-- ==========================================================
+
+-- 用户自定义分类规则：email-processor-service 在调用 agent 之前按 position 顺序评估
+-- conditions: {"sender", "subject_regex", "body_keywords", "headers": [{"name", "pattern"}]}，所有条件同时满足才命中
+-- actions:    {"categories", "priority", "create_task": {"title", "due_in_days"}, "notify": {"channel", "message"}}
+-- decisive:   命中后停止评估后续规则，并且不再调用 agent
+CREATE TABLE IF NOT EXISTS classification_rules (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    decisive BOOLEAN NOT NULL DEFAULT FALSE,
+    conditions JSONB NOT NULL,
+    actions JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_classification_rules_user
+    ON classification_rules(user_id, position, id) WHERE enabled = TRUE;
+
+-- 命中的规则（可解释性）：[{"rule_id", "name", "decisive"}]
+ALTER TABLE emails_metadata ADD COLUMN IF NOT EXISTS rule_hits JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+-- ==========================================================
+-- Migration 012: Decision Source & Re-classification Queue
+-- ==========================================================
+
+-- 决策来源：agent / rules / fallback（agent 不可用时的本地分类器）/ unknown
+ALTER TABLE emails_metadata ADD COLUMN IF NOT EXISTS decision_source VARCHAR(20) NOT NULL DEFAULT 'agent';
+
+-- 离线分类的邮件在 agent 恢复后重新发布 email.received.agent
+CREATE TABLE IF NOT EXISTS reclassify_queue (
+    email_id INT PRIMARY KEY REFERENCES emails_raw(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason VARCHAR(20) NOT NULL,      -- fallback
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_reclassify_queue_created ON reclassify_queue(created_at);
+
+-- ==========================================================
+-- Migration 013: Metadata Versioning
+-- ==========================================================
+
+-- 每次重新分类 version + 1，覆盖前的决策写入 emails_metadata_history
+ALTER TABLE emails_metadata ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS emails_metadata_history (
+    id SERIAL PRIMARY KEY,
+    email_id INT NOT NULL REFERENCES emails_raw(id) ON DELETE CASCADE,
+    version INT NOT NULL,
+    categories TEXT[] NOT NULL,
+    priority TEXT NOT NULL,
+    summary TEXT NOT NULL,
+    rule_hits JSONB NOT NULL DEFAULT '[]'::jsonb,
+    decision_source VARCHAR(20) NOT NULL,
+    decided_at TIMESTAMP NOT NULL,                -- 该版本决策的时间
+    archived_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE(email_id, version)
+);
+
+-- ==========================================================
+-- Migration 014: User Feedback
+-- ==========================================================
+
+-- 用户对 AI 决策的修正：
+--   classification: PATCH /emails/:id/metadata 修改分类 / 优先级（emails_metadata.decision_source 置为 user）
+--   task / notification: 标记自动创建的任务或发送的通知是错误的
+-- 最近的修正作为 few-shot 示例随 /decide 请求发送给 agent-service
+CREATE TABLE IF NOT EXISTS email_feedback (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email_id INT NOT NULL REFERENCES emails_raw(id) ON DELETE CASCADE,
+    feedback_type VARCHAR(20) NOT NULL,         -- classification / task / notification
+    original_categories TEXT[] NOT NULL DEFAULT '{}',
+    original_priority TEXT NOT NULL DEFAULT '',
+    original_source VARCHAR(20) NOT NULL DEFAULT '',
+    corrected_categories TEXT[],                -- 仅 classification
+    corrected_priority TEXT,                    -- 仅 classification
+    detail TEXT NOT NULL DEFAULT '',            -- 任务标题 / 通知内容
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_feedback_user ON email_feedback(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_email_feedback_email ON email_feedback(email_id);
+
+-- ==========================================================
+-- Migration 015: Multiple Tasks per Email
+-- ==========================================================
+
+-- 一封邮件可以提取多个任务（task.bulk_created），唯一性改为每封邮件的每个任务标题
+DROP INDEX IF EXISTS idx_tasks_unique_pending_email_user;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_unique_pending_email_title
+    ON tasks(email_id, user_id, title) WHERE status = 'pending' AND email_id IS NOT NULL;
+
+-- ==========================================================
+-- Migration 016: User Timezone & Absolute Task Deadlines
+-- ==========================================================
+
+-- 用户时区（IANA 名称，如 Asia/Shanghai），用于解析邮件中的相对截止时间和生成习惯任务
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+-- tasks.due_date: DATE → TIMESTAMPTZ（绝对截止时间，可带具体时间）
+-- 已有的日期迁移为当天结束（UTC）；逾期判断改为 due_date < NOW()
+DO $$ BEGIN
+    IF (SELECT data_type FROM information_schema.columns
+        WHERE table_name = 'tasks' AND column_name = 'due_date') = 'date' THEN
+        ALTER TABLE tasks ALTER COLUMN due_date TYPE TIMESTAMPTZ
+            USING (due_date + TIME '23:59:59') AT TIME ZONE 'UTC';
+    END IF;
+END $$;
+
+-- ==========================================================
+-- Migration 017: Email Full-Text Search
+-- ==========================================================
+
+-- 搜索向量：主题（A）> 摘要（B）> 正文（C），正文只取前 100000 个字符
+CREATE OR REPLACE FUNCTION email_search_vector(subject TEXT, body TEXT, summary TEXT)
+RETURNS TSVECTOR AS $$
+    SELECT setweight(to_tsvector('english', COALESCE(subject, '')), 'A')
+        || setweight(to_tsvector('english', COALESCE(summary, '')), 'B')
+        || setweight(to_tsvector('english', left(COALESCE(body, ''), 100000)), 'C');
+$$ LANGUAGE SQL IMMUTABLE;
+
+ALTER TABLE emails_raw ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;
+
+-- 邮件入库 / 修改主题正文时重新计算（摘要取当前元数据）
+CREATE OR REPLACE FUNCTION emails_raw_search_vector_trigger() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector := email_search_vector(
+        NEW.subject, NEW.body,
+        (SELECT summary FROM emails_metadata WHERE email_id = NEW.id));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_emails_raw_search_vector ON emails_raw;
+CREATE TRIGGER trg_emails_raw_search_vector
+    BEFORE INSERT OR UPDATE OF subject, body ON emails_raw
+    FOR EACH ROW EXECUTE FUNCTION emails_raw_search_vector_trigger();
+
+-- 分类（含重新分类、用户修正）写入摘要后更新搜索向量
+CREATE OR REPLACE FUNCTION emails_metadata_search_vector_trigger() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE emails_raw
+    SET search_vector = email_search_vector(subject, body, NEW.summary)
+    WHERE id = NEW.email_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_emails_metadata_search_vector ON emails_metadata;
+CREATE TRIGGER trg_emails_metadata_search_vector
+    AFTER INSERT OR UPDATE OF summary ON emails_metadata
+    FOR EACH ROW EXECUTE FUNCTION emails_metadata_search_vector_trigger();
+
+-- 回填已有邮件
+UPDATE emails_raw r
+SET search_vector = email_search_vector(r.subject, r.body,
+    (SELECT m.summary FROM emails_metadata m WHERE m.email_id = r.id))
+WHERE r.search_vector IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_emails_raw_search ON emails_raw USING GIN (search_vector);
+-- 搜索结果按时间倒序游标分页
+CREATE INDEX IF NOT EXISTS idx_emails_raw_user_created ON emails_raw(user_id, created_at DESC, id DESC);
+
+-- ==========================================================
+-- Migration 018: Email Archive & Delete
+-- ==========================================================
+
+ALTER TABLE emails_raw ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP;
+
+-- 删除邮件时由 api-gateway 显式处理任务（保留并解除关联，或删除未完成的任务），
+-- 外键改为 SET NULL，避免级联删除静默清掉任务
+ALTER TABLE tasks
+    DROP CONSTRAINT IF EXISTS tasks_email_id_fkey,
+    ADD CONSTRAINT tasks_email_id_fkey
+        FOREIGN KEY (email_id) REFERENCES emails_raw(id) ON DELETE SET NULL;
+
+-- 通知日志是审计记录，邮件删除后保留（email_id 置为 NULL）
+ALTER TABLE notifications_log ALTER COLUMN email_id DROP NOT NULL;
+ALTER TABLE notifications_log
+    DROP CONSTRAINT IF EXISTS notifications_log_email_id_fkey,
+    ADD CONSTRAINT notifications_log_email_id_fkey
+        FOREIGN KEY (email_id) REFERENCES emails_raw(id) ON DELETE SET NULL;
+
+-- email.deleted 后 mail-ingestion-service 回收不再被引用的附件 blob
+CREATE INDEX IF NOT EXISTS idx_email_attachments_storage_key ON email_attachments(storage_key);
+
+-- ==========================================================
+-- Migration 019: Sender Contacts
+-- ==========================================================
+
+-- 按发件人聚合的统计，由 email-processor-service 在每次分类后重新计算；is_vip 由用户设置
+CREATE TABLE IF NOT EXISTS contacts (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    address VARCHAR(320) NOT NULL,          -- 小写发件人地址
+    display_name TEXT NOT NULL DEFAULT '',
+    message_count INT NOT NULL DEFAULT 0,
+    avg_priority NUMERIC(3,2),              -- agent / 用户给出的优先级均值：LOW=1, MEDIUM=2, HIGH=3
+    task_rate NUMERIC(4,3) NOT NULL DEFAULT 0, -- 已分类邮件中产生任务的比例
+    -- 增量维护 avg_priority / task_rate 的累计值（每次分类只更新计数，不重新聚合历史邮件）
+    priority_sum INT NOT NULL DEFAULT 0,
+    priority_count INT NOT NULL DEFAULT 0,
+    task_count INT NOT NULL DEFAULT 0,
+    classified_count INT NOT NULL DEFAULT 0,
+    last_contact_at TIMESTAMP,
+    is_vip BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, address)
+);
+
+CREATE INDEX IF NOT EXISTS idx_contacts_user_last ON contacts(user_id, last_contact_at DESC);
+CREATE INDEX IF NOT EXISTS idx_emails_raw_user_sender ON emails_raw(user_id, lower(raw_json->>'from'));
+
+-- 回填已有邮件的发件人
+INSERT INTO contacts (user_id, address, display_name, message_count,
+                      priority_sum, priority_count, task_count, classified_count,
+                      avg_priority, task_rate, last_contact_at)
+SELECT user_id, address, display_name, message_count,
+       priority_sum, priority_count, task_count, classified_count,
+       CASE WHEN priority_count > 0 THEN priority_sum::numeric / priority_count END,
+       CASE WHEN classified_count > 0 THEN task_count::numeric / classified_count ELSE 0 END,
+       last_contact_at
+FROM (
+    SELECT r.user_id,
+           lower(r.raw_json->>'from') AS address,
+           COALESCE((array_agg(r.raw_json->>'from_name' ORDER BY r.created_at DESC)
+                     FILTER (WHERE COALESCE(r.raw_json->>'from_name', '') <> ''))[1], '') AS display_name,
+           COUNT(*) AS message_count,
+           COALESCE(SUM(CASE m.priority WHEN 'LOW' THEN 1 WHEN 'MEDIUM' THEN 2 WHEN 'HIGH' THEN 3 END)
+               FILTER (WHERE m.decision_source IN ('agent', 'user')), 0) AS priority_sum,
+           COUNT(m.priority) FILTER (WHERE m.decision_source IN ('agent', 'user')
+                                     AND m.priority IN ('LOW', 'MEDIUM', 'HIGH')) AS priority_count,
+           COUNT(*) FILTER (WHERE m.email_id IS NOT NULL
+                            AND EXISTS (SELECT 1 FROM tasks t WHERE t.email_id = r.id)) AS task_count,
+           COUNT(m.email_id) AS classified_count,
+           MAX(r.created_at) AS last_contact_at
+    FROM emails_raw r
+    LEFT JOIN emails_metadata m ON m.email_id = r.id
+    WHERE COALESCE(r.raw_json->>'from', '') <> ''
+    GROUP BY r.user_id, lower(r.raw_json->>'from')
+) s
+ON CONFLICT (user_id, address) DO NOTHING;
+
+-- ==========================================================
+-- Migration 020: Decision Cache Opt-out
+-- ==========================================================
+
+-- 模板化邮件复用之前的 agent 决策（Redis 缓存）；关闭后每封邮件都调用 agent
+ALTER TABLE users ADD COLUMN IF NOT EXISTS decision_cache_enabled BOOLEAN NOT NULL DEFAULT TRUE;
+
 -- ==========================================================
 -- Migration Complete
 -- ==========================================================
@@ -3,6 +3,7 @@ package mqhandler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 
 	"notification-service/internal/repository"
 	"notification-service/internal/service"
@@ -43,6 +44,14 @@ func (h *NotificationCreatedHandler) Handle(ctx context.Context, raw json.RawMes
 
 	// Insert notification to database
 	notificationID, err := h.repo.Insert(ctx, p.UserID, p.EmailID, p.Channel, p.Message)
+	if errors.Is(err, repository.ErrEmailNotFound) {
+		// 邮件在事件投递前已被删除：确认并跳过，不再发送通知
+		h.logger.Info("Email deleted before notification was created, skip",
+			zap.Int("user_id", p.UserID),
+			zap.Int("email_id", p.EmailID),
+		)
+		return nil
+	}
 	if err != nil {
 		h.logger.Error("Failed to insert notification", zap.Error(err))
 		return err
@@ -2,11 +2,16 @@ package repository
 
 import (
 	"context"
+	"errors"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 )
 
+// ErrEmailNotFound 通知关联的邮件已被删除（notifications.email_id 外键冲突）
+var ErrEmailNotFound = errors.New("email not found")
+
 type NotificationRepository struct {
 	db     *pgxpool.Pool
 	logger *zap.Logger
@@ -33,6 +38,10 @@ func (r *NotificationRepository) Insert(ctx context.Context, userID, emailID int
     `
 	var id int
 	err := r.db.QueryRow(ctx, query, userID, emailID, channel, message).Scan(&id)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" && pgErr.ConstraintName == "notifications_email_id_fkey" {
+		return 0, ErrEmailNotFound
+	}
 	if err != nil {
 		r.logger.Error("Failed to insert notification", zap.Error(err))
 		return 0, err
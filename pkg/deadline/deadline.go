@@ -0,0 +1,81 @@
+package deadline
+
+import (
+	"strings"
+	"time"
+
+	// 内嵌时区数据库，精简镜像中没有 /usr/share/zoneinfo 时 LoadLocation 仍然可用
+	_ "time/tzdata"
+)
+
+// DefaultTimezone 用户未设置时区时使用
+const DefaultTimezone = "UTC"
+
+// localLayouts 不带时区偏移的日期时间，按用户时区解析
+var localLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// ValidTimezone 检查是否为有效的 IANA 时区名（如 Asia/Shanghai）
+func ValidTimezone(name string) bool {
+	if strings.TrimSpace(name) == "" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// LoadLocation 加载用户时区，无效或为空时返回 UTC
+func LoadLocation(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// EndOfDay 返回 t 所在日期（loc 时区）的 23:59:59
+func EndOfDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 23, 59, 59, 0, loc)
+}
+
+// Parse 解析 ISO-8601 截止时间：
+//   - 带时区偏移（RFC 3339）：按原样使用
+//   - 不带偏移的日期时间：视为 loc 时区的本地时间
+//   - 只有日期：loc 时区当天结束
+func Parse(value string, loc *time.Location) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, true
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return EndOfDay(t, loc), true
+	}
+	return time.Time{}, false
+}
+
+// Resolve 计算绝对截止时间：优先使用明确的日期（Parse），否则为 base 所在日期（loc 时区）之后 dueInDays 天的当天结束
+func Resolve(dueDate string, dueInDays int, base time.Time, loc *time.Location) time.Time {
+	if t, ok := Parse(dueDate, loc); ok {
+		return t
+	}
+	if dueInDays < 0 {
+		dueInDays = 0
+	}
+	return EndOfDay(base.In(loc).AddDate(0, 0, dueInDays), loc)
+}
@@ -0,0 +1,77 @@
+// Package emailstore 多个服务共同读写的邮件表（emails_raw / emails_metadata）上的共享查询，
+// 保证 api-gateway 与 email-processor-service 使用同一份 SQL
+package emailstore
+
+import (
+	"context"
+	"encoding/json"
+
+	mqcontracts "mygoproject/contracts/mq"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// BuildAgentPayloadTx rebuilds the email.received payload of an existing email for re-classification.
+// The latest email.received.agent payload in the outbox is reused when available (it carries headers,
+// attachments and sender-rule overrides); otherwise the payload is rebuilt from emails_raw.
+func BuildAgentPayloadTx(ctx context.Context, tx pgx.Tx, emailID int) (*mqcontracts.EmailReceivedPayload, error) {
+	query := `
+        SELECT
+            r.user_id,
+            r.subject,
+            r.body,
+            COALESCE(r.raw_json->>'from', ''),
+            r.thread_id,
+            r.created_at,
+            (SELECT o.payload
+             FROM outbox_events o
+             WHERE o.aggregate_id = r.id AND o.routing_key = 'email.received.agent'
+             ORDER BY o.id DESC
+             LIMIT 1)
+        FROM emails_raw r
+        WHERE r.id = $1
+    `
+	var p mqcontracts.EmailReceivedPayload
+	var threadID *int
+	var original []byte
+	err := tx.QueryRow(ctx, query, emailID).Scan(
+		&p.UserID,
+		&p.Subject,
+		&p.Body,
+		&p.From,
+		&threadID,
+		&p.ReceivedAt,
+		&original,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(original) > 0 {
+		var prev mqcontracts.EmailReceivedPayload
+		if json.Unmarshal(original, &prev) == nil && prev.EmailID == emailID {
+			p = prev
+		}
+	}
+	p.EmailID = emailID
+	if threadID != nil {
+		p.ThreadID = *threadID
+	}
+	return &p, nil
+}
+
+// ArchiveMetadataTx copies the current decision of an email to emails_metadata_history before it is
+// overwritten. Callers bump emails_metadata.version in the same transaction; an email that has not
+// been classified yet is a no-op.
+func ArchiveMetadataTx(ctx context.Context, tx pgx.Tx, emailID int) error {
+	query := `
+        INSERT INTO emails_metadata_history
+            (email_id, version, categories, priority, summary, rule_hits, decision_source, decided_at)
+        SELECT email_id, version, categories, priority, summary, rule_hits, decision_source, updated_at
+        FROM emails_metadata
+        WHERE email_id = $1
+        ON CONFLICT (email_id, version) DO NOTHING
+    `
+	_, err := tx.Exec(ctx, query, emailID)
+	return err
+}
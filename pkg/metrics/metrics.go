@@ -66,6 +66,51 @@ var (
 		[]string{"status"}, // status: success, failed
 	)
 
+	// 入库前过滤计数
+	EmailFilteredCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "email_filtered_count",
+			Help: "Total number of emails filtered before the agent",
+		},
+		[]string{"reason"}, // reason: blocked, spam, skip_agent
+	)
+
+	// 分类规则命中计数
+	ClassificationRuleCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "classification_rule_count",
+			Help: "Total number of emails matched by user classification rules",
+		},
+		[]string{"outcome"}, // outcome: decisive（跳过 agent）, merged（与 agent 决策合并）
+	)
+
+	// 入库限流计数
+	IngestionThrottledCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ingestion_throttled_count",
+			Help: "Total number of ingestions rejected by per-user quotas",
+		},
+		[]string{"reason"}, // reason: per_minute, per_day, body_size
+	)
+
+	// agent 决策缓存计数
+	DecisionCacheCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "decision_cache_count",
+			Help: "Total number of agent decision cache lookups",
+		},
+		[]string{"result"}, // result: hit, miss, bypass（用户关闭或重新分类）
+	)
+
+	// agent 决策校验计数
+	DecisionValidationCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_decision_validation_count",
+			Help: "Total number of agent decision fields corrected or rejected by validation",
+		},
+		[]string{"field", "action"}, // action: sanitized（修正或丢弃后继续使用）, rejected（决策不可用）
+	)
+
 	// 慢查询计数
 	SlowQueryTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -106,6 +151,31 @@ func IncrementEmailProcessed(status string) {
 	EmailProcessedCount.WithLabelValues(status).Inc()
 }
 
+// IncrementEmailFiltered 增加入库前过滤计数
+func IncrementEmailFiltered(reason string) {
+	EmailFilteredCount.WithLabelValues(reason).Inc()
+}
+
+// IncrementClassificationRule 增加分类规则命中计数
+func IncrementClassificationRule(outcome string) {
+	ClassificationRuleCount.WithLabelValues(outcome).Inc()
+}
+
+// IncrementIngestionThrottled 增加入库限流计数
+func IncrementIngestionThrottled(reason string) {
+	IngestionThrottledCount.WithLabelValues(reason).Inc()
+}
+
+// IncrementDecisionCache 增加 agent 决策缓存计数
+func IncrementDecisionCache(result string) {
+	DecisionCacheCount.WithLabelValues(result).Inc()
+}
+
+// IncrementDecisionValidation 增加 agent 决策校验计数
+func IncrementDecisionValidation(field, action string) {
+	DecisionValidationCount.WithLabelValues(field, action).Inc()
+}
+
 // IncrementSlowQuery 增加慢查询计数
 func IncrementSlowQuery(sql string, duration time.Duration) {
 	// 截断 SQL 语句（避免标签值过长）
@@ -0,0 +1,164 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// errMissingBatchResult handler 返回的结果少于消息数
+var errMissingBatchResult = errors.New("batch handler returned no result for message")
+
+// BatchMessage 批量消费中的一条消息，Ctx 带有该消息自己的 trace context
+type BatchMessage struct {
+	Ctx  context.Context
+	Body json.RawMessage
+}
+
+// BatchMessageHandler 批量处理消息，返回值与 msgs 一一对应（nil 表示成功），每条消息分别 ack / nack。
+// ctx 随消费者停止而取消，用于整批共享的调用；单条消息的处理使用 BatchMessage.Ctx
+type BatchMessageHandler func(ctx context.Context, msgs []BatchMessage) []error
+
+// SetBatchHandler 启用 micro-batching：最多攒 maxSize 条消息，或第一条消息到达后等待 maxWait，
+// 然后一次性交给 handler 处理。设置后 SetHandler 的单条处理函数不再使用
+func (c *Consumer) SetBatchHandler(h BatchMessageHandler, maxSize int, maxWait time.Duration) {
+	if maxSize <= 0 {
+		maxSize = 1
+	}
+	c.batchHandler = h
+	c.batchSize = maxSize
+	c.batchWait = maxWait
+}
+
+// pendingDelivery 已收到、等待批量处理的消息
+type pendingDelivery struct {
+	msg   amqp091.Delivery
+	ctx   context.Context
+	span  oteltrace.Span
+	start time.Time
+}
+
+// consumeBatches 批量消费循环
+func (c *Consumer) consumeBatches(deliveries <-chan amqp091.Delivery) error {
+	for {
+		// 等待一批中的第一条消息
+		var first amqp091.Delivery
+		select {
+		case <-c.ctx.Done():
+			c.logger.Info("Consumer stopping, waiting for current messages to complete...",
+				zap.String("routing_key", c.routingKey),
+			)
+			close(c.stopChan)
+			return nil
+		case msg, ok := <-deliveries:
+			if !ok {
+				c.logger.Info("Consumer channel closed",
+					zap.String("routing_key", c.routingKey),
+				)
+				close(c.stopChan)
+				return nil
+			}
+			first = msg
+		}
+
+		batch := []pendingDelivery{c.newPendingDelivery(first)}
+		closed := c.fillBatch(deliveries, &batch)
+		c.processBatch(batch)
+
+		if closed {
+			c.logger.Info("Consumer channel closed",
+				zap.String("routing_key", c.routingKey),
+			)
+			close(c.stopChan)
+			return nil
+		}
+	}
+}
+
+// fillBatch 继续收集消息，直到凑满一批或等待超时；停止消费时立即把已收到的消息交给 handler
+// （整批共享的调用随之取消，由 handler 决定重新入队）。deliveries 关闭时返回 true
+func (c *Consumer) fillBatch(deliveries <-chan amqp091.Delivery, batch *[]pendingDelivery) bool {
+	timer := time.NewTimer(c.batchWait)
+	defer timer.Stop()
+
+	for len(*batch) < c.batchSize {
+		select {
+		case <-timer.C:
+			return false
+		case <-c.ctx.Done():
+			return false
+		case msg, ok := <-deliveries:
+			if !ok {
+				return true
+			}
+			*batch = append(*batch, c.newPendingDelivery(msg))
+		}
+	}
+	return false
+}
+
+func (c *Consumer) newPendingDelivery(msg amqp091.Delivery) pendingDelivery {
+	ctx, span := c.messageContext(msg)
+	return pendingDelivery{msg: msg, ctx: ctx, span: span, start: time.Now()}
+}
+
+// processBatch 调用批量 handler 并逐条确认消息
+func (c *Consumer) processBatch(batch []pendingDelivery) {
+	// 逐条记录是否已确认：panic 时只拒绝尚未确认的消息，避免消息一直处于 unacked 状态
+	settled := make([]bool, len(batch))
+	defer func() {
+		for _, d := range batch {
+			d.span.End()
+		}
+	}()
+
+	// Panic 恢复：尚未确认的消息拒绝并重新入队
+	defer func() {
+		if r := recover(); r != nil {
+			c.logger.Error("Batch handler panic recovered",
+				zap.String("routing_key", c.routingKey),
+				zap.String("queue", c.queue.Name),
+				zap.Int("batch_size", len(batch)),
+				zap.Any("panic", r),
+			)
+			for i, d := range batch {
+				if settled[i] {
+					continue
+				}
+				if err := d.msg.Nack(false, true); err != nil {
+					c.logger.Error("Failed to nack message after panic",
+						zap.String("routing_key", c.routingKey),
+						zap.Error(err),
+					)
+				}
+			}
+		}
+	}()
+
+	msgs := make([]BatchMessage, len(batch))
+	for i, d := range batch {
+		msgs[i] = BatchMessage{Ctx: d.ctx, Body: d.msg.Body}
+	}
+
+	errs := c.batchHandler(c.ctx, msgs)
+	for i, d := range batch {
+		// handler 没有给出结果的消息不能视为成功，重新入队
+		err := errMissingBatchResult
+		if i < len(errs) {
+			err = errs[i]
+		}
+		// 先标记再确认：settle 中途 panic 时不再重复确认同一条消息
+		settled[i] = true
+		c.settle(d.msg, d.span, err, d.start)
+	}
+
+	c.logger.Debug("Batch processed",
+		zap.String("routing_key", c.routingKey),
+		zap.Int("batch_size", len(batch)),
+	)
+}
@@ -0,0 +1,227 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+	"mygoproject/pkg/trace"
+)
+
+// fakeAcknowledger 记录每条消息的确认结果；panicOnAck 模拟确认时 panic
+type fakeAcknowledger struct {
+	mu         sync.Mutex
+	results    map[uint64]string
+	panicOnAck uint64
+}
+
+func newFakeAcknowledger() *fakeAcknowledger {
+	return &fakeAcknowledger{results: make(map[uint64]string)}
+}
+
+func (a *fakeAcknowledger) record(tag uint64, result string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if prev, ok := a.results[tag]; ok {
+		result = prev + "," + result
+	}
+	a.results[tag] = result
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	if tag == a.panicOnAck {
+		panic("ack failed")
+	}
+	a.record(tag, "ack")
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.record(tag, fmt.Sprintf("nack(requeue=%v)", requeue))
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	a.record(tag, fmt.Sprintf("reject(requeue=%v)", requeue))
+	return nil
+}
+
+func newTestBatchConsumer(t *testing.T, size int, wait time.Duration) *Consumer {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return &Consumer{
+		queue:      amqp091.Queue{Name: "test.q"},
+		routingKey: "test",
+		logger:     zap.NewNop(),
+		maxRetries: 3,
+		ctx:        ctx,
+		cancel:     cancel,
+		stopChan:   make(chan struct{}),
+		batchSize:  size,
+		batchWait:  wait,
+	}
+}
+
+func newTestDelivery(ack *fakeAcknowledger, tag uint64) amqp091.Delivery {
+	return amqp091.Delivery{
+		Acknowledger: ack,
+		DeliveryTag:  tag,
+		Body:         []byte(fmt.Sprintf(`{"id": %d, "trace_id": "trace-%d"}`, tag, tag)),
+	}
+}
+
+func TestFillBatch_FlushesWhenFull(t *testing.T) {
+	c := newTestBatchConsumer(t, 3, time.Hour)
+	ack := newFakeAcknowledger()
+	deliveries := make(chan amqp091.Delivery, 5)
+	for tag := uint64(2); tag <= 5; tag++ {
+		deliveries <- newTestDelivery(ack, tag)
+	}
+
+	batch := []pendingDelivery{c.newPendingDelivery(newTestDelivery(ack, 1))}
+	if closed := c.fillBatch(deliveries, &batch); closed {
+		t.Fatal("fillBatch reported closed channel")
+	}
+	if len(batch) != 3 {
+		t.Fatalf("batch size = %d, want 3", len(batch))
+	}
+	if len(deliveries) != 2 {
+		t.Errorf("remaining deliveries = %d, want 2", len(deliveries))
+	}
+}
+
+func TestFillBatch_FlushesAfterTimeout(t *testing.T) {
+	c := newTestBatchConsumer(t, 10, 20*time.Millisecond)
+	ack := newFakeAcknowledger()
+	deliveries := make(chan amqp091.Delivery, 1)
+	deliveries <- newTestDelivery(ack, 2)
+
+	batch := []pendingDelivery{c.newPendingDelivery(newTestDelivery(ack, 1))}
+	start := time.Now()
+	if closed := c.fillBatch(deliveries, &batch); closed {
+		t.Fatal("fillBatch reported closed channel")
+	}
+	if len(batch) != 2 {
+		t.Errorf("batch size = %d, want 2", len(batch))
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("flushed after %v, before batch wait", elapsed)
+	}
+}
+
+func TestFillBatch_ChannelClosed(t *testing.T) {
+	c := newTestBatchConsumer(t, 10, time.Hour)
+	ack := newFakeAcknowledger()
+	deliveries := make(chan amqp091.Delivery, 1)
+	deliveries <- newTestDelivery(ack, 2)
+	close(deliveries)
+
+	batch := []pendingDelivery{c.newPendingDelivery(newTestDelivery(ack, 1))}
+	if closed := c.fillBatch(deliveries, &batch); !closed {
+		t.Error("fillBatch did not report closed channel")
+	}
+	if len(batch) != 2 {
+		t.Errorf("batch size = %d, want 2", len(batch))
+	}
+}
+
+func TestFillBatch_StopsWithConsumer(t *testing.T) {
+	c := newTestBatchConsumer(t, 10, time.Hour)
+	ack := newFakeAcknowledger()
+	c.cancel()
+
+	batch := []pendingDelivery{c.newPendingDelivery(newTestDelivery(ack, 1))}
+	if closed := c.fillBatch(make(chan amqp091.Delivery), &batch); closed {
+		t.Error("fillBatch reported closed channel")
+	}
+	if len(batch) != 1 {
+		t.Errorf("batch size = %d, want 1", len(batch))
+	}
+}
+
+func TestProcessBatch_SettlesEachDelivery(t *testing.T) {
+	c := newTestBatchConsumer(t, 3, time.Hour)
+	ack := newFakeAcknowledger()
+
+	var gotCtx context.Context
+	var gotMsgs []BatchMessage
+	c.batchHandler = func(ctx context.Context, msgs []BatchMessage) []error {
+		gotCtx, gotMsgs = ctx, msgs
+		// 第三条没有返回值，视为失败
+		return []error{nil, errors.New("agent service 5xx: 503")}
+	}
+
+	batch := []pendingDelivery{
+		c.newPendingDelivery(newTestDelivery(ack, 1)),
+		c.newPendingDelivery(newTestDelivery(ack, 2)),
+		c.newPendingDelivery(newTestDelivery(ack, 3)),
+	}
+	c.processBatch(batch)
+
+	want := map[uint64]string{1: "ack", 2: "nack(requeue=true)", 3: "nack(requeue=true)"}
+	for tag, result := range want {
+		if got := ack.results[tag]; got != result {
+			t.Errorf("delivery %d: %q, want %q", tag, got, result)
+		}
+	}
+	if gotCtx != c.ctx {
+		t.Error("handler did not receive the consumer context")
+	}
+	if len(gotMsgs) != 3 {
+		t.Fatalf("handler got %d messages, want 3", len(gotMsgs))
+	}
+	for i, msg := range gotMsgs {
+		if got, want := trace.FromContext(msg.Ctx), fmt.Sprintf("trace-%d", i+1); got != want {
+			t.Errorf("message %d trace id = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestProcessBatch_HandlerPanicRequeuesAll(t *testing.T) {
+	c := newTestBatchConsumer(t, 2, time.Hour)
+	ack := newFakeAcknowledger()
+	c.batchHandler = func(ctx context.Context, msgs []BatchMessage) []error {
+		panic("handler failed")
+	}
+
+	c.processBatch([]pendingDelivery{
+		c.newPendingDelivery(newTestDelivery(ack, 1)),
+		c.newPendingDelivery(newTestDelivery(ack, 2)),
+	})
+
+	for _, tag := range []uint64{1, 2} {
+		if got := ack.results[tag]; got != "nack(requeue=true)" {
+			t.Errorf("delivery %d: %q, want nack(requeue=true)", tag, got)
+		}
+	}
+}
+
+func TestProcessBatch_SettlePanicRequeuesOnlyUnsettled(t *testing.T) {
+	c := newTestBatchConsumer(t, 4, time.Hour)
+	ack := newFakeAcknowledger()
+	ack.panicOnAck = 2
+	c.batchHandler = func(ctx context.Context, msgs []BatchMessage) []error {
+		return make([]error, len(msgs))
+	}
+
+	c.processBatch([]pendingDelivery{
+		c.newPendingDelivery(newTestDelivery(ack, 1)),
+		c.newPendingDelivery(newTestDelivery(ack, 2)),
+		c.newPendingDelivery(newTestDelivery(ack, 3)),
+		c.newPendingDelivery(newTestDelivery(ack, 4)),
+	})
+
+	// 第 1 条已确认，第 2 条确认时 panic（不再重复确认），其余重新入队
+	want := map[uint64]string{1: "ack", 2: "", 3: "nack(requeue=true)", 4: "nack(requeue=true)"}
+	for tag, result := range want {
+		if got := ack.results[tag]; got != result {
+			t.Errorf("delivery %d: %q, want %q", tag, got, result)
+		}
+	}
+}
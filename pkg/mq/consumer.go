@@ -9,6 +9,7 @@ import (
 
 	"github.com/rabbitmq/amqp091-go"
 	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"mygoproject/pkg/metrics"
 	"mygoproject/pkg/otel"
@@ -34,6 +35,11 @@ type Consumer struct {
 	ctx          context.Context
 	cancel       context.CancelFunc
 	stopChan     chan struct{}
+
+	// 批量模式（SetBatchHandler）
+	batchHandler BatchMessageHandler
+	batchSize    int
+	batchWait    time.Duration
 }
 
 // NewConsumer creates a consumer for a specific routing key.
@@ -156,10 +162,17 @@ func (c *Consumer) IsConnected() bool {
 
 // StartConsuming starts consuming messages. This method blocks and should be called in a goroutine.
 func (c *Consumer) StartConsuming() error {
-	if c.handler == nil {
+	if c.handler == nil && c.batchHandler == nil {
 		return fmt.Errorf("consumer handler not set")
 	}
 
+	// 批量模式：预取数量至少为一批，否则凑不满
+	if c.batchHandler != nil {
+		if err := c.channel.Qos(c.batchSize, 0, false); err != nil {
+			return fmt.Errorf("failed to set qos: %w", err)
+		}
+	}
+
 	deliveries, err := c.channel.Consume(
 		c.queue.Name,
 		"worker",
@@ -178,6 +191,10 @@ func (c *Consumer) StartConsuming() error {
 		zap.String("queue", c.queue.Name),
 	)
 
+	if c.batchHandler != nil {
+		return c.consumeBatches(deliveries)
+	}
+
 	// 最安全的消费模型：保证每条消息都会被 ack 或 nack
 	for {
 		select {
@@ -199,173 +216,164 @@ func (c *Consumer) StartConsuming() error {
 				// 记录消费开始时间
 				consumeStart := time.Now()
 
-				// 从消息头中提取 OpenTelemetry trace context
-				ctx := context.Background()
-				propagator := otel.GetTextMapPropagator()
-				carrier := otel.NewMQHeaderCarrier(msg.Headers)
-				ctx = propagator.Extract(ctx, carrier)
-
-				// 创建 OpenTelemetry span
-				ctx, span := otel.MQConsumeSpan(ctx, c.routingKey, c.queue.Name)
+				ctx, span := c.messageContext(msg)
 				defer span.End()
 
-				// 向后兼容：从消息头中提取 trace_id 并添加到 context（用于日志）
-				if traceIDHeader, ok := msg.Headers["x-trace-id"]; ok {
-					if traceID, ok := traceIDHeader.(string); ok && traceID != "" {
-						ctx = trace.WithContext(ctx, traceID)
-					}
-				}
-
-				// 如果消息头中没有 trace_id，尝试从 payload 中提取（向后兼容）
-				if traceID := trace.FromContext(ctx); traceID == "" {
-					var payloadWithTrace struct {
-						TraceID string `json:"trace_id"`
-					}
-					if err := json.Unmarshal(msg.Body, &payloadWithTrace); err == nil && payloadWithTrace.TraceID != "" {
-						ctx = trace.WithContext(ctx, payloadWithTrace.TraceID)
-					}
-				}
-
-				traceID := trace.FromContext(ctx)
-				c.logger.Debug("Received message",
-					zap.String("routing_key", c.routingKey),
-					zap.String("queue", c.queue.Name),
-					zap.Int("message_size", len(msg.Body)),
-					zap.String("trace_id", traceID),
-				)
-
-			// Panic 恢复：确保即使 handler panic 也能正确处理消息
-			defer func() {
-				if r := recover(); r != nil {
-					c.logger.Error("Handler panic recovered",
-						zap.String("routing_key", c.routingKey),
-						zap.String("queue", c.queue.Name),
-						zap.Any("panic", r),
-					)
-					// Panic → 拒绝消息并重新入队
-					if err := msg.Nack(false, true); err != nil {
-						c.logger.Error("Failed to nack message after panic",
+				// Panic 恢复：确保即使 handler panic 也能正确处理消息
+				defer func() {
+					if r := recover(); r != nil {
+						c.logger.Error("Handler panic recovered",
 							zap.String("routing_key", c.routingKey),
-							zap.Error(err),
+							zap.String("queue", c.queue.Name),
+							zap.Any("panic", r),
 						)
+						// Panic → 拒绝消息并重新入队
+						if err := msg.Nack(false, true); err != nil {
+							c.logger.Error("Failed to nack message after panic",
+								zap.String("routing_key", c.routingKey),
+								zap.Error(err),
+							)
+						}
+						// 记录消费延迟
+						consumeLatency := time.Since(consumeStart)
+						metrics.RecordMQConsumeLatency(c.routingKey, c.queue.Name, consumeLatency)
 					}
-					// 记录消费延迟
-					consumeLatency := time.Since(consumeStart)
-					metrics.RecordMQConsumeLatency(c.routingKey, c.queue.Name, consumeLatency)
-				}
+				}()
+
+				// 执行业务处理
+				c.settle(msg, span, c.handler(ctx, msg.Body), consumeStart)
 			}()
+		}
+	}
+}
 
-			// 获取重试次数（从消息头）
-			retryCount := 0
-			if retryHeader, ok := msg.Headers["x-retry-count"]; ok {
-				if count, ok := retryHeader.(int64); ok {
-					retryCount = int(count)
-				}
-			}
+// messageContext 从消息头中提取 trace context 并创建消费 span
+func (c *Consumer) messageContext(msg amqp091.Delivery) (context.Context, oteltrace.Span) {
+	// 从消息头中提取 OpenTelemetry trace context
+	ctx := context.Background()
+	propagator := otel.GetTextMapPropagator()
+	carrier := otel.NewMQHeaderCarrier(msg.Headers)
+	ctx = propagator.Extract(ctx, carrier)
+
+	// 创建 OpenTelemetry span
+	ctx, span := otel.MQConsumeSpan(ctx, c.routingKey, c.queue.Name)
+
+	// 向后兼容：从消息头中提取 trace_id 并添加到 context（用于日志）
+	if traceIDHeader, ok := msg.Headers["x-trace-id"]; ok {
+		if traceID, ok := traceIDHeader.(string); ok && traceID != "" {
+			ctx = trace.WithContext(ctx, traceID)
+		}
+	}
 
-			// 执行业务处理
-			if err := c.handler(ctx, msg.Body); err != nil {
-				span.RecordError(err)
-				span.SetStatus(codes.Error, err.Error())
-				c.logger.Error("Handler error",
-					zap.String("routing_key", c.routingKey),
-					zap.String("queue", c.queue.Name),
-					zap.Int("retry_count", retryCount),
-					zap.Error(err),
-				)
+	// 如果消息头中没有 trace_id，尝试从 payload 中提取（向后兼容）
+	if traceID := trace.FromContext(ctx); traceID == "" {
+		var payloadWithTrace struct {
+			TraceID string `json:"trace_id"`
+		}
+		if err := json.Unmarshal(msg.Body, &payloadWithTrace); err == nil && payloadWithTrace.TraceID != "" {
+			ctx = trace.WithContext(ctx, payloadWithTrace.TraceID)
+		}
+	}
 
-				// 检查错误类型：JSON 解析错误直接发送到 DLQ，不重试
-				errStr := err.Error()
-				if contains(errStr, "json_unmarshal_error") || contains(errStr, "json:") {
-					c.logger.Warn("JSON unmarshal error, sending directly to DLQ",
-						zap.String("routing_key", c.routingKey),
-						zap.Error(err),
-					)
-					
-					// 发送到死信队列
-					if dlqErr := c.dlqPublisher.PublishToDLQ(c.routingKey, msg.Body, err.Error()); dlqErr != nil {
-						c.logger.Error("Failed to publish to DLQ",
-							zap.String("routing_key", c.routingKey),
-							zap.Error(dlqErr),
-						)
-					}
-					
-					// Ack 掉原消息（已发送到 DLQ）
-					if err := msg.Ack(false); err != nil {
-						c.logger.Error("Failed to ack message after DLQ",
-							zap.String("routing_key", c.routingKey),
-							zap.Error(err),
-						)
-					}
-					// 记录消费延迟
-					consumeLatency := time.Since(consumeStart)
-					metrics.RecordMQConsumeLatency(c.routingKey, c.queue.Name, consumeLatency)
-					return
-				}
-
-				// 检查是否超过最大重试次数
-				if retryCount >= c.maxRetries {
-					c.logger.Warn("Max retries exceeded, sending to DLQ",
-						zap.String("routing_key", c.routingKey),
-						zap.Int("retry_count", retryCount),
-						zap.Int("max_retries", c.maxRetries),
-					)
-					
-					// 发送到死信队列
-					if dlqErr := c.dlqPublisher.PublishToDLQ(c.routingKey, msg.Body, err.Error()); dlqErr != nil {
-						c.logger.Error("Failed to publish to DLQ",
-							zap.String("routing_key", c.routingKey),
-							zap.Error(dlqErr),
-						)
-					}
-					
-					// Ack 掉原消息（已发送到 DLQ）
-					if err := msg.Ack(false); err != nil {
-						c.logger.Error("Failed to ack message after DLQ",
-							zap.String("routing_key", c.routingKey),
-							zap.Error(err),
-						)
-					}
-					// 记录消费延迟
-					consumeLatency := time.Since(consumeStart)
-					metrics.RecordMQConsumeLatency(c.routingKey, c.queue.Name, consumeLatency)
-					return
-				}
-
-				// 未超过最大重试次数 → 拒绝消息并重新入队
-				// 注意：RabbitMQ 不会自动增加重试计数，需要手动设置
-				if err := msg.Nack(false, true); err != nil {
-					c.logger.Error("Failed to nack message",
-						zap.String("routing_key", c.routingKey),
-						zap.Error(err),
-					)
-				}
-				// 记录消费延迟
-				consumeLatency := time.Since(consumeStart)
-				metrics.RecordMQConsumeLatency(c.routingKey, c.queue.Name, consumeLatency)
-				return
-			}
+	traceID := trace.FromContext(ctx)
+	c.logger.Debug("Received message",
+		zap.String("routing_key", c.routingKey),
+		zap.String("queue", c.queue.Name),
+		zap.Int("message_size", len(msg.Body)),
+		zap.String("trace_id", traceID),
+	)
+	return ctx, span
+}
 
-			// Handler 成功 → 确认消息
-			span.SetStatus(codes.Ok, "message processed successfully")
-			if err := msg.Ack(false); err != nil {
-				span.RecordError(err)
-				c.logger.Error("Failed to ack message",
-					zap.String("routing_key", c.routingKey),
-					zap.Error(err),
-				)
-			} else {
-				c.logger.Debug("Message processed successfully",
-					zap.String("routing_key", c.routingKey),
-					zap.String("queue", c.queue.Name),
-				)
-			}
-			// 记录消费延迟
-			consumeLatency := time.Since(consumeStart)
-			metrics.RecordMQConsumeLatency(c.routingKey, c.queue.Name, consumeLatency)
-		}()
+// settle 根据处理结果确认消息：成功 ack；JSON 错误或超过最大重试次数发送到 DLQ 后 ack；其他错误 nack 重新入队
+func (c *Consumer) settle(msg amqp091.Delivery, span oteltrace.Span, err error, consumeStart time.Time) {
+	// 记录消费延迟
+	defer func() {
+		metrics.RecordMQConsumeLatency(c.routingKey, c.queue.Name, time.Since(consumeStart))
+	}()
+
+	// 获取重试次数（从消息头）
+	retryCount := 0
+	if retryHeader, ok := msg.Headers["x-retry-count"]; ok {
+		if count, ok := retryHeader.(int64); ok {
+			retryCount = int(count)
 		}
 	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.logger.Error("Handler error",
+			zap.String("routing_key", c.routingKey),
+			zap.String("queue", c.queue.Name),
+			zap.Int("retry_count", retryCount),
+			zap.Error(err),
+		)
+
+		// 检查错误类型：JSON 解析错误直接发送到 DLQ，不重试
+		errStr := err.Error()
+		if contains(errStr, "json_unmarshal_error") || contains(errStr, "json:") {
+			c.logger.Warn("JSON unmarshal error, sending directly to DLQ",
+				zap.String("routing_key", c.routingKey),
+				zap.Error(err),
+			)
+			c.sendToDLQ(msg, err)
+			return
+		}
+
+		// 检查是否超过最大重试次数
+		if retryCount >= c.maxRetries {
+			c.logger.Warn("Max retries exceeded, sending to DLQ",
+				zap.String("routing_key", c.routingKey),
+				zap.Int("retry_count", retryCount),
+				zap.Int("max_retries", c.maxRetries),
+			)
+			c.sendToDLQ(msg, err)
+			return
+		}
+
+		// 未超过最大重试次数 → 拒绝消息并重新入队
+		// 注意：RabbitMQ 不会自动增加重试计数，需要手动设置
+		if err := msg.Nack(false, true); err != nil {
+			c.logger.Error("Failed to nack message",
+				zap.String("routing_key", c.routingKey),
+				zap.Error(err),
+			)
+		}
+		return
+	}
+
+	// Handler 成功 → 确认消息
+	span.SetStatus(codes.Ok, "message processed successfully")
+	if err := msg.Ack(false); err != nil {
+		span.RecordError(err)
+		c.logger.Error("Failed to ack message",
+			zap.String("routing_key", c.routingKey),
+			zap.Error(err),
+		)
+	} else {
+		c.logger.Debug("Message processed successfully",
+			zap.String("routing_key", c.routingKey),
+			zap.String("queue", c.queue.Name),
+		)
+	}
+}
+
+// sendToDLQ 发送到死信队列，并 ack 掉原消息
+func (c *Consumer) sendToDLQ(msg amqp091.Delivery, cause error) {
+	if dlqErr := c.dlqPublisher.PublishToDLQ(c.routingKey, msg.Body, cause.Error()); dlqErr != nil {
+		c.logger.Error("Failed to publish to DLQ",
+			zap.String("routing_key", c.routingKey),
+			zap.Error(dlqErr),
+		)
+	}
+
+	if err := msg.Ack(false); err != nil {
+		c.logger.Error("Failed to ack message after DLQ",
+			zap.String("routing_key", c.routingKey),
+			zap.Error(err),
+		)
+	}
 }
 
 // Stop gracefully stops the consumer
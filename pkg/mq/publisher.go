@@ -108,3 +108,23 @@ func (p *Publisher) PublishWithContext(ctx context.Context, routingKey string, p
 	}
 	return err
 }
+
+// QueueDepth returns the number of ready messages in the given queue.
+// 使用独立 channel 做被动声明：队列不存在时 broker 会关闭该 channel，不影响发布用的 channel
+func (p *Publisher) QueueDepth(queueName string) (int, error) {
+	if !p.IsConnected() {
+		return 0, fmt.Errorf("publisher not connected")
+	}
+
+	ch, err := p.conn.Channel()
+	if err != nil {
+		return 0, fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer ch.Close()
+
+	q, err := ch.QueueDeclarePassive(queueName, true, false, false, false, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect queue %s: %w", queueName, err)
+	}
+	return q.Messages, nil
+}
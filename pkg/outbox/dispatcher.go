@@ -12,6 +12,9 @@ import (
 	"go.uber.org/zap"
 )
 
+// FailureHandler 在事件达到最大重试次数、被标记为 failed 时调用
+type FailureHandler func(ctx context.Context, event *Event, err error)
+
 // Dispatcher 负责从 outbox 中读取事件并发布到 MQ
 type Dispatcher struct {
 	repo      *Repository
@@ -20,6 +23,7 @@ type Dispatcher struct {
 	maxRetries int
 	interval   time.Duration
 	batchSize  int
+	onFailure  FailureHandler
 }
 
 // NewDispatcher 创建新的 Dispatcher
@@ -56,6 +60,12 @@ func (d *Dispatcher) WithBatchSize(batchSize int) *Dispatcher {
 	return d
 }
 
+// WithFailureHandler 设置事件最终失败时的回调（例如写入 failed_events 以便后续重试）
+func (d *Dispatcher) WithFailureHandler(handler FailureHandler) *Dispatcher {
+	d.onFailure = handler
+	return d
+}
+
 // Start 启动 Dispatcher（在 goroutine 中运行）
 func (d *Dispatcher) Start(ctx context.Context) {
 	d.logger.Info("Starting Outbox Dispatcher",
@@ -103,11 +113,14 @@ func (d *Dispatcher) processPendingEvents(ctx context.Context) {
 			)
 			
 			// 标记为失败或增加重试次数
-			if err := d.repo.MarkAsFailed(ctx, event.ID, d.maxRetries); err != nil {
+			if markErr := d.repo.MarkAsFailed(ctx, event.ID, d.maxRetries); markErr != nil {
 				d.logger.Error("Failed to mark event as failed",
 					zap.Int64("event_id", event.ID),
-					zap.Error(err),
+					zap.Error(markErr),
 				)
+			} else if event.RetryCount+1 >= d.maxRetries && d.onFailure != nil {
+				// 已达到最大重试次数，交给调用方处理
+				d.onFailure(ctx, event, err)
 			}
 			continue
 		}
@@ -244,3 +244,18 @@ func (r *Repository) GetFailedEvents(ctx context.Context, limit int) ([]*Event,
 
 	return events, rows.Err()
 }
+
+// CountPending 统计指定路由键尚未发布的事件数（用于导入等批量写入的背压）
+func (r *Repository) CountPending(ctx context.Context, routingKey string) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM outbox_events
+		WHERE status = 'pending' AND routing_key = $1
+	`
+
+	var count int
+	if err := r.db.QueryRow(ctx, query, routingKey).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count pending events: %w", err)
+	}
+	return count, nil
+}
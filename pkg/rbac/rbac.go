@@ -7,6 +7,9 @@ const (
 	PermissionCreateHabit    = "habit:create"
 	PermissionBulkCreateTask = "task:bulk_create"
 
+	// 管理权限
+	PermissionManageFailedEvents = "failed_event:manage" // 查看 / 重试 / 放弃所有用户的发布失败事件
+
 	// 普通操作权限
 	PermissionReadTask   = "task:read"
 	PermissionUpdateTask = "task:update"
@@ -35,6 +38,7 @@ var rolePermissions = map[string][]string{
 		PermissionCreateProject,
 		PermissionCreateHabit,
 		PermissionBulkCreateTask,
+		PermissionManageFailedEvents,
 	},
 }
 
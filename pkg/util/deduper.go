@@ -36,7 +36,7 @@ func NewDeduperWithLogger(rdb *redis.Client, ttl time.Duration, logger *zap.Logg
 // returns true if this is the FIRST time processing
 // returns false if it's a duplicate
 func (d *Deduper) AcquireOnce(ctx context.Context, handler string, emailID int) bool {
-	key := fmt.Sprintf("dedup:%s:%d", handler, emailID)
+	key := dedupKey(handler, emailID)
 
 	ok, err := d.rdb.SetNX(ctx, key, 1, d.ttl).Result()
 	if err != nil {
@@ -63,3 +63,19 @@ func (d *Deduper) AcquireOnce(ctx context.Context, handler string, emailID int)
 	return ok
 }
 
+// Release removes the dedup lock so a redelivered message is processed again
+// (the handler failed and the message was requeued)
+func (d *Deduper) Release(ctx context.Context, handler string, emailID int) {
+	if err := d.rdb.Del(ctx, dedupKey(handler, emailID)).Err(); err != nil && d.logger != nil {
+		// 释放失败时锁在 TTL 后过期
+		d.logger.Warn("Redis dedup release failed",
+			zap.String("handler", handler),
+			zap.Int("email_id", emailID),
+			zap.Error(err),
+		)
+	}
+}
+
+func dedupKey(handler string, emailID int) string {
+	return fmt.Sprintf("dedup:%s:%d", handler, emailID)
+}
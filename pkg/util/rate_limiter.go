@@ -0,0 +1,160 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// tokenBucketScript 原子地检查并消费多个令牌桶：任一桶令牌不足时都不扣减，
+// 返回 {0, 需要等待的毫秒数, 等待时间最长的桶下标(从1开始)}，否则返回 {1, 0, 0}
+//
+// KEYS[i]          桶（hash: tokens, ts）
+// ARGV[1]          当前时间（毫秒）
+// ARGV[2i], [2i+1] 第 i 个桶的容量、每毫秒补充的令牌数
+var tokenBucketScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local tokens = {}
+local wait = 0
+local blocked = 0
+
+for i, key in ipairs(KEYS) do
+    local capacity = tonumber(ARGV[i * 2])
+    local rate = tonumber(ARGV[i * 2 + 1])
+    local state = redis.call('HMGET', key, 'tokens', 'ts')
+    local t = tonumber(state[1])
+    local ts = tonumber(state[2])
+    if t == nil then
+        t = capacity
+        ts = now
+    end
+    t = math.min(capacity, t + math.max(0, now - ts) * rate)
+    tokens[i] = t
+    if t < 1 then
+        local w = math.ceil((1 - t) / rate)
+        if w > wait then
+            wait = w
+            blocked = i
+        end
+    end
+end
+
+if blocked > 0 then
+    return {0, wait, blocked}
+end
+
+for i, key in ipairs(KEYS) do
+    local capacity = tonumber(ARGV[i * 2])
+    local rate = tonumber(ARGV[i * 2 + 1])
+    redis.call('HSET', key, 'tokens', tokens[i] - 1, 'ts', now)
+    redis.call('PEXPIRE', key, math.ceil(capacity / rate) + 1000)
+end
+return {1, 0, 0}
+`)
+
+// tokenRefundScript 向每个桶归还一个令牌（不超过容量），桶已过期（已补满）时不处理
+//
+// KEYS[i] 桶
+// ARGV[i] 第 i 个桶的容量
+var tokenRefundScript = redis.NewScript(`
+for i, key in ipairs(KEYS) do
+    local t = tonumber(redis.call('HGET', key, 'tokens'))
+    if t ~= nil then
+        redis.call('HSET', key, 'tokens', math.min(tonumber(ARGV[i]), t + 1))
+    end
+end
+return 1
+`)
+
+// Bucket 令牌桶：容量为 Limit，每 Per 时间补满
+type Bucket struct {
+	Key   string
+	Limit int
+	Per   time.Duration
+}
+
+// RateLimitResult 限流结果
+type RateLimitResult struct {
+	Allowed    bool
+	RetryAfter time.Duration
+	Bucket     *Bucket // 等待时间最长的桶（Allowed 为 false 时）
+}
+
+// RateLimiter 基于 Redis 的令牌桶限流器（多实例共享）
+type RateLimiter struct {
+	rdb    *redis.Client
+	logger *zap.Logger
+}
+
+func NewRateLimiter(rdb *redis.Client, logger *zap.Logger) *RateLimiter {
+	return &RateLimiter{
+		rdb:    rdb,
+		logger: logger,
+	}
+}
+
+// Allow 从所有桶中各消费一个令牌，任一桶不足时都不消费
+// Redis 不可用时不阻止处理（与 Deduper 一致）
+func (l *RateLimiter) Allow(ctx context.Context, buckets ...Bucket) RateLimitResult {
+	if len(buckets) == 0 {
+		return RateLimitResult{Allowed: true}
+	}
+
+	keys := make([]string, 0, len(buckets))
+	args := make([]interface{}, 0, len(buckets)*2+1)
+	args = append(args, time.Now().UnixMilli())
+	for _, b := range buckets {
+		keys = append(keys, b.Key)
+		args = append(args, b.Limit, float64(b.Limit)/float64(b.Per.Milliseconds()))
+	}
+
+	res, err := tokenBucketScript.Run(ctx, l.rdb, keys, args...).Int64Slice()
+	if err != nil || len(res) != 3 {
+		if l.logger != nil {
+			l.logger.Warn("Redis rate limit check failed, allowing request",
+				zap.Strings("keys", keys),
+				zap.Error(err),
+			)
+		}
+		return RateLimitResult{Allowed: true}
+	}
+
+	if res[0] == 1 {
+		return RateLimitResult{Allowed: true}
+	}
+	blocked := buckets[res[2]-1]
+	return RateLimitResult{
+		RetryAfter: time.Duration(res[1]) * time.Millisecond,
+		Bucket:     &blocked,
+	}
+}
+
+// Refund 归还 Allow 消费的令牌（例如消费令牌后的操作失败回滚）
+// Redis 不可用时只记录日志，令牌按正常速率补充
+func (l *RateLimiter) Refund(ctx context.Context, buckets ...Bucket) {
+	if len(buckets) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(buckets))
+	args := make([]interface{}, 0, len(buckets))
+	for _, b := range buckets {
+		keys = append(keys, b.Key)
+		args = append(args, b.Limit)
+	}
+
+	if err := tokenRefundScript.Run(ctx, l.rdb, keys, args...).Err(); err != nil && l.logger != nil {
+		l.logger.Warn("Redis rate limit refund failed",
+			zap.Strings("keys", keys),
+			zap.Error(err),
+		)
+	}
+}
+
+// FormatRateLimitKey 生成限流桶的 key，例如 ratelimit:ingest:minute:42
+func FormatRateLimitKey(scope, window string, id int) string {
+	return fmt.Sprintf("ratelimit:%s:%s:%d", scope, window, id)
+}
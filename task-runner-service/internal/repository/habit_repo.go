@@ -21,9 +21,10 @@ func NewHabitRepository(db *pgxpool.Pool, logger *zap.Logger) *HabitRepository {
 
 func (r *HabitRepository) ListAllActive(ctx context.Context) ([]HabitForToday, error) {
 	query := `
-        SELECT id, user_id, title, recurrence_pattern
-        FROM habits
-        WHERE is_active = TRUE
+        SELECT h.id, h.user_id, h.title, h.recurrence_pattern, u.timezone
+        FROM habits h
+        JOIN users u ON u.id = h.user_id
+        WHERE h.is_active = TRUE
     `
 	rows, err := r.db.Query(ctx, query)
 	if err != nil {
@@ -39,6 +40,7 @@ func (r *HabitRepository) ListAllActive(ctx context.Context) ([]HabitForToday, e
 			&h.UserID,
 			&h.Title,
 			&h.RecurrencePattern,
+			&h.Timezone,
 		); err != nil {
 			return nil, err
 		}
@@ -52,5 +54,6 @@ type HabitForToday struct {
 	UserID           int
 	Title            string
 	RecurrencePattern string
+	Timezone         string // 用户时区，按用户本地日期生成任务
 }
 
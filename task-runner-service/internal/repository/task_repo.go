@@ -20,13 +20,13 @@ func NewTaskRepository(db *pgxpool.Pool, logger *zap.Logger) *TaskRepository {
 	}
 }
 
-// MarkExpired marks tasks as overdue if due_date < today and status = 'pending'
+// MarkExpired marks tasks as overdue if due_date (an absolute timestamptz) has passed and status = 'pending'
 func (r *TaskRepository) MarkExpired(ctx context.Context) error {
 	query := `
         UPDATE tasks
         SET status = 'overdue'
         WHERE status = 'pending'
-          AND due_date < CURRENT_DATE
+          AND due_date < NOW()
           AND due_date IS NOT NULL
     `
 	result, err := r.db.Exec(ctx, query)
@@ -50,7 +50,7 @@ func (r *TaskRepository) MarkExpiredTx(ctx context.Context, tx pgx.Tx) error {
         UPDATE tasks
         SET status = 'overdue'
         WHERE status = 'pending'
-          AND due_date < CURRENT_DATE
+          AND due_date < NOW()
           AND due_date IS NOT NULL
     `
 	result, err := tx.Exec(ctx, query)
@@ -73,7 +73,7 @@ func (r *TaskRepository) ListExpiredPendingTasks(ctx context.Context) ([]int, er
 	query := `
         SELECT id FROM tasks
         WHERE status = 'pending'
-          AND due_date < CURRENT_DATE
+          AND due_date < NOW()
           AND due_date IS NOT NULL
     `
 	rows, err := r.db.Query(ctx, query)
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"task-runner-service/internal/repository"
+	"mygoproject/pkg/deadline"
 	"mygoproject/pkg/mq"
 	"mygoproject/pkg/outbox"
 
@@ -178,21 +179,32 @@ func (o *Orchestrator) GenerateHabitTasks(ctx context.Context) error {
 		return err
 	}
 
-	// 找出今天需要生成的习惯
+	// 找出今天需要生成的习惯（按用户时区的本地日期判断，截止时间为用户本地当天结束）
 	var habitsToGenerate []struct {
-		ID     int
-		UserID int
-		Title  string
+		ID      int
+		UserID  int
+		Title   string
+		DueDate string
+		DueAt   time.Time
 	}
-	dueDate := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
-	
+
 	for _, habit := range habits {
-		if o.shouldGenerateToday(habit.RecurrencePattern, today) {
+		loc := deadline.LoadLocation(habit.Timezone)
+		localToday := today.In(loc)
+		if o.shouldGenerateToday(habit.RecurrencePattern, localToday) {
 			habitsToGenerate = append(habitsToGenerate, struct {
-				ID     int
-				UserID int
-				Title  string
-			}{ID: habit.ID, UserID: habit.UserID, Title: habit.Title})
+				ID      int
+				UserID  int
+				Title   string
+				DueDate string
+				DueAt   time.Time
+			}{
+				ID:      habit.ID,
+				UserID:  habit.UserID,
+				Title:   habit.Title,
+				DueDate: localToday.Format("2006-01-02"),
+				DueAt:   deadline.EndOfDay(localToday, loc),
+			})
 		}
 	}
 
@@ -215,7 +227,8 @@ func (o *Orchestrator) GenerateHabitTasks(ctx context.Context) error {
 			"habit_id": habit.ID,
 			"user_id":  habit.UserID,
 			"title":    habit.Title,
-			"due_date": dueDate.Format("2006-01-02"),
+			"due_date": habit.DueDate,
+			"due_at":   habit.DueAt,
 		}
 		habitID64 := int64(habit.ID)
 		if err := outbox.InsertEventInTx(ctx, tx, o.outboxRepo, "habit", &habitID64, "habit.task.generated", payload); err != nil {
@@ -8,6 +8,7 @@ type Task struct {
     EmailID   int       `json:"email_id"`
     Title     string    `json:"title"`
     DueDate   time.Time `json:"due_date"`
+    Priority  string    `json:"priority,omitempty"` // LOW / MEDIUM / HIGH
     Status    string    `json:"status"`
     CreatedAt time.Time `json:"created_at"`
 }
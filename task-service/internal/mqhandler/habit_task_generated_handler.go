@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"time"
 
+	"mygoproject/pkg/deadline"
 	"task-service/internal/repository"
 
 	"go.uber.org/zap"
@@ -24,10 +25,11 @@ func NewHabitTaskGeneratedHandler(taskRepo *repository.TaskRepository, logger *z
 
 func (h *HabitTaskGeneratedHandler) Handle(ctx context.Context, raw json.RawMessage) error {
 	var p struct {
-		HabitID int    `json:"habit_id"`
-		UserID  int    `json:"user_id"`
-		Title   string `json:"title"`
-		DueDate string `json:"due_date"` // YYYY-MM-DD format
+		HabitID int        `json:"habit_id"`
+		UserID  int        `json:"user_id"`
+		Title   string     `json:"title"`
+		DueDate string     `json:"due_date"`         // YYYY-MM-DD format
+		DueAt   *time.Time `json:"due_at,omitempty"` // 用户时区当天结束
 	}
 	if err := json.Unmarshal(raw, &p); err != nil {
 		h.logger.Error("Failed to unmarshal HabitTaskGeneratedPayload", zap.Error(err))
@@ -41,15 +43,21 @@ func (h *HabitTaskGeneratedHandler) Handle(ctx context.Context, raw json.RawMess
 		zap.String("due_date", p.DueDate),
 	)
 
-	// Parse due date
-	dueDate, err := time.Parse("2006-01-02", p.DueDate)
-	if err != nil {
-		h.logger.Error("Failed to parse due_date", zap.Error(err))
-		return err
+	// Parse due date（旧事件没有 due_at，按 UTC 当天结束）
+	var dueDate time.Time
+	if p.DueAt != nil && !p.DueAt.IsZero() {
+		dueDate = *p.DueAt
+	} else {
+		date, err := time.Parse("2006-01-02", p.DueDate)
+		if err != nil {
+			h.logger.Error("Failed to parse due_date", zap.Error(err))
+			return err
+		}
+		dueDate = deadline.EndOfDay(date, time.UTC)
 	}
 
 	// Insert task from habit (幂等性由数据库唯一索引保证)
-	_, err = h.taskRepo.InsertFromHabit(ctx, p.HabitID, p.UserID, p.Title, dueDate)
+	_, err := h.taskRepo.InsertFromHabit(ctx, p.HabitID, p.UserID, p.Title, dueDate)
 	if err != nil {
 		h.logger.Error("Failed to insert task from habit", zap.Error(err))
 		return err
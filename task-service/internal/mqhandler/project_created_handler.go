@@ -105,7 +105,7 @@ func (h *ProjectCreatedHandler) Handle(ctx context.Context, raw json.RawMessage)
 
 		// Step 3: Create tasks for this milestone
 		for _, taskData := range milestoneData.Tasks {
-			taskDueDate := now.AddDate(0, 0, taskData.DueInDays)
+			taskDueDate := dueDate(now, taskData.DueAt, taskData.DueInDays)
 			taskID, err := h.taskRepo.InsertFromProject(
 				ctx,
 				projectID,
@@ -3,6 +3,7 @@ package mqhandler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -34,6 +35,7 @@ func (h *TaskBulkCreatedHandler) Handle(ctx context.Context, raw json.RawMessage
 
 	h.logger.Info("Handling task.bulk_created event",
 		zap.Int("user_id", p.UserID),
+		zap.Int("email_id", p.EmailID),
 		zap.Int("task_count", len(p.Tasks)),
 		zap.String("trace_id", p.TraceID),
 	)
@@ -53,22 +55,30 @@ func (h *TaskBulkCreatedHandler) Handle(ctx context.Context, raw json.RawMessage
 		return nil
 	}
 
-	// 转换为 model.Task 列表
+	// 转换为 model.Task 列表（EmailID 为 0 表示文本转任务，没有关联的 email）
 	tasks := make([]model.Task, len(p.Tasks))
 	now := time.Now()
 	for i, taskItem := range p.Tasks {
-		dueDate := now.AddDate(0, 0, taskItem.DueInDays)
 		tasks[i] = model.Task{
-			UserID:  p.UserID,
-			EmailID: 0, // 文本转任务没有关联的 email
-			Title:   taskItem.Title,
-			DueDate: dueDate,
-			Status:  "pending",
+			UserID:   p.UserID,
+			EmailID:  p.EmailID,
+			Title:    taskItem.Title,
+			DueDate:  dueDate(now, taskItem.DueAt, taskItem.DueInDays),
+			Priority: taskItem.Priority,
+			Status:   "pending",
 		}
 	}
 
 	// 批量插入任务
 	ids, err := h.taskRepo.BulkInsert(ctx, p.UserID, tasks)
+	if errors.Is(err, repository.ErrEmailNotFound) {
+		// 邮件在事件投递前已被删除：确认并跳过
+		h.logger.Info("Email deleted before tasks were created, skip",
+			zap.Int("user_id", p.UserID),
+			zap.Int("email_id", p.EmailID),
+		)
+		return nil
+	}
 	if err != nil {
 		h.logger.Error("Failed to bulk insert tasks",
 			zap.Error(err),
@@ -80,9 +90,19 @@ func (h *TaskBulkCreatedHandler) Handle(ctx context.Context, raw json.RawMessage
 
 	h.logger.Info("Tasks bulk created successfully",
 		zap.Int("user_id", p.UserID),
+		zap.Int("email_id", p.EmailID),
 		zap.Int("created_count", len(ids)),
+		zap.Int("skipped_count", len(tasks)-len(ids)),
 	)
 
 	return nil
 }
 
+// dueDate 使用生产者解析好的绝对截止时间；旧事件没有 due_at 时按 now + dueInDays 计算
+func dueDate(now time.Time, dueAt *time.Time, dueInDays int) time.Time {
+	if dueAt != nil && !dueAt.IsZero() {
+		return *dueAt
+	}
+	return now.AddDate(0, 0, dueInDays)
+}
+
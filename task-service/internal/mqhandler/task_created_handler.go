@@ -3,6 +3,7 @@ package mqhandler
 import (
     "context"
     "encoding/json"
+    "errors"
     "fmt"
     "time"
 
@@ -45,18 +46,25 @@ func (h *TaskCreatedHandler) Handle(ctx context.Context, raw json.RawMessage) er
         return fmt.Errorf("invalid email_id: %d (must be > 0)", p.EmailID)
     }
 
-    dueDate := time.Now().AddDate(0, 0, p.DueInDays)
-
     task := &model.Task{
-        UserID:  p.UserID,
-        EmailID: p.EmailID,
-        Title:   p.Title,
-        DueDate: dueDate,
-        Status:  "pending",
+        UserID:   p.UserID,
+        EmailID:  p.EmailID,
+        Title:    p.Title,
+        DueDate:  dueDate(time.Now(), p.DueAt, p.DueInDays),
+        Priority: p.Priority,
+        Status:   "pending",
         // CreatedAt 让 DB 默认填
     }
 
     _, err := h.taskRepo.Insert(ctx, task)
+    if errors.Is(err, repository.ErrEmailNotFound) {
+        // 邮件在事件投递前已被删除：确认并跳过
+        h.logger.Info("Email deleted before task was created, skip",
+            zap.Int("user_id", p.UserID),
+            zap.Int("email_id", p.EmailID),
+        )
+        return nil
+    }
     if err != nil {
         h.logger.Error("Failed to insert task", zap.Error(err))
         return err
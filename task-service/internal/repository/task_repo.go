@@ -3,14 +3,30 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
 
 	"task-service/internal/model"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 )
 
+// ErrEmailNotFound 任务关联的邮件已被删除（tasks.email_id 外键冲突）
+var ErrEmailNotFound = errors.New("email not found")
+
+// emailNotFound 将 tasks_email_id_fkey 外键冲突转换为 ErrEmailNotFound：
+// 邮件删除后仍在途的 task.created / task.bulk_created 事件不应重试
+func emailNotFound(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" && pgErr.ConstraintName == "tasks_email_id_fkey" {
+		return ErrEmailNotFound
+	}
+	return err
+}
+
 type TaskRepository struct {
 	db     *pgxpool.Pool
 	logger *zap.Logger
@@ -37,8 +53,8 @@ func (r *TaskRepository) Insert(ctx context.Context, t *model.Task) (int, error)
 	}
 
 	query := `
-        INSERT INTO tasks (user_id, email_id, title, due_date, status)
-        VALUES ($1, $2, $3, $4, $5)
+        INSERT INTO tasks (user_id, email_id, title, due_date, status, priority)
+        VALUES ($1, $2, $3, $4, $5, COALESCE(NULLIF($6, ''), 'MEDIUM'))
         RETURNING id
     `
 	var id int
@@ -48,6 +64,7 @@ func (r *TaskRepository) Insert(ctx context.Context, t *model.Task) (int, error)
 		t.Title,
 		t.DueDate,
 		t.Status,
+		t.Priority,
 	).Scan(&id)
 	if err != nil {
 		r.logger.Error("Failed to insert task",
@@ -55,7 +72,7 @@ func (r *TaskRepository) Insert(ctx context.Context, t *model.Task) (int, error)
 			zap.Int("user_id", t.UserID),
 			zap.Any("email_id", emailID),
 		)
-		return 0, err
+		return 0, emailNotFound(err)
 	}
 	r.logger.Info("Task inserted successfully",
 		zap.Int("task_id", id),
@@ -67,7 +84,7 @@ func (r *TaskRepository) Insert(ctx context.Context, t *model.Task) (int, error)
 func (r *TaskRepository) ListByUser(ctx context.Context, userID int) ([]model.Task, error) {
 	r.logger.Debug("Listing tasks for user", zap.Int("user_id", userID))
 	query := `
-        SELECT id, user_id, email_id, title, due_date, status, created_at
+        SELECT id, user_id, email_id, title, due_date, COALESCE(priority, ''), status, created_at
         FROM tasks
         WHERE user_id = $1
         ORDER BY created_at DESC
@@ -92,6 +109,7 @@ func (r *TaskRepository) ListByUser(ctx context.Context, userID int) ([]model.Ta
 			&emailID,
 			&t.Title,
 			&t.DueDate,
+			&t.Priority,
 			&t.Status,
 			&t.CreatedAt,
 		); err != nil {
@@ -163,7 +181,9 @@ func (r *TaskRepository) MarkExpired(ctx context.Context) error {
 	return nil
 }
 
-// BulkInsert inserts multiple tasks in a single transaction
+// BulkInsert inserts multiple tasks in a single transaction.
+// Tasks from an email that already exist (same email_id + title, still pending) are skipped,
+// so the returned ids only contain newly created tasks.
 func (r *TaskRepository) BulkInsert(ctx context.Context, userID int, tasks []model.Task) ([]int, error) {
 	if len(tasks) == 0 {
 		return []int{}, nil
@@ -183,8 +203,10 @@ func (r *TaskRepository) BulkInsert(ctx context.Context, userID int, tasks []mod
 	defer tx.Rollback(ctx)
 
 	query := `
-        INSERT INTO tasks (user_id, email_id, title, due_date, status)
-        VALUES ($1, $2, $3, $4, $5)
+        INSERT INTO tasks (user_id, email_id, title, due_date, status, priority)
+        VALUES ($1, $2, $3, $4, $5, COALESCE(NULLIF($6, ''), 'MEDIUM'))
+        ON CONFLICT (email_id, user_id, title) WHERE status = 'pending' AND email_id IS NOT NULL
+        DO NOTHING
         RETURNING id
     `
 
@@ -205,13 +227,22 @@ func (r *TaskRepository) BulkInsert(ctx context.Context, userID int, tasks []mod
 			t.Title,
 			t.DueDate,
 			t.Status,
+			t.Priority,
 		).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// 冲突导致 DO NOTHING：同一邮件的任务已存在（重复投递），幂等跳过
+			r.logger.Debug("Task already exists for email and title (幂等)",
+				zap.Int("email_id", t.EmailID),
+				zap.String("title", t.Title),
+			)
+			continue
+		}
 		if err != nil {
 			r.logger.Error("Failed to insert task in bulk",
 				zap.Error(err),
 				zap.String("title", t.Title),
 			)
-			return nil, err
+			return nil, emailNotFound(err)
 		}
 		ids = append(ids, id)
 	}